@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Scopes which may be granted to API keys
+const (
+	ScopeSensorsRead  = "sensors:read"
+	ScopeSensorsWrite = "sensors:write"
+	ScopeAdmin        = "admin"
+)
+
+// APIKey is an API key, without its secret
+type APIKey struct {
+	ID int64 `json:"id"`
+	// Tenant which the key grants access to
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+	// Non-secret start of the key, to identify it in listings
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IssuedAPIKey is a newly issued (or rotated) API key, including its secret.
+// The secret is only returned once.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// IssueAPIKey issues an API key for the caller's tenant. Requires the admin scope.
+// Returns an error matching ErrNotImplemented if authentication is disabled on the server.
+func (c *Client) IssueAPIKey(ctx context.Context, name string, scopes []string) (*IssuedAPIKey, error) {
+	body := struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}{name, scopes}
+	var res struct {
+		Data *IssuedAPIKey `json:"data"`
+	}
+	err := c.do(ctx, request{method: "POST", path: "/admin/api-keys", body: body}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// ListAPIKeys returns the API keys of the caller's tenant, including revoked keys. Requires the admin scope.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	var res struct {
+		Data []*APIKey `json:"data"`
+	}
+	err := c.do(ctx, request{method: "GET", path: "/admin/api-keys"}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// RotateAPIKey replaces an API key's secret. Requires the admin scope.
+func (c *Client) RotateAPIKey(ctx context.Context, id int64) (*IssuedAPIKey, error) {
+	var res struct {
+		Data *IssuedAPIKey `json:"data"`
+	}
+	err := c.do(ctx, request{method: "POST", path: "/admin/api-keys/" + strconv.FormatInt(id, 10) + "/rotate"}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// RevokeAPIKey revokes an API key, and returns it. Requires the admin scope.
+func (c *Client) RevokeAPIKey(ctx context.Context, id int64) (*APIKey, error) {
+	var res struct {
+		Data *APIKey `json:"data"`
+	}
+	err := c.do(ctx, request{method: "DELETE", path: "/admin/api-keys/" + strconv.FormatInt(id, 10)}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/api"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClient_APIKeys(t *testing.T) {
+	apiKeys := auth.NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	_, adminKey, err := apiKeys.Issue(context.Background(), "acme", "admin", []string{auth.ScopeAdmin})
+	require.NoError(t, err)
+	c := newTestServer(t, []api.Option{api.WithAuthentication(apiKeys, nil)}, WithAPIKey(adminKey))
+	ctx := context.Background()
+
+	issued, err := c.IssueAPIKey(ctx, "reader", []string{ScopeSensorsRead})
+	require.NoError(t, err)
+	require.Equal(t, "acme", issued.TenantID)
+	require.Equal(t, []string{ScopeSensorsRead}, issued.Scopes)
+	require.NotEmpty(t, issued.Key)
+
+	// The issued key authenticates requests, with its scopes
+	reader := newTestServer(t, []api.Option{api.WithAuthentication(apiKeys, nil)}, WithAPIKey(issued.Key))
+	_, err = reader.GetSensor(ctx, "abc123")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = reader.CreateSensor(ctx, &Sensor{Name: "abc123"})
+	require.ErrorIs(t, err, ErrForbidden)
+	require.EqualError(t, err, "sensor api: forbidden: missing the required scope \"sensors:write\" (HTTP 403)")
+	_, err = reader.ListAPIKeys(ctx)
+	require.ErrorIs(t, err, ErrForbidden)
+
+	keys, err := c.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	rotated, err := c.RotateAPIKey(ctx, issued.ID)
+	require.NoError(t, err)
+	require.Equal(t, issued.ID, rotated.ID)
+	require.NotEqual(t, issued.Key, rotated.Key)
+
+	revoked, err := c.RevokeAPIKey(ctx, issued.ID)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	_, err = c.RotateAPIKey(ctx, issued.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Invalid or missing keys are unauthorized
+	_, err = reader.GetSensor(ctx, "abc123")
+	require.ErrorIs(t, err, ErrUnauthorized)
+	_, err = New(reader.baseURL).GetSensor(ctx, "abc123")
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestClient_APIKeysDisabled(t *testing.T) {
+	c := newTestServer(t, nil)
+
+	_, err := c.IssueAPIKey(context.Background(), "reader", []string{ScopeSensorsRead})
+	require.ErrorIs(t, err, ErrNotImplemented)
+	require.EqualError(t, err, "sensor api: authentication is disabled (HTTP 501)")
+}
@@ -0,0 +1,256 @@
+// Package client is a Go client for the sensor API.
+//
+//	c := client.New("https://sensors.example.com", client.WithAPIKey(apiKey))
+//	sensor, err := c.GetSensor(ctx, "abc123")
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+//
+// Idempotent requests (GET, PUT and DELETE) are retried when the server is unavailable or unreachable.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Retries after the first attempt, unless changed with WithRetries
+	defaultMaxRetries = 3
+	// Wait before the first retry. Doubles with each retry.
+	defaultRetryWait = 250 * time.Millisecond
+	// Longest wait between retries, including waits requested by the server's Retry-After header
+	maxRetryWait     = 30 * time.Second
+	defaultUserAgent = "go-sensor-api-client"
+)
+
+// Client sends requests to the sensor API. It is safe for concurrent use.
+type Client struct {
+	// eg. "https://sensors.example.com", without a trailing slash
+	baseURL    string
+	httpClient *http.Client
+	// Sent in the X-API-Key header, if set
+	apiKey string
+	// Sent in the Authorization header, if set (eg. a JWT from an SSO provider)
+	bearerToken string
+	userAgent   string
+	maxRetries  int
+	retryWait   time.Duration
+}
+
+type Option func(*Client)
+
+// New creates a client for the API at baseURL, eg. "https://sensors.example.com"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		userAgent:  defaultUserAgent,
+		maxRetries: defaultMaxRetries,
+		retryWait:  defaultRetryWait,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithAPIKey authenticates requests with an API key
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithBearerToken authenticates requests with a bearer token, eg. a JWT issued by an SSO provider
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.bearerToken = token
+	}
+}
+
+// WithHTTPClient sends requests with the given HTTP client, eg. to configure timeouts or proxies.
+// Uses http.DefaultClient by default.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times failed requests are retried (0 disables retries),
+// and how long to wait before the first retry. The wait doubles with each retry.
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// request is a request to the API
+type request struct {
+	method string
+	// eg. "/sensors/abc123". Path segments must already be escaped.
+	path  string
+	query url.Values
+	// Encoded as the JSON request body, if not nil
+	body interface{}
+	// If true, failed requests are not retried (eg. readiness checks, which report the server's current state)
+	noRetry bool
+}
+
+// do sends a request, and decodes the JSON response into res (if not nil).
+// If res is a *[]byte, it is set to the raw response body instead.
+//
+// Responses with an error status are returned as an *APIError.
+// Idempotent requests are retried on network errors, and on 502, 503 and 504 responses.
+// Requests of any method are retried on 429 responses, which the server rejects without processing.
+func (c *Client) do(ctx context.Context, req request, res interface{}) error {
+	// Encode the body once, so that it can be sent again on retries
+	var payload []byte
+	if req.body != nil {
+		var err error
+		payload, err = json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, err := c.send(ctx, req, payload)
+		if err == nil {
+			return decodeResponse(body, res)
+		}
+		if ctx.Err() != nil || req.noRetry || attempt >= c.maxRetries || !shouldRetry(req.method, err) {
+			return err
+		}
+
+		// Wait before retrying, for at least as long as the server asked
+		wait := c.retryWait << attempt
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		if wait > maxRetryWait {
+			wait = maxRetryWait
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes a single attempt at a request, and returns the response body
+func (c *Client) send(ctx context.Context, req request, payload []byte) ([]byte, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.bearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+
+	res, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.StatusCode >= 400 {
+		return nil, newAPIError(res, resBody)
+	}
+
+	return resBody, nil
+}
+
+// shouldRetry returns true if a failed request may succeed when sent again
+func shouldRetry(method string, err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// Network errors. The server may have processed the request, so only retry if that's safe.
+		return isIdempotent(method)
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func decodeResponse(body []byte, res interface{}) error {
+	if res == nil {
+		return nil
+	}
+	if raw, ok := res.(*[]byte); ok {
+		*raw = body
+		return nil
+	}
+	if err := json.Unmarshal(body, res); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds. Returns 0 if the header is missing or invalid.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/api"
+	"github.com/eschwartz/go-sensor-api/internal/app/health"
+	"github.com/eschwartz/go-sensor-api/internal/app/metrics"
+	"github.com/eschwartz/go-sensor-api/internal/app/ratelimit"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer serves a SensorRouter with a memory store (unless other options are given), and returns a client for it
+func newTestServer(t *testing.T, routerOpts []api.Option, clientOpts ...Option) *Client {
+	routerOpts = append([]api.Option{api.WithStore(store.NewMemorySensorStore())}, routerOpts...)
+	router := api.NewSensorRouter(routerOpts...)
+	server := httptest.NewServer(router.Handler())
+	t.Cleanup(server.Close)
+
+	clientOpts = append([]Option{WithRetries(3, time.Millisecond)}, clientOpts...)
+	return New(server.URL+"/", clientOpts...)
+}
+
+// flakyTransport fails the first requests, before sending requests to the server
+type flakyTransport struct {
+	mu sync.Mutex
+	// Responses (or errors, if the status is 0) to return instead of sending requests
+	failures []int
+	requests []*http.Request
+}
+
+func (t *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests = append(t.requests, r)
+	var status = -1
+	if len(t.failures) > 0 {
+		status, t.failures = t.failures[0], t.failures[1:]
+	}
+	t.mu.Unlock()
+
+	switch status {
+	case -1:
+		return http.DefaultTransport.RoundTrip(r)
+	case 0:
+		return nil, errors.New("connection reset by peer")
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/problem+json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"type": "about:blank", "title": "Service Unavailable", "status": 503, "detail": "try again later"}`)),
+		Request:    r,
+	}, nil
+}
+
+func TestClient_Retries(t *testing.T) {
+	transport := &flakyTransport{failures: []int{0, http.StatusServiceUnavailable, http.StatusBadGateway}}
+	c := newTestServer(t, nil, WithHTTPClient(&http.Client{Transport: transport}))
+
+	// Idempotent requests are retried
+	_, err := c.UpdateSensor(context.Background(), "abc123", &Sensor{Name: "abc123"})
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Len(t, transport.requests, 4)
+
+	// Request bodies are sent again
+	for _, r := range transport.requests[1:] {
+		body, err := r.GetBody()
+		require.NoError(t, err)
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"name":"abc123"`)
+	}
+
+	// Requests fail once they run out of retries
+	transport.failures = []int{503, 503, 503, 503}
+	transport.requests = nil
+	_, err = c.GetSensor(context.Background(), "abc123")
+	require.ErrorIs(t, err, ErrUnavailable)
+	require.EqualError(t, err, "sensor api: try again later (HTTP 503)")
+	require.Len(t, transport.requests, 4)
+
+	// Non-idempotent requests are not retried, as the server may have processed them
+	transport.failures = []int{0}
+	transport.requests = nil
+	_, err = c.CreateSensor(context.Background(), &Sensor{Name: "abc123"})
+	require.ErrorContains(t, err, "connection reset by peer")
+	require.Len(t, transport.requests, 1)
+
+	// ...unless they were rate limited, which the server rejects without processing
+	transport.failures = []int{http.StatusTooManyRequests}
+	transport.requests = nil
+	_, err = c.CreateSensor(context.Background(), &Sensor{Name: "abc123"})
+	require.NoError(t, err)
+	require.Len(t, transport.requests, 2)
+
+	// Client errors are not retried
+	transport.requests = nil
+	_, err = c.UpdateSensor(context.Background(), "abc123", &Sensor{Name: ""})
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.Len(t, transport.requests, 1)
+}
+
+func TestClient_RetriesCanceled(t *testing.T) {
+	transport := &flakyTransport{failures: []int{503, 503}}
+	c := newTestServer(t, nil,
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithRetries(3, time.Hour),
+	)
+
+	// Canceling the context stops waiting to retry
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.GetSensor(ctx, "abc123")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Len(t, transport.requests, 1)
+}
+
+func TestClient_RateLimited(t *testing.T) {
+	c := newTestServer(t,
+		[]api.Option{api.WithRateLimits(ratelimit.NewMemoryLimiter(0.001, 1), nil)},
+		WithRetries(0, 0),
+	)
+
+	_, err := c.GetSensor(context.Background(), "abc123")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// The server's Retry-After header is reported
+	_, err = c.GetSensor(context.Background(), "abc123")
+	require.ErrorIs(t, err, ErrRateLimited)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	require.Greater(t, apiErr.RetryAfter, time.Duration(0))
+}
+
+func TestClient_Headers(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"name": "abc123"}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithAPIKey("sk_abc"), WithBearerToken("jwt"), WithUserAgent("sensorctl/1.0"))
+	_, err := c.GetSensor(context.Background(), "abc123")
+	require.NoError(t, err)
+	require.Equal(t, "sk_abc", headers.Get("X-API-Key"))
+	require.Equal(t, "Bearer jwt", headers.Get("Authorization"))
+	require.Equal(t, "sensorctl/1.0", headers.Get("User-Agent"))
+	require.Equal(t, "application/json", headers.Get("Accept"))
+}
+
+func TestClient_Health(t *testing.T) {
+	c := newTestServer(t, nil)
+	require.NoError(t, c.Live(context.Background()))
+	report, err := c.Ready(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "ok", report.Status)
+
+	// Unavailable servers report their failed checks
+	c = newTestServer(t, []api.Option{api.WithHealthChecker(health.NewChecker(time.Second, health.Check{
+		Name: "database",
+		Run: func(ctx context.Context) error {
+			return errors.New("connection refused")
+		},
+	}))})
+	report, err = c.Ready(context.Background())
+	require.ErrorIs(t, err, ErrUnavailable)
+	require.EqualError(t, err, "sensor api: server is not ready (status: error) (HTTP 503)")
+	require.Equal(t, "error", report.Status)
+	require.Equal(t, "connection refused", report.Checks["database"].Error)
+}
+
+func TestClient_OpenAPIAndMetrics(t *testing.T) {
+	c := newTestServer(t, []api.Option{api.WithMetrics(metrics.NewRegistry())})
+
+	document, err := c.OpenAPI(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, string(document), `"openapi": "3.0.3"`)
+
+	_, err = c.GetSensor(context.Background(), "abc123")
+	require.ErrorIs(t, err, ErrNotFound)
+	data, err := c.Metrics(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, string(data), "http_requests_total")
+
+	// Metrics are disabled by default
+	_, err = newTestServer(t, nil).Metrics(context.Background())
+	require.ErrorIs(t, err, ErrNotFound)
+}
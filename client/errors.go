@@ -0,0 +1,129 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ValidationProblemType identifies problems caused by invalid request body fields
+const ValidationProblemType = "https://github.com/eschwartz/go-sensor-api#validation-errors"
+
+// Errors which an *APIError matches with errors.Is, by response status
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrRequestTooLarge = errors.New("request too large")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrNotImplemented  = errors.New("not implemented")
+	ErrBadGateway      = errors.New("bad gateway")
+	ErrUnavailable     = errors.New("service unavailable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:            ErrBadRequest,
+	http.StatusUnauthorized:          ErrUnauthorized,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusRequestEntityTooLarge: ErrRequestTooLarge,
+	http.StatusTooManyRequests:       ErrRateLimited,
+	http.StatusNotImplemented:        ErrNotImplemented,
+	http.StatusBadGateway:            ErrBadGateway,
+	http.StatusServiceUnavailable:    ErrUnavailable,
+}
+
+// APIError is an error response from the API.
+//
+// Use errors.Is to check the kind of error (eg. errors.Is(err, ErrNotFound)),
+// and errors.As to get the invalid fields of a *ValidationError.
+type APIError struct {
+	StatusCode int
+	// Error message from the server, eg. "no sensor resource exists: abc123"
+	Message string
+	// Problem type URI, identifying the class of error, eg. "https://github.com/eschwartz/go-sensor-api#not-found"
+	Type string
+	// How long the server asked to wait before retrying, for rate limited requests
+	RetryAfter time.Duration
+	// Invalid fields, if the request was rejected as a validation problem
+	validation *ValidationError
+	body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("sensor api: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+func (e *APIError) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+func (e *APIError) Unwrap() error {
+	if e.validation == nil {
+		return nil
+	}
+	return e.validation
+}
+
+// FieldError is an invalid field of a request body
+type FieldError struct {
+	// JSON pointer to the field (RFC 6901), eg. "/tags/0"
+	Pointer string `json:"pointer"`
+	// Why the field is invalid, eg. "must be between -90 and 90"
+	Detail string `json:"detail"`
+}
+
+// ValidationError lists every invalid field of a request body, as reported by the server
+type ValidationError struct {
+	// eg. "invalid sensor: lat must be between -90 and 90"
+	Detail string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Detail
+}
+
+// problem is an RFC 7807 error response, served as application/problem+json
+type problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail"`
+	Errors []FieldError `json:"errors"`
+}
+
+// newAPIError creates an error from an error response.
+// Errors are served as problems. Other bodies (eg. from a proxy) are ignored.
+func newAPIError(res *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Message:    http.StatusText(res.StatusCode),
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		body:       body,
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/problem+json") {
+		return apiErr
+	}
+
+	var p problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		return apiErr
+	}
+	apiErr.Type = p.Type
+	if p.Detail != "" {
+		apiErr.Message = p.Detail
+	} else if p.Title != "" {
+		apiErr.Message = p.Title
+	}
+	if p.Type == ValidationProblemType {
+		apiErr.validation = &ValidationError{Detail: apiErr.Message, Fields: p.Errors}
+	}
+	return apiErr
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// HealthReport is the result of the server's readiness checks
+type HealthReport struct {
+	// "ok", "error" if a required dependency is unavailable, or "draining" while the server shuts down
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the result of checking a single dependency, eg. the database
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// Optional dependencies don't make the server unavailable
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Live checks that the server is able to serve requests
+func (c *Client) Live(ctx context.Context) error {
+	return c.do(ctx, request{method: "GET", path: "/health/live", noRetry: true}, nil)
+}
+
+// Ready checks the server's dependencies.
+// If the server is unavailable, the report is returned with an error matching ErrUnavailable.
+func (c *Client) Ready(ctx context.Context) (*HealthReport, error) {
+	var report HealthReport
+	err := c.do(ctx, request{method: "GET", path: "/health/ready", noRetry: true}, &report)
+
+	// Unavailable servers respond with a report of the failed checks
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable {
+		if json.Unmarshal(apiErr.body, &report) == nil && report.Status != "" {
+			apiErr.Message = "server is not ready (status: " + report.Status + ")"
+			return &report, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// OpenAPI returns the OpenAPI document describing the API
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	var document []byte
+	if err := c.do(ctx, request{method: "GET", path: "/openapi.json"}, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// Metrics returns the server's metrics, in the Prometheus text format.
+// Returns an error matching ErrNotFound if metrics are disabled on the server.
+func (c *Client) Metrics(ctx context.Context) ([]byte, error) {
+	var metrics []byte
+	if err := c.do(ctx, request{method: "GET", path: "/metrics"}, &metrics); err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
@@ -0,0 +1,278 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Sensor is a physical sensor, at a geographical location
+type Sensor struct {
+	// Set by the server
+	ID   int      `json:"id"`
+	Name string   `json:"name"`
+	Lat  float64  `json:"lat"`
+	Lon  float64  `json:"lon"`
+	Tags []string `json:"tags"`
+	// Human-readable address / locality of the sensor, if known. Set by the server.
+	PlaceName string `json:"place_name,omitempty"`
+}
+
+// sensorInput is the request body to create or update a sensor
+type sensorInput struct {
+	Name string   `json:"name"`
+	Lat  float64  `json:"lat"`
+	Lon  float64  `json:"lon"`
+	Tags []string `json:"tags"`
+}
+
+func newSensorInput(sensor *Sensor) *sensorInput {
+	return &sensorInput{Name: sensor.Name, Lat: sensor.Lat, Lon: sensor.Lon, Tags: sensor.Tags}
+}
+
+// SensorPlace is the place name of a sensor's location
+type SensorPlace struct {
+	Name      string  `json:"name"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	PlaceName string  `json:"place_name"`
+}
+
+// PlaceSuggestion is a candidate place for a partial place name
+type PlaceSuggestion struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	// Extent of the place, as [min lon, min lat, max lon, max lat], if known
+	BBox *[4]float64 `json:"bbox,omitempty"`
+	// Kind of place, eg. "city", "address" or "poi"
+	Type string `json:"type,omitempty"`
+	// Number of sensors within the place's bounding box, if requested
+	SensorCount *int `json:"sensor_count,omitempty"`
+}
+
+// SensorPage is a page of sensors, ordered by name
+type SensorPage struct {
+	Sensors []*Sensor
+	// Pass as ListOptions.Cursor, to get the next page. Empty on the last page.
+	NextCursor string
+}
+
+type ListOptions struct {
+	// Most sensors to include in the page (1 to 1000). Uses the server's default (100) if 0.
+	Limit int
+	// NextCursor of the previous page. Empty for the first page.
+	Cursor string
+}
+
+type SuggestOptions struct {
+	// Location to prefer places near, eg. Location(44.95, -93.1)
+	Near string
+	// If true, include the number of sensors within each place's bounding box
+	SensorCounts bool
+}
+
+// Location formats a coordinate, for FindClosest or SuggestOptions.Near
+func Location(lat float64, lon float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+}
+
+// CreateSensor adds a sensor, and returns it as stored (eg. with its ID).
+// Names and tags are stored without surrounding whitespace.
+func (c *Client) CreateSensor(ctx context.Context, sensor *Sensor) (*Sensor, error) {
+	var res struct {
+		Data *Sensor `json:"data"`
+	}
+	err := c.do(ctx, request{method: "POST", path: "/sensors", body: newSensorInput(sensor)}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// GetSensor returns a sensor by name. Returns an error matching ErrNotFound if there is no such sensor.
+func (c *Client) GetSensor(ctx context.Context, name string) (*Sensor, error) {
+	var res struct {
+		Data *Sensor `json:"data"`
+	}
+	err := c.do(ctx, request{method: "GET", path: "/sensors/" + url.PathEscape(name)}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// UpdateSensor replaces the sensor with the given name. The sensor may be renamed.
+func (c *Client) UpdateSensor(ctx context.Context, name string, sensor *Sensor) (*Sensor, error) {
+	var res struct {
+		Data *Sensor `json:"data"`
+	}
+	err := c.do(ctx, request{method: "PUT", path: "/sensors/" + url.PathEscape(name), body: newSensorInput(sensor)}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// DeleteSensor deletes the sensor with the given name, and returns it as it was before it was deleted.
+// Returns an error matching ErrNotFound if there is no such sensor.
+func (c *Client) DeleteSensor(ctx context.Context, name string) (*Sensor, error) {
+	var res struct {
+		Data *Sensor `json:"data"`
+	}
+	err := c.do(ctx, request{method: "DELETE", path: "/sensors/" + url.PathEscape(name)}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// ListSensors returns a page of sensors, ordered by name. Use Sensors to iterate over every sensor.
+func (c *Client) ListSensors(ctx context.Context, opts *ListOptions) (*SensorPage, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.Limit != 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		if opts.Cursor != "" {
+			query.Set("cursor", opts.Cursor)
+		}
+	}
+
+	var res struct {
+		Data       []*Sensor `json:"data"`
+		NextCursor string    `json:"next_cursor"`
+	}
+	err := c.do(ctx, request{method: "GET", path: "/sensors", query: query}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SensorPage{Sensors: res.Data, NextCursor: res.NextCursor}, nil
+}
+
+// FindClosest returns the sensors closest to a location, ordered by distance.
+// The location is a coordinate (see Location), or a place name to geocode.
+// The radius is formatted like "50km" or "100mi". Uses the server's default (20km) if empty.
+func (c *Client) FindClosest(ctx context.Context, location string, radius string) ([]*Sensor, error) {
+	query := url.Values{"location": {location}}
+	if radius != "" {
+		query.Set("radius", radius)
+	}
+
+	var res struct {
+		Data []*Sensor `json:"data"`
+	}
+	err := c.do(ctx, request{method: "GET", path: "/sensors/closest", query: query}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// GetSensorPlace returns the place name of a sensor's location.
+// Returns an error matching ErrNotImplemented if geocoding is not configured on the server.
+func (c *Client) GetSensorPlace(ctx context.Context, name string) (*SensorPlace, error) {
+	var res struct {
+		Data *SensorPlace `json:"data"`
+	}
+	err := c.do(ctx, request{method: "GET", path: "/sensors/" + url.PathEscape(name) + "/place"}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// SuggestPlaces returns candidate places for a partial place name, eg. to autocomplete a search box.
+// Returns an error matching ErrNotImplemented if geocoding is not configured on the server.
+func (c *Client) SuggestPlaces(ctx context.Context, q string, opts *SuggestOptions) ([]*PlaceSuggestion, error) {
+	query := url.Values{"q": {q}}
+	if opts != nil {
+		if opts.Near != "" {
+			query.Set("near", opts.Near)
+		}
+		if opts.SensorCounts {
+			query.Set("sensor_counts", "true")
+		}
+	}
+
+	var res struct {
+		Data []*PlaceSuggestion `json:"data"`
+	}
+	err := c.do(ctx, request{method: "GET", path: "/geocode/suggest", query: query}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// SensorIterator iterates over every sensor, ordered by name, fetching a page at a time.
+//
+//	it := c.Sensors(100)
+//	for it.Next(ctx) {
+//		sensor := it.Sensor()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SensorIterator struct {
+	client *Client
+	limit  int
+	// Remaining sensors of the current page
+	page   []*Sensor
+	cursor string
+	// Set after the last page is fetched
+	done   bool
+	sensor *Sensor
+	err    error
+}
+
+// Sensors returns an iterator over every sensor, fetching pageSize sensors at a time.
+// Uses the server's default page size if pageSize is 0.
+func (c *Client) Sensors(pageSize int) *SensorIterator {
+	return &SensorIterator{client: c, limit: pageSize}
+}
+
+// Next advances to the next sensor, fetching the next page if needed.
+// Returns false when there are no more sensors, or if a page could not be fetched (see Err).
+func (it *SensorIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	for len(it.page) == 0 {
+		if it.done {
+			it.sensor = nil
+			return false
+		}
+		page, err := it.client.ListSensors(ctx, &ListOptions{Limit: it.limit, Cursor: it.cursor})
+		if err != nil {
+			it.sensor = nil
+			it.err = err
+			return false
+		}
+		it.page = page.Sensors
+		it.cursor = page.NextCursor
+		it.done = page.NextCursor == ""
+	}
+
+	it.sensor, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Sensor returns the current sensor
+func (it *SensorIterator) Sensor() *Sensor {
+	return it.sensor
+}
+
+// Err returns the error which stopped the iteration, if any
+func (it *SensorIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/api"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClient_Sensors(t *testing.T) {
+	c := newTestServer(t, nil)
+	ctx := context.Background()
+
+	created, err := c.CreateSensor(ctx, &Sensor{Name: " abc123 ", Lat: 44.916241209323736, Lon: -93.21112681214602, Tags: []string{"x", "y", "x"}})
+	require.NoError(t, err)
+	require.Equal(t, &Sensor{ID: 1, Name: "abc123", Lat: 44.916241209323736, Lon: -93.21112681214602, Tags: []string{"x", "y"}}, created)
+
+	sensor, err := c.GetSensor(ctx, "abc123")
+	require.NoError(t, err)
+	require.Equal(t, created, sensor)
+
+	// Rename the sensor
+	updated, err := c.UpdateSensor(ctx, "abc123", &Sensor{Name: "xyz789", Lat: 44.9, Lon: -93.2, Tags: []string{"z"}})
+	require.NoError(t, err)
+	require.Equal(t, &Sensor{ID: 1, Name: "xyz789", Lat: 44.9, Lon: -93.2, Tags: []string{"z"}}, updated)
+
+	_, err = c.GetSensor(ctx, "abc123")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.EqualError(t, err, "sensor api: no sensor resource exists: abc123 (HTTP 404)")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, "https://github.com/eschwartz/go-sensor-api#not-found", apiErr.Type)
+	_, err = c.UpdateSensor(ctx, "abc123", &Sensor{Name: "abc123"})
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = c.CreateSensor(ctx, &Sensor{Name: "xyz789", Lat: 1, Lon: 2})
+	require.ErrorIs(t, err, ErrConflict)
+	require.EqualError(t, err, "sensor api: a sensor resource already exists: xyz789 (HTTP 409)")
+
+	deleted, err := c.DeleteSensor(ctx, "xyz789")
+	require.NoError(t, err)
+	require.Equal(t, updated, deleted)
+	_, err = c.GetSensor(ctx, "xyz789")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = c.DeleteSensor(ctx, "xyz789")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_ValidationError(t *testing.T) {
+	c := newTestServer(t, nil)
+
+	_, err := c.CreateSensor(context.Background(), &Sensor{Name: "", Lat: 9999, Tags: []string{"a", " "}})
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.EqualError(t, err, "sensor api: invalid sensor: name must not be empty; lat must be between -90 and 90; tags/1 must not be blank (HTTP 400)")
+
+	// Every invalid field is reported
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, []FieldError{
+		{Pointer: "/name", Detail: "must not be empty"},
+		{Pointer: "/lat", Detail: "must be between -90 and 90"},
+		{Pointer: "/tags/1", Detail: "must not be blank"},
+	}, validationErr.Fields)
+
+	// Other bad requests aren't validation errors
+	_, err = c.FindClosest(context.Background(), "44.9,-93.2", "far")
+	require.ErrorIs(t, err, ErrBadRequest)
+	require.False(t, errors.As(err, &validationErr))
+}
+
+func TestClient_ListSensors(t *testing.T) {
+	c := newTestServer(t, nil)
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		_, err := c.CreateSensor(ctx, &Sensor{Name: fmt.Sprintf("sensor-%d", i), Lat: 44.9, Lon: -93.2})
+		require.NoError(t, err)
+	}
+
+	page, err := c.ListSensors(ctx, &ListOptions{Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, page.Sensors, 5)
+	require.Equal(t, "sensor-0", page.Sensors[0].Name)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = c.ListSensors(ctx, &ListOptions{Limit: 5, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Sensors, 2)
+	require.Equal(t, "sensor-5", page.Sensors[0].Name)
+	require.Empty(t, page.NextCursor)
+
+	// Iterate over every sensor, a page at a time
+	it := c.Sensors(3)
+	var names []string
+	for it.Next(ctx) {
+		names = append(names, it.Sensor().Name)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"sensor-0", "sensor-1", "sensor-2", "sensor-3", "sensor-4", "sensor-5", "sensor-6"}, names)
+	require.False(t, it.Next(ctx))
+
+	// Errors stop the iteration
+	it = c.Sensors(1001)
+	require.False(t, it.Next(ctx))
+	require.ErrorIs(t, it.Err(), ErrBadRequest)
+	require.Nil(t, it.Sensor())
+}
+
+func TestClient_Geocoding(t *testing.T) {
+	// Geocoding is not configured
+	c := newTestServer(t, nil)
+	ctx := context.Background()
+	_, err := c.SuggestPlaces(ctx, "Minn", nil)
+	require.ErrorIs(t, err, ErrNotImplemented)
+
+	c = newTestServer(t, []api.Option{api.WithGeocoder(geo.NewGazetteerGeoService([]*geo.GazetteerEntry{
+		{Name: "Minneapolis", Lat: 44.98, Lon: -93.27, CountryCode: "US", Admin1Name: "Minnesota", Population: 425000},
+		{Name: "Chicago", Lat: 41.88, Lon: -87.63, CountryCode: "US", Admin1Name: "Illinois", Population: 2700000},
+	}))})
+	_, err = c.CreateSensor(ctx, &Sensor{Name: "MPLS", Lat: 44.97, Lon: -93.26})
+	require.NoError(t, err)
+	_, err = c.CreateSensor(ctx, &Sensor{Name: "CHI", Lat: 41.86, Lon: -87.68})
+	require.NoError(t, err)
+
+	suggestions, err := c.SuggestPlaces(ctx, "Minn", &SuggestOptions{Near: Location(44.95, -93.1)})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, "Minneapolis, Minnesota, US", suggestions[0].Name)
+
+	place, err := c.GetSensorPlace(ctx, "MPLS")
+	require.NoError(t, err)
+	require.Equal(t, &SensorPlace{Name: "MPLS", Lat: 44.97, Lon: -93.26, PlaceName: "Minneapolis, Minnesota, US"}, place)
+	_, err = c.GetSensorPlace(ctx, "not-a-sensor")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Find sensors by place name, or by coordinate
+	sensors, err := c.FindClosest(ctx, "Chicago", "")
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	require.Equal(t, "CHI", sensors[0].Name)
+
+	sensors, err = c.FindClosest(ctx, Location(44.97, -93.26), "1000mi")
+	require.NoError(t, err)
+	require.Len(t, sensors, 2)
+	require.Equal(t, "MPLS", sensors[0].Name)
+}
@@ -1,27 +1,290 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/eschwartz/go-sensor-api/internal/app/api"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/logging"
+	"github.com/eschwartz/go-sensor-api/internal/app/outbox"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	router, err := api.NewSensorRouter()
+	// Issue an API key from the command line, eg. to bootstrap the first admin key
+	if len(os.Args) > 1 && os.Args[1] == "create-api-key" {
+		if err := createAPIKey(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to create API key: %s", err)
+		}
+		return
+	}
+
+	// Print the configuration, with secrets redacted, eg. to check which settings are in effect
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		if err := printConfig(os.Args[3:]); err != nil {
+			log.Fatalf("Failed to load configuration: %s", err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to create sensor router: %s", err)
+		log.Fatalf("Invalid configuration: %s", err)
 	}
 
-	// Read port from env var, or use default val
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
+	// Log as JSON. This also applies to the standard "log" package.
+	// The level may be changed by reloading the configuration.
+	var logLevel slog.LevelVar
+	logLevel.Set(cfg.Log.SlogLevel())
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, &logLevel)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %s", err)
+	}
+	slog.SetDefault(logger)
+
+	// Trace requests, if an exporter is configured
+	tracer, err := tracing.New(cfg.Tracing)
+	if err != nil {
+		fatal("failed to configure tracing", err)
+	}
+	if tracer != nil {
+		tracing.SetTracer(tracer)
+	}
+
+	router, err := api.FromConfig(cfg)
+	if err != nil {
+		fatal("failed to create sensor router", err)
+	}
+
+	// Shut down gracefully on SIGTERM (eg. from Kubernetes) or SIGINT (Ctrl+C)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore the default behaviour once shutdown starts, so a second signal exits immediately
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Reload the configuration on SIGHUP
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	defer signal.Stop(reloads)
+	go func(current *config.Config) {
+		for range reloads {
+			current = reloadConfig(current, router, &logLevel)
+		}
+	}(cfg)
+
+	// Publish sensor changes from the outbox, if configured.
+	// Workers keep running until the server has drained, as in-flight requests may create events.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	var workerClosers []io.Closer
+	if cfg.Outbox.Publisher != "" {
+		relay, closers, err := newOutboxRelay(cfg)
+		if err != nil {
+			fatal("failed to create outbox relay", err)
+		}
+		workerClosers = append(workerClosers, closers...)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			relay.Run(workerCtx)
+		}()
+	}
+
+	// gRPC Listen, if enabled. The gRPC API shares the router's store, auth and rate limits.
+	var grpcErr <-chan error
+	if cfg.GRPCPort != 0 {
+		grpcListener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			fatal("failed to listen for gRPC", err)
+		}
+		slog.Info(fmt.Sprintf("listening on grpc localhost:%d", cfg.GRPCPort), "port", cfg.GRPCPort)
+		grpcErr = router.StartGRPC(ctx, grpcListener, cfg.Server)
 	}
 
 	// HTTP Listen
-	log.Printf("Listening on http://localhost:%s", port)
-	err = http.ListenAndServe(fmt.Sprintf(":%s", port), router.Handler())
-	log.Fatal(err)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if err != nil {
+		fatal("failed to listen", err)
+	}
+	slog.Info(fmt.Sprintf("listening on http://localhost:%d", cfg.Port), "port", cfg.Port)
+	serveErr := router.Serve(ctx, listener, cfg.Server)
+	// If the HTTP server failed, stop the gRPC server too
+	stop()
+	if grpcErr != nil {
+		if err := <-grpcErr; err != nil {
+			slog.Error("gRPC server failed", "error", err)
+		}
+	}
+
+	// Stop background workers, and flush any buffered spans
+	stopWorkers()
+	workers.Wait()
+	closeAll(workerClosers)
+	if tracer != nil {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracer.Shutdown(flushCtx); err != nil {
+			slog.Error("failed to export spans", "error", err)
+		}
+	}
+
+	if serveErr != nil {
+		fatal("server failed", serveErr)
+	}
+	slog.Info("shut down")
+}
+
+// reloadConfig loads the configuration again (eg. after the config file was edited),
+// and applies the settings which can change without restarting.
+// Returns the new configuration, or the current one if the new configuration is invalid.
+func reloadConfig(current *config.Config, router *api.SensorRouter, logLevel *slog.LevelVar) *config.Config {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		slog.Error("failed to reload configuration: keeping the current configuration", "error", err)
+		return current
+	}
+
+	changes := config.Diff(current, cfg)
+	for _, change := range changes {
+		if change.Reloadable {
+			slog.Info("reloaded setting", "setting", change.Key)
+		} else {
+			slog.Warn("setting changed: restart to apply it", "setting", change.Key)
+		}
+	}
+	logLevel.Set(cfg.Log.SlogLevel())
+	router.Reload(cfg)
+	slog.Info("reloaded configuration", "changes", len(changes))
+
+	return cfg
+}
+
+// printConfig prints the configuration as YAML, with secrets redacted
+//
+//	sensor-api config print -config config.yaml
+func printConfig(args []string) error {
+	cfg, err := config.Load(args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	return config.Print(os.Stdout, cfg)
+}
+
+// closeAll closes background workers' resources, logging any errors
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil {
+			slog.Error("failed to close worker resources", "error", err)
+		}
+	}
+}
+
+// fatal logs an error, and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// newOutboxRelay creates an outbox relay, using the configured publisher.
+// The returned closers should be closed once the relay has stopped.
+func newOutboxRelay(cfg *config.Config) (*outbox.Relay, []io.Closer, error) {
+	var publisher outbox.EventPublisher
+	var closers []io.Closer
+	switch cfg.Outbox.Publisher {
+	case "stdout":
+		publisher = outbox.NewStdoutPublisher()
+	case "file":
+		filePublisher, err := outbox.NewFilePublisher(cfg.Outbox.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		publisher = filePublisher
+		closers = append(closers, filePublisher)
+	case "http":
+		publisher = outbox.NewHTTPPublisher(cfg.Outbox.URL, &http.Client{})
+	default:
+		return nil, nil, fmt.Errorf("invalid OUTBOX_PUBLISHER \"%s\": must be \"stdout\", \"file\" or \"http\"", cfg.Outbox.Publisher)
+	}
+
+	// The relay uses its own connection to the configured store, which must record an outbox
+	sensorStore, err := store.Open(cfg.DatabaseURL)
+	if err != nil {
+		closeAll(closers)
+		return nil, nil, err
+	}
+	if closer, ok := sensorStore.(io.Closer); ok {
+		closers = append(closers, closer)
+	}
+	outboxStore, ok := sensorStore.(store.OutboxStore)
+	if !ok {
+		closeAll(closers)
+		return nil, nil, fmt.Errorf("the sensor store doesn't record an outbox: DATABASE_URL must be a postgres:// URL")
+	}
+
+	return outbox.NewRelay(outboxStore, publisher), closers, nil
+}
+
+// createAPIKey issues an API key, and prints it to stdout
+//
+//	sensor-api create-api-key -tenant acme -name admin -scopes admin
+func createAPIKey(args []string) error {
+	flags := flag.NewFlagSet("create-api-key", flag.ExitOnError)
+	name := flags.String("name", "", "Name of the API key (required)")
+	tenant := flags.String("tenant", store.DefaultTenant, "Tenant (organization) whose sensors the key can access")
+	scopes := flags.String("scopes", auth.ScopeAdmin, "Comma-separated scopes: "+strings.Join(auth.Scopes, ", "))
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+
+	// The database is configured as for the server, by CONFIG_FILE or env vars
+	cfg, err := config.Load(nil, os.Getenv)
+	if err != nil {
+		return err
+	}
+	sensorStore, err := store.Open(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	if closer, ok := sensorStore.(io.Closer); ok {
+		defer closer.Close()
+	}
+	keyStore, ok := sensorStore.(store.APIKeyStore)
+	if !ok {
+		return fmt.Errorf("the sensor store doesn't support API keys: DATABASE_URL must be a postgres:// or sqlite:// URL")
+	}
+
+	key, secret, err := auth.NewAPIKeyService(keyStore).
+		Issue(context.Background(), *tenant, *name, strings.Split(*scopes, ","))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Created API key %d (%s) for tenant %s with scopes %s. Store it securely, it cannot be retrieved again.",
+		key.ID, key.Prefix, key.TenantID, strings.Join(key.Scopes, ","))
+	fmt.Println(secret)
+	return nil
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// commandFlags returns the flags accepted by a command, including the global flags
+func commandFlags(cmd *command) []*flag.Flag {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	var opts globalOptions
+	opts.register(fs)
+	cmd.setup(fs)
+
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return names
+}
+
+var profileSubcommands = []string{"set", "use", "list", "delete"}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+var completionCommand = &command{
+	name:    "completion",
+	args:    "bash|zsh|fish",
+	summary: "Print a shell completion script, eg. source <(sensorctl completion bash)",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) != 1 {
+				return errors.New("expected a shell: " + strings.Join(completionShells, ", "))
+			}
+			switch args[0] {
+			case "bash":
+				writeBashCompletion(c.stdout)
+			case "zsh":
+				// zsh can run bash completion functions
+				fmt.Fprintln(c.stdout, "autoload -U +X compinit && compinit")
+				fmt.Fprintln(c.stdout, "autoload -U +X bashcompinit && bashcompinit")
+				writeBashCompletion(c.stdout)
+			case "fish":
+				writeFishCompletion(c.stdout)
+			default:
+				return fmt.Errorf("unsupported shell \"%s\": must be one of %s", args[0], strings.Join(completionShells, ", "))
+			}
+			return nil
+		}
+	},
+}
+
+// writeBashCompletion writes a bash completion function for every command and flag.
+// Sensor and profile names are completed by running sensorctl.
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, `# sensorctl completion for bash. Load with: source <(sensorctl completion bash)
+_sensorctl() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local cmd="${COMP_WORDS[1]}"
+    COMPREPLY=()
+
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+
+    case "$prev" in
+        -o|--o|-output|--output)
+            COMPREPLY=($(compgen -W "%s" -- "$cur"))
+            return
+            ;;
+        -profile|--profile)
+            COMPREPLY=($(compgen -W "$(sensorctl profile list -o name 2>/dev/null)" -- "$cur"))
+            return
+            ;;
+        -config|--config)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            return
+            ;;
+    esac
+
+    if [[ $cur == -* ]]; then
+        case "$cmd" in
+`, strings.Join(commandNames(), " "), strings.Join(outputFormats, " "))
+
+	for _, cmd := range commands {
+		var names []string
+		for _, f := range commandFlags(cmd) {
+			names = append(names, "-"+f.Name)
+		}
+		fmt.Fprintf(w, "            %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", cmd.name, strings.Join(names, " "))
+	}
+
+	fmt.Fprintf(w, `        esac
+        return
+    fi
+
+    case "$cmd" in
+        get|update|delete)
+            COMPREPLY=($(compgen -W "$(sensorctl list -o name 2>/dev/null)" -- "$cur"))
+            ;;
+        import|export)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            ;;
+        profile)
+            if [[ $COMP_CWORD -eq 2 ]]; then
+                COMPREPLY=($(compgen -W "%s" -- "$cur"))
+            else
+                COMPREPLY=($(compgen -W "$(sensorctl profile list -o name 2>/dev/null)" -- "$cur"))
+            fi
+            ;;
+        completion)
+            COMPREPLY=($(compgen -W "%s" -- "$cur"))
+            ;;
+    esac
+}
+complete -F _sensorctl sensorctl
+`, strings.Join(profileSubcommands, " "), strings.Join(completionShells, " "))
+}
+
+// writeFishCompletion writes fish completions for every command and flag
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# sensorctl completion for fish. Load with: sensorctl completion fish | source")
+	fmt.Fprintln(w, "complete -c sensorctl -f")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c sensorctl -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+
+	for _, cmd := range commands {
+		condition := fishQuote("__fish_seen_subcommand_from " + cmd.name)
+		for _, f := range commandFlags(cmd) {
+			// Go flags take a single dash, like fish's old-style options
+			line := fmt.Sprintf("complete -c sensorctl -n %s -o %s -d %s", condition, f.Name, fishQuote(f.Usage))
+			switch f.Name {
+			case "o", "output":
+				line += " -x -a " + fishQuote(strings.Join(outputFormats, " "))
+			case "profile":
+				line += " -x -a '(sensorctl profile list -o name 2>/dev/null)'"
+			case "config":
+				line += " -r -F"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	fmt.Fprintln(w, "complete -c sensorctl -n '__fish_seen_subcommand_from get update delete' -a '(sensorctl list -o name 2>/dev/null)'")
+	fmt.Fprintln(w, "complete -c sensorctl -n '__fish_seen_subcommand_from import export' -F")
+	fmt.Fprintf(w, "complete -c sensorctl -n '__fish_seen_subcommand_from profile; and not __fish_seen_subcommand_from %s' -a %s\n",
+		strings.Join(profileSubcommands, " "), fishQuote(strings.Join(profileSubcommands, " ")))
+	fmt.Fprintln(w, "complete -c sensorctl -n '__fish_seen_subcommand_from use delete set' -a '(sensorctl profile list -o name 2>/dev/null)'")
+	fmt.Fprintf(w, "complete -c sensorctl -n '__fish_seen_subcommand_from completion' -a %s\n", fishQuote(strings.Join(completionShells, " ")))
+}
+
+// fishQuote quotes a string for a fish script
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
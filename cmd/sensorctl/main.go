@@ -0,0 +1,234 @@
+// sensorctl manages sensors from the command line, using the sensor API.
+//
+//	sensorctl profile set prod -server https://sensors.example.com -api-key sk_...
+//	sensorctl closest -near "Minneapolis" -radius 10km
+//	sensorctl export sensors.json
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/client"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// command is a sensorctl subcommand, eg. "get"
+type command struct {
+	name string
+	// Positional arguments, for usage messages, eg. "NAME"
+	args    string
+	summary string
+	// setup registers the command's flags, and returns the function which runs the command
+	setup func(fs *flag.FlagSet) func(ctx context.Context, cli *cli, args []string) error
+}
+
+// commands, in the order they're listed by usage messages.
+// Initialized by init, as the completion command refers to the list.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		getCommand,
+		listCommand,
+		createCommand,
+		updateCommand,
+		deleteCommand,
+		closestCommand,
+		importCommand,
+		exportCommand,
+		profileCommand,
+		completionCommand,
+	}
+}
+
+// cli is the environment of a command
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+	// Global flags, shared by every command
+	opts globalOptions
+	// Created by client(), from the flags and profile
+	api *client.Client
+}
+
+// globalOptions are flags accepted by every command
+type globalOptions struct {
+	configFile string
+	profile    string
+	server     string
+	apiKey     string
+	token      string
+	output     string
+	timeout    time.Duration
+}
+
+func (opts *globalOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&opts.configFile, "config", "", "Profiles file. Defaults to $SENSORCTL_CONFIG, or sensorctl/config.yaml in the user config directory")
+	fs.StringVar(&opts.profile, "profile", "", "Profile to use. Defaults to $SENSORCTL_PROFILE, or the current profile")
+	fs.StringVar(&opts.server, "server", "", "API URL, eg. https://sensors.example.com. Overrides the profile ($SENSORCTL_SERVER)")
+	fs.StringVar(&opts.apiKey, "api-key", "", "API key. Overrides the profile ($SENSORCTL_API_KEY)")
+	fs.StringVar(&opts.token, "token", "", "Bearer token, eg. an SSO JWT. Overrides the profile ($SENSORCTL_TOKEN)")
+	fs.StringVar(&opts.output, "output", "", "Output format: "+strings.Join(outputFormats, ", ")+". Defaults to the profile's format, or table")
+	fs.StringVar(&opts.output, "o", "", "Shorthand for -output")
+	fs.DurationVar(&opts.timeout, "timeout", time.Minute, "Time limit for the command, including retries")
+}
+
+func main() {
+	// Stop on Ctrl+C, canceling any requests in flight
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}
+	err := c.run(ctx, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		printError(c.stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command named by the first argument
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		c.usage(c.stdout)
+		return nil
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		c.usage(c.stderr)
+		return fmt.Errorf("unknown command \"%s\"", args[0])
+	}
+
+	fs := flag.NewFlagSet("sensorctl "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: sensorctl %s %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	c.opts.register(fs)
+	runCmd := cmd.setup(fs)
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+	return runCmd(ctx, c, positional)
+}
+
+func (c *cli) usage(w io.Writer) {
+	fmt.Fprintf(w, "sensorctl manages sensors, using the sensor API.\n\nUsage: sensorctl <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"sensorctl <command> -h\" for the command's flags.\n")
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// parseInterspersed parses flags which may come before, after or between positional arguments,
+// eg. "get abc123 -o json". Returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		// Arguments after "--" are never flags
+		consumed := len(args) - fs.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// client returns an API client, configured by the flags, environment and profile
+func (c *cli) client() (*client.Client, error) {
+	if c.api != nil {
+		return c.api, nil
+	}
+
+	p, err := c.profile()
+	if err != nil {
+		return nil, err
+	}
+	var opts []client.Option
+	if p.APIKey != "" {
+		opts = append(opts, client.WithAPIKey(p.APIKey))
+	}
+	if p.Token != "" {
+		opts = append(opts, client.WithBearerToken(p.Token))
+	}
+	opts = append(opts, client.WithUserAgent("sensorctl"))
+
+	c.api = client.New(p.Server, opts...)
+	return c.api, nil
+}
+
+// profile returns the settings to use: the selected profile, overridden by environment variables and flags
+func (c *cli) profile() (*profile, error) {
+	cfg, err := loadProfiles(c.configPath())
+	if err != nil {
+		return nil, err
+	}
+
+	name := firstNonEmpty(c.opts.profile, c.getenv("SENSORCTL_PROFILE"), cfg.CurrentProfile)
+	p := &profile{}
+	if name != "" {
+		selected, ok := cfg.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("no profile named \"%s\": create it with \"sensorctl profile set %s -server <url>\"", name, name)
+		}
+		*p = *selected
+	}
+
+	p.Server = firstNonEmpty(c.opts.server, c.getenv("SENSORCTL_SERVER"), p.Server, defaultServer)
+	p.APIKey = firstNonEmpty(c.opts.apiKey, c.getenv("SENSORCTL_API_KEY"), p.APIKey)
+	p.Token = firstNonEmpty(c.opts.token, c.getenv("SENSORCTL_TOKEN"), p.Token)
+	p.Output = firstNonEmpty(c.opts.output, p.Output, "table")
+	return p, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// printError prints an error. Validation errors include every invalid field.
+func printError(w io.Writer, err error) {
+	fmt.Fprintf(w, "Error: %s\n", err)
+}
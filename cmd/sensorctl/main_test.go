@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/api"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCLI runs sensorctl commands against a test server, with a temporary profiles file
+type testCLI struct {
+	t      *testing.T
+	config string
+	env    map[string]string
+	stdin  string
+}
+
+func newTestCLI(t *testing.T, server string) *testCLI {
+	return &testCLI{
+		t:      t,
+		config: filepath.Join(t.TempDir(), "config.yaml"),
+		env:    map[string]string{"SENSORCTL_SERVER": server},
+	}
+}
+
+func newTestServer(t *testing.T, opts ...api.Option) string {
+	opts = append([]api.Option{api.WithStore(store.NewMemorySensorStore())}, opts...)
+	server := httptest.NewServer(api.NewSensorRouter(opts...).Handler())
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// run runs a command, and returns its stdout and stderr
+func (tc *testCLI) run(args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	c := &cli{
+		stdin:  strings.NewReader(tc.stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(key string) string {
+			if key == "SENSORCTL_CONFIG" {
+				return tc.config
+			}
+			return tc.env[key]
+		},
+	}
+	err := c.run(context.Background(), args)
+	return stdout.String(), stderr.String(), err
+}
+
+// mustRun runs a command which should succeed, and returns its stdout
+func (tc *testCLI) mustRun(args ...string) string {
+	stdout, stderr, err := tc.run(args...)
+	require.NoError(tc.t, err, stderr)
+	return stdout
+}
+
+func TestSensorCommands(t *testing.T) {
+	tc := newTestCLI(t, newTestServer(t))
+
+	out := tc.mustRun("create", "abc123", "-lat", "44.9", "-lon", "-93.2", "-tags", "indoor, basement")
+	require.Equal(t, "NAME    LAT   LON    TAGS             PLACE\nabc123  44.9  -93.2  indoor,basement  \n", out)
+
+	// Flags may come after the arguments
+	out = tc.mustRun("get", "abc123", "-o", "json")
+	require.JSONEq(t, `{"id":1,"name":"abc123","lat":44.9,"lon":-93.2,"tags":["indoor","basement"]}`, out)
+
+	out = tc.mustRun("update", "abc123", "-name", "xyz789", "-tags", "", "-o", "yaml")
+	require.Equal(t, "id: 1\nlat: 44.9\nlon: -93.2\nname: xyz789\ntags: []\n", out)
+
+	tc.mustRun("create", "def456", "-lat", "44.95", "-lon", "-93.1")
+	out = tc.mustRun("list", "-o", "name")
+	require.Equal(t, "def456\nxyz789\n", out)
+	out = tc.mustRun("list", "-limit", "1", "-o", "name")
+	require.Equal(t, "def456\n", out)
+
+	out = tc.mustRun("closest", "-near", "44.95,-93.1", "--radius", "10km", "-o", "name")
+	require.Equal(t, "def456\nxyz789\n", out)
+
+	out = tc.mustRun("delete", "xyz789", "def456", "-o", "name")
+	require.Equal(t, "xyz789\ndef456\n", out)
+	out = tc.mustRun("list", "-o", "json")
+	require.Equal(t, "[]\n", out)
+
+	_, _, err := tc.run("get", "xyz789")
+	require.EqualError(t, err, "sensor api: no sensor resource exists: xyz789 (HTTP 404)")
+	_, _, err = tc.run("create", "", "-lat", "100")
+	require.EqualError(t, err, "sensor api: invalid sensor: name must not be empty; lat must be between -90 and 90 (HTTP 400)")
+	_, _, err = tc.run("update", "abc123")
+	require.Error(t, err)
+	_, _, err = tc.run("list", "-o", "xml")
+	require.EqualError(t, err, "invalid output format \"xml\": must be one of table, json, yaml, name")
+	_, _, err = tc.run("frobnicate")
+	require.EqualError(t, err, "unknown command \"frobnicate\"")
+}
+
+func TestClosestCommand_PlaceName(t *testing.T) {
+	tc := newTestCLI(t, newTestServer(t, api.WithGeocoder(geo.NewGazetteerGeoService([]*geo.GazetteerEntry{
+		{Name: "Minneapolis", Lat: 44.98, Lon: -93.27, CountryCode: "US", Admin1Name: "Minnesota", Population: 425000},
+	}))))
+	tc.mustRun("create", "MPLS", "-lat", "44.97", "-lon", "-93.26")
+	tc.mustRun("create", "STP", "-lat", "44.95", "-lon", "-93.09")
+
+	out := tc.mustRun("closest", "-near", "Minneapolis", "-radius", "10km", "-o", "name")
+	require.Equal(t, "MPLS\n", out)
+}
+
+func TestImportExport(t *testing.T) {
+	tc := newTestCLI(t, newTestServer(t))
+	tc.mustRun("create", "abc123", "-lat", "1", "-lon", "2")
+
+	// Existing sensors are updated, others are created
+	tc.stdin = `[{"name":"abc123","lat":44.9,"lon":-93.2,"tags":["x"]},{"name":"def456","lat":44.95,"lon":-93.1,"tags":[]}]`
+	_, stderr, err := tc.run("import", "-")
+	require.NoError(t, err)
+	require.Equal(t, "Imported 2 sensors: 1 created, 1 updated, 0 failed\n", stderr)
+
+	path := filepath.Join(t.TempDir(), "sensors.yaml")
+	tc.mustRun("export", path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `- id: 1
+  lat: 44.9
+  lon: -93.2
+  name: abc123
+  tags:
+    - x
+- id: 2
+  lat: 44.95
+  lon: -93.1
+  name: def456
+  tags: []
+`, string(data))
+
+	// Import the export into another server
+	other := newTestCLI(t, newTestServer(t))
+	other.mustRun("import", path)
+	require.Equal(t, tc.mustRun("export"), other.mustRun("export"))
+
+	// Invalid sensors are reported, and the others are imported
+	tc.stdin = `[{"name":"ghi789","lat":1,"lon":2,"tags":[]},{"name":"bad","lat":1000,"lon":0,"tags":[]}]`
+	_, stderr, err = tc.run("import", "-")
+	require.EqualError(t, err, "failed to import 1 of 2 sensors")
+	require.Contains(t, stderr, "Failed to import bad: sensor api: invalid sensor: lat must be between -90 and 90 (HTTP 400)\n")
+	require.Equal(t, "abc123\ndef456\nghi789\n", tc.mustRun("list", "-o", "name"))
+}
+
+func TestProfiles(t *testing.T) {
+	apiKeys := auth.NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	_, key, err := apiKeys.Issue(context.Background(), "acme", "sensorctl", []string{auth.ScopeSensorsRead, auth.ScopeSensorsWrite})
+	require.NoError(t, err)
+	server := newTestServer(t, api.WithAuthentication(apiKeys, nil))
+
+	tc := newTestCLI(t, "")
+	tc.mustRun("profile", "set", "local", "-server", "http://localhost:1")
+	tc.mustRun("profile", "set", "prod", "-server", server, "-api-key", key, "-output", "json")
+	out := tc.mustRun("profile", "list")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"CURRENT", "NAME", "SERVER", "AUTH"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"*", "local", "http://localhost:1", "none"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"prod", server, "api-key"}, strings.Fields(lines[2]))
+
+	// The profiles file is only readable by the user
+	info, err := os.Stat(tc.config)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Select a profile by flag, environment variable, or as the current profile
+	out = tc.mustRun("list", "-profile", "prod")
+	require.Equal(t, "[]\n", out)
+	tc.env["SENSORCTL_PROFILE"] = "prod"
+	tc.mustRun("create", "abc123")
+	delete(tc.env, "SENSORCTL_PROFILE")
+	tc.mustRun("profile", "use", "prod")
+	out = tc.mustRun("list", "-o", "name")
+	require.Equal(t, "abc123\n", out)
+
+	// Flags override the profile
+	_, _, err = tc.run("list", "-api-key", "not-a-key")
+	require.EqualError(t, err, "sensor api: invalid credentials: invalid API key (HTTP 401)")
+
+	_, _, err = tc.run("list", "-profile", "staging")
+	require.EqualError(t, err, "no profile named \"staging\": create it with \"sensorctl profile set staging -server <url>\"")
+
+	tc.mustRun("profile", "delete", "prod")
+	require.Equal(t, "local\n", tc.mustRun("profile", "list", "-o", "name"))
+}
+
+func TestCompletion(t *testing.T) {
+	tc := newTestCLI(t, "")
+	for _, shell := range completionShells {
+		out := tc.mustRun("completion", shell)
+		// Every command and flag is completed
+		for _, cmd := range commands {
+			require.Contains(t, out, cmd.name)
+			for _, f := range commandFlags(cmd) {
+				require.Contains(t, out, f.Name)
+			}
+		}
+	}
+	_, _, err := tc.run("completion", "powershell")
+	require.EqualError(t, err, "unsupported shell \"powershell\": must be one of bash, zsh, fish")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/client"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// outputFormats are the values of the -output flag
+var outputFormats = []string{"table", "json", "yaml", "name"}
+
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// printSensors writes sensors in the given output format.
+// A single sensor (eg. from "get") is written as an object in JSON and YAML, rather than a list.
+func printSensors(w io.Writer, format string, sensors []*client.Sensor, single bool) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tLAT\tLON\tTAGS\tPLACE")
+		for _, sensor := range sensors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				sensor.Name,
+				strconv.FormatFloat(sensor.Lat, 'f', -1, 64),
+				strconv.FormatFloat(sensor.Lon, 'f', -1, 64),
+				strings.Join(sensor.Tags, ","),
+				sensor.PlaceName,
+			)
+		}
+		return tw.Flush()
+	case "name":
+		for _, sensor := range sensors {
+			fmt.Fprintln(w, sensor.Name)
+		}
+		return nil
+	case "json", "yaml":
+		var v interface{} = sensors
+		if single && len(sensors) == 1 {
+			v = sensors[0]
+		}
+		return encode(w, format, v)
+	default:
+		return fmt.Errorf("invalid output format \"%s\": must be one of %s", format, strings.Join(outputFormats, ", "))
+	}
+}
+
+// encode writes a value as indented JSON, or as YAML.
+// YAML uses the same field names as JSON (eg. "place_name"), so files can be converted between the formats.
+func encode(w io.Writer, format string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(generic); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+// defaultServer is used when no server is configured, eg. for a local development API
+const defaultServer = "http://localhost:8000"
+
+// profile is a named API endpoint and its credentials
+type profile struct {
+	Server string `yaml:"server"`
+	APIKey string `yaml:"api_key,omitempty"`
+	Token  string `yaml:"token,omitempty"`
+	// Default output format for the profile
+	Output string `yaml:"output,omitempty"`
+}
+
+// profileConfig is the profiles file, eg. ~/.config/sensorctl/config.yaml
+type profileConfig struct {
+	// Profile to use when none is selected by -profile or $SENSORCTL_PROFILE
+	CurrentProfile string              `yaml:"current_profile,omitempty"`
+	Profiles       map[string]*profile `yaml:"profiles"`
+}
+
+// loadProfiles reads the profiles file. Returns an empty config if the file does not exist.
+func loadProfiles(path string) (*profileConfig, error) {
+	cfg := &profileConfig{Profiles: map[string]*profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*profile{}
+	}
+	return cfg, nil
+}
+
+// saveProfiles writes the profiles file. The file is only readable by the user, as it contains credentials.
+func saveProfiles(path string, cfg *profileConfig) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to save profiles: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save profiles: %w", err)
+	}
+	return nil
+}
+
+// configPath returns the path of the profiles file
+func (c *cli) configPath() string {
+	if path := firstNonEmpty(c.opts.configFile, c.getenv("SENSORCTL_CONFIG")); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		// No home directory, eg. in a container. Use the working directory.
+		return "sensorctl.yaml"
+	}
+	return filepath.Join(dir, "sensorctl", "config.yaml")
+}
+
+var profileCommand = &command{
+	name:    "profile",
+	args:    "set|use|list|delete [NAME]",
+	summary: "Manage named profiles of API endpoints and credentials",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		// Flags for "profile set". The global -server, -api-key, -token and -output flags are reused.
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) == 0 {
+				fs.Usage()
+				return errors.New("missing profile subcommand")
+			}
+			path := c.configPath()
+			cfg, err := loadProfiles(path)
+			if err != nil {
+				return err
+			}
+
+			switch sub := args[0]; sub {
+			case "list":
+				if len(args) != 1 {
+					return errors.New("usage: sensorctl profile list")
+				}
+				return c.printProfiles(cfg)
+			case "set", "use", "delete":
+				if len(args) != 2 {
+					return fmt.Errorf("usage: sensorctl profile %s NAME", sub)
+				}
+				name := args[1]
+				switch sub {
+				case "set":
+					p, ok := cfg.Profiles[name]
+					if !ok {
+						p = &profile{}
+						cfg.Profiles[name] = p
+					}
+					// Only change the settings which are given
+					p.Server = firstNonEmpty(c.opts.server, p.Server)
+					p.APIKey = firstNonEmpty(c.opts.apiKey, p.APIKey)
+					p.Token = firstNonEmpty(c.opts.token, p.Token)
+					p.Output = firstNonEmpty(c.opts.output, p.Output)
+					if p.Server == "" {
+						return errors.New("missing -server for the profile")
+					}
+					if p.Output != "" && !isOutputFormat(p.Output) {
+						return fmt.Errorf("invalid output format \"%s\"", p.Output)
+					}
+					// The first profile is used by default
+					if cfg.CurrentProfile == "" {
+						cfg.CurrentProfile = name
+					}
+				case "use":
+					if _, ok := cfg.Profiles[name]; !ok {
+						return fmt.Errorf("no profile named \"%s\"", name)
+					}
+					cfg.CurrentProfile = name
+				case "delete":
+					if _, ok := cfg.Profiles[name]; !ok {
+						return fmt.Errorf("no profile named \"%s\"", name)
+					}
+					delete(cfg.Profiles, name)
+					if cfg.CurrentProfile == name {
+						cfg.CurrentProfile = ""
+					}
+				}
+				return saveProfiles(path, cfg)
+			default:
+				fs.Usage()
+				return fmt.Errorf("unknown profile subcommand \"%s\"", sub)
+			}
+		}
+	},
+}
+
+// printProfiles lists the profiles, without their credentials
+func (c *cli) printProfiles(cfg *profileConfig) error {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if c.opts.output == "name" {
+		for _, name := range names {
+			fmt.Fprintln(c.stdout, name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tSERVER\tAUTH")
+	for _, name := range names {
+		p := cfg.Profiles[name]
+		current := ""
+		if name == cfg.CurrentProfile {
+			current = "*"
+		}
+		auth := "none"
+		if p.APIKey != "" {
+			auth = "api-key"
+		} else if p.Token != "" {
+			auth = "token"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, name, p.Server, auth)
+	}
+	return w.Flush()
+}
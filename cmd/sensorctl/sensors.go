@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/client"
+	"strings"
+)
+
+// outputFormat returns the -output format, or the profile's default
+func (c *cli) outputFormat() (string, error) {
+	p, err := c.profile()
+	if err != nil {
+		return "", err
+	}
+	if !isOutputFormat(p.Output) {
+		return "", fmt.Errorf("invalid output format \"%s\": must be one of %s", p.Output, strings.Join(outputFormats, ", "))
+	}
+	return p.Output, nil
+}
+
+// printSensors writes sensors to stdout, in the selected output format
+func (c *cli) printSensors(sensors []*client.Sensor, single bool) error {
+	format, err := c.outputFormat()
+	if err != nil {
+		return err
+	}
+	return printSensors(c.stdout, format, sensors, single)
+}
+
+// parseTags parses a comma-separated list of tags, eg. "indoor,basement"
+func parseTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+var getCommand = &command{
+	name:    "get",
+	args:    "NAME...",
+	summary: "Show sensors by name",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) == 0 {
+				return errors.New("missing sensor name")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			var sensors []*client.Sensor
+			for _, name := range args {
+				sensor, err := api.GetSensor(ctx, name)
+				if err != nil {
+					return err
+				}
+				sensors = append(sensors, sensor)
+			}
+			return c.printSensors(sensors, len(args) == 1)
+		}
+	},
+}
+
+var listCommand = &command{
+	name:    "list",
+	args:    "",
+	summary: "List sensors, ordered by name",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		limit := fs.Int("limit", 0, "Most sensors to list. Lists every sensor if 0")
+		pageSize := fs.Int("page-size", 500, "Sensors to fetch per request (1 to 1000)")
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("unexpected argument \"%s\"", args[0])
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			size := *pageSize
+			if *limit > 0 && *limit < size {
+				size = *limit
+			}
+
+			sensors := []*client.Sensor{}
+			it := api.Sensors(size)
+			for (*limit <= 0 || len(sensors) < *limit) && it.Next(ctx) {
+				sensors = append(sensors, it.Sensor())
+			}
+			if err := it.Err(); err != nil {
+				return err
+			}
+			return c.printSensors(sensors, false)
+		}
+	},
+}
+
+var createCommand = &command{
+	name:    "create",
+	args:    "NAME -lat LAT -lon LON [-tags TAG,...]",
+	summary: "Create a sensor",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		lat := fs.Float64("lat", 0, "Latitude, in degrees")
+		lon := fs.Float64("lon", 0, "Longitude, in degrees")
+		tags := fs.String("tags", "", "Comma-separated tags, eg. indoor,basement")
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) != 1 {
+				return errors.New("expected exactly one sensor name")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			sensor, err := api.CreateSensor(ctx, &client.Sensor{Name: args[0], Lat: *lat, Lon: *lon, Tags: parseTags(*tags)})
+			if err != nil {
+				return err
+			}
+			return c.printSensors([]*client.Sensor{sensor}, true)
+		}
+	},
+}
+
+var updateCommand = &command{
+	name:    "update",
+	args:    "NAME [-name NEW_NAME] [-lat LAT] [-lon LON] [-tags TAG,...]",
+	summary: "Update a sensor. Only the given fields are changed.",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		name := fs.String("name", "", "New name for the sensor")
+		lat := fs.Float64("lat", 0, "Latitude, in degrees")
+		lon := fs.Float64("lon", 0, "Longitude, in degrees")
+		tags := fs.String("tags", "", "Comma-separated tags, replacing the sensor's tags. Empty to remove every tag.")
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) != 1 {
+				return errors.New("expected exactly one sensor name")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			sensor, err := api.GetSensor(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			// The API replaces the whole sensor, so keep the fields which aren't given
+			changed := false
+			fs.Visit(func(f *flag.Flag) {
+				switch f.Name {
+				case "name":
+					sensor.Name = *name
+				case "lat":
+					sensor.Lat = *lat
+				case "lon":
+					sensor.Lon = *lon
+				case "tags":
+					sensor.Tags = parseTags(*tags)
+				default:
+					return
+				}
+				changed = true
+			})
+			if !changed {
+				return errors.New("nothing to update: set -name, -lat, -lon or -tags")
+			}
+
+			updated, err := api.UpdateSensor(ctx, args[0], sensor)
+			if err != nil {
+				return err
+			}
+			return c.printSensors([]*client.Sensor{updated}, true)
+		}
+	},
+}
+
+var deleteCommand = &command{
+	name:    "delete",
+	args:    "NAME...",
+	summary: "Delete sensors by name",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) == 0 {
+				return errors.New("missing sensor name")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			var deleted []*client.Sensor
+			for _, name := range args {
+				sensor, err := api.DeleteSensor(ctx, name)
+				if err != nil {
+					// Show which sensors were deleted before the failure
+					if len(deleted) > 0 {
+						_ = c.printSensors(deleted, false)
+					}
+					return fmt.Errorf("failed to delete %s: %w", name, err)
+				}
+				deleted = append(deleted, sensor)
+			}
+			return c.printSensors(deleted, len(args) == 1)
+		}
+	},
+}
+
+var closestCommand = &command{
+	name:    "closest",
+	args:    "-near PLACE|LAT,LON [-radius DISTANCE]",
+	summary: "Find the sensors closest to a place or coordinate, ordered by distance",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		near := fs.String("near", "", "Place name, eg. \"Minneapolis\", or coordinate, eg. 44.98,-93.27")
+		radius := fs.String("radius", "", "Distance to search within, eg. 10km or 5mi. Defaults to the server's default (20km)")
+		return func(ctx context.Context, c *cli, args []string) error {
+			// Allow the place as an argument, eg. "closest Minneapolis"
+			if *near == "" && len(args) > 0 {
+				*near, args = strings.Join(args, " "), nil
+			}
+			if *near == "" {
+				return errors.New("missing -near")
+			}
+			if len(args) != 0 {
+				return fmt.Errorf("unexpected argument \"%s\"", args[0])
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			sensors, err := api.FindClosest(ctx, *near, *radius)
+			if err != nil {
+				return err
+			}
+			return c.printSensors(sensors, false)
+		}
+	},
+}
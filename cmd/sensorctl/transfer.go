@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/client"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isYAMLFile returns true if the path has a YAML file extension
+func isYAMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// decodeSensors reads a list of sensors, as written by "export"
+func decodeSensors(r io.Reader, format string) ([]*client.Sensor, error) {
+	var sensors []*client.Sensor
+	if format == "yaml" {
+		// Decode via JSON, to use the JSON field names (eg. "place_name")
+		var generic interface{}
+		if err := yaml.NewDecoder(r).Decode(&generic); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		data, err := json.Marshal(generic)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if err := json.Unmarshal(data, &sensors); err != nil {
+			return nil, fmt.Errorf("invalid sensors: %w", err)
+		}
+		return sensors, nil
+	}
+
+	if err := json.NewDecoder(r).Decode(&sensors); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return sensors, nil
+}
+
+var importCommand = &command{
+	name:    "import",
+	args:    "FILE|-",
+	summary: "Create or update sensors from a JSON or YAML list, eg. from \"export\"",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		format := fs.String("format", "", "Format of the file: json or yaml. Defaults to yaml for .yaml and .yml files, otherwise json")
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) != 1 {
+				return errors.New("expected exactly one file, or - for stdin")
+			}
+			path := args[0]
+			if *format == "" {
+				*format = "json"
+				if isYAMLFile(path) {
+					*format = "yaml"
+				}
+			}
+			if *format != "json" && *format != "yaml" {
+				return fmt.Errorf("invalid format \"%s\": must be json or yaml", *format)
+			}
+
+			r := c.stdin
+			if path != "-" {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			sensors, err := decodeSensors(r, *format)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+
+			// Import every sensor, and report the failures at the end
+			var created, updated, failed int
+			for _, sensor := range sensors {
+				_, err := api.GetSensor(ctx, sensor.Name)
+				exists := err == nil
+				if errors.Is(err, client.ErrNotFound) {
+					_, err = api.CreateSensor(ctx, sensor)
+					if err == nil {
+						created++
+					}
+					// The sensor was created since we checked, so update it instead
+					exists = errors.Is(err, client.ErrConflict)
+				}
+				if exists {
+					_, err = api.UpdateSensor(ctx, sensor.Name, sensor)
+					if err == nil {
+						updated++
+					}
+				}
+				if err != nil {
+					if ctx.Err() != nil {
+						return err
+					}
+					failed++
+					fmt.Fprintf(c.stderr, "Failed to import %s: %s\n", sensor.Name, err)
+				}
+			}
+
+			fmt.Fprintf(c.stderr, "Imported %d sensors: %d created, %d updated, %d failed\n", created+updated, created, updated, failed)
+			if failed > 0 {
+				return fmt.Errorf("failed to import %d of %d sensors", failed, len(sensors))
+			}
+			return nil
+		}
+	},
+}
+
+var exportCommand = &command{
+	name:    "export",
+	args:    "[FILE]",
+	summary: "Write every sensor as a JSON or YAML list, to a file or stdout",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error {
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) > 1 {
+				return errors.New("expected at most one file")
+			}
+
+			// Uses -output if given, otherwise the file extension. Tables can't be imported, so default to json.
+			format := c.opts.output
+			if format == "" {
+				format = "json"
+				if len(args) == 1 && isYAMLFile(args[0]) {
+					format = "yaml"
+				}
+			}
+			if format != "json" && format != "yaml" {
+				return fmt.Errorf("invalid output format \"%s\": must be json or yaml", format)
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			sensors := []*client.Sensor{}
+			it := api.Sensors(1000)
+			for it.Next(ctx) {
+				sensors = append(sensors, it.Sensor())
+			}
+			if err := it.Err(); err != nil {
+				return err
+			}
+
+			if len(args) == 0 || args[0] == "-" {
+				return encode(c.stdout, format, sensors)
+			}
+			f, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			if err := encode(f, format, sensors); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stderr, "Exported %d sensors to %s\n", len(sensors), args[0])
+			return nil
+		}
+	},
+}
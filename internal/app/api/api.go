@@ -1,8 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -15,24 +16,39 @@ type JSONHandlerFunc func(r *http.Request) (interface{}, int, error)
 func WithJSONHandler(f JSONHandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Call the underlying handler function
+		// Handlers may set response headers, using setResponseHeader()
+		r = r.WithContext(context.WithValue(r.Context(), responseHeaderContextKey{}, w.Header()))
 		data, status, httpErr := f(r)
+		writeJSONResponse(w, r, data, status, httpErr)
+	}
+}
+
+// writeJSONResponse writes a JSONHandlerFunc's response data, or error
+func writeJSONResponse(w http.ResponseWriter, r *http.Request, data interface{}, status int, httpErr error) {
+	// Serve handler errors as RFC 7807 problems
+	contentType := "application/json"
+	if httpErr != nil {
+		data = problemFromError(httpErr, status)
+		contentType = "application/problem+json"
+	}
+
+	// Write JSON response
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(data)
+
+	// Handle JSON encoding failure
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to encode json response", "error", err)
+		w.WriteHeader(500)
+	}
+}
+
+type responseHeaderContextKey struct{}
 
-		// Serve handler errors as JSON
-		if httpErr != nil {
-			data = map[string]string{
-				"error": httpErr.Error(),
-			}
-		}
-
-		// Write JSON response
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(status)
-		err := json.NewEncoder(w).Encode(data)
-
-		// Handle JSON encoding failure
-		if err != nil {
-			log.Printf("failed to encode json response: %s", err)
-			w.WriteHeader(500)
-		}
+// setResponseHeader sets a header on the response to a JSONHandlerFunc
+func setResponseHeader(r *http.Request, key string, value string) {
+	if header, ok := r.Context().Value(responseHeaderContextKey{}).(http.Header); ok {
+		header.Set(key, value)
 	}
 }
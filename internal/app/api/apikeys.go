@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (router *SensorRouter) IssueAPIKeyHandler(r *http.Request) (interface{}, int, error) {
+	if router.apiKeys == nil {
+		return nil, http.StatusNotImplemented, errors.New("authentication is disabled")
+	}
+
+	// Parse JSON request body
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var req IssueAPIKeyRequest
+	if err := decoder.Decode(&req); err != nil {
+		return nil, requestBodyErrorStatus(err), fmt.Errorf("invalid request body: %w", err)
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, http.StatusBadRequest, errors.New("invalid request body: missing required \"name\"")
+	}
+
+	key, secret, err := router.apiKeys.Issue(r.Context(), tenantID(r.Context()), req.Name, req.Scopes)
+	if errors.Is(err, auth.ErrInvalidScope) {
+		return nil, requestBodyErrorStatus(err), fmt.Errorf("invalid request body: %w", err)
+	}
+	if err != nil {
+		router.logger().ErrorContext(r.Context(), "failed to issue API key", "error", err)
+		return nil, http.StatusInternalServerError, errors.New("failed to issue API key: internal server error")
+	}
+
+	router.audit(r.Context(), "issued", "API key "+key.Prefix)
+
+	return IssuedAPIKeyResponse{IssuedAPIKey{APIKey: *key, Key: secret}}, http.StatusCreated, nil
+}
+
+func (router *SensorRouter) ListAPIKeysHandler(r *http.Request) (interface{}, int, error) {
+	if router.apiKeys == nil {
+		return nil, http.StatusNotImplemented, errors.New("authentication is disabled")
+	}
+
+	keys, err := router.apiKeys.List(r.Context(), tenantID(r.Context()))
+	if err != nil {
+		router.logger().ErrorContext(r.Context(), "failed to list API keys", "error", err)
+		return nil, http.StatusInternalServerError, errors.New("failed to list API keys: internal server error")
+	}
+
+	return APIKeyListResponse{keys}, http.StatusOK, nil
+}
+
+func (router *SensorRouter) RotateAPIKeyHandler(r *http.Request) (interface{}, int, error) {
+	if router.apiKeys == nil {
+		return nil, http.StatusNotImplemented, errors.New("authentication is disabled")
+	}
+	id, err := apiKeyIDFromRequest(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	key, secret, err := router.apiKeys.Rotate(r.Context(), tenantID(r.Context()), id)
+	if err != nil {
+		// Missing and revoked keys can't be rotated
+		var missingErr *store.MissingResourceError
+		if errors.As(err, &missingErr) {
+			return nil, http.StatusNotFound, err
+		}
+		router.logger().ErrorContext(r.Context(), "failed to rotate API key", "api_key_id", id, "error", err)
+		return nil, http.StatusInternalServerError, errors.New("failed to rotate API key: internal server error")
+	}
+
+	router.audit(r.Context(), "rotated", "API key "+key.Prefix)
+
+	return IssuedAPIKeyResponse{IssuedAPIKey{APIKey: *key, Key: secret}}, http.StatusOK, nil
+}
+
+func (router *SensorRouter) RevokeAPIKeyHandler(r *http.Request) (interface{}, int, error) {
+	if router.apiKeys == nil {
+		return nil, http.StatusNotImplemented, errors.New("authentication is disabled")
+	}
+	id, err := apiKeyIDFromRequest(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	key, err := router.apiKeys.Revoke(r.Context(), tenantID(r.Context()), id)
+	if err != nil {
+		var missingErr *store.MissingResourceError
+		if errors.As(err, &missingErr) {
+			return nil, http.StatusNotFound, err
+		}
+		router.logger().ErrorContext(r.Context(), "failed to revoke API key", "api_key_id", id, "error", err)
+		return nil, http.StatusInternalServerError, errors.New("failed to revoke API key: internal server error")
+	}
+
+	router.audit(r.Context(), "revoked", "API key "+key.Prefix)
+
+	return APIKeyDetailsResponse{*key}, http.StatusOK, nil
+}
+
+// apiKeyIDFromRequest parses the {id} URL var
+func apiKeyIDFromRequest(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid API key id: must be an integer")
+	}
+
+	return id, nil
+}
+
+type IssueAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// IssuedAPIKey is a newly issued (or rotated) API key, including its secret.
+// The secret is only returned once.
+type IssuedAPIKey struct {
+	store.APIKey
+	Key string `json:"key"`
+}
+
+type IssuedAPIKeyResponse struct {
+	Data IssuedAPIKey `json:"data"`
+}
+
+type APIKeyDetailsResponse struct {
+	Data store.APIKey `json:"data"`
+}
+
+type APIKeyListResponse struct {
+	Data []*store.APIKey `json:"data"`
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// withScope wraps a handler, so that it requires an API key or token with the given scope.
+// Requests are rejected with a 401 if the credentials are missing or invalid,
+// or a 403 if the credentials do not grant the scope.
+// If tokens can't be verified (eg. the identity provider is down), requests are rejected with a 503.
+//
+// The authenticated principal is available to handlers, using auth.FromContext.
+// If authentication is disabled, all requests are allowed, and access the default tenant.
+func (router *SensorRouter) withScope(scope string, f JSONHandlerFunc) JSONHandlerFunc {
+	return func(r *http.Request) (interface{}, int, error) {
+		if router.apiKeys == nil && router.tokens == nil {
+			return f(r)
+		}
+
+		credentials := credentialsFromRequest(r)
+		if credentials == "" {
+			return nil, http.StatusUnauthorized, router.missingCredentialsError()
+		}
+		principal, err := router.authenticate(r.Context(), credentials)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, http.StatusUnauthorized, err
+		}
+		if errors.Is(err, auth.ErrKeysUnavailable) {
+			router.logger().ErrorContext(r.Context(), "failed to authenticate request", "error", err)
+			return nil, http.StatusServiceUnavailable, errors.New("authentication is temporarily unavailable")
+		}
+		if err != nil {
+			router.logger().ErrorContext(r.Context(), "failed to authenticate request", "error", err)
+			return nil, http.StatusInternalServerError, errors.New("internal server error")
+		}
+
+		if principal.TenantID == "" {
+			return nil, http.StatusForbidden, errors.New("forbidden: credentials do not belong to a tenant")
+		}
+		if !principal.HasScope(scope) {
+			return nil, http.StatusForbidden, fmt.Errorf("forbidden: missing the required scope \"%s\"", scope)
+		}
+
+		return f(r.WithContext(auth.NewContext(r.Context(), principal)))
+	}
+}
+
+// authenticate checks an API key (which look like "sk_...") or a JWT bearer token
+func (router *SensorRouter) authenticate(ctx context.Context, credentials string) (*auth.Principal, error) {
+	if strings.HasPrefix(credentials, "sk_") {
+		if router.apiKeys == nil {
+			return nil, fmt.Errorf("%w: API keys are not accepted", auth.ErrInvalidCredentials)
+		}
+		principal, err := router.apiKeys.Authenticate(ctx, credentials)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, fmt.Errorf("%w: invalid API key", auth.ErrInvalidCredentials)
+		}
+		return principal, err
+	}
+
+	if router.tokens == nil {
+		return nil, fmt.Errorf("%w: invalid API key", auth.ErrInvalidCredentials)
+	}
+	return router.tokens.Authenticate(ctx, credentials)
+}
+
+func (router *SensorRouter) missingCredentialsError() error {
+	if router.tokens != nil {
+		return errors.New("missing credentials: use an \"Authorization: Bearer <token>\" header")
+	}
+	return errors.New("missing API key: use an \"Authorization: Bearer <key>\" or \"X-API-Key\" header")
+}
+
+// credentialsFromRequest reads an API key or token from the Authorization or X-API-Key header
+func credentialsFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, credentials, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(credentials)
+		}
+		return ""
+	}
+
+	return r.Header.Get("X-API-Key")
+}
+
+// tenantID returns the tenant of the authenticated principal.
+// Requests are in the default tenant if authentication is disabled.
+func tenantID(ctx context.Context) string {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return store.DefaultTenant
+	}
+	return principal.TenantID
+}
+
+// sensorStore returns the sensor store, scoped to the tenant of the request
+func (router *SensorRouter) sensorStore(r *http.Request) store.SensorStore {
+	return router.store.ForTenant(tenantID(r.Context()))
+}
+
+// audit logs a change made by the authenticated principal
+func (router *SensorRouter) audit(ctx context.Context, action string, resource string) {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		router.logger().InfoContext(ctx, "audit", "action", action, "resource", resource, "tenant_id", store.DefaultTenant, "subject", "anonymous")
+		return
+	}
+	router.logger().InfoContext(ctx, "audit", "action", action, "resource", resource,
+		"tenant_id", principal.TenantID, "subject", principal.Subject, "subject_name", principal.Name)
+}
+
+// newJWTValidator creates a JWT validator.
+// Returns nil if neither a JWKS URL nor a JWKS file is configured.
+func newJWTValidator(cfg config.JWT) (*auth.JWTValidator, error) {
+	var keys auth.KeySource
+	if cfg.JWKSURL != "" {
+		remoteKeys := auth.NewRemoteKeySource(cfg.JWKSURL, &http.Client{Timeout: 10 * time.Second, Transport: &tracing.Transport{}})
+		remoteKeys.CacheTTL = cfg.JWKSCacheTTL
+		keys = remoteKeys
+	} else if cfg.JWKSFile != "" {
+		fileKeys, err := auth.LoadJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = fileKeys
+	} else {
+		return nil, nil
+	}
+
+	validator := auth.NewJWTValidator(keys)
+	validator.Issuer = cfg.Issuer
+	validator.Audience = cfg.Audience
+	validator.RolesClaim = cfg.RolesClaim
+	validator.TenantClaim = cfg.TenantClaim
+	validator.DefaultTenant = cfg.DefaultTenant
+	if cfg.RoleScopes != "" {
+		var err error
+		validator.RoleScopes, err = parseRoleScopes(cfg.RoleScopes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return validator, nil
+}
+
+// parseRoleScopes parses a role to scope mapping,
+// eg. "sensor-viewer=sensors:read;sensor-editor=sensors:read,sensors:write"
+func parseRoleScopes(value string) (map[string][]string, error) {
+	roleScopes := make(map[string][]string)
+	for _, mapping := range strings.Split(value, ";") {
+		if strings.TrimSpace(mapping) == "" {
+			continue
+		}
+		role, scopes, ok := strings.Cut(mapping, "=")
+		if !ok || strings.TrimSpace(role) == "" {
+			return nil, fmt.Errorf("invalid JWT_ROLE_SCOPES \"%s\": must be formatted like \"role=scope,scope;role=scope\"", mapping)
+		}
+		for _, scope := range strings.Split(scopes, ",") {
+			scope = strings.TrimSpace(scope)
+			if !auth.ValidScope(scope) {
+				return nil, fmt.Errorf("invalid scope \"%s\" in JWT_ROLE_SCOPES: must be one of %s", scope, strings.Join(auth.Scopes, ", "))
+			}
+			roleScopes[strings.TrimSpace(role)] = append(roleScopes[strings.TrimSpace(role)], scope)
+		}
+	}
+
+	return roleScopes, nil
+}
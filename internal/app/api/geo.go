@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/metrics"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"io"
+	"net/http"
+)
+
+// geoServices is the configured geocoder
+type geoServices struct {
+	// Cached geocoder, used by handlers
+	cached geo.GeoService
+	// The providers, without the cache, for health checks
+	providers geo.GeoService
+	// Resources to release when the geocoder is no longer used (eg. the persistent cache's connection pool)
+	closers []io.Closer
+}
+
+// newGeoService creates a cached geocoder, using the configured providers.
+// Provider calls and cache lookups are recorded in the metrics registry, and traced.
+// Returns nil if no providers are configured.
+func newGeoService(cfg *config.Config, registry *metrics.Registry) (*geoServices, error) {
+	providerNames := cfg.GeocoderProviders()
+	if len(providerNames) == 0 {
+		return nil, nil
+	}
+
+	geoMetrics := metrics.NewGeoMetrics(registry)
+	var providers []geo.FallbackProvider
+	for _, name := range providerNames {
+		provider, err := newGeoProvider(name, cfg.Geocoder)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, geo.FallbackProvider{
+			Name:    name,
+			Service: geoMetrics.Instrument(name, geo.NewTracedGeoService(name, provider)),
+			Timeout: cfg.Geocoder.Timeout,
+		})
+	}
+
+	cacheOpts := geo.CacheOptions{TTL: cfg.Geocoder.CacheTTL}
+	var closers []io.Closer
+	if cfg.Geocoder.CachePersistent {
+		persistentCache, err := geo.NewPostgresGeocodeCache(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		cacheOpts.Persistent = persistentCache
+		closers = append(closers, persistentCache)
+	}
+
+	fallback := geo.NewFallbackGeoService(providers...)
+	cache := geo.NewCachingGeoService(fallback, cacheOpts)
+	metrics.RegisterGeocodeCache(registry, cache)
+
+	return &geoServices{
+		cached:    geo.NewTracedGeoService("GeoService", cache),
+		providers: fallback,
+		closers:   closers,
+	}, nil
+}
+
+// newGeoProvider creates a single geocoding provider, by name
+func newGeoProvider(name string, cfg config.Geocoder) (geo.GeoService, error) {
+	// Record a span for each request to the provider, and propagate the trace context
+	opts := []geo.ProviderOption{geo.WithHTTPClient(&http.Client{Transport: &tracing.Transport{}})}
+	if cfg.UserAgent != "" {
+		opts = append(opts, geo.WithUserAgent(cfg.UserAgent))
+	}
+	withBaseURL := func(baseURL string) []geo.ProviderOption {
+		if baseURL == "" {
+			return opts
+		}
+		return append(opts, geo.WithBaseURL(baseURL))
+	}
+
+	switch name {
+	case "mapbox":
+		if cfg.Mapbox.AccessToken == "" {
+			return nil, fmt.Errorf("must set MAPBOX_ACCESS_TOKEN to use the mapbox geocoder")
+		}
+		return geo.NewMapboxGeoService(cfg.Mapbox.AccessToken, withBaseURL(cfg.Mapbox.URL)...), nil
+	case "nominatim":
+		return geo.NewNominatimGeoService(withBaseURL(cfg.Nominatim.URL)...), nil
+	case "photon":
+		return geo.NewPhotonGeoService(withBaseURL(cfg.Photon.URL)...), nil
+	case "pelias":
+		return geo.NewPeliasGeoService(cfg.Pelias.APIKey, withBaseURL(cfg.Pelias.URL)...), nil
+	case "gazetteer":
+		return newGazetteerGeoService(cfg.Gazetteer)
+	default:
+		return nil, fmt.Errorf("invalid geocoder \"%s\": must be one of mapbox, nominatim, photon, pelias, gazetteer", name)
+	}
+}
+
+// newGazetteerGeoService loads an offline geocoder from a GeoNames file
+func newGazetteerGeoService(cfg config.Gazetteer) (geo.GeoService, error) {
+	if cfg.File == "" {
+		return nil, fmt.Errorf("must set GAZETTEER_FILE to use the gazetteer geocoder")
+	}
+
+	gazetteer, err := geo.LoadGazetteer(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+
+	// Region names are optional, and allow queries like "Springfield, Illinois"
+	if cfg.Admin1File != "" {
+		if err := gazetteer.LoadAdmin1Names(cfg.Admin1File); err != nil {
+			return nil, err
+		}
+	}
+
+	return gazetteer, nil
+}
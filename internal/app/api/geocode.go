@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SuggestPlacesHandler returns candidate places for a partial place name,
+// eg. to autocomplete a map search box
+func (router *SensorRouter) SuggestPlacesHandler(r *http.Request) (interface{}, int, error) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		return nil, http.StatusBadRequest, errors.New("missing required \"q\" param")
+	}
+
+	// Parse optional location to bias results towards, eg 45.12,-90.34
+	var near *geo.Point
+	if nearParam := query.Get("near"); nearParam != "" {
+		lat, lon, ok := parseLatLon(nearParam)
+		if !ok {
+			return nil, http.StatusBadRequest,
+				errors.New("invalid value for \"near\": must be formatted like \"45.12,-90.34\"")
+		}
+		near = &geo.Point{Lat: lat, Lon: lon}
+	}
+
+	countSensors := false
+	if countParam := query.Get("sensor_counts"); countParam != "" {
+		var err error
+		countSensors, err = strconv.ParseBool(countParam)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("invalid value for \"sensor_counts\": must be \"true\" or \"false\"")
+		}
+	}
+
+	if router.geo == nil {
+		return nil, http.StatusNotImplemented, errors.New("geocoding is not configured")
+	}
+
+	suggestions, err := router.geo.Suggest(r.Context(), q, near)
+	if errors.Is(err, geo.ErrUnsupported) {
+		return nil, http.StatusNotImplemented, errors.New("place suggestions are not supported by the configured geocoding providers")
+	}
+	if err != nil {
+		router.logger().ErrorContext(r.Context(), "failed to suggest places", "query", q, "error", err)
+		return nil, http.StatusBadGateway, errors.New("failed to suggest places")
+	}
+
+	sensors := router.sensorStore(r)
+	res := PlaceSuggestionListResponse{Data: make([]PlaceSuggestion, len(suggestions))}
+	for i, suggestion := range suggestions {
+		res.Data[i].Suggestion = suggestion
+
+		// Count sensors within the place's bounding box, if it has one
+		if countSensors && suggestion.BBox != nil {
+			bbox := suggestion.BBox
+			count, err := sensors.CountWithinBounds(r.Context(), bbox.MinLat(), bbox.MinLon(), bbox.MaxLat(), bbox.MaxLon())
+			if err != nil {
+				router.logger().ErrorContext(r.Context(), "failed to count sensors within place", "place", suggestion.Name, "error", err)
+				return nil, http.StatusInternalServerError, errors.New("internal server error")
+			}
+			res.Data[i].SensorCount = &count
+		}
+	}
+
+	return res, http.StatusOK, nil
+}
+
+// parseLatLon parses a "{lat},{lon}" string, eg 45.12,-90.34
+func parseLatLon(param string) (float64, float64, bool) {
+	match := latLonRegexp.FindStringSubmatch(param)
+	if len(match) != 3 {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(match[2], 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+
+	return lat, lon, true
+}
+
+type PlaceSuggestion struct {
+	geo.Suggestion
+	// Number of sensors within the place's bounding box, if requested
+	SensorCount *int `json:"sensor_count,omitempty"`
+}
+
+type PlaceSuggestionListResponse struct {
+	Data []PlaceSuggestion `json:"data"`
+}
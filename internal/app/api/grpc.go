@@ -0,0 +1,537 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	sensorsv1 "github.com/eschwartz/go-sensor-api/proto/sensors/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Radius of FindClosest calls, if the radius is omitted (as for GET /sensors/closest)
+const defaultRadiusMeters = 20000
+
+// Changes queued for each WatchChanges stream. Streams which fall further behind are ended.
+const watchBuffer = 256
+
+// grpcMethod is the scope and rate limit bucket of a SensorService method
+type grpcMethod struct {
+	scope  string
+	bucket string
+}
+
+// grpcMethods lists the SensorService methods. Other services (health checks and reflection) don't require credentials.
+var grpcMethods = map[string]grpcMethod{
+	sensorsv1.SensorService_Get_FullMethodName:          {auth.ScopeSensorsRead, standardRateLimit},
+	sensorsv1.SensorService_Create_FullMethodName:       {auth.ScopeSensorsWrite, standardRateLimit},
+	sensorsv1.SensorService_Update_FullMethodName:       {auth.ScopeSensorsWrite, standardRateLimit},
+	sensorsv1.SensorService_Delete_FullMethodName:       {auth.ScopeSensorsWrite, standardRateLimit},
+	sensorsv1.SensorService_FindClosest_FullMethodName:  {auth.ScopeSensorsRead, spatialRateLimit},
+	sensorsv1.SensorService_List_FullMethodName:         {auth.ScopeSensorsRead, standardRateLimit},
+	sensorsv1.SensorService_WatchChanges_FullMethodName: {auth.ScopeSensorsRead, standardRateLimit},
+}
+
+// GRPCServer creates a gRPC server for the SensorService, using the router's store, authentication,
+// rate limits and geocoder. It also serves gRPC health checks and reflection (eg. for grpcurl).
+func (router *SensorRouter) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(router.unaryInterceptor),
+		grpc.ChainStreamInterceptor(router.streamInterceptor),
+	}, opts...)
+	server := grpc.NewServer(opts...)
+	sensorsv1.RegisterSensorServiceServer(server, &sensorService{router: router})
+	healthpb.RegisterHealthServer(server, router.grpcHealth)
+	reflection.Register(server)
+	return server
+}
+
+// StartGRPC serves the gRPC API on the listener in the background, until ctx is cancelled.
+// Returns a channel which receives the result, once the server has stopped. It shuts down like Serve:
+//  1. health checks start failing, so load balancers stop sending new calls
+//  2. after the drain delay, WatchChanges streams are ended, and other calls
+//     have up to the shutdown timeout to complete
+//
+// Serve waits for the gRPC server to stop before closing the router's resources.
+// The server is registered before StartGRPC returns, so Serve can't miss it.
+func (router *SensorRouter) StartGRPC(ctx context.Context, listener net.Listener, cfg config.Server) <-chan error {
+	router.grpcServing.Add(1)
+	result := make(chan error, 1)
+	go func() {
+		defer router.grpcServing.Done()
+		result <- router.serveGRPC(ctx, listener, cfg)
+	}()
+	return result
+}
+
+func (router *SensorRouter) serveGRPC(ctx context.Context, listener net.Listener, cfg config.Server) error {
+	server := router.GRPCServer()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The server failed, before we were asked to stop
+		return err
+	case <-ctx.Done():
+	}
+
+	router.logger().Info("shutting down gRPC: failing health checks", "drain_delay", cfg.DrainDelay.String())
+	router.grpcHealth.Shutdown()
+	time.Sleep(cfg.DrainDelay)
+
+	router.logger().Info("shutting down gRPC: waiting for in-flight calls", "timeout", cfg.ShutdownTimeout.String())
+	router.grpcStoppingOnce.Do(func() { close(router.grpcStopping) })
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-time.After(cfg.ShutdownTimeout):
+		// Calls are still running, so cut them off
+		server.Stop()
+		return errors.New("failed to drain gRPC calls: timed out")
+	}
+}
+
+func (router *SensorRouter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := router.clock()
+	ctx, err := router.authorizeCall(ctx, info.FullMethod)
+	var res interface{}
+	if err == nil {
+		res, err = handler(ctx, req)
+	}
+	router.logCall(ctx, info.FullMethod, start, err)
+	return res, err
+}
+
+func (router *SensorRouter) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := router.clock()
+	ctx, err := router.authorizeCall(ss.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+	router.logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// contextStream is a grpc.ServerStream with a different context (eg. with the authenticated principal)
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorizeCall checks a SensorService call's rate limits and authenticates it, as for REST requests (see withAccessControl).
+// Returns a context with the authenticated principal.
+func (router *SensorRouter) authorizeCall(ctx context.Context, fullMethod string) (context.Context, error) {
+	method, ok := grpcMethods[fullMethod]
+	if !ok {
+		return ctx, nil
+	}
+
+	// Limit by IP address before authenticating, so that invalid credentials can't be tried without limit
+	if err := router.allowCall(ctx, method.bucket, callClientAddr(ctx)); err != nil {
+		return ctx, err
+	}
+
+	if router.apiKeys != nil || router.tokens != nil {
+		credentials := credentialsFromMetadata(ctx)
+		if credentials == "" {
+			return ctx, status.Error(codes.Unauthenticated, router.missingCredentialsError().Error())
+		}
+		principal, err := router.authenticate(ctx, credentials)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return ctx, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if errors.Is(err, auth.ErrKeysUnavailable) {
+			router.logger().ErrorContext(ctx, "failed to authenticate call", "error", err)
+			return ctx, status.Error(codes.Unavailable, "authentication is temporarily unavailable")
+		}
+		if err != nil {
+			router.logger().ErrorContext(ctx, "failed to authenticate call", "error", err)
+			return ctx, status.Error(codes.Internal, "internal server error")
+		}
+
+		if principal.TenantID == "" {
+			return ctx, status.Error(codes.PermissionDenied, "forbidden: credentials do not belong to a tenant")
+		}
+		if !principal.HasScope(method.scope) {
+			return ctx, status.Errorf(codes.PermissionDenied, "forbidden: missing the required scope \"%s\"", method.scope)
+		}
+		ctx = auth.NewContext(ctx, principal)
+
+		if err := router.allowCall(ctx, method.bucket, principalClientID(principal)); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+// allowCall takes a call from the client's bucket. Returns a ResourceExhausted error if the client is over the limit.
+func (router *SensorRouter) allowCall(ctx context.Context, bucket string, clientID string) error {
+	limiter := router.rateLimits[bucket]
+	if limiter == nil {
+		return nil
+	}
+	result, err := limiter.Allow(ctx, bucket+":"+clientID)
+	if err != nil {
+		// An unavailable rate limit store should not take down the API
+		router.logger().ErrorContext(ctx, "failed to check rate limit, allowing call", "bucket", bucket, "error", err)
+		return nil
+	}
+	if !result.Allowed {
+		retryAfter := ceilSeconds(result.RetryAfter)
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded: retry in %ds", retryAfter)
+	}
+	return nil
+}
+
+// credentialsFromMetadata reads an API key or token from the authorization or x-api-key metadata
+func credentialsFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		scheme, credentials, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(credentials)
+		}
+		return ""
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// callClientAddr identifies the IP address making a call, for rate limiting
+func callClientAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return "ip:unknown"
+}
+
+// logCall writes an access log record for a gRPC call
+func (router *SensorRouter) logCall(ctx context.Context, fullMethod string, start time.Time, err error) {
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	router.logger().InfoContext(ctx, "grpc call",
+		"method", fullMethod,
+		"code", status.Code(err).String(),
+		"duration_ms", float64(router.clock().Sub(start).Microseconds())/1000,
+		"remote_addr", remoteAddr,
+	)
+}
+
+// sensorService implements the SensorService, over the router's store
+type sensorService struct {
+	sensorsv1.UnimplementedSensorServiceServer
+	router *SensorRouter
+}
+
+// sensorStore returns the sensor store, scoped to the tenant of the call
+func (s *sensorService) sensorStore(ctx context.Context) store.SensorStore {
+	return s.router.store.ForTenant(tenantID(ctx))
+}
+
+func (s *sensorService) Get(ctx context.Context, req *sensorsv1.GetSensorRequest) (*sensorsv1.Sensor, error) {
+	sensor, err := s.sensorStore(ctx).GetByName(ctx, req.GetName())
+	if err != nil {
+		return nil, s.error(ctx, "failed to retrieve sensor", err)
+	}
+	if sensor == nil {
+		return nil, status.Error(codes.NotFound, (&store.MissingResourceError{ID: req.GetName(), ResourceType: "sensor"}).Error())
+	}
+
+	return sensorToProto(sensor), nil
+}
+
+func (s *sensorService) Create(ctx context.Context, req *sensorsv1.CreateSensorRequest) (*sensorsv1.Sensor, error) {
+	sensor, err := sensorFromProto(req.GetSensor())
+	if err != nil {
+		return nil, s.error(ctx, "invalid sensor", err)
+	}
+
+	// Lookup the sensor's place name (if enabled)
+	sensors := s.sensorStore(ctx)
+	if err := s.router.enrichNewSensor(ctx, sensors, sensor); err != nil {
+		return nil, s.error(ctx, "failed to store sensor", err)
+	}
+
+	created, err := sensors.Create(ctx, sensor)
+	if err != nil {
+		return nil, s.error(ctx, "failed to store sensor", err)
+	}
+	s.router.audit(ctx, "created", "sensor "+created.Name)
+
+	return sensorToProto(created), nil
+}
+
+func (s *sensorService) Update(ctx context.Context, req *sensorsv1.UpdateSensorRequest) (*sensorsv1.Sensor, error) {
+	sensor, err := sensorFromProto(req.GetSensor())
+	if err != nil {
+		return nil, s.error(ctx, "invalid sensor", err)
+	}
+	sensors := s.sensorStore(ctx)
+
+	// Lookup the sensor's place name, if enabled and the location has changed
+	if err := s.router.enrichUpdatedSensor(ctx, sensors, req.GetName(), sensor); err != nil {
+		return nil, s.error(ctx, "failed to update sensor", err)
+	}
+
+	updated, err := sensors.UpdateByName(ctx, req.GetName(), sensor)
+	if err != nil {
+		return nil, s.error(ctx, "failed to update sensor", err)
+	}
+	s.router.audit(ctx, "updated", "sensor "+req.GetName())
+
+	return sensorToProto(updated), nil
+}
+
+func (s *sensorService) Delete(ctx context.Context, req *sensorsv1.DeleteSensorRequest) (*sensorsv1.Sensor, error) {
+	deleted, err := s.sensorStore(ctx).DeleteByName(ctx, req.GetName())
+	if err != nil {
+		return nil, s.error(ctx, "failed to delete sensor", err)
+	}
+	s.router.audit(ctx, "deleted", "sensor "+req.GetName())
+
+	return sensorToProto(deleted), nil
+}
+
+func (s *sensorService) FindClosest(ctx context.Context, req *sensorsv1.FindClosestRequest) (*sensorsv1.FindClosestResponse, error) {
+	radius := int(req.GetRadiusMeters())
+	if radius == 0 {
+		radius = defaultRadiusMeters
+	}
+	if radius < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid radius_meters: must not be negative")
+	}
+
+	var lat, lon float64
+	switch location := req.GetLocation().(type) {
+	case *sensorsv1.FindClosestRequest_Coordinate:
+		lat, lon = location.Coordinate.GetLat(), location.Coordinate.GetLon()
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, status.Error(codes.InvalidArgument, "invalid coordinate: lat must be between -90 and 90, and lon between -180 and 180")
+		}
+	case *sensorsv1.FindClosestRequest_Place:
+		if s.router.geo == nil {
+			return nil, status.Error(codes.Unimplemented, "geocoding is not configured: use a coordinate")
+		}
+		var err error
+		lat, lon, err = s.router.geo.Geocode(ctx, location.Place)
+		if errors.Is(err, geo.ErrPlaceNotFound) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid place: no location found at \"%s\"", location.Place)
+		}
+		if err != nil {
+			s.router.logger().ErrorContext(ctx, "failed to geocode location", "location", location.Place, "error", err)
+			return nil, status.Error(codes.Unavailable, "failed to geocode location")
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "missing location: set a coordinate or place")
+	}
+
+	sensors, err := s.sensorStore(ctx).FindClosest(ctx, lat, lon, radius)
+	if err != nil {
+		return nil, s.error(ctx, "failed to find closest sensors", err)
+	}
+
+	res := &sensorsv1.FindClosestResponse{Sensors: make([]*sensorsv1.Sensor, len(sensors))}
+	for i, sensor := range sensors {
+		res.Sensors[i] = sensorToProto(sensor)
+	}
+	return res, nil
+}
+
+func (s *sensorService) List(req *sensorsv1.ListSensorsRequest, stream sensorsv1.SensorService_ListServer) error {
+	ctx := stream.Context()
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		return status.Error(codes.InvalidArgument, "invalid limit: must not be negative")
+	}
+
+	// Read a page at a time, so that large tenants aren't loaded into memory at once
+	sensors := s.sensorStore(ctx)
+	after := req.GetStartAfter()
+	sent := 0
+	for {
+		pageSize := maxListLimit
+		if limit > 0 && limit-sent < pageSize {
+			pageSize = limit - sent
+		}
+		if pageSize == 0 {
+			return nil
+		}
+
+		page, err := sensors.List(ctx, after, pageSize)
+		if err != nil {
+			return s.error(ctx, "failed to list sensors", err)
+		}
+		for _, sensor := range page {
+			if err := stream.Send(sensorToProto(sensor)); err != nil {
+				return err
+			}
+		}
+		sent += len(page)
+		if len(page) < pageSize {
+			return nil
+		}
+		after = page[len(page)-1].Name
+	}
+}
+
+func (s *sensorService) WatchChanges(req *sensorsv1.WatchChangesRequest, stream sensorsv1.SensorService_WatchChangesServer) error {
+	ctx := stream.Context()
+	watcher := s.router.changes.Watch(tenantID(ctx), watchBuffer)
+	defer watcher.Close()
+
+	// Send headers once the watch has started, so clients know that later changes will be sent
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event := <-watcher.Events():
+			if err := stream.Send(changeToProto(event)); err != nil {
+				return err
+			}
+		case <-watcher.Done():
+			if watcher.Lagged() {
+				return status.Error(codes.Aborted, "missed changes: the client fell behind. Watch again, and reload the sensors.")
+			}
+			return nil
+		case <-s.router.grpcStopping:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// error converts an error from the store to a gRPC status.
+// Unexpected errors are logged, and reported as internal errors.
+func (s *sensorService) error(ctx context.Context, msg string, err error) error {
+	var validationErr *store.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationStatus(validationErr)
+	}
+	var missingErr *store.MissingResourceError
+	if errors.As(err, &missingErr) {
+		return status.Error(codes.NotFound, missingErr.Error())
+	}
+	var duplicateErr *store.DuplicateResourceError
+	if errors.As(err, &duplicateErr) {
+		return status.Error(codes.AlreadyExists, duplicateErr.Error())
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	s.router.logger().ErrorContext(ctx, msg, "error", err)
+	return status.Error(codes.Internal, msg+": internal server error")
+}
+
+// validationStatus reports every invalid field, as a BadRequest detail
+func validationStatus(err *store.ValidationError) error {
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range err.Fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			// JSON pointers to fields of the sensor, eg. "/tags/1" is "sensor.tags[1]"
+			Field:       fieldPath(field.Pointer),
+			Description: field.Detail,
+		})
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(badRequest)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
+
+// fieldPath converts a JSON pointer to a sensor field (eg. "/tags/1") to a protobuf field path (eg. "sensor.tags[1]")
+func fieldPath(pointer string) string {
+	path := "sensor"
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if _, err := strconv.Atoi(part); err == nil {
+			path += "[" + part + "]"
+		} else {
+			path += "." + part
+		}
+	}
+	return path
+}
+
+// sensorFromProto validates a sensor from a request (eg. to create or update it),
+// and returns it as it should be stored
+func sensorFromProto(pb *sensorsv1.Sensor) (*store.Sensor, error) {
+	if pb == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing sensor")
+	}
+
+	sensor := &store.Sensor{Name: pb.GetName(), Lat: pb.GetLat(), Lon: pb.GetLon(), Tags: pb.GetTags()}
+	if err := sensor.Validate(); err != nil {
+		return nil, err
+	}
+	sensor.Normalize()
+	return sensor, nil
+}
+
+func sensorToProto(sensor *store.Sensor) *sensorsv1.Sensor {
+	return &sensorsv1.Sensor{
+		Id:        int64(sensor.ID),
+		Name:      sensor.Name,
+		Lat:       sensor.Lat,
+		Lon:       sensor.Lon,
+		Tags:      sensor.Tags,
+		PlaceName: sensor.PlaceName,
+	}
+}
+
+var changeTypes = map[string]sensorsv1.SensorChange_Type{
+	store.SensorCreatedEvent: sensorsv1.SensorChange_CREATED,
+	store.SensorUpdatedEvent: sensorsv1.SensorChange_UPDATED,
+	store.SensorDeletedEvent: sensorsv1.SensorChange_DELETED,
+}
+
+func changeToProto(event *store.OutboxEvent) *sensorsv1.SensorChange {
+	return &sensorsv1.SensorChange{
+		Id:     event.ID,
+		Type:   changeTypes[event.Type],
+		Sensor: sensorToProto(event.Sensor),
+		Time:   timestamppb.New(event.CreatedAt),
+	}
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/ratelimit"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	sensorsv1 "github.com/eschwartz/go-sensor-api/proto/sensors/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newGRPCConn serves the router's gRPC API over an in-memory connection, and returns a client connection to it
+func newGRPCConn(t *testing.T, router *SensorRouter) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := router.GRPCServer()
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func newGRPCClient(t *testing.T, router *SensorRouter) sensorsv1.SensorServiceClient {
+	return sensorsv1.NewSensorServiceClient(newGRPCConn(t, router))
+}
+
+// withAPIKey returns a context which sends an API key with each call
+func withAPIKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key)
+}
+
+func requireCode(t *testing.T, code codes.Code, err error) {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+}
+
+func TestGRPC_Sensors(t *testing.T) {
+	client := newGRPCClient(t, NewSensorRouter(WithStore(store.NewMemorySensorStore())))
+	ctx := context.Background()
+
+	created, err := client.Create(ctx, &sensorsv1.CreateSensorRequest{Sensor: &sensorsv1.Sensor{
+		Name: " abc123 ", Lat: 44.9, Lon: -93.2, Tags: []string{"x", "y", "x"},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), created.Id)
+	require.Equal(t, "abc123", created.Name)
+	require.Equal(t, []string{"x", "y"}, created.Tags)
+
+	sensor, err := client.Get(ctx, &sensorsv1.GetSensorRequest{Name: "abc123"})
+	require.NoError(t, err)
+	require.Equal(t, "abc123", sensor.Name)
+	require.Equal(t, 44.9, sensor.Lat)
+
+	// Rename the sensor
+	updated, err := client.Update(ctx, &sensorsv1.UpdateSensorRequest{Name: "abc123", Sensor: &sensorsv1.Sensor{
+		Name: "xyz789", Lat: 45, Lon: -93, Tags: []string{"z"},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), updated.Id)
+	require.Equal(t, "xyz789", updated.Name)
+
+	_, err = client.Get(ctx, &sensorsv1.GetSensorRequest{Name: "abc123"})
+	requireCode(t, codes.NotFound, err)
+	require.Equal(t, "no sensor resource exists: abc123", status.Convert(err).Message())
+	_, err = client.Update(ctx, &sensorsv1.UpdateSensorRequest{Name: "abc123", Sensor: &sensorsv1.Sensor{Name: "abc123"}})
+	requireCode(t, codes.NotFound, err)
+	_, err = client.Create(ctx, &sensorsv1.CreateSensorRequest{Sensor: &sensorsv1.Sensor{Name: "xyz789", Lat: 1, Lon: 2}})
+	requireCode(t, codes.AlreadyExists, err)
+	require.Equal(t, "a sensor resource already exists: xyz789", status.Convert(err).Message())
+
+	deleted, err := client.Delete(ctx, &sensorsv1.DeleteSensorRequest{Name: "xyz789"})
+	require.NoError(t, err)
+	require.Equal(t, "xyz789", deleted.Name)
+	_, err = client.Delete(ctx, &sensorsv1.DeleteSensorRequest{Name: "xyz789"})
+	requireCode(t, codes.NotFound, err)
+}
+
+func TestGRPC_ValidationError(t *testing.T) {
+	client := newGRPCClient(t, NewSensorRouter(WithStore(store.NewMemorySensorStore())))
+
+	_, err := client.Create(context.Background(), &sensorsv1.CreateSensorRequest{Sensor: &sensorsv1.Sensor{
+		Name: "", Lat: 9999, Tags: []string{"a", " "},
+	}})
+	requireCode(t, codes.InvalidArgument, err)
+	st := status.Convert(err)
+	require.Equal(t, "invalid sensor: name must not be empty; lat must be between -90 and 90; tags/1 must not be blank", st.Message())
+
+	// Every invalid field is reported
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	var fields []string
+	for _, violation := range badRequest.FieldViolations {
+		fields = append(fields, violation.Field+" "+violation.Description)
+	}
+	require.Equal(t, []string{
+		"sensor.name must not be empty",
+		"sensor.lat must be between -90 and 90",
+		"sensor.tags[1] must not be blank",
+	}, fields)
+
+	_, err = client.Create(context.Background(), &sensorsv1.CreateSensorRequest{})
+	requireCode(t, codes.InvalidArgument, err)
+	require.Equal(t, "missing sensor", status.Convert(err).Message())
+}
+
+func TestGRPC_FindClosest(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+	client := newGRPCClient(t, router)
+	ctx := context.Background()
+	for _, sensor := range []*sensorsv1.Sensor{
+		{Name: "MPLS", Lat: 44.97, Lon: -93.26},
+		{Name: "STP", Lat: 44.95, Lon: -93.09},
+		{Name: "CHI", Lat: 41.86, Lon: -87.68},
+	} {
+		_, err := client.Create(ctx, &sensorsv1.CreateSensorRequest{Sensor: sensor})
+		require.NoError(t, err)
+	}
+
+	res, err := client.FindClosest(ctx, &sensorsv1.FindClosestRequest{
+		Location: &sensorsv1.FindClosestRequest_Coordinate{Coordinate: &sensorsv1.Coordinate{Lat: 44.96, Lon: -93.1}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Sensors, 2)
+	require.Equal(t, "STP", res.Sensors[0].Name)
+	require.Equal(t, "MPLS", res.Sensors[1].Name)
+
+	res, err = client.FindClosest(ctx, &sensorsv1.FindClosestRequest{
+		Location:     &sensorsv1.FindClosestRequest_Coordinate{Coordinate: &sensorsv1.Coordinate{Lat: 44.96, Lon: -93.1}},
+		RadiusMeters: 1000000,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Sensors, 3)
+
+	_, err = client.FindClosest(ctx, &sensorsv1.FindClosestRequest{})
+	requireCode(t, codes.InvalidArgument, err)
+	_, err = client.FindClosest(ctx, &sensorsv1.FindClosestRequest{
+		Location: &sensorsv1.FindClosestRequest_Coordinate{Coordinate: &sensorsv1.Coordinate{Lat: 100}},
+	})
+	requireCode(t, codes.InvalidArgument, err)
+
+	// Geocoding is not configured
+	_, err = client.FindClosest(ctx, &sensorsv1.FindClosestRequest{Location: &sensorsv1.FindClosestRequest_Place{Place: "Minneapolis"}})
+	requireCode(t, codes.Unimplemented, err)
+
+	router.geo = geo.NewGazetteerGeoService([]*geo.GazetteerEntry{
+		{Name: "Minneapolis", Lat: 44.98, Lon: -93.27, CountryCode: "US", Admin1Name: "Minnesota", Population: 425000},
+	})
+	res, err = client.FindClosest(ctx, &sensorsv1.FindClosestRequest{
+		Location:     &sensorsv1.FindClosestRequest_Place{Place: "Minneapolis"},
+		RadiusMeters: 10000,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Sensors, 1)
+	require.Equal(t, "MPLS", res.Sensors[0].Name)
+
+	_, err = client.FindClosest(ctx, &sensorsv1.FindClosestRequest{Location: &sensorsv1.FindClosestRequest_Place{Place: "Atlantis"}})
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestGRPC_List(t *testing.T) {
+	sensorStore := store.NewMemorySensorStore()
+	for _, name := range []string{"c", "a", "e", "b", "d"} {
+		_, err := sensorStore.Create(context.Background(), &store.Sensor{Name: name, Tags: []string{}})
+		require.NoError(t, err)
+	}
+	client := newGRPCClient(t, NewSensorRouter(WithStore(sensorStore)))
+
+	list := func(req *sensorsv1.ListSensorsRequest) []string {
+		stream, err := client.List(context.Background(), req)
+		require.NoError(t, err)
+		var names []string
+		for {
+			sensor, err := stream.Recv()
+			if err == io.EOF {
+				return names
+			}
+			require.NoError(t, err)
+			names = append(names, sensor.Name)
+		}
+	}
+
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, list(&sensorsv1.ListSensorsRequest{}))
+	require.Equal(t, []string{"a", "b"}, list(&sensorsv1.ListSensorsRequest{Limit: 2}))
+	// Resume after the last sensor received
+	require.Equal(t, []string{"c", "d", "e"}, list(&sensorsv1.ListSensorsRequest{StartAfter: "b"}))
+	require.Empty(t, list(&sensorsv1.ListSensorsRequest{StartAfter: "e"}))
+
+	stream, err := client.List(context.Background(), &sensorsv1.ListSensorsRequest{Limit: -1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	requireCode(t, codes.InvalidArgument, err)
+}
+
+func TestGRPC_WatchChanges(t *testing.T) {
+	router, key := newAuthRouter(t, auth.ScopeSensorsRead, auth.ScopeSensorsWrite)
+	_, otherKey, err := router.apiKeys.Issue(context.Background(), "other", "test", []string{auth.ScopeSensorsWrite})
+	require.NoError(t, err)
+	client := newGRPCClient(t, router)
+	ctx, cancel := context.WithCancel(withAPIKey(context.Background(), key))
+	defer cancel()
+
+	stream, err := client.WatchChanges(ctx, &sensorsv1.WatchChangesRequest{})
+	require.NoError(t, err)
+	// Wait for the watch to start
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	// Changes made with either API are sent
+	_, err = client.Create(ctx, &sensorsv1.CreateSensorRequest{Sensor: &sensorsv1.Sensor{Name: "abc123", Lat: 44.9, Lon: -93.2}})
+	require.NoError(t, err)
+	rr := httpRequestWithHeaders(t, router, "PUT", "/sensors/abc123", `{"name":"abc123","lat":45,"lon":-93,"tags":["x"]}`,
+		map[string]string{"Content-Type": "application/json", "X-API-Key": key})
+	require.Equal(t, http.StatusOK, rr.Code)
+	// Changes to other tenants' sensors are not
+	_, err = client.Create(withAPIKey(context.Background(), otherKey), &sensorsv1.CreateSensorRequest{Sensor: &sensorsv1.Sensor{Name: "other"}})
+	require.NoError(t, err)
+	_, err = client.Delete(ctx, &sensorsv1.DeleteSensorRequest{Name: "abc123"})
+	require.NoError(t, err)
+
+	var changes []string
+	var lastID int64
+	for i := 0; i < 3; i++ {
+		change, err := stream.Recv()
+		require.NoError(t, err)
+		require.Greater(t, change.Id, lastID)
+		lastID = change.Id
+		require.NotNil(t, change.Time)
+		changes = append(changes, change.Type.String()+" "+change.Sensor.Name)
+	}
+	require.Equal(t, []string{"CREATED abc123", "UPDATED abc123", "DELETED abc123"}, changes)
+
+	// The stream ends when the client cancels it
+	cancel()
+	_, err = stream.Recv()
+	requireCode(t, codes.Canceled, err)
+}
+
+func TestGRPC_Authentication(t *testing.T) {
+	router, readKey := newAuthRouter(t, auth.ScopeSensorsRead)
+	client := newGRPCClient(t, router)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, &sensorsv1.GetSensorRequest{Name: "abc123"})
+	requireCode(t, codes.Unauthenticated, err)
+	require.Equal(t, "missing API key: use an \"Authorization: Bearer <key>\" or \"X-API-Key\" header", status.Convert(err).Message())
+
+	_, err = client.Get(withAPIKey(ctx, "sk_not-a-real-key"), &sensorsv1.GetSensorRequest{Name: "abc123"})
+	requireCode(t, codes.Unauthenticated, err)
+
+	// Keys are accepted as x-api-key metadata, too
+	_, err = client.Get(metadata.AppendToOutgoingContext(ctx, "x-api-key", readKey), &sensorsv1.GetSensorRequest{Name: "abc123"})
+	requireCode(t, codes.NotFound, err)
+
+	_, err = client.Create(withAPIKey(ctx, readKey), &sensorsv1.CreateSensorRequest{Sensor: &sensorsv1.Sensor{Name: "abc123"}})
+	requireCode(t, codes.PermissionDenied, err)
+	require.Equal(t, "forbidden: missing the required scope \"sensors:write\"", status.Convert(err).Message())
+
+	// Streams are authenticated too
+	stream, err := client.List(ctx, &sensorsv1.ListSensorsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	requireCode(t, codes.Unauthenticated, err)
+
+	// Health checks don't require a key
+	res, err := healthpb.NewHealthClient(newGRPCConn(t, router)).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+}
+
+func TestGRPC_RateLimit(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithRateLimits(nil, ratelimit.NewMemoryLimiter(1, 1)),
+	)
+	client := newGRPCClient(t, router)
+	ctx := context.Background()
+	req := &sensorsv1.FindClosestRequest{Location: &sensorsv1.FindClosestRequest_Coordinate{Coordinate: &sensorsv1.Coordinate{}}}
+
+	_, err := client.FindClosest(ctx, req)
+	require.NoError(t, err)
+	var header metadata.MD
+	_, err = client.FindClosest(ctx, req, grpc.Header(&header))
+	requireCode(t, codes.ResourceExhausted, err)
+	require.Equal(t, []string{"1"}, header.Get("retry-after"))
+
+	// Other calls use the standard limit
+	_, err = client.Get(ctx, &sensorsv1.GetSensorRequest{Name: "abc123"})
+	requireCode(t, codes.NotFound, err)
+}
+
+func TestGRPC_RateLimitInvalidCredentials(t *testing.T) {
+	router, _ := newAuthRouter(t, auth.ScopeSensorsRead)
+	router.rateLimits = map[string]ratelimit.Limiter{
+		standardRateLimit: ratelimit.NewMemoryLimiter(0.1, 2),
+	}
+	client := newGRPCClient(t, router)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "sk_0123456789abcdef_0123")
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(ctx, &sensorsv1.GetSensorRequest{Name: "abc123"})
+		requireCode(t, codes.Unauthenticated, err)
+	}
+	_, err := client.Get(ctx, &sensorsv1.GetSensorRequest{Name: "abc123"})
+	requireCode(t, codes.ResourceExhausted, err)
+}
+
+func TestGRPC_HealthAndReflection(t *testing.T) {
+	conn := newGRPCConn(t, NewSensorRouter(WithStore(store.NewMemorySensorStore())))
+	ctx := context.Background()
+
+	health := healthpb.NewHealthClient(conn)
+	res, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: "sensors.v1.SensorService"})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+
+	// List the services, as grpcurl does
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	reflectionRes, err := stream.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, service := range reflectionRes.GetListServicesResponse().Service {
+		services = append(services, service.Name)
+	}
+	require.Contains(t, services, "sensors.v1.SensorService")
+	require.Contains(t, services, "grpc.health.v1.Health")
+}
+
+func TestStartGRPC_Shutdown(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+	listener := bufconn.Listen(1024 * 1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := router.StartGRPC(ctx, listener, config.Server{DrainDelay: 50 * time.Millisecond, ShutdownTimeout: time.Second})
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := sensorsv1.NewSensorServiceClient(conn).WatchChanges(context.Background(), &sensorsv1.WatchChangesRequest{})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	// Health checks fail while draining, then watches are ended, and the server stops
+	cancel()
+	require.Eventually(t, func() bool {
+		res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil && res.Status == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 5*time.Millisecond)
+
+	_, err = stream.Recv()
+	requireCode(t, codes.Unavailable, err)
+	require.Equal(t, "server is shutting down", status.Convert(err).Message())
+	require.NoError(t, <-served)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/health"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"net/http"
+)
+
+// newHealthChecker creates the readiness checks for the sensor store, and (if enabled) the geocoder.
+// The store is checked with whichever of Ping, CheckPostGIS and CheckSchemaVersion it implements.
+// geocoder should not be cached, so that the check reaches the providers. It may be nil, if geocoding is disabled.
+func newHealthChecker(cfg config.Health, sensorStore store.SensorStore, geocoder geo.GeoService) (*health.Checker, error) {
+	var checks []health.Check
+	if db, ok := sensorStore.(interface {
+		Ping(ctx context.Context) error
+	}); ok {
+		checks = append(checks, health.Check{Name: "database", Run: db.Ping})
+	}
+	if db, ok := sensorStore.(interface {
+		CheckPostGIS(ctx context.Context) error
+	}); ok {
+		checks = append(checks, health.Check{Name: "postgis", Run: db.CheckPostGIS})
+	}
+	if db, ok := sensorStore.(interface {
+		CheckSchemaVersion(ctx context.Context) error
+	}); ok {
+		checks = append(checks, health.Check{Name: "schema", Run: db.CheckSchemaVersion})
+	}
+
+	// Geocoding providers are external services, which may charge per request, so are only checked if enabled
+	if cfg.Geocoder {
+		if geocoder == nil {
+			return nil, errors.New("HEALTH_CHECK_GEOCODER requires a geocoder: set GEOCODER_PROVIDERS")
+		}
+		checks = append(checks, health.Check{
+			Name:     "geocoder",
+			Run:      geocodeCheck(geocoder, cfg.GeocoderQuery),
+			Optional: true,
+		})
+	}
+
+	return health.NewChecker(cfg.Timeout, checks...), nil
+}
+
+// geocodeCheck checks that the geocoder responds to a query.
+// Not finding the place still means the geocoder is up.
+func geocodeCheck(geocoder geo.GeoService, query string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, _, err := geocoder.Geocode(ctx, query)
+		if errors.Is(err, geo.ErrPlaceNotFound) {
+			return nil
+		}
+		return err
+	}
+}
+
+// LivenessHandler responds while the process is able to serve requests.
+// It does not check dependencies: restarting the API won't fix the database.
+func (router *SensorRouter) LivenessHandler(r *http.Request) (interface{}, int, error) {
+	return map[string]string{
+		"status": health.StatusOK,
+	}, http.StatusOK, nil
+}
+
+// ReadinessHandler checks the API's dependencies, and responds with a 503 if any required dependency is unavailable,
+// or if the server is shutting down.
+func (router *SensorRouter) ReadinessHandler(r *http.Request) (interface{}, int, error) {
+	// Tell load balancers to stop sending requests, while we shut down
+	if router.draining.Load() {
+		return map[string]string{
+			"status": "draining",
+		}, http.StatusServiceUnavailable, nil
+	}
+
+	report := health.Report{Status: health.StatusOK, Checks: map[string]health.Result{}}
+	if router.health != nil {
+		report = router.health.Run(r.Context())
+	}
+	if report.Status != health.StatusOK {
+		return report, http.StatusServiceUnavailable, nil
+	}
+	return report, http.StatusOK, nil
+}
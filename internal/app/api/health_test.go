@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/health"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLiveness(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithHealthChecker(health.NewChecker(time.Second, health.Check{Name: "database", Run: failingCheck})),
+	)
+
+	// Liveness doesn't depend on the database
+	rr := httpRequest(t, router, "GET", "/health/live", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"status": "ok"}`, rr.Body.String())
+}
+
+func TestReadiness(t *testing.T) {
+	databaseUp := true
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithHealthChecker(health.NewChecker(time.Second,
+			health.Check{Name: "database", Run: func(ctx context.Context) error {
+				if !databaseUp {
+					return errors.New("connection refused")
+				}
+				return nil
+			}},
+			health.Check{Name: "geocoder", Run: failingCheck, Optional: true},
+		)),
+	)
+
+	// Optional checks can fail
+	rr := httpRequest(t, router, "GET", "/health/ready", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var report health.Report
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	require.Equal(t, "ok", report.Status)
+	require.Equal(t, "ok", report.Checks["database"].Status)
+	require.Equal(t, health.Result{Status: "error", LatencyMs: report.Checks["geocoder"].LatencyMs, Error: "geocoder is down", Optional: true}, report.Checks["geocoder"])
+
+	// Required checks can't
+	databaseUp = false
+	rr = httpRequest(t, router, "GET", "/health/ready", "")
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	require.Equal(t, "error", report.Status)
+	require.Equal(t, "connection refused", report.Checks["database"].Error)
+
+	// Readiness fails while shutting down
+	databaseUp = true
+	router.draining.Store(true)
+	rr = httpRequest(t, router, "GET", "/health/ready", "")
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.JSONEq(t, `{"status": "draining"}`, rr.Body.String())
+}
+
+func TestReadiness_NoChecks(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	rr := httpRequest(t, router, "GET", "/health/ready", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"status": "ok", "checks": {}}`, rr.Body.String())
+}
+
+func TestGeocodeCheck(t *testing.T) {
+	ctx := context.Background()
+
+	// Places which aren't found still mean the geocoder is up
+	require.NoError(t, geocodeCheck(&MockGeoService{}, "Atlantis")(ctx))
+	require.NoError(t, geocodeCheck(&MockGeoService{places: map[string][2]float64{"London": {51.5, -0.1}}}, "London")(ctx))
+	require.EqualError(t, geocodeCheck(&failingGeoService{}, "London")(ctx), "geocoder is down")
+}
+
+// failingGeoService fails to geocode every place
+type failingGeoService struct {
+	MockGeoService
+}
+
+func (svc *failingGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	return 0, 0, errors.New("geocoder is down")
+}
+
+func failingCheck(ctx context.Context) error {
+	return errors.New("geocoder is down")
+}
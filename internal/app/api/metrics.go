@@ -0,0 +1,60 @@
+package api
+
+import (
+	"github.com/eschwartz/go-sensor-api/internal/app/metrics"
+	"net/http"
+	"strconv"
+)
+
+// HTTP methods used as metric labels. Others are labelled "OTHER".
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// requestMetrics records the number and latency of HTTP requests
+type requestMetrics struct {
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+func newRequestMetrics(registry *metrics.Registry) *requestMetrics {
+	return &requestMetrics{
+		requests: registry.NewCounterVec("sensor_api_http_requests_total",
+			"HTTP requests, by route template and status", "method", "route", "status"),
+		duration: registry.NewHistogramVec("sensor_api_http_request_duration_seconds",
+			"Latency of HTTP requests, by route template and status", metrics.DefaultBuckets, "method", "route", "status"),
+	}
+}
+
+// withRequestMetrics records metrics for every request.
+// Must be wrapped by withAccessLog, which collects the route template of the request.
+func (router *SensorRouter) withRequestMetrics(next http.Handler) http.Handler {
+	if router.requestMetrics == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := router.clock()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		// Label unmatched requests by a constant, rather than by path, so that scanners can't create unlimited series
+		route := "unmatched"
+		if info, ok := r.Context().Value(requestInfoContextKey{}).(*requestInfo); ok && info.route != "" {
+			route = info.route
+		}
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+		status := strconv.Itoa(rw.status)
+		router.requestMetrics.requests.Inc(method, route, status)
+		router.requestMetrics.duration.Observe(router.clock().Sub(start).Seconds(), method, route, status)
+	})
+}
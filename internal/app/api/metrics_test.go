@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/metrics"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithMetrics(registry),
+	)
+
+	httpRequest(t, router, "GET", "/sensors/abc123", "")
+	httpRequest(t, router, "GET", "/sensors/abc123", "")
+	httpRequest(t, router, "GET", "/no/such/path", "")
+	httpRequest(t, router, "BREW", "/sensors/abc123", "")
+
+	rr := httpRequest(t, router, "GET", "/metrics", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	// Requests are labelled by route template, not path
+	require.Contains(t, body, `sensor_api_http_requests_total{method="GET",route="/sensors/{name}",status="404"} 2`)
+	require.Contains(t, body, `sensor_api_http_request_duration_seconds_count{method="GET",route="/sensors/{name}",status="404"} 2`)
+	require.Contains(t, body, `sensor_api_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	require.Contains(t, body, `sensor_api_http_requests_total{method="OTHER",route="unmatched",status="405"} 1`)
+	require.NotContains(t, body, "/no/such/path")
+}
+
+func TestMetrics_RequiresAdmin(t *testing.T) {
+	apiKeys := auth.NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithAuthentication(apiKeys, nil),
+		WithMetrics(metrics.NewRegistry()),
+	)
+	_, adminKey, err := apiKeys.Issue(context.Background(), "acme", "admin", []string{auth.ScopeAdmin})
+	require.NoError(t, err)
+	_, readerKey, err := apiKeys.Issue(context.Background(), "acme", "reader", []string{auth.ScopeSensorsRead})
+	require.NoError(t, err)
+
+	rr := httpRequest(t, router, "GET", "/metrics", "")
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = httpRequestWithHeaders(t, router, "GET", "/metrics", "", map[string]string{"X-API-Key": readerKey})
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	rr = httpRequestWithHeaders(t, router, "GET", "/metrics", "", map[string]string{"X-API-Key": adminKey})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "sensor_api_http_requests_total")
+}
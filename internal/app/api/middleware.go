@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/eschwartz/go-sensor-api/internal/app/logging"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// Longest X-Request-ID accepted from clients
+const maxRequestIDLength = 128
+
+// requestInfo collects details about a request, as it is handled
+type requestInfo struct {
+	// Path template of the matched route, eg. "/sensors/{name}"
+	route string
+}
+
+type requestInfoContextKey struct{}
+
+// withRequestID assigns each request an ID, available from the request context using logging.RequestID.
+// The ID is taken from the X-Request-ID header if the client (or a proxy) sent one, and is echoed in the response.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// withAccessLog logs every request, once it has been handled
+func (router *SensorRouter) withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := router.clock()
+		ctx, info := withRequestInfo(r.Context())
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		router.logger().InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"route", info.route,
+			"path", r.URL.Path,
+			"status", rw.status,
+			"duration_ms", float64(router.clock().Sub(start).Microseconds())/1000,
+			"bytes", rw.bytes,
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// withRequestInfo returns a context carrying a requestInfo, reusing the requestInfo of an outer middleware if there is one
+func withRequestInfo(ctx context.Context) (context.Context, *requestInfo) {
+	if info, ok := ctx.Value(requestInfoContextKey{}).(*requestInfo); ok {
+		return ctx, info
+	}
+	info := &requestInfo{}
+	return context.WithValue(ctx, requestInfoContextKey{}, info), info
+}
+
+// recordRoute is mux middleware, which records the template of the matched route in the requestInfo
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(requestInfoContextKey{}).(*requestInfo); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				info.route, _ = route.GetPathTemplate()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseRecorder records the status code and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// validRequestID checks that a client-supplied request ID is safe to log and echo
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
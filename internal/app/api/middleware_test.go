@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/eschwartz/go-sensor-api/internal/app/logging"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends the default logger's output to a buffer, for the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", slog.LevelInfo)
+	require.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return &buf
+}
+
+// logRecords parses JSON log lines
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestID(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	// Request IDs are echoed
+	rr := httpRequestWithHeaders(t, router, "GET", "/health", "", map[string]string{"X-Request-ID": "req-123"})
+	require.Equal(t, "req-123", rr.Header().Get("X-Request-ID"))
+
+	// ...or generated, if missing or invalid
+	rr = httpRequest(t, router, "GET", "/health", "")
+	require.Len(t, rr.Header().Get("X-Request-ID"), 32)
+	rr = httpRequestWithHeaders(t, router, "GET", "/health", "", map[string]string{"X-Request-ID": "has spaces"})
+	require.Len(t, rr.Header().Get("X-Request-ID"), 32)
+	rr = httpRequestWithHeaders(t, router, "GET", "/health", "", map[string]string{"X-Request-ID": strings.Repeat("x", 200)})
+	require.Len(t, rr.Header().Get("X-Request-ID"), 32)
+}
+
+func TestAccessLog(t *testing.T) {
+	logs := captureLogs(t)
+	router := NewSensorRouter(WithStore(&MockSensorStore{returnErrors: true}))
+
+	rr := httpRequestWithHeaders(t, router, "GET", "/sensors/abc123", "", map[string]string{"X-Request-ID": "req-123"})
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	records := logRecords(t, logs)
+	require.Len(t, records, 2)
+
+	// Errors are logged with the request ID
+	require.Equal(t, "ERROR", records[0]["level"])
+	require.Equal(t, "failed to retrieve sensor", records[0]["msg"])
+	require.Equal(t, "abc123", records[0]["sensor"])
+	require.Equal(t, "req-123", records[0]["request_id"])
+
+	// Followed by the access log
+	access := records[1]
+	require.Equal(t, "request", access["msg"])
+	require.Equal(t, "req-123", access["request_id"])
+	require.Equal(t, "GET", access["method"])
+	require.Equal(t, "/sensors/{name}", access["route"])
+	require.Equal(t, "/sensors/abc123", access["path"])
+	require.Equal(t, float64(http.StatusInternalServerError), access["status"])
+	require.Equal(t, float64(rr.Body.Len()), access["bytes"])
+	require.Contains(t, access, "duration_ms")
+}
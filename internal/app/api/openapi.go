@@ -0,0 +1,474 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/gorilla/mux"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// openAPIDocument is an OpenAPI 3 description of the API.
+// It is served at GET /openapi.json, and requests are validated against it.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+var openAPI = mustParseOpenAPISpec(openAPIDocument)
+
+// openAPISpec is the subset of an OpenAPI 3 document needed to validate requests and responses
+type openAPISpec struct {
+	// Operations, by path template and lower case method
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas    map[string]*jsonSchema       `json:"schemas"`
+		Parameters map[string]*openAPIParameter `json:"parameters"`
+		Responses  map[string]*openAPIResponse  `json:"responses"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Parameters  []*openAPIParameter         `json:"parameters"`
+	RequestBody *openAPIRequestBody         `json:"requestBody"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Ref      string      `json:"$ref"`
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required"`
+	Schema   *jsonSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Ref     string                       `json:"$ref"`
+	Content map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema  *jsonSchema     `json:"schema"`
+	Example json.RawMessage `json:"example"`
+}
+
+// jsonSchema is the subset of JSON Schema (as extended by OpenAPI 3.0) used by the API's document.
+// Other keywords (eg. minimum, or pattern) document rules which the handlers check, so that every invalid field
+// can be reported at once (see store.Sensor.Validate).
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Nullable             bool                   `json:"nullable"`
+	Enum                 []string               `json:"enum"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+}
+
+// additionalProperties is either false (no other properties are allowed), or a schema for other properties
+type additionalProperties struct {
+	Allowed bool
+	Schema  *jsonSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+func mustParseOpenAPISpec(document []byte) *openAPISpec {
+	spec, err := parseOpenAPISpec(document)
+	if err != nil {
+		panic(fmt.Sprintf("invalid openapi.json: %s", err))
+	}
+	return spec
+}
+
+// parseOpenAPISpec parses an OpenAPI document, and replaces $refs with the components they refer to
+func parseOpenAPISpec(document []byte) (*openAPISpec, error) {
+	var spec openAPISpec
+	if err := json.Unmarshal(document, &spec); err != nil {
+		return nil, err
+	}
+
+	resolved := map[*jsonSchema]bool{}
+	for _, schema := range spec.Components.Schemas {
+		if err := spec.resolveSchema(schema, resolved); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	for _, parameter := range spec.Components.Parameters {
+		if parameter.Schema, err = spec.resolveChild(parameter.Schema, resolved); err != nil {
+			return nil, err
+		}
+	}
+	for _, response := range spec.Components.Responses {
+		if err := spec.resolveContent(response.Content, resolved); err != nil {
+			return nil, err
+		}
+	}
+
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			for i, parameter := range operation.Parameters {
+				if parameter.Ref != "" {
+					name := strings.TrimPrefix(parameter.Ref, "#/components/parameters/")
+					if operation.Parameters[i] = spec.Components.Parameters[name]; operation.Parameters[i] == nil {
+						return nil, fmt.Errorf("%s %s: unknown parameter \"%s\"", method, path, parameter.Ref)
+					}
+					continue
+				}
+				if parameter.Schema, err = spec.resolveChild(parameter.Schema, resolved); err != nil {
+					return nil, err
+				}
+			}
+			if operation.RequestBody != nil {
+				if err := spec.resolveContent(operation.RequestBody.Content, resolved); err != nil {
+					return nil, err
+				}
+			}
+			for status, response := range operation.Responses {
+				if response.Ref != "" {
+					name := strings.TrimPrefix(response.Ref, "#/components/responses/")
+					if operation.Responses[status] = spec.Components.Responses[name]; operation.Responses[status] == nil {
+						return nil, fmt.Errorf("%s %s: unknown response \"%s\"", method, path, response.Ref)
+					}
+					continue
+				}
+				if err := spec.resolveContent(response.Content, resolved); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return &spec, nil
+}
+
+func (spec *openAPISpec) resolveContent(content map[string]*openAPIMediaType, resolved map[*jsonSchema]bool) error {
+	var err error
+	for contentType, mediaType := range content {
+		if mediaType.Schema, err = spec.resolveChild(mediaType.Schema, resolved); err != nil {
+			return fmt.Errorf("%s: %w", contentType, err)
+		}
+	}
+	return nil
+}
+
+// resolveSchema replaces $refs within a schema. Schemas may refer to each other, so each is only resolved once.
+func (spec *openAPISpec) resolveSchema(schema *jsonSchema, resolved map[*jsonSchema]bool) error {
+	if schema == nil || resolved[schema] {
+		return nil
+	}
+	resolved[schema] = true
+
+	var err error
+	for name, property := range schema.Properties {
+		if schema.Properties[name], err = spec.resolveChild(property, resolved); err != nil {
+			return err
+		}
+	}
+	if schema.Items, err = spec.resolveChild(schema.Items, resolved); err != nil {
+		return err
+	}
+	if schema.AdditionalProperties != nil {
+		if schema.AdditionalProperties.Schema, err = spec.resolveChild(schema.AdditionalProperties.Schema, resolved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (spec *openAPISpec) resolveChild(child *jsonSchema, resolved map[*jsonSchema]bool) (*jsonSchema, error) {
+	if child == nil {
+		return nil, nil
+	}
+	child, err := spec.schemaRef(child)
+	if err != nil {
+		return nil, err
+	}
+	return child, spec.resolveSchema(child, resolved)
+}
+
+// schemaRef returns the component a schema refers to, or the schema itself if it isn't a $ref
+func (spec *openAPISpec) schemaRef(schema *jsonSchema) (*jsonSchema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+	component := spec.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	if component == nil {
+		return nil, fmt.Errorf("unknown schema \"%s\"", schema.Ref)
+	}
+	return component, nil
+}
+
+// operation returns the operation for a route, or nil if it is not described
+func (spec *openAPISpec) operation(pathTemplate string, method string) *openAPIOperation {
+	return spec.Paths[pathTemplate][strings.ToLower(method)]
+}
+
+// withRequestValidation checks a request's query params and JSON body against the OpenAPI document,
+// before it is handled
+func withRequestValidation(f JSONHandlerFunc) JSONHandlerFunc {
+	return func(r *http.Request) (interface{}, int, error) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			return f(r)
+		}
+		pathTemplate, err := route.GetPathTemplate()
+		if err != nil {
+			return f(r)
+		}
+		operation := openAPI.operation(pathTemplate, r.Method)
+		if operation == nil {
+			return f(r)
+		}
+
+		if err := operation.validateParams(r); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		if status, err := operation.validateBody(r); err != nil {
+			return nil, status, err
+		}
+
+		return f(r)
+	}
+}
+
+func (operation *openAPIOperation) validateParams(r *http.Request) error {
+	query := r.URL.Query()
+	for _, parameter := range operation.Parameters {
+		if parameter.In != "query" {
+			// Path params are matched by the router
+			continue
+		}
+
+		value := query.Get(parameter.Name)
+		if value == "" {
+			if parameter.Required {
+				return fmt.Errorf("missing required \"%s\" param", parameter.Name)
+			}
+			continue
+		}
+		if parameter.Schema == nil {
+			continue
+		}
+
+		// Query params are strings, so convert them to the type the schema expects
+		var typed interface{} = value
+		switch parameter.Schema.Type {
+		case "integer", "number":
+			typed = json.Number(value)
+		case "boolean":
+			if value != "true" && value != "false" {
+				return fmt.Errorf("invalid value for \"%s\": must be \"true\" or \"false\"", parameter.Name)
+			}
+			typed = value == "true"
+		}
+		if fields := parameter.Schema.validate(typed, ""); len(fields) > 0 {
+			return fmt.Errorf("invalid value for \"%s\": %s", parameter.Name, fields[0].Detail)
+		}
+	}
+	return nil
+}
+
+// validateBody checks a JSON request body. The body is restored, so that the handler can read it.
+// Bodies which aren't valid JSON are left to the handler, which reports why they can't be decoded.
+func (operation *openAPIOperation) validateBody(r *http.Request) (int, error) {
+	if operation.RequestBody == nil || r.Body == nil {
+		return 0, nil
+	}
+	mediaType := operation.RequestBody.Content["application/json"]
+	if mediaType == nil || mediaType.Schema == nil {
+		return 0, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return requestBodyErrorStatus(err), fmt.Errorf("invalid request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	value, err := decodeJSONValue(body)
+	if err != nil {
+		return 0, nil
+	}
+	if fields := mediaType.Schema.validate(value, ""); len(fields) > 0 {
+		return http.StatusBadRequest, &store.ValidationError{ResourceType: "request body", Fields: fields}
+	}
+	return 0, nil
+}
+
+// decodeJSONValue decodes JSON for validation. Numbers are kept as json.Number, so that integers can be told apart.
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func escapePointer(token string) string {
+	return pointerEscaper.Replace(token)
+}
+
+// validate checks a decoded JSON value (see decodeJSONValue) against the schema.
+// Returns every field which doesn't match, identified by its JSON pointer from the value at pointer.
+func (s *jsonSchema) validate(value interface{}, pointer string) []store.FieldError {
+	invalid := func(detail string) []store.FieldError {
+		return []store.FieldError{{Pointer: pointer, Detail: detail}}
+	}
+
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return invalid("must not be null")
+	}
+
+	var fields []store.FieldError
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				fields = append(fields, store.FieldError{Pointer: pointer + "/" + escapePointer(name), Detail: "is required"})
+			}
+		}
+
+		// Check properties in order, so that the same request always gets the same errors
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := pointer + "/" + escapePointer(name)
+			property := s.Properties[name]
+			if property == nil && s.AdditionalProperties != nil {
+				if !s.AdditionalProperties.Allowed {
+					fields = append(fields, store.FieldError{Pointer: field, Detail: "is not a known field"})
+					continue
+				}
+				property = s.AdditionalProperties.Schema
+			}
+			if property != nil {
+				fields = append(fields, property.validate(object[name], field)...)
+			}
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return invalid("must be an array")
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return invalid(fmt.Sprintf("must have at least %d items", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return invalid(fmt.Sprintf("must have at most %d items", *s.MaxItems))
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				fields = append(fields, s.Items.validate(item, fmt.Sprintf("%s/%d", pointer, i))...)
+			}
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return invalid("must be a string")
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			return invalid("must be " + quotedList(s.Enum))
+		}
+
+	case "number", "integer":
+		mustBe := "must be a number"
+		if s.Type == "integer" {
+			mustBe = "must be an integer"
+		}
+		var number float64
+		switch value := value.(type) {
+		case json.Number:
+			f, err := value.Float64()
+			if err != nil {
+				return invalid(mustBe)
+			}
+			number = f
+		case float64:
+			number = value
+		default:
+			return invalid(mustBe)
+		}
+		if s.Type == "integer" && number != math.Trunc(number) {
+			return invalid(mustBe)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("must be a boolean")
+		}
+
+	case "":
+		// Any value is allowed
+
+	default:
+		return invalid(fmt.Sprintf("has an unsupported schema type \"%s\"", s.Type))
+	}
+
+	return fields
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// quotedList formats values like `"a", "b" or "c"`
+func quotedList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("\"%s\"", value)
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
+
+// OpenAPIHandler serves the OpenAPI document
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPIDocument)
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/metrics"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI_DescribesEveryRoute(t *testing.T) {
+	// Enable everything which adds routes
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithMetrics(metrics.NewRegistry()),
+	)
+
+	var routes []string
+	err := router.routes().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return fmt.Errorf("route %s must be limited to specific methods: %w", path, err)
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var described []string
+	for path, operations := range openAPI.Paths {
+		for method := range operations {
+			described = append(described, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	// Every route is described, and every operation has a route
+	require.ElementsMatch(t, routes, described)
+}
+
+func TestOpenAPI_Examples(t *testing.T) {
+	examples := 0
+	for path, operations := range openAPI.Paths {
+		for method, operation := range operations {
+			require.NotEmpty(t, operation.Responses, "%s %s", method, path)
+
+			if operation.RequestBody != nil {
+				for contentType, mediaType := range operation.RequestBody.Content {
+					requireExampleMatchesSchema(t, mediaType, fmt.Sprintf("%s %s request (%s)", method, path, contentType))
+					examples++
+				}
+			}
+			for status, response := range operation.Responses {
+				_, err := strconv.Atoi(status)
+				require.NoError(t, err, "%s %s: invalid status %s", method, path, status)
+				for contentType, mediaType := range response.Content {
+					requireExampleMatchesSchema(t, mediaType, fmt.Sprintf("%s %s %s response (%s)", method, path, status, contentType))
+					examples++
+				}
+			}
+		}
+	}
+	require.NotZero(t, examples)
+}
+
+func requireExampleMatchesSchema(t *testing.T, mediaType *openAPIMediaType, description string) {
+	require.NotNil(t, mediaType.Schema, "%s has no schema", description)
+	if mediaType.Example == nil {
+		return
+	}
+	example, err := decodeJSONValue(mediaType.Example)
+	require.NoError(t, err, description)
+	require.Empty(t, mediaType.Schema.validate(example, ""), "%s example does not match its schema", description)
+}
+
+func TestOpenAPI_Responses(t *testing.T) {
+	mockStore := &MockSensorStore{findClosestRes: []*store.Sensor{{ID: 2, Name: "abc123", Lat: 44.91, Lon: -93.22}}}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+	closestRouter := NewSensorRouter(WithStore(mockStore))
+
+	// Responses match the document, including errors
+	tests := []struct {
+		router *SensorRouter
+		method string
+		url    string
+		body   string
+		route  string
+	}{
+		{router, "GET", "/health", "", "/health"},
+		{router, "GET", "/health/live", "", "/health/live"},
+		{router, "GET", "/health/ready", "", "/health/ready"},
+		{router, "POST", "/sensors", `{"name": "abc123", "lat": 44.91, "lon": -93.22}`, "/sensors"},
+		{router, "POST", "/sensors", `{"name": "abc123", "lat": "north"}`, "/sensors"},
+		{router, "GET", "/sensors/abc123", "", "/sensors/{name}"},
+		{router, "GET", "/sensors/xyz789", "", "/sensors/{name}"},
+		{router, "PUT", "/sensors/abc123", `{"name": "abc123", "lat": 44.92, "lon": -93.23, "tags": ["a"]}`, "/sensors/{name}"},
+		{router, "GET", "/sensors/abc123/place", "", "/sensors/{name}/place"},
+		{router, "GET", "/geocode/suggest?q=Minn", "", "/geocode/suggest"},
+		{closestRouter, "GET", "/sensors/closest?location=44.91,-93.22", "", "/sensors/closest"},
+		{closestRouter, "GET", "/sensors/closest?location=44.91,-93.22&radius=far", "", "/sensors/closest"},
+		{router, "GET", "/admin/api-keys", "", "/admin/api-keys"},
+	}
+	for _, test := range tests {
+		rr := httpRequest(t, test.router, test.method, test.url, test.body)
+		requireMatchesOpenAPI(t, test.method, test.route, rr)
+	}
+}
+
+func TestOpenAPI_APIKeyResponses(t *testing.T) {
+	keys := auth.NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	_, admin, err := keys.Issue(context.Background(), "acme", "admin", []string{auth.ScopeAdmin})
+	require.NoError(t, err)
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithAuthentication(keys, nil),
+	)
+	headers := map[string]string{"X-API-Key": admin}
+
+	rr := httpRequestWithHeaders(t, router, "POST", "/admin/api-keys", `{"name": "ingest", "scopes": ["sensors:read"]}`, headers)
+	requireMatchesOpenAPI(t, "POST", "/admin/api-keys", rr)
+	id := int(unmarshalResponseJSON(t, rr)["data"].(map[string]interface{})["id"].(float64))
+
+	rr = httpRequestWithHeaders(t, router, "GET", "/admin/api-keys", "", headers)
+	requireMatchesOpenAPI(t, "GET", "/admin/api-keys", rr)
+	rr = httpRequestWithHeaders(t, router, "POST", fmt.Sprintf("/admin/api-keys/%d/rotate", id), "", headers)
+	requireMatchesOpenAPI(t, "POST", "/admin/api-keys/{id}/rotate", rr)
+	rr = httpRequestWithHeaders(t, router, "DELETE", fmt.Sprintf("/admin/api-keys/%d", id), "", headers)
+	requireMatchesOpenAPI(t, "DELETE", "/admin/api-keys/{id}", rr)
+	rr = httpRequestWithHeaders(t, router, "DELETE", "/admin/api-keys/9999", "", headers)
+	requireMatchesOpenAPI(t, "DELETE", "/admin/api-keys/{id}", rr)
+
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	requireMatchesOpenAPI(t, "GET", "/sensors/{name}", rr)
+}
+
+// requireMatchesOpenAPI checks that a response is described by the route's operation, and matches its schema
+func requireMatchesOpenAPI(t *testing.T, method string, route string, rr *httptest.ResponseRecorder) {
+	operation := openAPI.operation(route, method)
+	require.NotNil(t, operation, "%s %s is not described", method, route)
+	response := operation.Responses[strconv.Itoa(rr.Code)]
+	require.NotNil(t, response, "%s %s: %d response is not described", method, route, rr.Code)
+
+	mediaType := response.Content[rr.Header().Get("Content-Type")]
+	require.NotNil(t, mediaType, "%s %s: %d response is not described as %s", method, route, rr.Code, rr.Header().Get("Content-Type"))
+	value, err := decodeJSONValue(rr.Body.Bytes())
+	require.NoError(t, err)
+	require.Empty(t, mediaType.Schema.validate(value, ""), "%s %s: %d response does not match its schema: %s", method, route, rr.Code, rr.Body)
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithAuthentication(auth.NewAPIKeyService(store.NewMemoryAPIKeyStore()), nil),
+	)
+
+	// The document is served without authentication
+	rr := httpRequest(t, router, "GET", "/openapi.json", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var document map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &document))
+	require.Equal(t, "3.0.3", document["openapi"])
+}
+
+func TestRequestValidation(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(&MockGeoService{}),
+	)
+
+	// Query params
+	tests := []struct {
+		url   string
+		error string
+	}{
+		{"/sensors/closest", "missing required \"location\" param"},
+		{"/sensors/closest?location=", "missing required \"location\" param"},
+		{"/geocode/suggest?near=44.95,-93.1", "missing required \"q\" param"},
+		{"/geocode/suggest?q=Minn&sensor_counts=1", "invalid value for \"sensor_counts\": must be \"true\" or \"false\""},
+	}
+	for _, test := range tests {
+		rr := httpRequest(t, router, "GET", test.url, "")
+		require.Equal(t, http.StatusBadRequest, rr.Code, test.url)
+		requireProblem(t, rr, test.error, test.url)
+	}
+
+	// JSON bodies. Every invalid field is reported, as a problem.
+	bodyTests := []struct {
+		method string
+		url    string
+		body   string
+		errors []interface{}
+	}{
+		{"POST", "/sensors", `[]`, []interface{}{
+			map[string]interface{}{"pointer": "", "detail": "must be an object"},
+		}},
+		{"POST", "/sensors", `{"name": "abc123", "lat": "44.9", "tags": ["a", 2], "color": "red"}`, []interface{}{
+			map[string]interface{}{"pointer": "/lon", "detail": "is required"},
+			map[string]interface{}{"pointer": "/color", "detail": "is not a known field"},
+			map[string]interface{}{"pointer": "/lat", "detail": "must be a number"},
+			map[string]interface{}{"pointer": "/tags/1", "detail": "must be a string"},
+		}},
+		{"PUT", "/sensors/abc123", `{"name": null, "lat": 44.9, "lon": -93.2}`, []interface{}{
+			map[string]interface{}{"pointer": "/name", "detail": "must not be null"},
+		}},
+		{"POST", "/admin/api-keys", `{"name": "ingest", "scopes": "admin"}`, []interface{}{
+			map[string]interface{}{"pointer": "/scopes", "detail": "must be an array"},
+		}},
+	}
+	for _, test := range bodyTests {
+		rr := httpRequest(t, router, test.method, test.url, test.body)
+		require.Equal(t, http.StatusBadRequest, rr.Code, test.body)
+		require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		res := unmarshalResponseJSON(t, rr)
+		require.Equal(t, test.errors, res["errors"], test.body)
+		require.Equal(t, validationProblemType, res["type"])
+		require.Contains(t, res["detail"], "invalid request body: ")
+	}
+
+	// Malformed JSON is reported by the handler
+	rr := httpRequest(t, router, "POST", "/sensors", `{"name": `)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	requireProblem(t, rr, "invalid request body: unexpected EOF")
+
+	// Valid requests are handled as usual, with the body intact
+	rr = httpRequest(t, router, "POST", "/sensors", `{"name": "abc123", "lat": 44.9, "lon": -93.2, "tags": null}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	fields := openAPI.Components.Schemas["SensorInput"].validate(map[string]interface{}{
+		"name": "abc123", "lat": json.Number("44.9"), "lon": json.Number("1.5e400"), "tags": []interface{}{"a", true},
+		"a/b~c": 1,
+	}, "")
+	require.Equal(t, []store.FieldError{
+		// Pointers escape "/" and "~" (RFC 6901)
+		{Pointer: "/a~1b~0c", Detail: "is not a known field"},
+		{Pointer: "/lon", Detail: "must be a number"},
+		{Pointer: "/tags/1", Detail: "must be a string"},
+	}, fields)
+}
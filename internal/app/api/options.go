@@ -0,0 +1,169 @@
+package api
+
+import (
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/health"
+	"github.com/eschwartz/go-sensor-api/internal/app/metrics"
+	"github.com/eschwartz/go-sensor-api/internal/app/ratelimit"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	sensorsv1 "github.com/eschwartz/go-sensor-api/proto/sensors/v1"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures a SensorRouter created with NewSensorRouter
+type Option func(router *SensorRouter)
+
+// NewSensorRouter creates a router from its dependencies, eg. to embed the API with a different store:
+//
+//	router := api.NewSensorRouter(
+//		api.WithStore(sensorStore),
+//		api.WithGeocoder(geocoder),
+//		api.WithAuthentication(auth.NewAPIKeyService(keyStore), nil),
+//	)
+//
+// Without options, sensors are kept in memory, geocoding is disabled, and authentication is disabled.
+// See FromConfig to create the router from the API's configuration.
+func NewSensorRouter(opts ...Option) *SensorRouter {
+	router := &SensorRouter{
+		store:        store.NewMemorySensorStore(),
+		rateLimits:   make(map[string]ratelimit.Limiter),
+		changes:      store.NewChangeFeed(),
+		grpcHealth:   grpchealth.NewServer(),
+		grpcStopping: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(router)
+	}
+
+	// Record changes made through either API, for WatchChanges
+	router.store = router.changes.Wrap(router.store)
+	router.grpcHealth.SetServingStatus(sensorsv1.SensorService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return router
+}
+
+// WithStore sets the sensor store
+func WithStore(sensorStore store.SensorStore) Option {
+	return func(router *SensorRouter) {
+		router.store = sensorStore
+	}
+}
+
+// WithGeocoder enables geocoding place names, eg. for GET /sensors/closest?location=London
+func WithGeocoder(geocoder geo.GeoService) Option {
+	return func(router *SensorRouter) {
+		router.geo = geocoder
+	}
+}
+
+// WithEnrichPlaceNames stores sensors with a reverse geocoded place name. Requires a geocoder.
+func WithEnrichPlaceNames(enabled bool) Option {
+	return func(router *SensorRouter) {
+		router.enrichPlaceNames.Store(enabled)
+	}
+}
+
+// WithAuthentication requires requests to have an API key or JWT bearer token.
+// Either may be nil, to disable that method. If both are nil, authentication is disabled.
+func WithAuthentication(apiKeys *auth.APIKeyService, tokens *auth.JWTValidator) Option {
+	return func(router *SensorRouter) {
+		router.apiKeys = apiKeys
+		router.tokens = tokens
+	}
+}
+
+// WithLogger sets the logger for errors, audit records and access logs. Defaults to slog.Default().
+// Use a logger from logging.New, so that records include request and trace IDs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(router *SensorRouter) {
+		router.log = logger
+	}
+}
+
+// WithClock sets the function used to read the current time, eg. when timing requests. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(router *SensorRouter) {
+		router.now = now
+	}
+}
+
+// WithMiddleware wraps the routes with additional middleware. The first middleware is the outermost.
+// Middleware runs after request IDs are assigned and tracing starts, so it may log with the request context.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(router *SensorRouter) {
+		router.middleware = append(router.middleware, middleware...)
+	}
+}
+
+// WithRateLimits limits each client's requests. Spatial queries (eg. GET /sensors/closest) use the spatial limiter,
+// and other requests use the standard limiter. Either may be nil, for no limit.
+func WithRateLimits(standard ratelimit.Limiter, spatial ratelimit.Limiter) Option {
+	return func(router *SensorRouter) {
+		for bucket, limiter := range map[string]ratelimit.Limiter{standardRateLimit: standard, spatialRateLimit: spatial} {
+			if limiter == nil {
+				delete(router.rateLimits, bucket)
+			} else {
+				router.rateLimits[bucket] = limiter
+			}
+		}
+	}
+}
+
+// WithTrustedProxy rate limits unauthenticated clients by the last address in the X-Forwarded-For header,
+// which was added by the proxy. Only use this behind a single proxy which appends to the header.
+func WithTrustedProxy() Option {
+	return func(router *SensorRouter) {
+		router.trustForwardedFor = true
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies. Larger bodies are rejected with a 413.
+// FromConfig applies the server configuration's limit.
+func WithMaxBodyBytes(maxBytes int64) Option {
+	return func(router *SensorRouter) {
+		router.maxBodyBytes = maxBytes
+	}
+}
+
+// WithMetrics records request metrics in the registry, and serves them at GET /metrics
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(router *SensorRouter) {
+		router.metrics = registry
+		router.requestMetrics = newRequestMetrics(registry)
+	}
+}
+
+// WithHealthChecker checks dependencies, for GET /health/ready
+func WithHealthChecker(checker *health.Checker) Option {
+	return func(router *SensorRouter) {
+		router.health = checker
+	}
+}
+
+// WithClosers adds resources to release when the router is closed (eg. a connection pool)
+func WithClosers(closers ...io.Closer) Option {
+	return func(router *SensorRouter) {
+		router.closers = append(router.closers, closers...)
+	}
+}
+
+// logger returns the router's logger
+func (router *SensorRouter) logger() *slog.Logger {
+	if router.log == nil {
+		return slog.Default()
+	}
+	return router.log
+}
+
+// clock returns the current time
+func (router *SensorRouter) clock() time.Time {
+	if router.now == nil {
+		return time.Now()
+	}
+	return router.now()
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/logging"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSensorRouter_Defaults(t *testing.T) {
+	// Sensors are kept in memory, without authentication
+	router := NewSensorRouter()
+
+	rr := httpRequest(t, router, "POST", "/sensors", `{"name": "sensor-1", "lat": 51.5, "lon": -0.1, "tags": []}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = httpRequest(t, router, "GET", "/sensors/sensor-1", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestWithMiddleware(t *testing.T) {
+	var calls []string
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Middleware runs after request IDs are assigned
+				calls = append(calls, name+" "+logging.RequestID(r.Context()))
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	router := NewSensorRouter(WithMiddleware(middleware("first"), middleware("second")), WithMiddleware(middleware("third")))
+
+	rr := httpRequestWithHeaders(t, router, "GET", "/health", "", map[string]string{"X-Request-ID": "req-123"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, []string{"first req-123", "second req-123", "third req-123"}, calls)
+}
+
+func TestWithLoggerAndClock(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", slog.LevelInfo)
+	require.NoError(t, err)
+	// The default logger isn't used
+	defaultLogs := captureLogs(t)
+
+	// Each read of the clock advances it by 250ms
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		now = now.Add(250 * time.Millisecond)
+		return now
+	}
+	router := NewSensorRouter(WithLogger(logger), WithClock(clock))
+
+	rr := httpRequest(t, router, "GET", "/sensors/missing", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	records := logRecords(t, &buf)
+	access := records[len(records)-1]
+	require.Equal(t, "request", access["msg"])
+	require.Equal(t, float64(250), access["duration_ms"])
+	require.Empty(t, strings.TrimSpace(defaultLogs.String()))
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.DatabaseURL = "memory://"
+	cfg.Auth.Disabled = true
+	router, err := FromConfig(cfg)
+	require.NoError(t, err)
+	defer router.Close()
+
+	rr := httpRequest(t, router, "GET", "/health/ready", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// The memory store can't store API keys
+	cfg.Auth.Disabled = false
+	_, err = FromConfig(cfg)
+	require.EqualError(t, err, "the memory sensor store doesn't support API keys: configure JWT authentication, or set AUTH_DISABLED=true")
+
+	// ...but SQLite can
+	cfg.DatabaseURL = "sqlite://" + filepath.Join(t.TempDir(), "sensors.db")
+	router, err = FromConfig(cfg)
+	require.NoError(t, err)
+	defer router.Close()
+	rr = httpRequest(t, router, "GET", "/sensors/sensor-1", "")
+	require.Equal(t, http.StatusUnauthorized, rr.Code, rr.Body.String())
+	rr = httpRequest(t, router, "GET", "/health/ready", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"net/http"
+)
+
+// Problem types, which identify the class of error. Each is documented in the README's "Errors" section.
+const (
+	problemTypeBase       = "https://github.com/eschwartz/go-sensor-api#"
+	validationProblemType = problemTypeBase + "validation-errors"
+)
+
+// problemClass is the type and title of the problems served with a status
+type problemClass struct {
+	typ   string
+	title string
+}
+
+// problemClasses are the problems served for each error status, other than validation errors
+var problemClasses = map[int]problemClass{
+	http.StatusBadRequest:            {problemTypeBase + "bad-request", "Bad request"},
+	http.StatusUnauthorized:          {problemTypeBase + "unauthorized", "Unauthorized"},
+	http.StatusForbidden:             {problemTypeBase + "forbidden", "Forbidden"},
+	http.StatusNotFound:              {problemTypeBase + "not-found", "Not found"},
+	http.StatusConflict:              {problemTypeBase + "conflict", "Conflict"},
+	http.StatusRequestEntityTooLarge: {problemTypeBase + "request-too-large", "Request body too large"},
+	http.StatusTooManyRequests:       {problemTypeBase + "rate-limited", "Rate limit exceeded"},
+	http.StatusInternalServerError:   {problemTypeBase + "internal-error", "Internal server error"},
+	http.StatusNotImplemented:        {problemTypeBase + "not-implemented", "Not implemented"},
+	http.StatusBadGateway:            {problemTypeBase + "bad-gateway", "Upstream service failed"},
+	http.StatusServiceUnavailable:    {problemTypeBase + "unavailable", "Service unavailable"},
+}
+
+// Problem is an error response, as described by RFC 7807 ("Problem Details for HTTP APIs").
+// Problems are served as application/problem+json.
+type Problem struct {
+	// URI identifying the kind of problem
+	Type string `json:"type"`
+	// Summary of the kind of problem, which doesn't change between occurrences
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Explanation of this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Every invalid field, for validation problems
+	Errors []store.FieldError `json:"errors,omitempty"`
+}
+
+// problemFromError returns the problem to respond with, for a handler error.
+// Validation errors list every invalid field. Other errors are classed by their status.
+func problemFromError(err error, status int) *Problem {
+	var validationErr *store.ValidationError
+	if errors.As(err, &validationErr) {
+		return &Problem{
+			Type:   validationProblemType,
+			Title:  "Invalid request",
+			Status: status,
+			Detail: validationErr.Error(),
+			Errors: validationErr.Fields,
+		}
+	}
+
+	class, ok := problemClasses[status]
+	if !ok {
+		// A problem with no meaning beyond its status (RFC 7807, section 4.2)
+		class = problemClass{typ: "about:blank", title: http.StatusText(status)}
+	}
+	return &Problem{
+		Type:   class.typ,
+		Title:  class.title,
+		Status: status,
+		Detail: err.Error(),
+	}
+}
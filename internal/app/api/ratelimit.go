@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate limit buckets.
+// Spatial queries are much more expensive than other requests, so are limited separately.
+const (
+	standardRateLimit = "standard"
+	spatialRateLimit  = "spatial"
+)
+
+// withAccessControl wraps a handler, so that it is rate limited, and requires the given scope (see withScope).
+//
+// Requests are limited by IP address before they are authenticated, so that clients with missing or invalid
+// credentials can't make unlimited requests (eg. each invalid API key is looked up in the database).
+// Authenticated requests are then also limited by their API key or token.
+func (router *SensorRouter) withAccessControl(scope string, bucket string, f JSONHandlerFunc) JSONHandlerFunc {
+	return router.withRateLimit(bucket, router.withScope(scope, router.withPrincipalRateLimit(bucket, f)))
+}
+
+// withAccessControlHandler is withAccessControl, for handlers which don't serve JSON (eg. metrics).
+// Rejected requests receive a JSON error, as for other routes.
+func (router *SensorRouter) withAccessControlHandler(scope string, bucket string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), responseHeaderContextKey{}, w.Header()))
+		served := false
+		data, status, err := router.withAccessControl(scope, bucket, func(r *http.Request) (interface{}, int, error) {
+			next.ServeHTTP(w, r)
+			served = true
+			return nil, 0, nil
+		})(r)
+		if !served {
+			writeJSONResponse(w, r, data, status, err)
+		}
+	}
+}
+
+// withRateLimit wraps a handler, so that each client IP address's requests are limited by the given bucket.
+// Rejected requests receive a 429, with a Retry-After header.
+func (router *SensorRouter) withRateLimit(bucket string, f JSONHandlerFunc) JSONHandlerFunc {
+	return func(r *http.Request) (interface{}, int, error) {
+		if err := router.allowRequest(r, bucket, router.clientAddr(r)); err != nil {
+			return nil, http.StatusTooManyRequests, err
+		}
+		return f(r)
+	}
+}
+
+// withPrincipalRateLimit wraps a handler, so that each API key or token's requests are limited by the given bucket.
+// The handler should be wrapped by withScope, so that the client is authenticated first.
+// Requests without a principal (ie. if authentication is disabled) are not limited.
+func (router *SensorRouter) withPrincipalRateLimit(bucket string, f JSONHandlerFunc) JSONHandlerFunc {
+	return func(r *http.Request) (interface{}, int, error) {
+		principal := auth.FromContext(r.Context())
+		if principal == nil {
+			return f(r)
+		}
+		if err := router.allowRequest(r, bucket, principalClientID(principal)); err != nil {
+			return nil, http.StatusTooManyRequests, err
+		}
+		return f(r)
+	}
+}
+
+// allowRequest takes a request from the client's bucket, and sets the rate limit response headers.
+// Returns an error if the client is over the limit.
+func (router *SensorRouter) allowRequest(r *http.Request, bucket string, clientID string) error {
+	limiter := router.rateLimits[bucket]
+	if limiter == nil {
+		return nil
+	}
+
+	result, err := limiter.Allow(r.Context(), bucket+":"+clientID)
+	if err != nil {
+		// An unavailable rate limit store should not take down the API
+		router.logger().ErrorContext(r.Context(), "failed to check rate limit, allowing request", "bucket", bucket, "error", err)
+		return nil
+	}
+
+	// See https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+	setResponseHeader(r, "RateLimit-Limit", strconv.Itoa(result.Limit))
+	setResponseHeader(r, "RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	setResponseHeader(r, "RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	if !result.Allowed {
+		retryAfter := ceilSeconds(result.RetryAfter)
+		setResponseHeader(r, "Retry-After", strconv.Itoa(retryAfter))
+		return fmt.Errorf("rate limit exceeded: retry in %ds", retryAfter)
+	}
+	return nil
+}
+
+// principalClientID identifies an authenticated client, for rate limiting
+func principalClientID(principal *auth.Principal) string {
+	return principal.TenantID + ":" + principal.Subject
+}
+
+// clientAddr identifies the IP address making a request, for rate limiting
+func (router *SensorRouter) clientAddr(r *http.Request) string {
+	// Use the original client address, if the API is behind a trusted proxy.
+	// Proxies append the address they received the request from, so only the last entry is set by the proxy:
+	// earlier entries are sent by the client, and may be spoofed.
+	if router.trustForwardedFor {
+		forwardedFor := strings.Join(r.Header.Values("X-Forwarded-For"), ",")
+		if i := strings.LastIndex(forwardedFor, ","); i >= 0 {
+			forwardedFor = forwardedFor[i+1:]
+		}
+		if client := strings.TrimSpace(forwardedFor); client != "" {
+			return "ip:" + client
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// newRateLimits creates rate limiters for each bucket.
+// Buckets without a configured rate are not limited.
+func newRateLimits(cfg *config.Config) (map[string]ratelimit.Limiter, error) {
+	limiters := make(map[string]ratelimit.Limiter)
+	for bucket, limit := range rateLimitsByBucket(cfg.RateLimit) {
+		if limit.rate == 0 {
+			continue
+		}
+
+		if cfg.RateLimit.Backend == "postgres" {
+			var err error
+			limiters[bucket], err = ratelimit.NewPostgresLimiter(cfg.DatabaseURL, limit.rate, limit.burst)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			limiters[bucket] = ratelimit.NewMemoryLimiter(limit.rate, limit.burst)
+		}
+	}
+
+	return limiters, nil
+}
+
+type rateLimit struct {
+	rate  float64
+	burst int
+}
+
+// rateLimitsByBucket returns the configured limit for each bucket. A rate of 0 means the bucket is not limited.
+// The burst defaults to one second's worth of requests.
+func rateLimitsByBucket(cfg config.RateLimit) map[string]rateLimit {
+	limits := map[string]rateLimit{
+		standardRateLimit: {cfg.RPS, cfg.Burst},
+		spatialRateLimit:  {cfg.SpatialRPS, cfg.SpatialBurst},
+	}
+	for bucket, limit := range limits {
+		if limit.burst == 0 {
+			limit.burst = int(math.Max(1, math.Ceil(limit.rate)))
+			limits[bucket] = limit
+		}
+	}
+	return limits
+}
+
+// reloadRateLimits applies changed limits to the existing rate limiters.
+// Enabling or disabling a bucket's limit requires a restart.
+func (router *SensorRouter) reloadRateLimits(cfg config.RateLimit) {
+	for bucket, limit := range rateLimitsByBucket(cfg) {
+		limiter, limited := router.rateLimits[bucket]
+		if limited != (limit.rate > 0) {
+			router.logger().Warn("restart to enable or disable the rate limit", "bucket", bucket)
+			continue
+		}
+		if adjustable, ok := limiter.(ratelimit.AdjustableLimiter); ok {
+			adjustable.SetLimit(limit.rate, limit.burst)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/ratelimit"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithRateLimits(ratelimit.NewMemoryLimiter(0.1, 2), nil),
+	)
+
+	// Should allow a burst of requests
+	rr := httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "10", rr.Header().Get("RateLimit-Reset"))
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+	// Should reject requests once the bucket is empty
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "10", rr.Header().Get("Retry-After"))
+	requireProblem(t, rr, "rate limit exceeded: retry in 10s")
+
+	// Spatial queries are limited separately
+	rr = httpRequest(t, router, "GET", "/sensors/closest?location=45.12,-90.34&radius=50km", "")
+	require.NotEqual(t, http.StatusTooManyRequests, rr.Code)
+	require.Empty(t, rr.Header().Get("RateLimit-Limit"))
+
+	// Health checks are not limited
+	rr = httpRequest(t, router, "GET", "/health", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestReload(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(&MockGeoService{}),
+		WithRateLimits(ratelimit.NewMemoryLimiter(0.1, 1), nil),
+	)
+	rr := httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+
+	cfg := config.Default()
+	cfg.RateLimit.RPS = 5
+	cfg.Geocoder.EnrichPlaceNames = true
+	router.Reload(cfg)
+
+	// The new limit applies straight away
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	require.Equal(t, "5", rr.Header().Get("RateLimit-Limit"))
+	require.True(t, router.enrichPlaceNames.Load())
+}
+
+func TestRateLimit_PerClient(t *testing.T) {
+	router, acmeKey := newAuthRouter(t, auth.ScopeSensorsRead)
+	_, otherKey, err := router.apiKeys.Issue(context.Background(), "acme", "other", []string{auth.ScopeSensorsRead})
+	require.NoError(t, err)
+	router.rateLimits = map[string]ratelimit.Limiter{
+		spatialRateLimit: ratelimit.NewMemoryLimiter(0.1, 1),
+	}
+	router.store = &MockSensorStore{}
+	router.trustForwardedFor = true
+
+	// Each API key has its own bucket, even from different addresses
+	url := "/sensors/closest?location=45.12,-90.34&radius=50km"
+	rr := httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-API-Key": acmeKey, "X-Forwarded-For": "198.51.100.1"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-API-Key": acmeKey, "X-Forwarded-For": "198.51.100.2"})
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	rr = httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-API-Key": otherKey, "X-Forwarded-For": "198.51.100.3"})
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Each address also has its own bucket, shared by its API keys
+	rr = httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-API-Key": otherKey, "X-Forwarded-For": "198.51.100.1"})
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+
+	// Unauthenticated clients are limited by IP address
+	router.apiKeys = nil
+	rr = httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-Forwarded-For": "203.0.113.1"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-Forwarded-For": "203.0.113.1"})
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	rr = httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-Forwarded-For": "203.0.113.2"})
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRateLimit_InvalidCredentials(t *testing.T) {
+	router, _ := newAuthRouter(t, auth.ScopeSensorsRead)
+	router.rateLimits = map[string]ratelimit.Limiter{
+		standardRateLimit: ratelimit.NewMemoryLimiter(0.1, 2),
+	}
+
+	// Clients are limited before they are authenticated, so they can't try keys without limit
+	headers := map[string]string{"X-API-Key": "sk_0123456789abcdef_0123"}
+	for i := 0; i < 2; i++ {
+		rr := httpRequestWithHeaders(t, router, "GET", "/sensors/abc123", "", headers)
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+	rr := httpRequestWithHeaders(t, router, "GET", "/sensors/abc123", "", headers)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+func TestRateLimit_SpoofedForwardedFor(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(&MockSensorStore{}),
+		WithRateLimits(nil, ratelimit.NewMemoryLimiter(0.1, 1)),
+		WithTrustedProxy(),
+	)
+	url := "/sensors/closest?location=45.12,-90.34&radius=50km"
+
+	rr := httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-Forwarded-For": "203.0.113.1"})
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// The client sends its own X-Forwarded-For header, which the proxy appends to.
+	// Spoofed addresses don't get a new bucket.
+	for _, spoofed := range []string{"198.51.100.1", "198.51.100.2, 10.0.0.1"} {
+		rr = httpRequestWithHeaders(t, router, "GET", url, "", map[string]string{"X-Forwarded-For": spoofed + ", 203.0.113.1"})
+		require.Equal(t, http.StatusTooManyRequests, rr.Code, spoofed)
+	}
+}
+
+func TestRateLimit_LimiterError(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithRateLimits(failingLimiter{}, nil),
+	)
+
+	// Requests are allowed if the limiter fails
+	rr := httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("failingLimiter failing for tests, on purpose")
+}
@@ -1,75 +1,297 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/health"
+	"github.com/eschwartz/go-sensor-api/internal/app/metrics"
+	"github.com/eschwartz/go-sensor-api/internal/app/ratelimit"
 	"github.com/eschwartz/go-sensor-api/internal/app/store"
 	"github.com/gorilla/mux"
+	grpchealth "google.golang.org/grpc/health"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"regexp"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Regexp for parsing radius query parameters
 // eg "50km"
 var radiusParamRegexp = regexp.MustCompile("([0-9]+)(km|mi)")
 
+// Radius of GET /sensors/closest, if the radius param is omitted
+const defaultRadiusParam = "20km"
+
+// Sensors per page of GET /sensors, if the limit param is omitted
+const defaultListLimit = 100
+
+// Most sensors per page of GET /sensors
+const maxListLimit = 1000
+
 // Regexp for parsing location query parameter
 // eg 45.12,-90.34
 var latLonRegexp = regexp.MustCompile("^(-?[0-9]+\\.?[0-9]*),(-?[0-9]+\\.?[0-9]*)$")
 
 type SensorRouter struct {
 	store store.SensorStore
+	// Records changes made through the store, for gRPC WatchChanges
+	changes *store.ChangeFeed
+	// Used to geocode place names. May be nil, if geocoding is not configured.
+	geo geo.GeoService
+	// If true, sensors are stored with a reverse geocoded place name. May be changed by Reload.
+	enrichPlaceNames atomic.Bool
+	// Used to authenticate API keys
+	apiKeys *auth.APIKeyService
+	// Used to authenticate JWT bearer tokens, if configured.
+	// If both apiKeys and tokens are nil, authentication is disabled.
+	tokens *auth.JWTValidator
+	// Rate limiters, by bucket. Buckets without a limiter are not limited.
+	rateLimits map[string]ratelimit.Limiter
+	// If true, rate limit unauthenticated clients by the address their proxy added to X-Forwarded-For (eg. behind a load balancer)
+	trustForwardedFor bool
+	// Metrics served at GET /metrics. May be nil, if metrics are disabled.
+	metrics *metrics.Registry
+	// Records HTTP request metrics, in the metrics registry
+	requestMetrics *requestMetrics
+	// Largest request body accepted. Unlimited if 0. Replaced by Serve.
+	maxBodyBytes int64
+	// Checks dependencies, for readiness probes. May be nil, if there are no dependencies to check.
+	health *health.Checker
+	// Set when the server is shutting down, so that readiness probes fail
+	draining atomic.Bool
+	// Status reported by gRPC health checks
+	grpcHealth *grpchealth.Server
+	// Closed when the gRPC server starts shutting down, to end WatchChanges streams
+	grpcStopping     chan struct{}
+	grpcStoppingOnce sync.Once
+	// gRPC servers started by StartGRPC, which Serve waits for before closing the router's resources
+	grpcServing sync.WaitGroup
+	// Closed by Close, eg. the database connection pool
+	closers []io.Closer
+	// Logs errors and requests. Uses slog.Default() if nil.
+	log *slog.Logger
+	// Returns the current time. Uses time.Now if nil.
+	now func() time.Time
+	// Additional middleware, wrapping the routes
+	middleware []func(http.Handler) http.Handler
 }
 
-func NewSensorRouter() (*SensorRouter, error) {
-	dbUrl := os.Getenv("DATABASE_URL")
-	if dbUrl == "" {
-		return nil, errors.New("must set DATABASE_URL")
+// FromConfig creates a router, and connects to the dependencies in the configuration.
+// cfg should be valid (see config.Load).
+func FromConfig(cfg *config.Config) (*SensorRouter, error) {
+	// Optionally, enforce tenant isolation with row-level security policies (see scripts/db-rls.sql)
+	var storeOpts []store.PostgisOption
+	if cfg.TenantRLS {
+		storeOpts = append(storeOpts, store.WithRowLevelSecurity())
+	}
+	backend, err := store.Backend(cfg.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	sensorStore, err := store.Open(cfg.DatabaseURL, storeOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := metrics.NewRegistry()
+	if pool, ok := sensorStore.(interface{ Stats() sql.DBStats }); ok {
+		metrics.RegisterDBStats(registry, pool.Stats)
+	}
+	if counter, ok := sensorStore.(store.SensorCounter); ok {
+		metrics.RegisterSensorTotals(registry, counter)
+	}
+
+	// Resources to release when the router is closed
+	var closers []io.Closer
+	if closer, ok := sensorStore.(io.Closer); ok {
+		closers = append(closers, closer)
 	}
 
-	postgisStore, err := store.NewPostgisStore(dbUrl)
+	geoServices, err := newGeoService(cfg, registry)
 	if err != nil {
 		return nil, err
 	}
+	var geoService, geoProviders geo.GeoService
+	if geoServices != nil {
+		geoService, geoProviders = geoServices.cached, geoServices.providers
+		closers = append(closers, geoServices.closers...)
+	}
 
-	return &SensorRouter{
-		store: postgisStore,
-	}, nil
+	healthChecker, err := newHealthChecker(cfg.Health, sensorStore, geoProviders)
+	if err != nil {
+		return nil, err
+	}
+
+	// Require API keys or tokens, unless explicitly disabled (eg. for local development)
+	var apiKeys *auth.APIKeyService
+	var tokens *auth.JWTValidator
+	if cfg.Auth.Disabled {
+		slog.Warn("authentication is disabled (AUTH_DISABLED=true). Anyone can create or update sensors.")
+	} else {
+		if keyStore, ok := sensorStore.(store.APIKeyStore); ok {
+			apiKeys = auth.NewAPIKeyService(keyStore)
+		}
+		tokens, err = newJWTValidator(cfg.Auth.JWT)
+		if err != nil {
+			return nil, err
+		}
+		// Without either, every request would be allowed
+		if apiKeys == nil && tokens == nil {
+			return nil, fmt.Errorf("the %s sensor store doesn't support API keys: configure JWT authentication, or set AUTH_DISABLED=true", backend)
+		}
+	}
+
+	rateLimits, err := newRateLimits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, limiter := range rateLimits {
+		if closer, ok := limiter.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
+	}
+
+	opts := []Option{
+		WithStore(metrics.NewStoreMetrics(registry).Instrument(store.NewTracedStore(sensorStore, backend))),
+		WithEnrichPlaceNames(cfg.Geocoder.EnrichPlaceNames),
+		WithAuthentication(apiKeys, tokens),
+		WithRateLimits(rateLimits[standardRateLimit], rateLimits[spatialRateLimit]),
+		WithMaxBodyBytes(cfg.Server.MaxBodyBytes),
+		WithMetrics(registry),
+		WithHealthChecker(healthChecker),
+		WithClosers(closers...),
+	}
+	if geoService != nil {
+		opts = append(opts, WithGeocoder(geoService))
+	}
+	if cfg.RateLimit.TrustProxy {
+		opts = append(opts, WithTrustedProxy())
+	}
+	return NewSensorRouter(opts...), nil
+}
+
+// Reload applies the settings which may change while the router is serving requests
+// (those tagged reload:"true" in config.Config, other than the log level)
+func (router *SensorRouter) Reload(cfg *config.Config) {
+	router.enrichPlaceNames.Store(cfg.Geocoder.EnrichPlaceNames)
+	router.reloadRateLimits(cfg.RateLimit)
 }
 
 func (router *SensorRouter) Handler() http.Handler {
-	r := mux.NewRouter()
+	// Apply middleware in reverse, so that the first is the outermost
+	var handler http.Handler = router.routes()
+	for i := len(router.middleware) - 1; i >= 0; i-- {
+		handler = router.middleware[i](handler)
+	}
 
-	// GET /health - Health Check
+	return withRequestID(withTracing(router.withAccessLog(router.withRequestMetrics(withMaxBodySize(router.maxBodyBytes, handler)))))
+}
+
+// routes registers the API's routes. Each route must be described in openapi.json.
+func (router *SensorRouter) routes() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(recordRoute)
+	// Unmatched requests get problems, like other errors
+	r.NotFoundHandler = WithJSONHandler(func(r *http.Request) (interface{}, int, error) {
+		return nil, http.StatusNotFound, fmt.Errorf("no route matches %s", r.URL.Path)
+	})
+	r.MethodNotAllowedHandler = WithJSONHandler(func(r *http.Request) (interface{}, int, error) {
+		return nil, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed for %s", r.Method, r.URL.Path)
+	})
+
+	// GET /health - Health Check. Deprecated: use /health/live and /health/ready
 	r.HandleFunc("/health", WithJSONHandler(router.HealthCheckHandler)).
 		Methods("GET")
 
+	// GET /health/live - Liveness probe
+	r.HandleFunc("/health/live", WithJSONHandler(router.LivenessHandler)).
+		Methods("GET")
+
+	// GET /health/ready - Readiness probe, checking dependencies
+	r.HandleFunc("/health/ready", WithJSONHandler(router.ReadinessHandler)).
+		Methods("GET")
+
+	// GET /metrics - Prometheus metrics, for admins
+	if router.metrics != nil {
+		r.HandleFunc("/metrics", router.withAccessControlHandler(auth.ScopeAdmin, standardRateLimit, router.metrics.Handler())).
+			Methods("GET")
+	}
+
+	// GET /openapi.json - OpenAPI document, describing the API
+	r.HandleFunc("/openapi.json", OpenAPIHandler).
+		Methods("GET")
+
 	// POST /sensors - Create Sensor
-	r.HandleFunc("/sensors", WithJSONHandler(router.CreateSensorHandler)).
+	r.HandleFunc("/sensors", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsWrite, standardRateLimit, withRequestValidation(router.CreateSensorHandler)))).
 		Methods("POST").
 		Headers("Content-Type", "application/json")
 
+	// GET /sensors?limit=&cursor= - List sensors, a page at a time
+	r.HandleFunc("/sensors", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsRead, standardRateLimit, withRequestValidation(router.ListSensorsHandler)))).
+		Methods("GET")
+
 	// GET /sensors/closest?location=&radius=
-	r.HandleFunc("/sensors/closest", WithJSONHandler(router.FindClosestSensor)).
-		Queries("location", "{location}", "radius", "{radius}")
+	r.HandleFunc("/sensors/closest", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsRead, spatialRateLimit, withRequestValidation(router.FindClosestSensor)))).
+		Methods("GET")
+
+	// GET /sensors/{name}/place - Get the place name for a sensor's location
+	r.HandleFunc("/sensors/{name}/place", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsRead, standardRateLimit, withRequestValidation(router.GetSensorPlaceHandler)))).
+		Methods("GET")
+
+	// GET /geocode/suggest?q=&near= - Autocomplete place names
+	r.HandleFunc("/geocode/suggest", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsRead, spatialRateLimit, withRequestValidation(router.SuggestPlacesHandler)))).
+		Methods("GET")
 
 	// GET /sensors/{name} - Get Sensor by Name
-	r.HandleFunc("/sensors/{name}", WithJSONHandler(router.GetSensorByNameHandler)).
+	r.HandleFunc("/sensors/{name}", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsRead, standardRateLimit, withRequestValidation(router.GetSensorByNameHandler)))).
 		Methods("GET")
 
 	// PUT /sensors/{name} - Update Sensor by Name
-	r.HandleFunc("/sensors/{name}", WithJSONHandler(router.UpdateSensorByNameHandler)).
+	r.HandleFunc("/sensors/{name}", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsWrite, standardRateLimit, withRequestValidation(router.UpdateSensorByNameHandler)))).
 		Methods("PUT")
 
+	// DELETE /sensors/{name} - Delete Sensor by Name
+	r.HandleFunc("/sensors/{name}", WithJSONHandler(router.withAccessControl(auth.ScopeSensorsWrite, standardRateLimit, withRequestValidation(router.DeleteSensorByNameHandler)))).
+		Methods("DELETE")
+
+	// POST /admin/api-keys - Issue an API key
+	r.HandleFunc("/admin/api-keys", WithJSONHandler(router.withAccessControl(auth.ScopeAdmin, standardRateLimit, withRequestValidation(router.IssueAPIKeyHandler)))).
+		Methods("POST")
+
+	// GET /admin/api-keys - List API keys
+	r.HandleFunc("/admin/api-keys", WithJSONHandler(router.withAccessControl(auth.ScopeAdmin, standardRateLimit, withRequestValidation(router.ListAPIKeysHandler)))).
+		Methods("GET")
+
+	// POST /admin/api-keys/{id}/rotate - Replace an API key's secret
+	r.HandleFunc("/admin/api-keys/{id}/rotate", WithJSONHandler(router.withAccessControl(auth.ScopeAdmin, standardRateLimit, withRequestValidation(router.RotateAPIKeyHandler)))).
+		Methods("POST")
+
+	// DELETE /admin/api-keys/{id} - Revoke an API key
+	r.HandleFunc("/admin/api-keys/{id}", WithJSONHandler(router.withAccessControl(auth.ScopeAdmin, standardRateLimit, withRequestValidation(router.RevokeAPIKeyHandler)))).
+		Methods("DELETE")
+
 	return r
 }
 
 func (router *SensorRouter) HealthCheckHandler(r *http.Request) (interface{}, int, error) {
+	// Tell load balancers to stop sending requests, while we shut down
+	if router.draining.Load() {
+		return map[string]bool{
+			"ok": false,
+		}, http.StatusServiceUnavailable, nil
+	}
+
 	return map[string]bool{
 		"ok": true,
 	}, http.StatusOK, nil
@@ -82,41 +304,91 @@ func (router *SensorRouter) CreateSensorHandler(r *http.Request) (interface{}, i
 		// Errors are mostly likely caused by malformed request bodies
 		// Here's a nice write-up on decoder error handling, if we want something
 		// more precise: https://www.alexedwards.net/blog/how-to-properly-parse-a-json-request-body
-		return nil, 400, err
+		return nil, requestBodyErrorStatus(err), err
 	}
 
-	// Store the new sensor
-	createdSensor, err := router.store.Create(sensor)
+	// Lookup the sensor's place name (if enabled), then store the new sensor
+	sensors := router.sensorStore(r)
+	var createdSensor *store.Sensor
+	err = router.enrichNewSensor(r.Context(), sensors, sensor)
+	if err == nil {
+		createdSensor, err = sensors.Create(r.Context(), sensor)
+	}
 	if err != nil {
+		// If the name is taken, return a 409
+		var duplicateErr *store.DuplicateResourceError
+		if errors.As(err, &duplicateErr) {
+			return nil, http.StatusConflict, err
+		}
+
 		// Unknown error from store, log and respond as 500
-		log.Printf("failed to create sensor: %s", err)
+		router.logger().ErrorContext(r.Context(), "failed to create sensor", "sensor", sensor.Name, "error", err)
 		return nil, 500, fmt.Errorf("failed to store sensor: %w", errors.New("internal server error"))
 	}
 
+	router.audit(r.Context(), "created", "sensor "+createdSensor.Name)
+
 	return SensorDetailsResponse{*createdSensor}, http.StatusCreated, nil
 }
 
+func (router *SensorRouter) ListSensorsHandler(r *http.Request) (interface{}, int, error) {
+	query := r.URL.Query()
+	limit := defaultListLimit
+	if limitParam := query.Get("limit"); limitParam != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return nil, http.StatusBadRequest,
+				fmt.Errorf("invalid value for \"limit\": must be between 1 and %d", maxListLimit)
+		}
+	}
+
+	// Cursors are the (encoded) name of the last sensor on the previous page
+	after, err := base64.RawURLEncoding.DecodeString(query.Get("cursor"))
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("invalid value for \"cursor\": must be a next_cursor from a previous page")
+	}
+
+	// Retrieve one more sensor than requested, to tell whether there's another page
+	sensors, err := router.sensorStore(r).List(r.Context(), string(after), limit+1)
+	if err != nil {
+		router.logger().ErrorContext(r.Context(), "failed to list sensors", "error", err)
+		return nil, http.StatusInternalServerError, errors.New("failed to list sensors: internal server error")
+	}
+
+	res := SensorPageResponse{Data: sensors}
+	if len(sensors) > limit {
+		res.Data = sensors[:limit]
+		res.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(sensors[limit-1].Name))
+	}
+	if res.Data == nil {
+		res.Data = []*store.Sensor{}
+	}
+
+	return res, http.StatusOK, nil
+}
+
 func (router *SensorRouter) GetSensorByNameHandler(r *http.Request) (interface{}, int, error) {
 	// Get sensor {name} from URL
 	vars := mux.Vars(r)
 	name, ok := vars["name"]
 	if !ok {
 		// Missing {name} means we probably misconfigured the route
-		log.Println("GET /sensors/{name} request is missing the \"name\" var.")
+		router.logger().ErrorContext(r.Context(), "request is missing the \"name\" route var")
 		return nil, http.StatusInternalServerError, errors.New("interval server error")
 	}
 
 	// Retrieve sensor from data store
-	sensor, err := router.store.GetByName(name)
+	sensor, err := router.sensorStore(r).GetByName(r.Context(), name)
 	if err != nil {
 		// Unknown error from store, log and respond as 500
-		log.Printf("failed to retrieve sensor by name \"%s\": %s", name, err)
+		router.logger().ErrorContext(r.Context(), "failed to retrieve sensor", "sensor", name, "error", err)
 		return nil, http.StatusInternalServerError, errors.New("failed to retrieve sensor: interval server error")
 	}
 
 	// Handle no matching sensor
 	if sensor == nil {
-		return nil, http.StatusNotFound, &store.MissingResourceError{name, "sensor"}
+		return nil, http.StatusNotFound, &store.MissingResourceError{ID: name, ResourceType: "sensor"}
 	}
 
 	return SensorDetailsResponse{*sensor}, http.StatusOK, nil
@@ -124,14 +396,14 @@ func (router *SensorRouter) GetSensorByNameHandler(r *http.Request) (interface{}
 
 func (router *SensorRouter) FindClosestSensor(r *http.Request) (interface{}, int, error) {
 	// Load query params
-	vars := mux.Vars(r)
-	locationParam, ok := vars["location"]
-	if !ok {
+	query := r.URL.Query()
+	locationParam := query.Get("location")
+	if locationParam == "" {
 		return nil, http.StatusBadRequest, errors.New("missing required \"location\" param")
 	}
-	radiusParam, ok := vars["radius"]
-	if !ok {
-		return nil, http.StatusBadRequest, errors.New("missing required \"radius\" param")
+	radiusParam := query.Get("radius")
+	if radiusParam == "" {
+		radiusParam = defaultRadiusParam
 	}
 
 	// Parse radius, eg "50km"
@@ -142,7 +414,7 @@ func (router *SensorRouter) FindClosestSensor(r *http.Request) (interface{}, int
 	}
 	// If the regex matches, we should always have 2 groups. If not, we didn't something wrong here
 	if len(radiusMatch) != 3 {
-		log.Printf("GET /sensors/closest: Unexpected number of regexp match groups for radius: \"%s\"", radiusParam)
+		router.logger().ErrorContext(r.Context(), "unexpected number of regexp match groups for radius", "radius", radiusParam)
 		return nil, http.StatusInternalServerError, errors.New("internal server error")
 	}
 	// Convert radius to meters
@@ -165,14 +437,27 @@ func (router *SensorRouter) FindClosestSensor(r *http.Request) (interface{}, int
 	// Parse location, eg 45.12,-90.34
 	locationMatch := latLonRegexp.FindStringSubmatch(locationParam)
 	if locationMatch == nil {
+		if router.geo == nil {
+			return nil, http.StatusBadRequest,
+				errors.New("invalid value for \"location\": must be formatted like \"45.12,-90.34")
+		}
+
 		// Attempt to geocode the location, assuming it's a place name / address
+		lat, lon, err := router.geo.Geocode(r.Context(), locationParam)
+		if errors.Is(err, geo.ErrPlaceNotFound) {
+			return nil, http.StatusBadRequest,
+				fmt.Errorf("invalid value for \"location\": no location found at \"%s\"", locationParam)
+		}
+		if err != nil {
+			router.logger().ErrorContext(r.Context(), "failed to geocode location", "location", locationParam, "error", err)
+			return nil, http.StatusBadGateway, errors.New("failed to geocode location")
+		}
 
-		return nil, http.StatusBadRequest,
-			errors.New("invalid value for \"location\": must be formatted like \"45.12,-90.34")
+		return router.findClosest(r, lat, lon, radiusMeters)
 	}
 	// If the regex matches, we should always have 2 groups. If not, we didn't something wrong here
 	if len(locationMatch) != 3 {
-		log.Printf("GET /sensors/closest: Unexpected number of regexp match groups for location: \"%s\"", locationParam)
+		router.logger().ErrorContext(r.Context(), "unexpected number of regexp match groups for location", "location", locationParam)
 		return nil, http.StatusInternalServerError, errors.New("internal server error")
 	}
 	lat, err := strconv.ParseFloat(locationMatch[1], 64)
@@ -186,10 +471,14 @@ func (router *SensorRouter) FindClosestSensor(r *http.Request) (interface{}, int
 			errors.New("invalid value for \"location\": must be formatted like \"45.12,-90.34")
 	}
 
+	return router.findClosest(r, lat, lon, radiusMeters)
+}
+
+func (router *SensorRouter) findClosest(r *http.Request, lat float64, lon float64, radiusMeters int) (interface{}, int, error) {
 	// Lookup closest sensors
-	sensors, err := router.store.FindClosest(lat, lon, radiusMeters)
+	sensors, err := router.sensorStore(r).FindClosest(r.Context(), lat, lon, radiusMeters)
 	if err != nil {
-		log.Printf("GET /sensors/closest failed to FindClosest(): %s", err)
+		router.logger().ErrorContext(r.Context(), "failed to find closest sensors", "error", err)
 		return nil, http.StatusInternalServerError, errors.New("internal server error")
 	}
 
@@ -202,34 +491,189 @@ func (router *SensorRouter) UpdateSensorByNameHandler(r *http.Request) (interfac
 	name, ok := vars["name"]
 	if !ok {
 		// Missing {name} means we probably misconfigured the route
-		log.Println("PUT /sensors/{name} request is missing the \"name\" var.")
+		router.logger().ErrorContext(r.Context(), "request is missing the \"name\" route var")
 		return nil, http.StatusInternalServerError, errors.New("interval server error")
 	}
 
 	// Decode sensor JSON body
 	sensor, err := decodeSensorJSON(r.Body)
 	if err != nil {
-		// Invalid request body, respond with 400
-		return nil, http.StatusBadRequest, err
+		// Invalid request body, respond with 400 (or 413, if it is too large)
+		return nil, requestBodyErrorStatus(err), err
 	}
+	sensors := router.sensorStore(r)
 
-	// Update the sensor in the data store
-	sensor, err = router.store.UpdateByName(name, sensor)
+	// Lookup the sensor's place name (if enabled and the location has changed),
+	// then update the sensor in the data store
+	err = router.enrichUpdatedSensor(r.Context(), sensors, name, sensor)
+	if err == nil {
+		sensor, err = sensors.UpdateByName(r.Context(), name, sensor)
+	}
 	if err != nil {
 		// If there's not matching resource, return a 404
 		var missingErr *store.MissingResourceError
 		if errors.As(err, &missingErr) {
 			return nil, http.StatusNotFound, err
 		}
+		// If the sensor was renamed to the name of another sensor, return a 409
+		var duplicateErr *store.DuplicateResourceError
+		if errors.As(err, &duplicateErr) {
+			return nil, http.StatusConflict, err
+		}
 
 		// Any other errors are treated as 500s
-		log.Printf("failed to update store in PUT /sensors/%s: %s", name, err)
+		router.logger().ErrorContext(r.Context(), "failed to update sensor", "sensor", name, "error", err)
 		return nil, http.StatusInternalServerError, errors.New("failed to update sensor: internal server error")
 	}
+	router.audit(r.Context(), "updated", "sensor "+name)
 
 	return SensorDetailsResponse{*sensor}, http.StatusOK, nil
 }
 
+func (router *SensorRouter) DeleteSensorByNameHandler(r *http.Request) (interface{}, int, error) {
+	// Get sensor {name} from URL
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		// Missing {name} means we probably misconfigured the route
+		router.logger().ErrorContext(r.Context(), "request is missing the \"name\" route var")
+		return nil, http.StatusInternalServerError, errors.New("interval server error")
+	}
+
+	sensor, err := router.sensorStore(r).DeleteByName(r.Context(), name)
+	if err != nil {
+		// If there's not matching resource, return a 404
+		var missingErr *store.MissingResourceError
+		if errors.As(err, &missingErr) {
+			return nil, http.StatusNotFound, err
+		}
+
+		router.logger().ErrorContext(r.Context(), "failed to delete sensor", "sensor", name, "error", err)
+		return nil, http.StatusInternalServerError, errors.New("failed to delete sensor: internal server error")
+	}
+	router.audit(r.Context(), "deleted", "sensor "+name)
+
+	// Respond with the deleted sensor, eg. so that it can be restored
+	return SensorDetailsResponse{*sensor}, http.StatusOK, nil
+}
+
+func (router *SensorRouter) GetSensorPlaceHandler(r *http.Request) (interface{}, int, error) {
+	// Get sensor {name} from URL
+	vars := mux.Vars(r)
+	name, ok := vars["name"]
+	if !ok {
+		// Missing {name} means we probably misconfigured the route
+		router.logger().ErrorContext(r.Context(), "request is missing the \"name\" route var")
+		return nil, http.StatusInternalServerError, errors.New("interval server error")
+	}
+
+	// Retrieve sensor from data store
+	sensor, err := router.sensorStore(r).GetByName(r.Context(), name)
+	if err != nil {
+		router.logger().ErrorContext(r.Context(), "failed to retrieve sensor", "sensor", name, "error", err)
+		return nil, http.StatusInternalServerError, errors.New("failed to retrieve sensor: interval server error")
+	}
+	if sensor == nil {
+		return nil, http.StatusNotFound, &store.MissingResourceError{ID: name, ResourceType: "sensor"}
+	}
+
+	// Use the stored place name, if we have one
+	placeName := sensor.PlaceName
+	if placeName == "" {
+		if router.geo == nil {
+			return nil, http.StatusNotImplemented, errors.New("reverse geocoding is not configured")
+		}
+
+		placeName, err = router.geo.ReverseGeocode(r.Context(), sensor.Lat, sensor.Lon)
+		if errors.Is(err, geo.ErrPlaceNotFound) {
+			return nil, http.StatusNotFound, fmt.Errorf("no place found at the location of sensor \"%s\"", name)
+		}
+		if err != nil {
+			router.logger().ErrorContext(r.Context(), "failed to reverse geocode sensor location", "sensor", name, "error", err)
+			return nil, http.StatusBadGateway, errors.New("failed to reverse geocode sensor location")
+		}
+	}
+
+	return SensorPlaceResponse{SensorPlace{
+		Name:      sensor.Name,
+		Lat:       sensor.Lat,
+		Lon:       sensor.Lon,
+		PlaceName: placeName,
+	}}, http.StatusOK, nil
+}
+
+// enrichPlaceName sets the sensor's place name, by reverse geocoding its location.
+// If the location is unchanged from the previous version of the sensor, the previous place name is kept.
+//
+// Geocoding failures are logged, but should not prevent the sensor from being stored.
+// enrichNewSensor looks up the place name of a sensor which is about to be created (if enabled).
+// Reverse geocoding may be a paid request, so names which are already taken
+// fail with a *store.DuplicateResourceError before the lookup.
+func (router *SensorRouter) enrichNewSensor(ctx context.Context, sensors store.SensorStore, sensor *store.Sensor) error {
+	if !router.enrichPlaceNames.Load() || router.geo == nil {
+		return nil
+	}
+	existing, err := sensors.GetByName(ctx, sensor.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return &store.DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+	}
+
+	router.enrichPlaceName(ctx, sensor, nil)
+	return nil
+}
+
+// enrichUpdatedSensor looks up the place name of a sensor which is about to be updated,
+// if enabled and its location has changed. Updates which the store would reject fail before the lookup:
+// a missing sensor with a *store.MissingResourceError, and a rename to a taken name with a *store.DuplicateResourceError.
+func (router *SensorRouter) enrichUpdatedSensor(ctx context.Context, sensors store.SensorStore, name string, sensor *store.Sensor) error {
+	if !router.enrichPlaceNames.Load() || router.geo == nil {
+		return nil
+	}
+	previous, err := sensors.GetByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		return &store.MissingResourceError{ID: name, ResourceType: "sensor"}
+	}
+	if sensor.Name != name {
+		existing, err := sensors.GetByName(ctx, sensor.Name)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return &store.DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+		}
+	}
+
+	router.enrichPlaceName(ctx, sensor, previous)
+	return nil
+}
+
+func (router *SensorRouter) enrichPlaceName(ctx context.Context, sensor *store.Sensor, previous *store.Sensor) {
+	if !router.enrichPlaceNames.Load() || router.geo == nil {
+		return
+	}
+
+	if previous != nil && previous.PlaceName != "" &&
+		previous.Lat == sensor.Lat && previous.Lon == sensor.Lon {
+		sensor.PlaceName = previous.PlaceName
+		return
+	}
+
+	placeName, err := router.geo.ReverseGeocode(ctx, sensor.Lat, sensor.Lon)
+	if err != nil && !errors.Is(err, geo.ErrPlaceNotFound) {
+		router.logger().WarnContext(ctx, "failed to reverse geocode sensor", "sensor", sensor.Name, "error", err)
+		return
+	}
+	sensor.PlaceName = placeName
+}
+
+// decodeSensorJSON decodes and validates a sensor from a request body (eg. for POST or PUT).
+// If any fields are invalid, a *store.ValidationError lists all of them.
 func decodeSensorJSON(r io.Reader) (*store.Sensor, error) {
 	// Parse JSON request body
 	decoder := json.NewDecoder(r)
@@ -242,6 +686,12 @@ func decodeSensorJSON(r io.Reader) (*store.Sensor, error) {
 		return nil, fmt.Errorf("invalid request body: %w", err)
 	}
 
+	// Check every field, then store names and tags without extra whitespace or duplicates
+	if err := sensor.Validate(); err != nil {
+		return nil, err
+	}
+	sensor.Normalize()
+
 	return &sensor, nil
 }
 
@@ -252,3 +702,20 @@ type SensorDetailsResponse struct {
 type SensorListResponse struct {
 	Data []*store.Sensor `json:"data"`
 }
+
+type SensorPageResponse struct {
+	Data []*store.Sensor `json:"data"`
+	// Pass as the cursor param, to get the next page. Omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type SensorPlace struct {
+	Name      string  `json:"name"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	PlaceName string  `json:"place_name"`
+}
+
+type SensorPlaceResponse struct {
+	Data SensorPlace `json:"data"`
+}
@@ -2,8 +2,11 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
 	"github.com/eschwartz/go-sensor-api/internal/app/store"
 	"github.com/stretchr/testify/require"
 	"io"
@@ -13,7 +16,7 @@ import (
 )
 
 func TestHealthCheck(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Send GET /health request
 	rr := httpRequest(t, router, "GET", "/health", "")
@@ -24,8 +27,27 @@ func TestHealthCheck(t *testing.T) {
 	require.Equal(t, true, res["ok"])
 }
 
+func TestUnmatchedRoutes(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	// Unmatched requests are problems, like other errors
+	rr := httpRequest(t, router, "GET", "/no/such/path", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	requireProblem(t, rr, "no route matches /no/such/path")
+
+	rr = httpRequest(t, router, "PATCH", "/sensors/abc123", "")
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	require.Equal(t, map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Method Not Allowed",
+		"status": 405.0,
+		"detail": "method PATCH is not allowed for /sensors/abc123",
+	}, unmarshalResponseJSON(t, rr))
+}
+
 func TestCreateSensor(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Create sensor using POST /sensors
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -49,7 +71,7 @@ func TestCreateSensor(t *testing.T) {
 	require.Equal(t, map[string]interface{}{
 		"data": map[string]interface{}{
 			// ID is empty when using the memory store
-			"id":   0.0,
+			"id":   1.0,
 			"name": "abc123",
 			"lat":  44.916241209323736,
 			"lon":  -93.21112681214602,
@@ -63,7 +85,7 @@ func TestCreateSensor(t *testing.T) {
 }
 
 func TestCreateSensor_Invalid(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Create a sensor with an invalid payload
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -76,12 +98,48 @@ func TestCreateSensor_Invalid(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
+func TestCreateSensor_InvalidFields(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	// Every invalid field is reported at once, as a problem
+	rr := httpRequest(t, router, "POST", "/sensors", `{"name": "", "lat": 9999, "lon": -93.2, "tags": ["a", " "]}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	require.Equal(t, map[string]interface{}{
+		"type":   "https://github.com/eschwartz/go-sensor-api#validation-errors",
+		"title":  "Invalid request",
+		"status": 400.0,
+		"detail": "invalid sensor: name must not be empty; lat must be between -90 and 90; tags/1 must not be blank",
+		"errors": []interface{}{
+			map[string]interface{}{"pointer": "/name", "detail": "must not be empty"},
+			map[string]interface{}{"pointer": "/lat", "detail": "must be between -90 and 90"},
+			map[string]interface{}{"pointer": "/tags/1", "detail": "must not be blank"},
+		},
+	}, unmarshalResponseJSON(t, rr))
+	requireMatchesOpenAPI(t, "POST", "/sensors", rr)
+
+	// Nothing is stored
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestCreateSensor_Normalized(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	// Names and tags are trimmed, and duplicate tags are removed
+	rr := httpRequest(t, router, "POST", "/sensors", `{"name": " abc123 ", "lat": 44.9, "lon": -93.2, "tags": ["x", " y", "x", "y "]}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	data := unmarshalResponseJSON(t, rr)["data"].(map[string]interface{})
+	require.Equal(t, "abc123", data["name"])
+	require.Equal(t, []interface{}{"x", "y"}, data["tags"])
+}
+
 func TestCreateSensor_StoreFailure(t *testing.T) {
 	// Use MockSensorStore, to test
 	// the behavior of the API when the storage backend fails
-	router := &SensorRouter{
-		store: &MockSensorStore{returnErrors: true},
-	}
+	router := NewSensorRouter(
+		WithStore(&MockSensorStore{returnErrors: true}),
+	)
 
 	// Attempt to create a sensor, with a failing store
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -96,14 +154,11 @@ func TestCreateSensor_StoreFailure(t *testing.T) {
 	require.Equal(t, http.StatusInternalServerError, rr.Code)
 
 	// Should respond with an error message
-	res := unmarshalResponseJSON(t, rr)
-	require.Equal(t, map[string]interface{}{
-		"error": "failed to store sensor: internal server error",
-	}, res)
+	requireProblem(t, rr, "failed to store sensor: internal server error")
 }
 
 func TestGetSensorByName(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Create sensor using POST /sensors
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -129,7 +184,7 @@ func TestGetSensorByName(t *testing.T) {
 	res := unmarshalResponseJSON(t, rr)
 	require.Equal(t, map[string]interface{}{
 		"data": map[string]interface{}{
-			"id":   0.0,
+			"id":   1.0,
 			"name": "abc123",
 			"lat":  44.916241209323736,
 			"lon":  -93.21112681214602,
@@ -142,8 +197,57 @@ func TestGetSensorByName(t *testing.T) {
 	}, res)
 }
 
+func TestListSensors(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+	for _, name := range []string{"STP", "MPLS", "CHI"} {
+		rr := httpRequest(t, router, "POST", "/sensors", fmt.Sprintf(`{"name": "%s", "lat": 44.9, "lon": -93.2}`, name))
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	// Sensors are listed by name, a page at a time
+	rr := httpRequest(t, router, "GET", "/sensors?limit=2", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	requireMatchesOpenAPI(t, "GET", "/sensors", rr)
+	res := unmarshalResponseJSON(t, rr)
+	data := res["data"].([]interface{})
+	require.Len(t, data, 2)
+	require.Equal(t, "CHI", data[0].(map[string]interface{})["name"])
+	require.Equal(t, "MPLS", data[1].(map[string]interface{})["name"])
+	require.NotEmpty(t, res["next_cursor"])
+
+	// The last page has no next_cursor
+	rr = httpRequest(t, router, "GET", "/sensors?limit=2&cursor="+res["next_cursor"].(string), "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	res = unmarshalResponseJSON(t, rr)
+	data = res["data"].([]interface{})
+	require.Len(t, data, 1)
+	require.Equal(t, "STP", data[0].(map[string]interface{})["name"])
+	require.NotContains(t, res, "next_cursor")
+
+	// Stores without sensors have an empty page
+	router = NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+	rr = httpRequest(t, router, "GET", "/sensors", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, map[string]interface{}{"data": []interface{}{}}, unmarshalResponseJSON(t, rr))
+}
+
+func TestListSensors_Invalid(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	for url, expected := range map[string]string{
+		"/sensors?limit=0":       "invalid value for \"limit\": must be between 1 and 1000",
+		"/sensors?limit=1001":    "invalid value for \"limit\": must be between 1 and 1000",
+		"/sensors?limit=abc":     "invalid value for \"limit\": must be an integer",
+		"/sensors?cursor=not+ok": "invalid value for \"cursor\": must be a next_cursor from a previous page",
+	} {
+		rr := httpRequest(t, router, "GET", url, "")
+		require.Equal(t, http.StatusBadRequest, rr.Code, url)
+		requireProblem(t, rr, expected, url)
+	}
+}
+
 func TestGetSensorByName_Missing(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Get a sensor that doesn't exist, using GET /sensors/:name
 	rr := httpRequest(t, router, "GET", "/sensors/not-a-sensor", "")
@@ -152,14 +256,11 @@ func TestGetSensorByName_Missing(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, rr.Code)
 
 	// Should include an error message
-	res := unmarshalResponseJSON(t, rr)
-	require.Equal(t, map[string]interface{}{
-		"error": "no sensor resource exists: not-a-sensor",
-	}, res)
+	requireProblem(t, rr, "no sensor resource exists: not-a-sensor")
 }
 
 func TestGetSensor_StoreFailure(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Create sensor using POST /sensors
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -182,17 +283,14 @@ func TestGetSensor_StoreFailure(t *testing.T) {
 	require.Equal(t, http.StatusInternalServerError, rr.Code)
 
 	// Should return the sensor that we created earlier
-	res := unmarshalResponseJSON(t, rr)
-	require.Equal(t, map[string]interface{}{
-		"error": "failed to retrieve sensor: interval server error",
-	}, res)
+	requireProblem(t, rr, "failed to retrieve sensor: interval server error")
 }
 
 func TestFindClosestSensor(t *testing.T) {
 	mockStore := &MockSensorStore{}
-	router := &SensorRouter{
-		store: mockStore,
-	}
+	router := NewSensorRouter(
+		WithStore(mockStore),
+	)
 
 	// Mock the store to return sensor values
 	// (FindClosest is not implemented in the MemoryStore)
@@ -246,8 +344,56 @@ func TestFindClosestSensor(t *testing.T) {
 	}{44.91, -93.22, 100e3}, mockStore.findClosestResArgs)
 }
 
+func TestFindClosestSensor_PlaceName(t *testing.T) {
+	mockStore := &MockSensorStore{findClosestRes: []*store.Sensor{}}
+	router := NewSensorRouter(
+		WithStore(mockStore),
+		WithGeocoder(&MockGeoService{places: map[string][2]float64{
+			"Minneapolis": {44.97, -93.26},
+		}}),
+	)
+
+	// Query the API for sensors closest to a place name
+	rr := httpRequest(t, router, "GET", "/sensors/closest?location=Minneapolis&radius=10km", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Should query the store using the geocoded location
+	require.Equal(t, struct {
+		lat          float64
+		lon          float64
+		radiusMeters int
+	}{44.97, -93.26, 10e3}, mockStore.findClosestResArgs)
+}
+
+func TestFindClosestSensor_DefaultRadius(t *testing.T) {
+	mockStore := &MockSensorStore{findClosestRes: []*store.Sensor{}}
+	router := NewSensorRouter(WithStore(mockStore))
+
+	// The radius is optional
+	rr := httpRequest(t, router, "GET", "/sensors/closest?location=44.91,-93.22", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, 20000, mockStore.findClosestResArgs.radiusMeters)
+
+	// The location is not
+	rr = httpRequest(t, router, "GET", "/sensors/closest?radius=10km", "")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	requireProblem(t, rr, "missing required \"location\" param")
+}
+
+func TestFindClosestSensor_PlaceNameNotFound(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(&MockSensorStore{}),
+		WithGeocoder(&MockGeoService{}),
+	)
+
+	rr := httpRequest(t, router, "GET", "/sensors/closest?location=Atlantis&radius=10km", "")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	requireProblem(t, rr, "invalid value for \"location\": no location found at \"Atlantis\"")
+}
+
 func TestUpdateSensorByName(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Create sensor to work with using POST /sensors
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -284,7 +430,7 @@ func TestUpdateSensorByName(t *testing.T) {
 	putRes := unmarshalResponseJSON(t, rr)
 	require.Equal(t, map[string]interface{}{
 		"data": map[string]interface{}{
-			"id":   0.0,
+			"id":   1.0,
 			"name": "abc123",
 			"lat":  -36.8779565276809,
 			"lon":  174.7881226266269744,
@@ -303,7 +449,7 @@ func TestUpdateSensorByName(t *testing.T) {
 
 	require.Equal(t, map[string]interface{}{
 		"data": map[string]interface{}{
-			"id":   0.0,
+			"id":   1.0,
 			"name": "abc123",
 			"lat":  -36.8779565276809,
 			"lon":  174.7881226266269744,
@@ -316,8 +462,30 @@ func TestUpdateSensorByName(t *testing.T) {
 	}, getRes, "GET response")
 }
 
+func TestSensor_DuplicateName(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+	for _, name := range []string{"abc123", "xyz789"} {
+		rr := httpRequest(t, router, "POST", "/sensors", `{"name": "`+name+`", "lat": 44.9, "lon": -93.2, "tags": []}`)
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	// Creating a sensor with an existing name should return a 409
+	rr := httpRequest(t, router, "POST", "/sensors", `{"name": "abc123", "lat": 10, "lon": 20, "tags": []}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	requireProblem(t, rr, "a sensor resource already exists: abc123")
+
+	// The existing sensor is unchanged
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, 44.9, unmarshalResponseJSON(t, rr)["data"].(map[string]interface{})["lat"])
+
+	// Renaming a sensor to an existing name should also return a 409
+	rr = httpRequest(t, router, "PUT", "/sensors/abc123", `{"name": "xyz789", "lat": 10, "lon": 20, "tags": []}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+}
+
 func TestUpdateSensorByName_Missing(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Update a sensor that doesn't exist, using PUT /sensors/not-a-sensor
 	rr := httpRequest(t, router, "PUT", "/sensors/not-a-sensor", `
@@ -335,14 +503,11 @@ func TestUpdateSensorByName_Missing(t *testing.T) {
 
 	// Should return a 404
 	require.Equal(t, http.StatusNotFound, rr.Code)
-	res := unmarshalResponseJSON(t, rr)
-	require.Equal(t, map[string]interface{}{
-		"error": "no sensor resource exists: not-a-sensor",
-	}, res)
+	requireProblem(t, rr, "no sensor resource exists: not-a-sensor")
 }
 
 func TestUpdateSensorByName_Invalid(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Create sensor to work with using POST /sensors
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -369,14 +534,32 @@ func TestUpdateSensorByName_Invalid(t *testing.T) {
 	`)
 	require.Equal(t, http.StatusBadRequest, rr.Code)
 
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
 	res := unmarshalResponseJSON(t, rr)
-	require.Equal(t, map[string]interface{}{
-		"error": "invalid request body: json: unknown field \"not\"",
-	}, res)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"pointer": "/name", "detail": "is required"},
+		map[string]interface{}{"pointer": "/lat", "detail": "is required"},
+		map[string]interface{}{"pointer": "/lon", "detail": "is required"},
+		map[string]interface{}{"pointer": "/not", "detail": "is not a known field"},
+		map[string]interface{}{"pointer": "/sensor", "detail": "is not a known field"},
+	}, res["errors"])
+
+	// Update the sensor, with invalid fields
+	rr = httpRequest(t, router, "PUT", "/sensors/abc123", `{"name": "abc/123", "lat": 44.9, "lon": 200}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	res = unmarshalResponseJSON(t, rr)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"pointer": "/name", "detail": "must start with a letter or digit, and contain only letters, digits, \".\", \"_\", \"~\" and \"-\""},
+		map[string]interface{}{"pointer": "/lon", "detail": "must be between -180 and 180"},
+	}, res["errors"])
+
+	// The sensor is unchanged
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, -93.21112681214602, unmarshalResponseJSON(t, rr)["data"].(map[string]interface{})["lon"])
 }
 
 func TestUpdateSensor_StoreFailure(t *testing.T) {
-	router := &SensorRouter{store.NewMemorySensorStore()}
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
 
 	// Create sensor using POST /sensors
 	rr := httpRequest(t, router, "POST", "/sensors", `
@@ -410,13 +593,310 @@ func TestUpdateSensor_StoreFailure(t *testing.T) {
 	require.Equal(t, http.StatusInternalServerError, rr.Code)
 
 	// Should return the sensor that we created earlier
+	requireProblem(t, rr, "failed to update sensor: internal server error")
+}
+
+func TestDeleteSensorByName(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	rr := httpRequest(t, router, "POST", "/sensors", `{"name": "abc123", "lat": 44.916241209323736, "lon": -93.21112681214602, "tags": ["x"]}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	// Delete the sensor, using DELETE /sensors/:name
+	rr = httpRequest(t, router, "DELETE", "/sensors/abc123", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	requireMatchesOpenAPI(t, "DELETE", "/sensors/{name}", rr)
+
+	// Should respond with the deleted sensor
+	require.Equal(t, map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   1.0,
+			"name": "abc123",
+			"lat":  44.916241209323736,
+			"lon":  -93.21112681214602,
+			"tags": []interface{}{"x"},
+		},
+	}, unmarshalResponseJSON(t, rr))
+
+	rr = httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Missing sensors respond with a 404
+	rr = httpRequest(t, router, "DELETE", "/sensors/abc123", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	requireProblem(t, rr, "no sensor resource exists: abc123")
+}
+
+func TestDeleteSensor_StoreFailure(t *testing.T) {
+	router := NewSensorRouter(WithStore(&MockSensorStore{returnErrors: true}))
+
+	rr := httpRequest(t, router, "DELETE", "/sensors/abc123", "")
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	requireProblem(t, rr, "failed to delete sensor: internal server error")
+}
+
+func TestGetSensorPlace(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(&MockGeoService{placeNames: map[string]string{
+			"44.97,-93.26": "Minneapolis, Minnesota, United States",
+		}}),
+	)
+
+	// Create sensor using POST /sensors
+	rr := httpRequest(t, router, "POST", "/sensors", `
+		{
+		  "name": "abc123",
+		  "lat": 44.97,
+		  "lon": -93.26,
+		  "tags": []
+		}
+	`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	// Get the sensor's place, using GET /sensors/:name/place
+	rr = httpRequest(t, router, "GET", "/sensors/abc123/place", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
 	res := unmarshalResponseJSON(t, rr)
 	require.Equal(t, map[string]interface{}{
-		"error": "failed to update sensor: internal server error",
+		"data": map[string]interface{}{
+			"name":       "abc123",
+			"lat":        44.97,
+			"lon":        -93.26,
+			"place_name": "Minneapolis, Minnesota, United States",
+		},
 	}, res)
 }
 
+func TestGetSensorPlace_NotFound(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(&MockGeoService{}),
+	)
+
+	// Create a sensor, somewhere without a place name
+	rr := httpRequest(t, router, "POST", "/sensors", `
+		{
+		  "name": "abc123",
+		  "lat": 0,
+		  "lon": 0,
+		  "tags": []
+		}
+	`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = httpRequest(t, router, "GET", "/sensors/abc123/place", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Missing sensors should also 404
+	rr = httpRequest(t, router, "GET", "/sensors/not-a-sensor/place", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetSensorPlace_NotConfigured(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	rr := httpRequest(t, router, "POST", "/sensors", `
+		{
+		  "name": "abc123",
+		  "lat": 44.97,
+		  "lon": -93.26,
+		  "tags": []
+		}
+	`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	// Should respond with a 501, if there is no geocoder
+	rr = httpRequest(t, router, "GET", "/sensors/abc123/place", "")
+	require.Equal(t, http.StatusNotImplemented, rr.Code)
+}
+
+func TestSuggestPlaces(t *testing.T) {
+	geoService := &MockGeoService{suggestions: map[string][]geo.Suggestion{
+		"Minn": {
+			{
+				Name: "Minneapolis, Minnesota, United States",
+				Lat:  44.97,
+				Lon:  -93.26,
+				BBox: &geo.BoundingBox{-93.33, 44.89, -93.19, 45.05},
+				Type: "place",
+			},
+			{Name: "Minnehaha Falls", Lat: 44.91, Lon: -93.21, Type: "poi"},
+		},
+	}}
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(geoService),
+	)
+
+	// Create sensors in and outside of Minneapolis
+	for _, body := range []string{
+		`{"name": "MPLS", "lat": 44.97, "lon": -93.26, "tags": []}`,
+		`{"name": "CHI", "lat": 41.86, "lon": -87.68, "tags": []}`,
+	} {
+		rr := httpRequest(t, router, "POST", "/sensors", body)
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+
+	rr := httpRequest(t, router, "GET", "/geocode/suggest?q=Minn&near=44.95,-93.1&sensor_counts=true", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Should return suggestions, with sensor counts for places with a bbox
+	res := unmarshalResponseJSON(t, rr)
+	require.Equal(t, map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{
+				"name":         "Minneapolis, Minnesota, United States",
+				"lat":          44.97,
+				"lon":          -93.26,
+				"bbox":         []interface{}{-93.33, 44.89, -93.19, 45.05},
+				"type":         "place",
+				"sensor_count": 1.0,
+			},
+			map[string]interface{}{
+				"name": "Minnehaha Falls",
+				"lat":  44.91,
+				"lon":  -93.21,
+				"type": "poi",
+			},
+		},
+	}, res)
+
+	// Should bias suggestions towards the "near" location
+	require.Equal(t, &geo.Point{Lat: 44.95, Lon: -93.1}, geoService.suggestNear)
+
+	// Should not count sensors, unless requested
+	rr = httpRequest(t, router, "GET", "/geocode/suggest?q=Minn", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, rr.Body.String(), "sensor_count")
+	require.Nil(t, geoService.suggestNear)
+}
+
+func TestSuggestPlaces_NoResults(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(&MockGeoService{}),
+	)
+
+	rr := httpRequest(t, router, "GET", "/geocode/suggest?q=Atlantis", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, map[string]interface{}{"data": []interface{}{}}, unmarshalResponseJSON(t, rr))
+}
+
+func TestSuggestPlaces_InvalidParams(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(&MockGeoService{}),
+	)
+
+	tests := []struct {
+		url   string
+		error string
+	}{
+		{"/geocode/suggest", "missing required \"q\" param"},
+		{"/geocode/suggest?q=%20", "missing required \"q\" param"},
+		{"/geocode/suggest?q=Minn&near=Minneapolis", "invalid value for \"near\": must be formatted like \"45.12,-90.34\""},
+		{"/geocode/suggest?q=Minn&near=95,-93", "invalid value for \"near\": must be formatted like \"45.12,-90.34\""},
+		{"/geocode/suggest?q=Minn&sensor_counts=yes", "invalid value for \"sensor_counts\": must be \"true\" or \"false\""},
+	}
+	for _, test := range tests {
+		rr := httpRequest(t, router, "GET", test.url, "")
+		require.Equal(t, http.StatusBadRequest, rr.Code, test.url)
+		requireProblem(t, rr, test.error, test.url)
+	}
+}
+
+func TestSuggestPlaces_NotConfigured(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()))
+
+	// Should respond with a 501, if there is no geocoder
+	rr := httpRequest(t, router, "GET", "/geocode/suggest?q=Minn", "")
+	require.Equal(t, http.StatusNotImplemented, rr.Code)
+}
+
+func TestSuggestPlaces_Unsupported(t *testing.T) {
+	// The public Nominatim instance doesn't allow autocomplete
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(geo.NewFallbackGeoService(geo.FallbackProvider{Name: "nominatim", Service: geo.NewNominatimGeoService()})),
+	)
+
+	rr := httpRequest(t, router, "GET", "/geocode/suggest?q=Minn", "")
+	require.Equal(t, http.StatusNotImplemented, rr.Code)
+	requireProblem(t, rr, "place suggestions are not supported by the configured geocoding providers")
+}
+
+func TestEnrichPlaceName(t *testing.T) {
+	geoService := &MockGeoService{placeNames: map[string]string{
+		"44.97,-93.26": "Minneapolis, Minnesota, United States",
+		"44.95,-93.09": "Saint Paul, Minnesota, United States",
+	}}
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithGeocoder(geoService),
+	)
+	router.enrichPlaceNames.Store(true)
+
+	// Create a sensor, which should be stored with a place name
+	rr := httpRequest(t, router, "POST", "/sensors", `
+		{
+		  "name": "abc123",
+		  "lat": 44.97,
+		  "lon": -93.26,
+		  "tags": []
+		}
+	`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	res := unmarshalResponseJSON(t, rr)
+	require.Equal(t, "Minneapolis, Minnesota, United States", res["data"].(map[string]interface{})["place_name"])
+	require.Equal(t, 1, geoService.reverseGeocodeCalls)
+
+	// Update the sensor's tags: the place name should be kept, without another lookup
+	rr = httpRequest(t, router, "PUT", "/sensors/abc123", `
+		{
+		  "name": "abc123",
+		  "lat": 44.97,
+		  "lon": -93.26,
+		  "tags": ["x"]
+		}
+	`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	res = unmarshalResponseJSON(t, rr)
+	require.Equal(t, "Minneapolis, Minnesota, United States", res["data"].(map[string]interface{})["place_name"])
+	require.Equal(t, 1, geoService.reverseGeocodeCalls)
+
+	// Move the sensor: the place name should be updated
+	rr = httpRequest(t, router, "PUT", "/sensors/abc123", `
+		{
+		  "name": "abc123",
+		  "lat": 44.95,
+		  "lon": -93.09,
+		  "tags": ["x"]
+		}
+	`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	res = unmarshalResponseJSON(t, rr)
+	require.Equal(t, "Saint Paul, Minnesota, United States", res["data"].(map[string]interface{})["place_name"])
+	require.Equal(t, 2, geoService.reverseGeocodeCalls)
+
+	// Requests which the store would reject don't look up a place name
+	rr = httpRequest(t, router, "POST", "/sensors", `{"name": "abc123", "lat": 44.97, "lon": -93.26, "tags": []}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	rr = httpRequest(t, router, "PUT", "/sensors/not-a-sensor", `{"name": "not-a-sensor", "lat": 44.97, "lon": -93.26, "tags": []}`)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = httpRequest(t, router, "POST", "/sensors", `{"name": "xyz789", "lat": 1, "lon": 2, "tags": []}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	rr = httpRequest(t, router, "PUT", "/sensors/xyz789", `{"name": "abc123", "lat": 44.97, "lon": -93.26, "tags": []}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Equal(t, 3, geoService.reverseGeocodeCalls)
+}
+
 func httpRequest(t *testing.T, router *SensorRouter, method string, url string, body string) *httptest.ResponseRecorder {
+	return httpRequestWithHeaders(t, router, method, url, body, nil)
+}
+
+func httpRequestWithHeaders(t *testing.T, router *SensorRouter, method string, url string, body string, headers map[string]string) *httptest.ResponseRecorder {
 	handler := router.Handler()
 	rr := httptest.NewRecorder()
 
@@ -432,6 +912,9 @@ func httpRequest(t *testing.T, router *SensorRouter, method string, url string,
 
 	// Set application/json header
 	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
 	// Send request
 	handler.ServeHTTP(rr, req)
@@ -447,6 +930,18 @@ func unmarshalResponseJSON(t *testing.T, rr *httptest.ResponseRecorder) map[stri
 	return res
 }
 
+// requireProblem checks that the response is an RFC 7807 problem, of the class for its status, with the given detail
+func requireProblem(t *testing.T, rr *httptest.ResponseRecorder, detail string, msgAndArgs ...interface{}) {
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"), msgAndArgs...)
+	class := problemClasses[rr.Code]
+	require.Equal(t, map[string]interface{}{
+		"type":   class.typ,
+		"title":  class.title,
+		"status": float64(rr.Code),
+		"detail": detail,
+	}, unmarshalResponseJSON(t, rr), msgAndArgs...)
+}
+
 // MockSensorStore is a mock implementation of SensorStore.
 // In most cases, integration tests should use an in-memory store
 // but there are some edge cases where mocking is appropriate
@@ -460,9 +955,16 @@ type MockSensorStore struct {
 		lon          float64
 		radiusMeters int
 	}
+	// Tenant passed to ForTenant()
+	tenantID string
+}
+
+func (s *MockSensorStore) ForTenant(tenantID string) store.SensorStore {
+	s.tenantID = tenantID
+	return s
 }
 
-func (s *MockSensorStore) FindClosest(lat float64, lon float64, radiusMeters int) ([]*store.Sensor, error) {
+func (s *MockSensorStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*store.Sensor, error) {
 	s.findClosestResArgs = struct {
 		lat          float64
 		lon          float64
@@ -480,23 +982,84 @@ func (s *MockSensorStore) FindClosest(lat float64, lon float64, radiusMeters int
 	return s.findClosestRes, nil
 }
 
-func (s *MockSensorStore) Create(sensor *store.Sensor) (*store.Sensor, error) {
+func (s *MockSensorStore) List(ctx context.Context, after string, limit int) ([]*store.Sensor, error) {
+	if s.returnErrors {
+		return nil, errors.New("MockSensorStore.List() failing for tests, on purpose")
+	}
+
+	return []*store.Sensor{}, nil
+}
+
+func (s *MockSensorStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	if s.returnErrors {
+		return 0, errors.New("MockSensorStore.CountWithinBounds() failing for tests, on purpose")
+	}
+	panic("mock method not implemented")
+}
+
+func (s *MockSensorStore) Create(ctx context.Context, sensor *store.Sensor) (*store.Sensor, error) {
 	if s.returnErrors {
 		return nil, errors.New("MockSensorStore.Create() failing for tests, on purpose")
 	}
 	panic("mock method not implemented")
 }
 
-func (s *MockSensorStore) GetByName(name string) (*store.Sensor, error) {
+func (s *MockSensorStore) GetByName(ctx context.Context, name string) (*store.Sensor, error) {
 	if s.returnErrors {
 		return nil, errors.New("MockSensorStore.GetByName() failing for tests, on purpose")
 	}
 	panic("mock method not implemented")
 }
 
-func (s *MockSensorStore) UpdateByName(name string, sensor *store.Sensor) (*store.Sensor, error) {
+func (s *MockSensorStore) UpdateByName(ctx context.Context, name string, sensor *store.Sensor) (*store.Sensor, error) {
 	if s.returnErrors {
 		return nil, errors.New("MockSensorStore.UpdateByName() failing for tests, on purpose")
 	}
 	panic("mock method not implemented")
 }
+
+func (s *MockSensorStore) DeleteByName(ctx context.Context, name string) (*store.Sensor, error) {
+	if s.returnErrors {
+		return nil, errors.New("MockSensorStore.DeleteByName() failing for tests, on purpose")
+	}
+	panic("mock method not implemented")
+}
+
+// MockGeoService geocodes places from a fixed map
+type MockGeoService struct {
+	places map[string][2]float64
+	// Reverse geocoded place names, by "{lat},{lon}"
+	placeNames map[string]string
+	// Number of calls to ReverseGeocode()
+	reverseGeocodeCalls int
+	// Suggestions, by query
+	suggestions map[string][]geo.Suggestion
+	// Location passed to the last call to Suggest()
+	suggestNear *geo.Point
+}
+
+func (svc *MockGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	latLon, ok := svc.places[place]
+	if !ok {
+		return 0, 0, geo.ErrPlaceNotFound
+	}
+	return latLon[0], latLon[1], nil
+}
+
+func (svc *MockGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	svc.reverseGeocodeCalls++
+	placeName, ok := svc.placeNames[fmt.Sprintf("%v,%v", lat, lon)]
+	if !ok {
+		return "", geo.ErrPlaceNotFound
+	}
+	return placeName, nil
+}
+
+func (svc *MockGeoService) Suggest(ctx context.Context, query string, near *geo.Point) ([]geo.Suggestion, error) {
+	svc.suggestNear = near
+	suggestions, ok := svc.suggestions[query]
+	if !ok {
+		return []geo.Suggestion{}, nil
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Serve serves the API on the listener, until ctx is cancelled (eg. on SIGTERM).
+// It then shuts down gracefully:
+//  1. readiness probes start failing, so load balancers stop sending new requests
+//  2. after the drain delay, the server stops accepting connections,
+//     and waits for in-flight requests to complete (up to the shutdown timeout)
+//  3. the router's resources (eg. the database connection pool) are closed, once the gRPC server (see StartGRPC) has also stopped
+func (router *SensorRouter) Serve(ctx context.Context, listener net.Listener, cfg config.Server) error {
+	server := &http.Server{
+		Handler:           router.Handler(),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(router.logger().Handler(), slog.LevelWarn),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The server failed, before we were asked to stop
+		return errors.Join(err, router.Close())
+	case <-ctx.Done():
+	}
+
+	router.logger().Info("shutting down: failing readiness checks", "drain_delay", cfg.DrainDelay.String())
+	router.draining.Store(true)
+	// Ask keep-alive clients to reconnect, so they find another instance
+	server.SetKeepAlivesEnabled(false)
+	time.Sleep(cfg.DrainDelay)
+
+	router.logger().Info("shutting down: waiting for in-flight requests", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	shutdownErr := server.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		// Requests are still running, so cut them off
+		shutdownErr = fmt.Errorf("failed to drain requests: %w", shutdownErr)
+		_ = server.Close()
+	}
+
+	// gRPC calls may still be using the store
+	router.grpcServing.Wait()
+	return errors.Join(shutdownErr, router.Close())
+}
+
+// Close releases the router's resources, eg. the database connection pool
+func (router *SensorRouter) Close() error {
+	var errs []error
+	for _, closer := range router.closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// withMaxBodySize limits request bodies to maxBytes, or does nothing if maxBytes is 0.
+// Handlers see an *http.MaxBytesError when they read past the limit, and should respond with a 413.
+func withMaxBodySize(maxBytes int64, next http.Handler) http.Handler {
+	if maxBytes == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestBodyErrorStatus is the status code for an error reading a request body
+func requestBodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
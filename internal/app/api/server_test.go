@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingStore blocks GetByName until released, to simulate a slow request
+type blockingStore struct {
+	store.SensorStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingStore() *blockingStore {
+	return &blockingStore{
+		SensorStore: store.NewMemorySensorStore(),
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+}
+
+func (s *blockingStore) ForTenant(tenantID string) store.SensorStore {
+	return s
+}
+
+func (s *blockingStore) GetByName(ctx context.Context, name string) (*store.Sensor, error) {
+	close(s.started)
+	<-s.release
+	return s.SensorStore.GetByName(ctx, name)
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// startServer serves the router on a random port, until ctx is cancelled.
+// Returns the server's URL, and a channel receiving the result of Serve.
+func startServer(t *testing.T, ctx context.Context, router *SensorRouter, cfg config.Server) (string, chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	captureLogs(t)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- router.Serve(ctx, listener, cfg)
+	}()
+
+	return "http://" + listener.Addr().String(), serveErr
+}
+
+func TestServe_GracefulShutdown(t *testing.T) {
+	sensors := newBlockingStore()
+	closed := false
+	router := NewSensorRouter(
+		WithStore(sensors),
+		WithClosers(closerFunc(func() error { closed = true; return nil })),
+	)
+	cfg := config.Default().Server
+	cfg.DrainDelay = 200 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	url, serveErr := startServer(t, ctx, router, cfg)
+
+	// Start a slow request
+	slowRes := make(chan *http.Response, 1)
+	slowErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get(url + "/sensors/abc123")
+		slowRes <- res
+		slowErr <- err
+	}()
+	<-sensors.started
+
+	// Readiness fails as soon as shutdown starts, while requests are still served
+	res, err := http.Get(url + "/health")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	cancel()
+	require.Eventually(t, func() bool {
+		res, err := http.Get(url + "/health")
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	// The in-flight request completes, before the server stops
+	select {
+	case err := <-serveErr:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(2 * cfg.DrainDelay):
+	}
+	close(sensors.release)
+	res = <-slowRes
+	require.NoError(t, <-slowErr)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	res.Body.Close()
+
+	require.NoError(t, <-serveErr)
+	require.True(t, closed)
+
+	// New connections are refused
+	_, err = http.Get(url + "/health")
+	require.Error(t, err)
+}
+
+func TestServe_ShutdownTimeout(t *testing.T) {
+	sensors := newBlockingStore()
+	defer close(sensors.release)
+	closed := false
+	router := NewSensorRouter(
+		WithStore(sensors),
+		WithClosers(closerFunc(func() error { closed = true; return nil })),
+	)
+	cfg := config.Default().Server
+	cfg.DrainDelay = 0
+	cfg.ShutdownTimeout = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	url, serveErr := startServer(t, ctx, router, cfg)
+
+	go func() {
+		res, err := http.Get(url + "/sensors/abc123")
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-sensors.started
+	cancel()
+
+	// Requests which take too long are cut off
+	err := <-serveErr
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "failed to drain requests")
+	require.True(t, closed)
+}
+
+func TestServe_CloseErrors(t *testing.T) {
+	router := NewSensorRouter(
+		WithStore(store.NewMemorySensorStore()),
+		WithClosers(closerFunc(func() error { return errors.New("failed to close db") }),
+			closerFunc(func() error { return errors.New("failed to close cache") })),
+	)
+	err := router.Close()
+	require.ErrorContains(t, err, "failed to close db")
+	require.ErrorContains(t, err, "failed to close cache")
+}
+
+func TestMaxBodySize(t *testing.T) {
+	router := NewSensorRouter(WithStore(store.NewMemorySensorStore()), WithMaxBodyBytes(100))
+
+	body := fmt.Sprintf(`{"name": "abc123", "lat": 44.5, "lon": -93.2, "tags": ["%s"]}`, strings.Repeat("x", 100))
+	rr := httpRequest(t, router, "POST", "/sensors", body)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	require.Contains(t, rr.Body.String(), "request body too large")
+
+	rr = httpRequest(t, router, "POST", "/sensors", `{"name": "abc123", "lat": 44.5, "lon": -93.2}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	// Serve keeps the router's limit, whatever the server configuration
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	url, _ := startServer(t, ctx, router, config.Server{})
+	res, err := http.Post(url+"/sensors", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+}
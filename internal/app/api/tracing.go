@@ -0,0 +1,35 @@
+package api
+
+import (
+	"github.com/eschwartz/go-sensor-api/internal/app/logging"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"net/http"
+)
+
+// withTracing records a server span for each request.
+// The span continues the trace in the request's traceparent header, if there is one.
+func withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, info := withRequestInfo(tracing.Extract(r.Context(), r.Header))
+		ctx, span := tracing.Start(ctx, r.Method, tracing.SpanKindServer,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("url.path", r.URL.Path),
+			tracing.String("user_agent.original", r.UserAgent()),
+			tracing.String("http.request_id", logging.RequestID(ctx)),
+		)
+		defer span.End()
+
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		// Name the span by route template, rather than path, so that spans for the same endpoint can be grouped
+		if info.route != "" {
+			span.SetName(r.Method + " " + info.route)
+			span.SetAttributes(tracing.String("http.route", info.route))
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", rw.status))
+		if rw.status >= 500 {
+			span.SetStatus(tracing.StatusError, http.StatusText(rw.status))
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing/tracingtest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracingtest.Install(t)
+	logs := captureLogs(t)
+	router := NewSensorRouter(WithStore(store.NewTracedStore(store.NewMemorySensorStore(), "memory")))
+
+	rr := httpRequestWithHeaders(t, router, "GET", "/sensors/abc123", "", map[string]string{
+		"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"X-Request-ID": "req-123",
+	})
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	// The request continues the caller's trace
+	server := recorder.Span(t, "GET /sensors/{name}")
+	require.Equal(t, tracing.SpanKindServer, server.Kind)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", server.ParentSpanID.String())
+	require.Equal(t, "/sensors/{name}", tracingtest.Attribute(server, "http.route"))
+	require.Equal(t, "/sensors/abc123", tracingtest.Attribute(server, "url.path"))
+	require.Equal(t, int64(http.StatusNotFound), tracingtest.Attribute(server, "http.response.status_code"))
+	require.Equal(t, "req-123", tracingtest.Attribute(server, "http.request_id"))
+	require.Equal(t, tracing.StatusUnset, server.Status)
+
+	// Store calls are children of the request
+	query := recorder.Span(t, "SensorStore.GetByName")
+	require.Equal(t, server.SpanContext.TraceID, query.SpanContext.TraceID)
+	require.Equal(t, server.SpanContext.SpanID, query.ParentSpanID)
+
+	// The access log links to the trace
+	access := logRecords(t, logs)[0]
+	require.Equal(t, "request", access["msg"])
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", access["trace_id"])
+	require.Equal(t, server.SpanContext.SpanID.String(), access["span_id"])
+}
+
+func TestTracing_ServerErrors(t *testing.T) {
+	recorder := tracingtest.Install(t)
+	captureLogs(t)
+	router := NewSensorRouter(WithStore(store.NewTracedStore(&MockSensorStore{returnErrors: true}, "mock")))
+
+	rr := httpRequest(t, router, "GET", "/sensors/abc123", "")
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	// Without a traceparent header, a new trace is started
+	server := recorder.Span(t, "GET /sensors/{name}")
+	require.False(t, server.ParentSpanID.IsValid())
+	require.Equal(t, tracing.StatusError, server.Status)
+	require.Equal(t, tracing.StatusError, recorder.Span(t, "SensorStore.GetByName").Status)
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"strings"
+)
+
+// API keys look like "sk_{prefix}_{secret}".
+// The prefix is used to look up the key, and may be shown in listings and logs.
+const (
+	apiKeyType         = "sk"
+	apiKeyPrefixBytes  = 8
+	apiKeySecretBytes  = 32
+	apiKeyPrefixLength = len(apiKeyType) + 1 + apiKeyPrefixBytes*2
+)
+
+// APIKeyService issues and authenticates API keys
+type APIKeyService struct {
+	store store.APIKeyStore
+}
+
+func NewAPIKeyService(keyStore store.APIKeyStore) *APIKeyService {
+	return &APIKeyService{
+		store: keyStore,
+	}
+}
+
+// Issue creates a new API key with the given scopes, granting access to a tenant's sensors.
+// Returns the stored key, and the secret key string, which cannot be retrieved again.
+func (svc *APIKeyService) Issue(ctx context.Context, tenantID string, name string, scopes []string) (*store.APIKey, string, error) {
+	if tenantID == "" {
+		return nil, "", errors.New("a tenant is required")
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return nil, "", fmt.Errorf("%w \"%s\": must be one of %s", ErrInvalidScope, scope, strings.Join(Scopes, ", "))
+		}
+	}
+
+	prefix, secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := svc.store.CreateAPIKey(ctx, &store.APIKey{
+		TenantID: tenantID,
+		Name:     name,
+		Prefix:   prefix,
+		Hash:     hashAPIKey(secret),
+		Scopes:   scopes,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	return key, secret, nil
+}
+
+// Authenticate checks an API key, and returns the principal it belongs to.
+// Returns ErrInvalidCredentials if the key is unknown or revoked.
+func (svc *APIKeyService) Authenticate(ctx context.Context, secret string) (*Principal, error) {
+	if len(secret) <= apiKeyPrefixLength || !strings.HasPrefix(secret, apiKeyType+"_") {
+		return nil, ErrInvalidCredentials
+	}
+
+	key, err := svc.store.GetAPIKeyByPrefix(ctx, secret[:apiKeyPrefixLength])
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve API key: %w", err)
+	}
+	if key == nil || key.RevokedAt != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if subtle.ConstantTimeCompare(key.Hash, hashAPIKey(secret)) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{
+		Subject:  "api-key:" + key.Prefix,
+		Name:     key.Name,
+		TenantID: key.TenantID,
+		Scopes:   key.Scopes,
+	}, nil
+}
+
+// List returns a tenant's API keys
+func (svc *APIKeyService) List(ctx context.Context, tenantID string) ([]*store.APIKey, error) {
+	return svc.store.ListAPIKeys(ctx, tenantID)
+}
+
+// Rotate replaces an API key's secret, keeping its name and scopes.
+// The old secret stops working immediately.
+func (svc *APIKeyService) Rotate(ctx context.Context, tenantID string, id int64) (*store.APIKey, string, error) {
+	prefix, secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := svc.store.RotateAPIKey(ctx, tenantID, id, prefix, hashAPIKey(secret))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (svc *APIKeyService) Revoke(ctx context.Context, tenantID string, id int64) (*store.APIKey, error) {
+	return svc.store.RevokeAPIKey(ctx, tenantID, id)
+}
+
+// generateAPIKey returns the prefix of a new random key, and the full key
+func generateAPIKey() (string, string, error) {
+	buf := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	prefix := apiKeyType + "_" + hex.EncodeToString(buf[:apiKeyPrefixBytes])
+	return prefix, prefix + "_" + hex.EncodeToString(buf[apiKeyPrefixBytes:]), nil
+}
+
+// hashAPIKey hashes a key for storage.
+// Keys are long and random, so a fast hash is sufficient (unlike passwords).
+func hashAPIKey(secret string) []byte {
+	hash := sha256.Sum256([]byte(secret))
+	return hash[:]
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyService(t *testing.T) {
+	keyStore := store.NewMemoryAPIKeyStore()
+	svc := NewAPIKeyService(keyStore)
+	ctx := context.Background()
+
+	key, secret, err := svc.Issue(ctx, "acme", "ingest", []string{ScopeSensorsRead, ScopeSensorsWrite})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(secret, key.Prefix+"_"))
+	require.Len(t, secret, apiKeyPrefixLength+1+apiKeySecretBytes*2)
+
+	// Should only store a hash of the key
+	stored, err := keyStore.GetAPIKeyByPrefix(ctx, key.Prefix)
+	require.NoError(t, err)
+	require.NotContains(t, string(stored.Hash), secret)
+
+	principal, err := svc.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	require.Equal(t, &Principal{
+		Subject:  "api-key:" + key.Prefix,
+		Name:     "ingest",
+		TenantID: "acme",
+		Scopes:   []string{ScopeSensorsRead, ScopeSensorsWrite},
+	}, principal)
+}
+
+func TestAPIKeyService_Tenants(t *testing.T) {
+	svc := NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	ctx := context.Background()
+
+	key, _, err := svc.Issue(ctx, "acme", "ingest", []string{ScopeSensorsRead})
+	require.NoError(t, err)
+
+	// Keys can only be managed within their own tenant
+	keys, err := svc.List(ctx, "globex")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	_, _, err = svc.Rotate(ctx, "globex", key.ID)
+	var missingErr *store.MissingResourceError
+	require.ErrorAs(t, err, &missingErr)
+	_, err = svc.Revoke(ctx, "globex", key.ID)
+	require.ErrorAs(t, err, &missingErr)
+
+	keys, err = svc.List(ctx, "acme")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	// Keys must belong to a tenant
+	_, _, err = svc.Issue(ctx, "", "ingest", []string{ScopeSensorsRead})
+	require.Error(t, err)
+}
+
+func TestAPIKeyService_InvalidKeys(t *testing.T) {
+	svc := NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	ctx := context.Background()
+
+	key, secret, err := svc.Issue(ctx, "acme", "ingest", []string{ScopeSensorsRead})
+	require.NoError(t, err)
+
+	for _, invalid := range []string{
+		"",
+		"not-a-key",
+		key.Prefix,
+		// Right prefix, wrong secret
+		key.Prefix + "_" + strings.Repeat("0", apiKeySecretBytes*2),
+		// Unknown prefix
+		"sk_0000000000000000" + secret[apiKeyPrefixLength:],
+	} {
+		_, err := svc.Authenticate(ctx, invalid)
+		require.ErrorIs(t, err, ErrInvalidCredentials, invalid)
+	}
+}
+
+func TestAPIKeyService_Rotate(t *testing.T) {
+	svc := NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	ctx := context.Background()
+
+	key, oldSecret, err := svc.Issue(ctx, "acme", "ingest", []string{ScopeSensorsRead})
+	require.NoError(t, err)
+
+	rotated, newSecret, err := svc.Rotate(ctx, "acme", key.ID)
+	require.NoError(t, err)
+	require.Equal(t, key.ID, rotated.ID)
+	require.NotEqual(t, oldSecret, newSecret)
+
+	// Old secret should stop working
+	_, err = svc.Authenticate(ctx, oldSecret)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	principal, err := svc.Authenticate(ctx, newSecret)
+	require.NoError(t, err)
+	require.Equal(t, "ingest", principal.Name)
+}
+
+func TestAPIKeyService_Revoke(t *testing.T) {
+	svc := NewAPIKeyService(store.NewMemoryAPIKeyStore())
+	ctx := context.Background()
+
+	key, secret, err := svc.Issue(ctx, "acme", "ingest", []string{ScopeSensorsRead})
+	require.NoError(t, err)
+
+	revoked, err := svc.Revoke(ctx, "acme", key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+
+	_, err = svc.Authenticate(ctx, secret)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// Revoked keys can't be rotated
+	_, _, err = svc.Rotate(ctx, "acme", key.ID)
+	var missingErr *store.MissingResourceError
+	require.ErrorAs(t, err, &missingErr)
+}
+
+func TestAPIKeyService_InvalidScope(t *testing.T) {
+	svc := NewAPIKeyService(store.NewMemoryAPIKeyStore())
+
+	_, _, err := svc.Issue(context.Background(), "acme", "ingest", []string{"sensors:delete"})
+	require.ErrorIs(t, err, ErrInvalidScope)
+	_, _, err = svc.Issue(context.Background(), "acme", "ingest", nil)
+	require.ErrorIs(t, err, ErrInvalidScope)
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	reader := &Principal{Scopes: []string{ScopeSensorsRead}}
+	require.True(t, reader.HasScope(ScopeSensorsRead))
+	require.False(t, reader.HasScope(ScopeSensorsWrite))
+
+	// Admins have every scope
+	admin := &Principal{Scopes: []string{ScopeAdmin}}
+	require.True(t, admin.HasScope(ScopeSensorsWrite))
+	require.True(t, admin.HasScope(ScopeAdmin))
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// Scopes grant access to groups of API operations
+const (
+	// Read sensors, and search for sensors by location
+	ScopeSensorsRead = "sensors:read"
+	// Create and update sensors
+	ScopeSensorsWrite = "sensors:write"
+	// Manage API keys. Admins are granted every other scope.
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every valid scope
+var Scopes = []string{ScopeSensorsRead, ScopeSensorsWrite, ScopeAdmin}
+
+var (
+	// ErrInvalidCredentials is returned when a credential is malformed, unknown, or revoked
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidScope is returned when issuing a credential with an unknown scope
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Principal is an authenticated API client
+type Principal struct {
+	// Unique identifier for the client, eg. "api-key:sk_0123456789abcdef", or a JWT "sub" claim
+	Subject string
+	// Human-readable name for the client
+	Name string
+	// Organization whose sensors the client may access
+	TenantID string
+	// Roles claimed by the client's identity provider, if any
+	Roles  []string
+	Scopes []string
+}
+
+// HasScope checks if the principal has been granted a scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidScope checks if a scope is one of Scopes
+func ValidScope(scope string) bool {
+	for _, valid := range Scopes {
+		if scope == valid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authenticator verifies credentials (eg. an API key or a bearer token),
+// and returns the principal they belong to.
+// Returns an error wrapping ErrInvalidCredentials if the credentials are not valid.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credentials string) (*Principal, error)
+}
+
+type principalContextKey struct{}
+
+// NewContext returns a context carrying the authenticated principal
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// FromContext returns the authenticated principal, or nil if the request was not authenticated
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,155 @@
+// Package authtest signs JWTs with locally generated keys, for testing token authentication
+package authtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Signer signs JWTs with a generated RS256 or ES256 key
+type Signer struct {
+	t   testing.TB
+	Kid string
+	Alg string
+	key crypto.Signer
+}
+
+// NewRSASigner generates an RSA key, for signing RS256 tokens
+func NewRSASigner(t testing.TB, kid string) *Signer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+
+	return &Signer{t: t, Kid: kid, Alg: "RS256", key: key}
+}
+
+// NewECSigner generates a P-256 key, for signing ES256 tokens
+func NewECSigner(t testing.TB, kid string) *Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %s", err)
+	}
+
+	return &Signer{t: t, Kid: kid, Alg: "ES256", key: key}
+}
+
+// Sign creates a signed token with the given claims
+func (s *Signer) Sign(claims map[string]interface{}) string {
+	return s.SignWithHeader(map[string]interface{}{"alg": s.Alg, "typ": "JWT", "kid": s.Kid}, claims)
+}
+
+// SignWithHeader creates a signed token with a custom header, eg. to test an unexpected "alg"
+func (s *Signer) SignWithHeader(header map[string]interface{}, claims map[string]interface{}) string {
+	signingInput := s.encode(header) + "." + s.encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			s.t.Fatalf("failed to sign token: %s", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, sig, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			s.t.Fatalf("failed to sign token: %s", err)
+		}
+		signature = append(padBytes(r, 32), padBytes(sig, 32)...)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Tenant is the "tenant_id" claim of tokens created with Claims
+const Tenant = "acme"
+
+// Claims returns standard claims for a token which expires in an hour
+func Claims(subject string, roles ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":       subject,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"iat":       time.Now().Unix(),
+		"tenant_id": Tenant,
+		"roles":     roles,
+	}
+}
+
+// jwk is a JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// jwk returns the signer's public key, as a JSON Web Key
+func (s *Signer) jwk() jwk {
+	switch key := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return jwk{
+			Kty: "RSA",
+			Kid: s.Kid,
+			Use: "sig",
+			Alg: s.Alg,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		return jwk{
+			Kty: "EC",
+			Kid: s.Kid,
+			Use: "sig",
+			Alg: s.Alg,
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(padBytes(key.X, 32)),
+			Y:   base64.RawURLEncoding.EncodeToString(padBytes(key.Y, 32)),
+		}
+	}
+
+	return jwk{}
+}
+
+// JWKS returns a JSON Web Key Set, containing the public keys of the signers
+func JWKS(signers ...*Signer) []byte {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for _, signer := range signers {
+		set.Keys = append(set.Keys, signer.jwk())
+	}
+	data, _ := json.Marshal(set)
+
+	return data
+}
+
+func (s *Signer) encode(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		s.t.Fatalf("failed to encode token: %s", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// padBytes encodes n as a fixed-length big-endian byte slice
+func padBytes(n *big.Int, size int) []byte {
+	buf := make([]byte, size)
+	return n.FillBytes(buf)
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSCacheTTL       = time.Hour
+	defaultJWKSRefreshBackoff = 30 * time.Second
+	maxJWKSResponseBytes      = 1 << 20
+)
+
+var (
+	// ErrUnknownKey is returned when a token is signed by a key which is not in the key set
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrKeysUnavailable is returned when signing keys can't be fetched (eg. the identity provider is down).
+	// Tokens can't be verified, but they aren't known to be invalid.
+	ErrKeysUnavailable = errors.New("signing keys are unavailable")
+)
+
+// KeySource provides public keys for verifying token signatures
+type KeySource interface {
+	// PublicKey returns the key with the given key ID ("kid").
+	// Returns ErrUnknownKey if there is no such key.
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWK is a JSON Web Key. Only RSA and EC (P-256) public keys are supported.
+// See https://www.rfc-editor.org/rfc/rfc7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC curve and coordinates
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set, as served by an OIDC provider's jwks_uri
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKey decodes the JWK
+func (jwk *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported EC curve \"%s\"", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("invalid EC key: point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type \"%s\"", jwk.Kty)
+	}
+}
+
+// ParseJWKS decodes the signing keys in a JSON Web Key Set, by key ID.
+// Encryption keys and unsupported key types are skipped.
+func ParseJWKS(data []byte) (StaticKeySource, error) {
+	var set JWKSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(StaticKeySource)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			slog.Warn("skipping unsupported JWKS key", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("invalid JWKS: no supported signing keys")
+	}
+
+	return keys, nil
+}
+
+// StaticKeySource is a fixed set of public keys, by key ID
+type StaticKeySource map[string]crypto.PublicKey
+
+// LoadJWKSFile loads a JSON Web Key Set from a file
+func LoadJWKSFile(path string) (StaticKeySource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	return ParseJWKS(data)
+}
+
+func (keys StaticKeySource) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	return lookupKey(keys, kid)
+}
+
+// RemoteKeySource fetches a JSON Web Key Set from a URL, eg. an OIDC provider's jwks_uri.
+//
+// Keys are cached for CacheTTL. Tokens signed with an unknown key trigger a refresh,
+// so that rotated keys are picked up quickly, but no more than once per RefreshBackoff.
+// If a refresh fails, previously fetched keys continue to be used.
+// If keys have never been fetched, fetches are retried no more than once per RefreshBackoff,
+// and fail with ErrKeysUnavailable in the meantime.
+type RemoteKeySource struct {
+	url  string
+	http *http.Client
+	// How long to cache keys. Defaults to 1h.
+	CacheTTL time.Duration
+	// Minimum time between fetches. Defaults to 30s.
+	RefreshBackoff time.Duration
+	// Returns the current time. Replaced in tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	keys      StaticKeySource
+	fetchedAt time.Time
+	// Why the last fetch failed, if keys have never been fetched
+	fetchErr error
+}
+
+func NewRemoteKeySource(url string, client *http.Client) *RemoteKeySource {
+	return &RemoteKeySource{
+		url:            url,
+		http:           client,
+		CacheTTL:       defaultJWKSCacheTTL,
+		RefreshBackoff: defaultJWKSRefreshBackoff,
+		now:            time.Now,
+	}
+}
+
+func (src *RemoteKeySource) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	// Holding the lock while fetching means concurrent requests share a single fetch
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	now := src.now()
+	if src.keys == nil && src.fetchErr != nil && now.Sub(src.fetchedAt) < src.RefreshBackoff {
+		// Don't hold up every request with a fetch, while the provider is failing
+		return nil, src.fetchErr
+	}
+	expired := now.Sub(src.fetchedAt) >= src.CacheTTL
+	if !expired && src.keys != nil {
+		key, err := lookupKey(src.keys, kid)
+		// Refresh for unknown keys, in case the provider has rotated its keys
+		if !errors.Is(err, ErrUnknownKey) || now.Sub(src.fetchedAt) < src.RefreshBackoff {
+			return key, err
+		}
+	}
+
+	keys, err := src.fetch(ctx)
+	// Don't retry immediately after a failure
+	src.fetchedAt = now
+	if err != nil {
+		if src.keys == nil {
+			src.fetchErr = fmt.Errorf("%w: %s", ErrKeysUnavailable, err)
+			return nil, src.fetchErr
+		}
+		slog.WarnContext(ctx, "failed to refresh JWKS, using cached keys", "url", src.url, "error", err)
+	} else {
+		src.keys = keys
+		src.fetchErr = nil
+	}
+
+	return lookupKey(src.keys, kid)
+}
+
+func (src *RemoteKeySource) fetch(ctx context.Context) (StaticKeySource, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare JWKS request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := src.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	return ParseJWKS(data)
+}
+
+// lookupKey finds a key by ID.
+// Tokens without a key ID may be used with a key set containing a single key.
+func lookupKey(keys StaticKeySource, kid string) (crypto.PublicKey, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w \"%s\"", ErrUnknownKey, kid)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth/authtest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadJWKSFile(t *testing.T) {
+	rsaSigner := authtest.NewRSASigner(t, "rsa-key")
+	ecSigner := authtest.NewECSigner(t, "ec-key")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, authtest.JWKS(rsaSigner, ecSigner), 0600))
+
+	keys, err := LoadJWKSFile(path)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	_, err = keys.PublicKey(context.Background(), "ec-key")
+	require.NoError(t, err)
+	_, err = keys.PublicKey(context.Background(), "other-key")
+	require.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestParseJWKS_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"keys": []}`,
+		// Unsupported key types and encryption keys are skipped
+		`{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`,
+		`{"keys": [{"kty": "RSA", "kid": "a", "use": "enc", "n": "AQAB", "e": "AQAB"}]}`,
+		// Invalid EC point
+		`{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`,
+	} {
+		_, err := ParseJWKS([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestRemoteKeySource(t *testing.T) {
+	oldSigner := authtest.NewRSASigner(t, "old-key")
+	newSigner := authtest.NewRSASigner(t, "new-key")
+	var jwks atomic.Value
+	jwks.Store(authtest.JWKS(oldSigner))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(jwks.Load().([]byte))
+	}))
+	defer server.Close()
+
+	src := NewRemoteKeySource(server.URL, server.Client())
+	now := time.Now()
+	src.now = func() time.Time { return now }
+
+	// Should fetch keys once, and cache them
+	for i := 0; i < 3; i++ {
+		_, err := src.PublicKey(context.Background(), "old-key")
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(1), fetches.Load())
+
+	// The provider rotates its keys.
+	// Unknown keys should trigger a refresh, but not more than once per backoff period.
+	jwks.Store(authtest.JWKS(oldSigner, newSigner))
+	_, err := src.PublicKey(context.Background(), "new-key")
+	require.ErrorIs(t, err, ErrUnknownKey)
+	require.Equal(t, int32(1), fetches.Load())
+
+	now = now.Add(time.Minute)
+	_, err = src.PublicKey(context.Background(), "new-key")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), fetches.Load())
+
+	// Should refresh keys after the cache TTL
+	now = now.Add(2 * time.Hour)
+	_, err = src.PublicKey(context.Background(), "old-key")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), fetches.Load())
+}
+
+func TestRemoteKeySource_FetchFailure(t *testing.T) {
+	signer := authtest.NewECSigner(t, "key-1")
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(authtest.JWKS(signer))
+	}))
+	defer server.Close()
+
+	src := NewRemoteKeySource(server.URL, server.Client())
+	now := time.Now()
+	src.now = func() time.Time { return now }
+
+	_, err := src.PublicKey(context.Background(), "key-1")
+	require.NoError(t, err)
+
+	// Should keep using cached keys, if a refresh fails
+	fail.Store(true)
+	now = now.Add(2 * time.Hour)
+	_, err = src.PublicKey(context.Background(), "key-1")
+	require.NoError(t, err)
+
+	// Should fail, if keys have never been fetched
+	var fetches atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(authtest.JWKS(signer))
+	}))
+	defer failing.Close()
+	src = NewRemoteKeySource(failing.URL, failing.Client())
+	src.now = func() time.Time { return now }
+	_, err = src.PublicKey(context.Background(), "key-1")
+	require.ErrorIs(t, err, ErrKeysUnavailable)
+	require.NotErrorIs(t, err, ErrUnknownKey)
+
+	// ...and shouldn't fetch again until the backoff has passed
+	_, err = src.PublicKey(context.Background(), "key-1")
+	require.ErrorIs(t, err, ErrKeysUnavailable)
+	require.Equal(t, int32(1), fetches.Load())
+
+	fail.Store(false)
+	now = now.Add(time.Minute)
+	_, err = src.PublicKey(context.Background(), "key-1")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), fetches.Load())
+}
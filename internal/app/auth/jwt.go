@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRolesClaim  = "roles"
+	defaultTenantClaim = "tenant_id"
+	defaultJWTLeeway   = time.Minute
+)
+
+// JWTValidator authenticates JWT bearer tokens, eg. issued by an OIDC provider.
+//
+// Tokens must be signed with RS256 or ES256, by a key from the configured KeySource,
+// and must have "sub" and "exp" claims. Roles are read from a claim, and mapped to scopes.
+// The principal's tenant is read from a claim, so tokens without one are rejected (unless DefaultTenant is set).
+type JWTValidator struct {
+	keys KeySource
+	// Expected "iss" claim. Not checked if empty.
+	Issuer string
+	// Expected "aud" claim. Not checked if empty.
+	Audience string
+	// Claim containing the user's roles, as an array or space-separated string.
+	// May be a dotted path to a nested claim, eg. "realm_access.roles". Defaults to "roles".
+	RolesClaim string
+	// Scopes granted by each role. If nil, roles which are scope names (eg. "sensors:read") grant that scope.
+	RoleScopes map[string][]string
+	// Claim containing the user's tenant (organization). May be a dotted path. Defaults to "tenant_id".
+	TenantClaim string
+	// Tenant of tokens without a tenant claim, eg. for single-organization identity providers.
+	// If empty, such tokens are rejected.
+	DefaultTenant string
+	// Allowed clock skew, when checking "exp" and "nbf". Defaults to 1m.
+	Leeway time.Duration
+	// Returns the current time. Replaced in tests.
+	now func() time.Time
+}
+
+func NewJWTValidator(keys KeySource) *JWTValidator {
+	return &JWTValidator{
+		keys:        keys,
+		RolesClaim:  defaultRolesClaim,
+		TenantClaim: defaultTenantClaim,
+		Leeway:      defaultJWTLeeway,
+		now:         time.Now,
+	}
+}
+
+// Authenticate verifies a JWT, and returns the principal it identifies.
+// Returns an error wrapping ErrInvalidCredentials if the token is invalid,
+// or ErrKeysUnavailable if the signing keys can't be fetched.
+func (v *JWTValidator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidToken("malformed header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed signature")
+	}
+
+	key, err := v.keys.PublicKey(ctx, header.Kid)
+	if errors.Is(err, ErrUnknownKey) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+	if err != nil {
+		// eg. ErrKeysUnavailable, which doesn't mean the token is invalid
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidToken("malformed claims")
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	tenantID, err := v.tenantID(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	roles := stringsClaim(claims, v.RolesClaim)
+	return &Principal{
+		Subject:  subject,
+		Name:     principalName(claims),
+		TenantID: tenantID,
+		Roles:    roles,
+		Scopes:   v.scopesForRoles(roles),
+	}, nil
+}
+
+// tenantID returns the token's tenant. The tenant claim must be a single non-empty string.
+// Only tokens without the claim use the default tenant: a malformed claim never falls back to it.
+func (v *JWTValidator) tenantID(claims map[string]interface{}) (string, error) {
+	value, ok := claim(claims, v.TenantClaim)
+	if !ok {
+		if v.DefaultTenant == "" {
+			return "", invalidToken(fmt.Sprintf("missing \"%s\" claim", v.TenantClaim))
+		}
+		return v.DefaultTenant, nil
+	}
+
+	tenantID, isString := value.(string)
+	if !isString || strings.TrimSpace(tenantID) == "" {
+		return "", invalidToken(fmt.Sprintf("invalid \"%s\" claim: must be a single tenant ID", v.TenantClaim))
+	}
+	return tenantID, nil
+}
+
+func (v *JWTValidator) validateClaims(claims map[string]interface{}) error {
+	now := v.now()
+
+	if subject, _ := claims["sub"].(string); subject == "" {
+		return invalidToken("missing \"sub\" claim")
+	}
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return invalidToken("missing \"exp\" claim")
+	}
+	if now.After(exp.Add(v.Leeway)) {
+		return invalidToken("token has expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.Leeway).Before(nbf) {
+		return invalidToken("token is not valid yet")
+	}
+
+	if v.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != v.Issuer {
+			return invalidToken("unexpected issuer")
+		}
+	}
+	if v.Audience != "" && !containsString(stringsClaim(claims, "aud"), v.Audience) {
+		return invalidToken("unexpected audience")
+	}
+
+	return nil
+}
+
+// scopesForRoles maps a principal's roles to the scopes they grant
+func (v *JWTValidator) scopesForRoles(roles []string) []string {
+	scopes := []string{}
+	for _, role := range roles {
+		granted := []string{role}
+		if v.RoleScopes != nil {
+			granted = v.RoleScopes[role]
+		}
+		for _, scope := range granted {
+			if ValidScope(scope) && !containsString(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
+
+// verifySignature checks a token's signature, using the algorithm from its header.
+// The key type must match the algorithm, so that eg. an RSA public key can't be used as an HMAC secret.
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return invalidToken("key type does not match algorithm RS256")
+		}
+		if rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return invalidToken("invalid signature")
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return invalidToken("key type does not match algorithm ES256")
+		}
+		// ES256 signatures are the 32-byte r and s values, concatenated
+		if len(signature) != 64 {
+			return invalidToken("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return invalidToken("invalid signature")
+		}
+	default:
+		return invalidToken(fmt.Sprintf("unsupported algorithm \"%s\"", alg))
+	}
+
+	return nil
+}
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidCredentials, reason)
+}
+
+func decodeSegment(segment string, dest interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, dest)
+}
+
+// numericDate reads a JWT NumericDate (seconds since the epoch) claim
+func numericDate(value interface{}) (time.Time, bool) {
+	seconds, ok := value.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(seconds), 0), true
+}
+
+// claim returns the claim at a dotted path (eg. "realm_access.roles"),
+// and whether it is present. Claims set to null are treated as present.
+func claim(claims map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// stringsClaim reads a claim which may be a string or an array of strings,
+// following a dotted path to nested claims (eg. "realm_access.roles")
+func stringsClaim(claims map[string]interface{}, path string) []string {
+	value, _ := claim(claims, path)
+	switch value := value.(type) {
+	case string:
+		// eg. the OAuth "scope" claim is space-separated
+		return strings.Fields(value)
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// principalName returns a human-readable name for the token's subject
+func principalName(claims map[string]interface{}) string {
+	for _, claim := range []string{"name", "preferred_username", "email", "sub"} {
+		if name, _ := claims[claim].(string); name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,163 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth"
+	"github.com/eschwartz/go-sensor-api/internal/app/auth/authtest"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newValidator(t *testing.T, signers ...*authtest.Signer) *auth.JWTValidator {
+	keys, err := auth.ParseJWKS(authtest.JWKS(signers...))
+	require.NoError(t, err)
+
+	return auth.NewJWTValidator(keys)
+}
+
+func TestJWTValidator(t *testing.T) {
+	for _, signer := range []*authtest.Signer{
+		authtest.NewRSASigner(t, "rsa-key"),
+		authtest.NewECSigner(t, "ec-key"),
+	} {
+		t.Run(signer.Alg, func(t *testing.T) {
+			validator := newValidator(t, signer)
+
+			claims := authtest.Claims("user-123", "sensors:read", "unknown-role")
+			claims["email"] = "jane@example.com"
+			principal, err := validator.Authenticate(context.Background(), signer.Sign(claims))
+			require.NoError(t, err)
+			require.Equal(t, &auth.Principal{
+				Subject:  "user-123",
+				Name:     "jane@example.com",
+				TenantID: authtest.Tenant,
+				Roles:    []string{"sensors:read", "unknown-role"},
+				Scopes:   []string{"sensors:read"},
+			}, principal)
+		})
+	}
+}
+
+func TestJWTValidator_RoleScopes(t *testing.T) {
+	signer := authtest.NewRSASigner(t, "key-1")
+	validator := newValidator(t, signer)
+	validator.RolesClaim = "realm_access.roles"
+	validator.RoleScopes = map[string][]string{
+		"sensor-editor": {auth.ScopeSensorsRead, auth.ScopeSensorsWrite},
+		"sensor-viewer": {auth.ScopeSensorsRead},
+	}
+
+	claims := authtest.Claims("user-123")
+	claims["realm_access"] = map[string]interface{}{
+		"roles": []string{"sensor-viewer", "sensor-editor", "offline_access"},
+	}
+	principal, err := validator.Authenticate(context.Background(), signer.Sign(claims))
+	require.NoError(t, err)
+	require.Equal(t, []string{"sensors:read", "sensors:write"}, principal.Scopes)
+}
+
+func TestJWTValidator_InvalidTokens(t *testing.T) {
+	signer := authtest.NewRSASigner(t, "key-1")
+	otherSigner := authtest.NewRSASigner(t, "key-1")
+	ecSigner := authtest.NewECSigner(t, "key-1")
+	validator := newValidator(t, signer)
+	validator.Issuer = "https://sso.example.com"
+	validator.Audience = "sensor-api"
+
+	validClaims := func() map[string]interface{} {
+		claims := authtest.Claims("user-123", "sensors:read")
+		claims["iss"] = "https://sso.example.com"
+		claims["aud"] = []string{"sensor-api", "other-api"}
+		return claims
+	}
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	// Sanity check
+	_, err := validator.Authenticate(context.Background(), signer.Sign(validClaims()))
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		"malformed":       "not.a-token",
+		"wrong key":       otherSigner.Sign(validClaims()),
+		"unknown kid":     authtest.NewRSASigner(t, "key-2").Sign(validClaims()),
+		"alg none":        signer.SignWithHeader(map[string]interface{}{"alg": "none", "kid": "key-1"}, validClaims()),
+		"alg HS256":       signer.SignWithHeader(map[string]interface{}{"alg": "HS256", "kid": "key-1"}, validClaims()),
+		"alg mismatch":    ecSigner.Sign(validClaims()),
+		"expired":         signer.Sign(withClaim("exp", time.Now().Add(-time.Hour).Unix())),
+		"missing exp":     signer.Sign(withClaim("exp", nil)),
+		"not yet valid":   signer.Sign(withClaim("nbf", time.Now().Add(time.Hour).Unix())),
+		"missing sub":     signer.Sign(withClaim("sub", nil)),
+		"missing tenant":  signer.Sign(withClaim("tenant_id", nil)),
+		"wrong issuer":    signer.Sign(withClaim("iss", "https://evil.example.com")),
+		"wrong audience":  signer.Sign(withClaim("aud", "other-api")),
+		"tampered claims": signer.Sign(validClaims())[:20] + "x" + signer.Sign(validClaims())[21:],
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := validator.Authenticate(context.Background(), token)
+			require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		})
+	}
+}
+
+func TestJWTValidator_Tenant(t *testing.T) {
+	signer := authtest.NewRSASigner(t, "key-1")
+	validator := newValidator(t, signer)
+	validator.TenantClaim = "org.id"
+
+	claims := authtest.Claims("user-123")
+	claims["org"] = map[string]interface{}{"id": "globex"}
+	principal, err := validator.Authenticate(context.Background(), signer.Sign(claims))
+	require.NoError(t, err)
+	require.Equal(t, "globex", principal.TenantID)
+
+	// Tokens without a tenant are rejected, unless there is a default tenant
+	delete(claims, "org")
+	_, err = validator.Authenticate(context.Background(), signer.Sign(claims))
+	require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	validator.DefaultTenant = "default"
+	principal, err = validator.Authenticate(context.Background(), signer.Sign(claims))
+	require.NoError(t, err)
+	require.Equal(t, "default", principal.TenantID)
+
+	// The claim is used as-is, rather than split like the roles claim
+	claims["org"] = map[string]interface{}{"id": "globex initech"}
+	principal, err = validator.Authenticate(context.Background(), signer.Sign(claims))
+	require.NoError(t, err)
+	require.Equal(t, "globex initech", principal.TenantID)
+
+	// Malformed tenant claims are rejected, rather than falling back to the default tenant
+	for name, tenant := range map[string]interface{}{
+		"empty":    "",
+		"blank":    " ",
+		"null":     nil,
+		"number":   123,
+		"array":    []string{"globex"},
+		"multiple": []string{"globex", "initech"},
+	} {
+		claims["org"] = map[string]interface{}{"id": tenant}
+		_, err = validator.Authenticate(context.Background(), signer.Sign(claims))
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials, name)
+	}
+}
+
+func TestJWTValidator_Leeway(t *testing.T) {
+	signer := authtest.NewECSigner(t, "key-1")
+	validator := newValidator(t, signer)
+
+	// Tokens which expired within the leeway are still accepted, to allow for clock skew
+	claims := authtest.Claims("user-123")
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	_, err := validator.Authenticate(context.Background(), signer.Sign(claims))
+	require.NoError(t, err)
+}
@@ -0,0 +1,378 @@
+// Package config loads the API's settings from a config file, env vars and command-line flags.
+//
+// Settings are applied in increasing order of precedence:
+//  1. defaults (see Default)
+//  2. a YAML or TOML config file, named by the -config flag or the CONFIG_FILE env var
+//  3. env vars, eg. DATABASE_URL. Secrets may instead be read from a file named by {NAME}_FILE, eg. DATABASE_URL_FILE
+//  4. command-line flags, eg. -server.write-timeout=1m
+//
+// Each setting is a field of Config, whose tags name it in each source:
+//
+//	config: the key in the config file (nested under its section's key).
+//	        Flags use the dotted key, with "-" in place of "_", eg. -rate-limit.spatial-rps
+//	env:    the env var
+//	secret: redacted by Print, and may be read from a {NAME}_FILE
+//	reload: applied on SIGHUP, without restarting
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config is the API's configuration
+type Config struct {
+	Port        int    `config:"port" env:"PORT" usage:"HTTP port to listen on"`
+	GRPCPort    int    `config:"grpc_port" env:"GRPC_PORT" usage:"gRPC port to listen on. The gRPC API is disabled if 0"`
+	DatabaseURL string `config:"database_url" env:"DATABASE_URL" secret:"true" usage:"Sensor store URL: postgres:// for PostGIS, sqlite:// for a SQLite file, file:// for a directory, or memory:// (required)"`
+	TenantRLS   bool   `config:"tenant_rls" env:"TENANT_RLS" usage:"Enforce tenant isolation with row-level security policies (see scripts/db-rls.sql)"`
+
+	Log       Log       `config:"log"`
+	Server    Server    `config:"server"`
+	Auth      Auth      `config:"auth"`
+	RateLimit RateLimit `config:"rate_limit"`
+	Geocoder  Geocoder  `config:"geocoder"`
+	Health    Health    `config:"health"`
+	Outbox    Outbox    `config:"outbox"`
+	Tracing   Tracing   `config:"tracing"`
+}
+
+// Log configures logging
+type Log struct {
+	Format string `config:"format" env:"LOG_FORMAT" usage:"Log format: json or text"`
+	Level  string `config:"level" env:"LOG_LEVEL" reload:"true" usage:"Minimum log level: debug, info, warn or error"`
+}
+
+// SlogLevel returns the log level. The level must be valid (see Validate).
+func (l Log) SlogLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(strings.ToUpper(l.Level)))
+	return level
+}
+
+// Server configures the HTTP server, and how it shuts down
+type Server struct {
+	// How long to wait for a client to send the request headers
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" usage:"How long to wait for a client to send the request headers"`
+	// How long to wait for a client to send the whole request, including the body
+	ReadTimeout time.Duration `config:"read_timeout" env:"SERVER_READ_TIMEOUT" usage:"How long to wait for a client to send the whole request"`
+	// How long a request may take, from reading the headers to writing the response
+	WriteTimeout time.Duration `config:"write_timeout" env:"SERVER_WRITE_TIMEOUT" usage:"How long a request may take, from reading the headers to writing the response"`
+	// How long to keep idle keep-alive connections open
+	IdleTimeout time.Duration `config:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" usage:"How long to keep idle keep-alive connections open"`
+	// Largest request headers accepted
+	MaxHeaderBytes int `config:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" usage:"Largest request headers accepted"`
+	// Largest request body accepted. Larger bodies are rejected with a 413.
+	MaxBodyBytes int64 `config:"max_body_bytes" env:"SERVER_MAX_BODY_BYTES" usage:"Largest request body accepted"`
+	// How long to keep serving after readiness starts failing, so load balancers notice and stop sending requests
+	DrainDelay time.Duration `config:"drain_delay" env:"SERVER_DRAIN_DELAY" usage:"How long to keep serving after readiness starts failing, on shutdown"`
+	// How long to wait for in-flight requests to complete, when shutting down
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" usage:"How long to wait for in-flight requests to complete, on shutdown"`
+}
+
+// Auth configures authentication
+type Auth struct {
+	Disabled bool `config:"disabled" env:"AUTH_DISABLED" usage:"Disable authentication (eg. for local development)"`
+	JWT      JWT  `config:"jwt"`
+}
+
+// JWT configures authentication with JWT bearer tokens.
+// Tokens are accepted if either JWKSURL or JWKSFile is set.
+type JWT struct {
+	JWKSURL       string        `config:"jwks_url" env:"JWT_JWKS_URL" usage:"URL of the identity provider's JSON Web Key Set"`
+	JWKSFile      string        `config:"jwks_file" env:"JWT_JWKS_FILE" usage:"JSON Web Key Set file, instead of jwks_url"`
+	JWKSCacheTTL  time.Duration `config:"jwks_cache_ttl" env:"JWT_JWKS_CACHE_TTL" usage:"How long to cache keys fetched from jwks_url"`
+	Issuer        string        `config:"issuer" env:"JWT_ISSUER" usage:"Required iss claim"`
+	Audience      string        `config:"audience" env:"JWT_AUDIENCE" usage:"Required aud claim"`
+	RolesClaim    string        `config:"roles_claim" env:"JWT_ROLES_CLAIM" usage:"Claim listing the user's roles"`
+	TenantClaim   string        `config:"tenant_claim" env:"JWT_TENANT_CLAIM" usage:"Claim naming the user's tenant"`
+	DefaultTenant string        `config:"default_tenant" env:"JWT_DEFAULT_TENANT" usage:"Tenant for tokens without a tenant claim"`
+	RoleScopes    string        `config:"role_scopes" env:"JWT_ROLE_SCOPES" usage:"Scopes granted to each role, eg. sensor-viewer=sensors:read;sensor-editor=sensors:read,sensors:write"`
+}
+
+// RateLimit configures rate limits. Buckets with a rate of 0 are not limited.
+type RateLimit struct {
+	Backend    string `config:"backend" env:"RATE_LIMIT_BACKEND" usage:"Where to store token buckets: memory, or postgres to share limits between instances"`
+	TrustProxy bool   `config:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY" usage:"Limit unauthenticated clients by the last address in the X-Forwarded-For header, added by a trusted proxy"`
+	// Burst defaults to one second's worth of requests, if 0
+	RPS          float64 `config:"rps" env:"RATE_LIMIT_RPS" reload:"true" usage:"Requests per second, for each client"`
+	Burst        int     `config:"burst" env:"RATE_LIMIT_BURST" reload:"true" usage:"Requests allowed in a burst (defaults to rps)"`
+	SpatialRPS   float64 `config:"spatial_rps" env:"RATE_LIMIT_SPATIAL_RPS" reload:"true" usage:"Requests per second, for each client, to spatial and geocoding endpoints"`
+	SpatialBurst int     `config:"spatial_burst" env:"RATE_LIMIT_SPATIAL_BURST" reload:"true" usage:"Requests allowed in a burst, to spatial and geocoding endpoints (defaults to spatial_rps)"`
+}
+
+// Geocoder configures geocoding providers
+type Geocoder struct {
+	// Providers to try, in order. Defaults to mapbox, if it has an access token.
+	Providers        []string      `config:"providers" env:"GEOCODER_PROVIDERS" usage:"Geocoders to try, in order: mapbox, nominatim, photon, pelias or gazetteer"`
+	Timeout          time.Duration `config:"timeout" env:"GEOCODER_TIMEOUT" usage:"Timeout for each provider, before falling back to the next"`
+	CacheTTL         time.Duration `config:"cache_ttl" env:"GEOCODE_CACHE_TTL" usage:"How long to cache geocoding results"`
+	CachePersistent  bool          `config:"cache_persistent" env:"GEOCODE_CACHE_PERSISTENT" usage:"Share cached results between instances, in the database"`
+	EnrichPlaceNames bool          `config:"enrich_place_names" env:"ENRICH_PLACE_NAMES" reload:"true" usage:"Store sensors with a reverse geocoded place name"`
+	// Sent as the User-Agent header. The public Nominatim instance requires one identifying the application.
+	UserAgent string `config:"user_agent" env:"GEOCODER_USER_AGENT" usage:"User-Agent sent to geocoding providers, eg. \"my-app (ops@example.com)\""`
+
+	Mapbox    Mapbox    `config:"mapbox"`
+	Nominatim Nominatim `config:"nominatim"`
+	Photon    Photon    `config:"photon"`
+	Pelias    Pelias    `config:"pelias"`
+	Gazetteer Gazetteer `config:"gazetteer"`
+}
+
+// Geocoders which may be listed in Geocoder.Providers
+var geocoderProviders = []string{"mapbox", "nominatim", "photon", "pelias", "gazetteer"}
+
+type Mapbox struct {
+	AccessToken string `config:"access_token" env:"MAPBOX_ACCESS_TOKEN" secret:"true" usage:"Mapbox access token"`
+	URL         string `config:"url" env:"MAPBOX_URL" usage:"Mapbox API base URL"`
+}
+
+type Nominatim struct {
+	URL string `config:"url" env:"NOMINATIM_URL" usage:"Nominatim base URL"`
+}
+
+type Photon struct {
+	URL string `config:"url" env:"PHOTON_URL" usage:"Photon base URL"`
+}
+
+type Pelias struct {
+	URL    string `config:"url" env:"PELIAS_URL" usage:"Pelias base URL"`
+	APIKey string `config:"api_key" env:"PELIAS_API_KEY" secret:"true" usage:"Pelias API key"`
+}
+
+type Gazetteer struct {
+	File       string `config:"file" env:"GAZETTEER_FILE" usage:"GeoNames cities file, for the offline gazetteer geocoder"`
+	Admin1File string `config:"admin1_file" env:"GAZETTEER_ADMIN1_FILE" usage:"GeoNames admin1 codes file, for region names"`
+}
+
+// Health configures readiness checks
+type Health struct {
+	Timeout       time.Duration `config:"timeout" env:"HEALTH_CHECK_TIMEOUT" usage:"Timeout for each readiness check"`
+	Geocoder      bool          `config:"geocoder" env:"HEALTH_CHECK_GEOCODER" usage:"Check the geocoding providers, in readiness probes"`
+	GeocoderQuery string        `config:"geocoder_query" env:"HEALTH_CHECK_GEOCODER_QUERY" usage:"Place to geocode, when checking the geocoder"`
+}
+
+// Outbox configures publishing sensor change events from the outbox.
+// Events are not published if Publisher is empty.
+type Outbox struct {
+	Publisher string `config:"publisher" env:"OUTBOX_PUBLISHER" usage:"Where to publish sensor changes: stdout, file or http"`
+	File      string `config:"file" env:"OUTBOX_FILE" usage:"File to append events to, for the file publisher"`
+	URL       string `config:"url" env:"OUTBOX_URL" secret:"true" usage:"URL to POST events to, for the http publisher"`
+}
+
+// Tracing configures exporting traces. Names follow the OpenTelemetry SDK's env vars.
+type Tracing struct {
+	Exporter string `config:"exporter" env:"OTEL_TRACES_EXPORTER" usage:"Where to export spans: otlp, console, file or none"`
+	// Base URL of the collector. Spans are sent to {Endpoint}/v1/traces, unless TracesEndpoint is set.
+	Endpoint       string  `config:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"Base URL of the OTLP collector"`
+	TracesEndpoint string  `config:"traces_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" usage:"URL of the OTLP collector's traces endpoint, instead of {endpoint}/v1/traces"`
+	Headers        string  `config:"headers" env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true" usage:"Headers sent to the collector, eg. api-key=abc123,tenant=acme"`
+	File           string  `config:"file" env:"OTEL_TRACES_FILE" usage:"File to append spans to, for the file exporter"`
+	ServiceName    string  `config:"service_name" env:"OTEL_SERVICE_NAME" usage:"Service name, in exported spans"`
+	SampleRatio    float64 `config:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" usage:"Fraction of new traces to sample, from 0 to 1"`
+}
+
+// Default returns the configuration used for unset settings
+func Default() *Config {
+	return &Config{
+		Port: 8000,
+		Log: Log{
+			Format: "json",
+			Level:  "info",
+		},
+		Server: Server{
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    64 << 10,
+			MaxBodyBytes:      1 << 20,
+			DrainDelay:        5 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Auth: Auth{
+			JWT: JWT{
+				JWKSCacheTTL: time.Hour,
+				RolesClaim:   "roles",
+				TenantClaim:  "tenant_id",
+			},
+		},
+		RateLimit: RateLimit{
+			Backend: "memory",
+		},
+		Geocoder: Geocoder{
+			Timeout:  10 * time.Second,
+			CacheTTL: 24 * time.Hour,
+		},
+		Health: Health{
+			Timeout:       2 * time.Second,
+			GeocoderQuery: "London",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "sensor-api",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// GeocoderProviders returns the geocoders to try, in order.
+// Defaults to mapbox, if it has an access token. Returns nil if geocoding is disabled.
+func (cfg *Config) GeocoderProviders() []string {
+	if len(cfg.Geocoder.Providers) == 0 && cfg.Geocoder.Mapbox.AccessToken != "" {
+		return []string{"mapbox"}
+	}
+	return cfg.Geocoder.Providers
+}
+
+// Validate checks that the settings are valid, and consistent with each other.
+// All problems are reported, not just the first.
+func (cfg *Config) Validate() error {
+	var errs []error
+	invalid := func(key string, value interface{}, reason string) {
+		errs = append(errs, fmt.Errorf("invalid %s \"%v\": %s", describe(key), value, reason))
+	}
+	required := func(key string, reason string) {
+		errs = append(errs, fmt.Errorf("must set %s %s", describe(key), reason))
+	}
+
+	if cfg.DatabaseURL == "" {
+		errs = append(errs, fmt.Errorf("must set %s", describe("database_url")))
+	} else if u, err := url.Parse(cfg.DatabaseURL); err != nil || !oneOf(u.Scheme, "postgres", "postgresql", "sqlite", "file", "memory") {
+		// Don't repeat the URL, which may contain a password
+		errs = append(errs, fmt.Errorf("invalid %s: must be a postgres://, sqlite://, file:// or memory:// URL", describe("database_url")))
+	} else if !oneOf(u.Scheme, "postgres", "postgresql") {
+		// These features store data in PostgreSQL
+		for _, feature := range []struct {
+			key     string
+			enabled bool
+		}{
+			{"tenant_rls", cfg.TenantRLS},
+			{"rate_limit.backend", cfg.RateLimit.Backend == "postgres"},
+			{"geocoder.cache_persistent", cfg.Geocoder.CachePersistent},
+			{"outbox.publisher", cfg.Outbox.Publisher != ""},
+		} {
+			if feature.enabled {
+				errs = append(errs, fmt.Errorf("%s requires a postgres:// database_url", describe(feature.key)))
+			}
+		}
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		invalid("port", cfg.Port, "must be from 1 to 65535")
+	}
+	if cfg.GRPCPort < 0 || cfg.GRPCPort > 65535 {
+		invalid("grpc_port", cfg.GRPCPort, "must be from 1 to 65535, or 0 to disable the gRPC API")
+	}
+	if cfg.GRPCPort != 0 && cfg.GRPCPort == cfg.Port {
+		invalid("grpc_port", cfg.GRPCPort, "must be different from the HTTP port")
+	}
+
+	if !oneOf(cfg.Log.Format, "json", "text") {
+		invalid("log.format", cfg.Log.Format, "must be \"json\" or \"text\"")
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(cfg.Log.Level))); err != nil {
+		invalid("log.level", cfg.Log.Level, "must be \"debug\", \"info\", \"warn\" or \"error\"")
+	}
+
+	if cfg.Server.MaxHeaderBytes <= 0 {
+		invalid("server.max_header_bytes", cfg.Server.MaxHeaderBytes, "must be a positive number")
+	}
+	if cfg.Server.MaxBodyBytes <= 0 {
+		invalid("server.max_body_bytes", cfg.Server.MaxBodyBytes, "must be a positive number")
+	}
+
+	if cfg.Auth.JWT.JWKSURL != "" && cfg.Auth.JWT.JWKSFile != "" {
+		errs = append(errs, errors.New("must set only one of auth.jwt.jwks_url and auth.jwt.jwks_file"))
+	}
+
+	if !oneOf(cfg.RateLimit.Backend, "memory", "postgres") {
+		invalid("rate_limit.backend", cfg.RateLimit.Backend, "must be \"memory\" or \"postgres\"")
+	}
+	if cfg.RateLimit.RPS < 0 {
+		invalid("rate_limit.rps", cfg.RateLimit.RPS, "must be a positive number, or 0 to disable the limit")
+	}
+	if cfg.RateLimit.SpatialRPS < 0 {
+		invalid("rate_limit.spatial_rps", cfg.RateLimit.SpatialRPS, "must be a positive number, or 0 to disable the limit")
+	}
+	if cfg.RateLimit.Burst < 0 {
+		invalid("rate_limit.burst", cfg.RateLimit.Burst, "must be a positive integer")
+	}
+	if cfg.RateLimit.SpatialBurst < 0 {
+		invalid("rate_limit.spatial_burst", cfg.RateLimit.SpatialBurst, "must be a positive integer")
+	}
+
+	for _, provider := range cfg.GeocoderProviders() {
+		switch {
+		case !oneOf(provider, geocoderProviders...):
+			invalid("geocoder.providers", provider, "must be one of "+strings.Join(geocoderProviders, ", "))
+		case provider == "mapbox" && cfg.Geocoder.Mapbox.AccessToken == "":
+			required("geocoder.mapbox.access_token", "to use the mapbox geocoder")
+		case provider == "gazetteer" && cfg.Geocoder.Gazetteer.File == "":
+			required("geocoder.gazetteer.file", "to use the gazetteer geocoder")
+		}
+	}
+	if cfg.Geocoder.EnrichPlaceNames && len(cfg.GeocoderProviders()) == 0 {
+		required("geocoder.providers", "to use geocoder.enrich_place_names")
+	}
+
+	if cfg.Health.Timeout <= 0 {
+		invalid("health.timeout", cfg.Health.Timeout, "must be a positive duration, eg. \"2s\"")
+	}
+	if cfg.Health.Geocoder && len(cfg.GeocoderProviders()) == 0 {
+		required("geocoder.providers", "to use health.geocoder")
+	}
+
+	switch cfg.Outbox.Publisher {
+	case "", "stdout":
+	case "file":
+		if cfg.Outbox.File == "" {
+			required("outbox.file", "to use the file outbox publisher")
+		}
+	case "http":
+		if cfg.Outbox.URL == "" {
+			required("outbox.url", "to use the http outbox publisher")
+		}
+	default:
+		invalid("outbox.publisher", cfg.Outbox.Publisher, "must be \"stdout\", \"file\" or \"http\"")
+	}
+
+	switch cfg.Tracing.Exporter {
+	case "none", "console", "otlp":
+	case "file":
+		if cfg.Tracing.File == "" {
+			required("tracing.file", "to use the file trace exporter")
+		}
+	default:
+		invalid("tracing.exporter", cfg.Tracing.Exporter, "must be \"otlp\", \"console\", \"file\" or \"none\"")
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", cfg.Tracing.SampleRatio, "must be a number from 0 to 1")
+	}
+
+	return errors.Join(errs...)
+}
+
+// describe names a setting in errors, with its env var, eg. "log.level (LOG_LEVEL)"
+func describe(key string) string {
+	for _, f := range fields(Default()) {
+		if f.key == key && f.env != "" {
+			return fmt.Sprintf("%s (%s)", key, f.env)
+		}
+	}
+	return key
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is a single setting, found by walking the Config struct
+type field struct {
+	// Dotted key, eg. server.write_timeout
+	key    string
+	env    string
+	usage  string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+// flagName is the command-line flag for the setting, eg. server.write-timeout
+func (f field) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fields lists the settings in cfg, in the order they are declared.
+// Setting a field's value updates cfg.
+func fields(cfg *Config) []field {
+	return appendFields(nil, "", reflect.ValueOf(cfg).Elem())
+}
+
+func appendFields(fields []field, prefix string, v reflect.Value) []field {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		key := structField.Tag.Get("config")
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if structField.Type.Kind() == reflect.Struct {
+			fields = appendFields(fields, key, v.Field(i))
+			continue
+		}
+		fields = append(fields, field{
+			key:    key,
+			env:    structField.Tag.Get("env"),
+			usage:  structField.Tag.Get("usage"),
+			secret: structField.Tag.Get("secret") == "true",
+			reload: structField.Tag.Get("reload") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return fields
+}
+
+// set parses a setting from a string, eg. from an env var or flag.
+// Lists are comma-separated.
+func (f field) set(s string) error {
+	if f.value.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return errors.New("must be a duration, eg. \"30s\"")
+		}
+		f.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		f.value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		f.value.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		f.value.SetFloat(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		// Only reachable if a field of an unsupported type is added to Config
+		panic("config: unsupported type " + f.value.Type().String() + " for " + f.key)
+	}
+	return nil
+}
+
+// setValue sets the field from a value decoded from a config file
+func (f field) setValue(value interface{}) error {
+	if list, ok := value.([]interface{}); ok {
+		if f.value.Kind() != reflect.Slice {
+			return errors.New("must not be a list")
+		}
+		var items []string
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		f.value.Set(reflect.ValueOf(items))
+		return nil
+	}
+	return f.set(fmt.Sprint(value))
+}
+
+// String formats the setting's value, as accepted by set
+func (f field) String() string {
+	switch v := f.value.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Load reads the configuration from the config file, env vars and command-line flags,
+// then validates it. args are the command-line arguments, without the program name.
+// getenv is usually os.Getenv. Empty env vars are treated as unset.
+//
+// Returns flag.ErrHelp if args include -h or -help, after printing usage to stderr.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	settings := fields(cfg)
+
+	// Flags are applied last, but parsed first, as they may name the config file
+	flags := flag.NewFlagSet("sensor-api", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML or TOML config file (or set CONFIG_FILE)")
+	type flagValue struct {
+		field field
+		value string
+	}
+	var flagValues []flagValue
+	for _, f := range settings {
+		f := f
+		usage := f.usage
+		if f.env != "" {
+			usage += " (" + f.env + ")"
+		}
+		record := func(value string) error {
+			flagValues = append(flagValues, flagValue{f, value})
+			return nil
+		}
+		if f.value.Kind() == reflect.Bool {
+			flags.BoolFunc(f.flagName(), usage, record)
+		} else {
+			flags.Func(f.flagName(), usage, record)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument \"%s\"", flags.Arg(0))
+	}
+
+	if *configFile == "" {
+		*configFile = getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := loadFile(settings, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loadEnv(settings, getenv); err != nil {
+		return nil, err
+	}
+
+	for _, fv := range flagValues {
+		if err := fv.field.set(fv.value); err != nil {
+			return nil, fmt.Errorf("invalid -%s \"%s\": %w", fv.field.flagName(), fv.value, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadEnv sets fields from env vars. Secrets may instead be read from a file, named by {NAME}_FILE.
+func loadEnv(settings []field, getenv func(string) string) error {
+	for _, f := range settings {
+		if f.env == "" {
+			continue
+		}
+		value := getenv(f.env)
+		if f.secret {
+			if path := getenv(f.env + "_FILE"); path != "" {
+				if value != "" {
+					return fmt.Errorf("must set only one of %s and %s_FILE", f.env, f.env)
+				}
+				contents, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read %s_FILE: %w", f.env, err)
+				}
+				// Files usually end with a newline, which isn't part of the secret
+				value = strings.TrimRight(string(contents), "\r\n")
+			}
+		}
+		if value == "" {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			if f.secret {
+				return fmt.Errorf("invalid %s: %w", f.env, err)
+			}
+			return fmt.Errorf("invalid %s \"%s\": %w", f.env, value, err)
+		}
+	}
+	return nil
+}
+
+// loadFile sets fields from a YAML (.yaml or .yml) or TOML (.toml) config file
+func loadFile(settings []field, path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &doc)
+	case ".toml":
+		err = toml.Unmarshal(contents, &doc)
+	default:
+		return fmt.Errorf("unsupported config file \"%s\": must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]field, len(settings))
+	for _, f := range settings {
+		byKey[f.key] = f
+	}
+	values := make(map[string]interface{})
+	flatten(values, "", doc)
+
+	// Sort keys, so errors are reported consistently
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("invalid config file %s: unknown setting \"%s\"", path, key)
+		}
+		value := values[key]
+		if value == nil {
+			continue
+		}
+		if err := f.setValue(value); err != nil {
+			if f.secret {
+				return fmt.Errorf("invalid %s in config file %s: %w", key, path, err)
+			}
+			return fmt.Errorf("invalid %s \"%v\" in config file %s: %w", key, value, path, err)
+		}
+	}
+	return nil
+}
+
+// flatten converts nested sections into dotted keys, eg. {"server": {"write_timeout": "1m"}} to {"server.write_timeout": "1m"}
+func flatten(values map[string]interface{}, prefix string, section map[string]interface{}) {
+	for key, value := range section {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(values, key, nested)
+			continue
+		}
+		values[key] = value
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Replaces secrets, in printed configuration
+const redacted = "REDACTED"
+
+// Print writes the configuration as YAML, which may be used as a config file.
+// Secrets are redacted. URLs keep everything but their password, to help debug connection problems.
+func Print(w io.Writer, cfg *Config) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{"": root}
+
+	for _, f := range fields(cfg) {
+		// Find (or create) the mapping for the field's section
+		parent := root
+		sectionKey := ""
+		path := strings.Split(f.key, ".")
+		for _, name := range path[:len(path)-1] {
+			sectionKey = strings.TrimPrefix(sectionKey+"."+name, ".")
+			section, ok := sections[sectionKey]
+			if !ok {
+				section = &yaml.Node{Kind: yaml.MappingNode}
+				sections[sectionKey] = section
+				parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, section)
+			}
+			parent = section
+		}
+
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: path[len(path)-1]},
+			valueNode(f),
+		)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func valueNode(f field) *yaml.Node {
+	if f.secret {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: redact(f.String())}
+	}
+
+	if items, ok := f.value.Interface().([]string); ok {
+		list := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range items {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return list
+	}
+
+	tag := "!!str"
+	if f.value.Type() != durationType {
+		switch f.value.Kind() {
+		case reflect.Bool:
+			tag = "!!bool"
+		case reflect.Int, reflect.Int64:
+			tag = "!!int"
+		case reflect.Float64:
+			tag = "!!float"
+		}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: f.String()}
+}
+
+// redact hides a secret. Unset secrets are left empty, so it's clear they're unset.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	u, err := url.Parse(secret)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return redacted
+	}
+
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	// Connection strings may also pass credentials as parameters, eg. ?password=
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.Contains(lower, "password") || strings.Contains(lower, "token") || strings.Contains(lower, "key") || strings.Contains(lower, "secret") {
+			query.Set(key, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Change is a setting which differs between two configurations
+type Change struct {
+	// Dotted key, eg. log.level
+	Key string
+	// If true, the change is applied on reload. Otherwise, it requires a restart.
+	Reloadable bool
+}
+
+// Diff lists the settings which differ between two configurations, eg. when reloading on SIGHUP
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	newFields := fields(new)
+	for i, oldField := range fields(old) {
+		if !reflect.DeepEqual(oldField.value.Interface(), newFields[i].value.Interface()) {
+			changes = append(changes, Change{Key: oldField.key, Reloadable: oldField.reload})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,315 @@
+package geo
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL          = 24 * time.Hour
+	defaultCacheNegativeTTL  = time.Hour
+	defaultCacheMaxEntries   = 10000
+	defaultCacheFetchTimeout = 30 * time.Second
+)
+
+// CacheOptions configures a CachingGeoService
+type CacheOptions struct {
+	// How long to cache geocoded locations
+	TTL time.Duration
+	// How long to cache places which could not be found
+	NegativeTTL time.Duration
+	// Maximum number of entries to keep in memory.
+	// The least recently used entries are evicted first.
+	MaxEntries int
+	// Optional persistent cache tier, consulted on in-memory cache misses
+	Persistent GeocodeCacheStore
+	// Maximum time for a lookup on a cache miss. The lookup is shared by concurrent callers,
+	// so it isn't cancelled when the caller which started it gives up. Defaults to 30s.
+	FetchTimeout time.Duration
+}
+
+// GeocodeCacheEntry is a cached geocoding result
+type GeocodeCacheEntry struct {
+	Lat float64
+	Lon float64
+	// Place name, for reverse geocoding results
+	PlaceName string
+	// Suggestions, for autocomplete results
+	Suggestions []Suggestion
+	// True if the place could not be found
+	NotFound  bool
+	ExpiresAt time.Time
+}
+
+// GeocodeCacheStore is a persistent tier for the geocoding cache
+type GeocodeCacheStore interface {
+	// Get returns nil if there is no entry for the key
+	Get(key string) (*GeocodeCacheEntry, error)
+	Set(key string, entry *GeocodeCacheEntry) error
+}
+
+// CachingGeoService is a GeoService decorator, which caches geocoding results.
+//
+// Concurrent lookups of the same place share a single call to the underlying service.
+// Each caller stops waiting when its own context is done, while the shared call runs to completion
+// (up to CacheOptions.FetchTimeout), so that its result is cached for the others.
+type CachingGeoService struct {
+	next GeoService
+	opts CacheOptions
+	// Returns the current time. Replaced in tests.
+	now func() time.Time
+
+	mu sync.Mutex
+	// Cache entries, in least-recently-used order (front is most recent)
+	lru     *list.List
+	entries map[string]*list.Element
+	// Lookups currently in progress, by key
+	inflight map[string]*inflightGeocode
+	stats    CacheStats
+}
+
+// CacheStats counts the results of cache lookups
+type CacheStats struct {
+	// Found in the in-memory cache
+	Hits uint64
+	// Found in the persistent cache
+	PersistentHits uint64
+	// Not cached, so fetched from the underlying service
+	Misses uint64
+	// Shared the result of a concurrent lookup of the same key
+	Shared uint64
+}
+
+type lruEntry struct {
+	key   string
+	entry *GeocodeCacheEntry
+}
+
+type inflightGeocode struct {
+	done  chan struct{}
+	entry *GeocodeCacheEntry
+	err   error
+}
+
+func NewCachingGeoService(next GeoService, opts CacheOptions) *CachingGeoService {
+	if opts.TTL == 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	if opts.NegativeTTL == 0 {
+		opts.NegativeTTL = defaultCacheNegativeTTL
+	}
+	if opts.MaxEntries == 0 {
+		opts.MaxEntries = defaultCacheMaxEntries
+	}
+	if opts.FetchTimeout == 0 {
+		opts.FetchTimeout = defaultCacheFetchTimeout
+	}
+
+	return &CachingGeoService{
+		next:     next,
+		opts:     opts,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*inflightGeocode),
+	}
+}
+
+func (svc *CachingGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	entry, err := svc.cached(ctx, "geocode:"+normalizePlace(place), func(ctx context.Context) (*GeocodeCacheEntry, error) {
+		lat, lon, err := svc.next.Geocode(ctx, place)
+		return &GeocodeCacheEntry{Lat: lat, Lon: lon}, err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if entry.NotFound {
+		return 0, 0, fmt.Errorf("no location found at %s: %w", place, ErrPlaceNotFound)
+	}
+
+	return entry.Lat, entry.Lon, nil
+}
+
+func (svc *CachingGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	// Round coordinates to ~1m, so that nearby lookups share an entry
+	key := fmt.Sprintf("reverse:%.5f,%.5f", lat, lon)
+	entry, err := svc.cached(ctx, key, func(ctx context.Context) (*GeocodeCacheEntry, error) {
+		placeName, err := svc.next.ReverseGeocode(ctx, lat, lon)
+		return &GeocodeCacheEntry{Lat: lat, Lon: lon, PlaceName: placeName}, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if entry.NotFound {
+		return "", fmt.Errorf("no place found at %s: %w", key, ErrPlaceNotFound)
+	}
+
+	return entry.PlaceName, nil
+}
+
+// Suggest caches suggestions by query and (approximate) location,
+// so that repeated keystrokes from nearby users share results
+func (svc *CachingGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	key := "suggest:" + normalizePlace(query)
+	if near != nil {
+		// Round the location to ~1km. Proximity bias doesn't need to be more precise.
+		key += fmt.Sprintf("@%.2f,%.2f", near.Lat, near.Lon)
+	}
+	entry, err := svc.cached(ctx, key, func(ctx context.Context) (*GeocodeCacheEntry, error) {
+		suggestions, err := svc.next.Suggest(ctx, query, near)
+		// Cache empty results with the negative TTL
+		if err == nil && len(suggestions) == 0 {
+			return nil, ErrPlaceNotFound
+		}
+		return &GeocodeCacheEntry{Suggestions: suggestions}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry.NotFound {
+		return []Suggestion{}, nil
+	}
+
+	return entry.Suggestions, nil
+}
+
+// cached returns the cache entry for a key,
+// or calls fetch to populate the entry on a cache miss.
+//
+// Concurrent calls for the same key share a single call to fetch. It runs with a context
+// which isn't cancelled with ctx, so that callers only stop waiting when their own context is done.
+func (svc *CachingGeoService) cached(ctx context.Context, key string, fetch func(ctx context.Context) (*GeocodeCacheEntry, error)) (*GeocodeCacheEntry, error) {
+	svc.mu.Lock()
+	// Check the in-memory cache
+	if entry := svc.getLocked(key); entry != nil {
+		svc.stats.Hits++
+		svc.mu.Unlock()
+		return entry, nil
+	}
+	// Wait for a matching lookup that's already in progress, or start one
+	call, ok := svc.inflight[key]
+	if ok {
+		svc.stats.Shared++
+	} else {
+		call = &inflightGeocode{done: make(chan struct{})}
+		svc.inflight[key] = call
+		// Keep the caller's values (eg. its trace), but not its cancellation
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), svc.opts.FetchTimeout)
+		go func() {
+			defer cancel()
+			call.entry, call.err = svc.lookup(fetchCtx, key, fetch)
+
+			svc.mu.Lock()
+			delete(svc.inflight, key)
+			if call.err == nil {
+				svc.setLocked(key, call.entry)
+			}
+			svc.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	svc.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.entry, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lookup resolves a cache miss, using the persistent tier or the underlying service
+func (svc *CachingGeoService) lookup(ctx context.Context, key string, fetch func(ctx context.Context) (*GeocodeCacheEntry, error)) (*GeocodeCacheEntry, error) {
+	// Check the persistent cache
+	if svc.opts.Persistent != nil {
+		entry, err := svc.opts.Persistent.Get(key)
+		if err != nil {
+			// The persistent cache is an optimization, so don't fail the lookup
+			slog.WarnContext(ctx, "failed to read persistent geocode cache", "key", key, "error", err)
+		} else if entry != nil && svc.now().Before(entry.ExpiresAt) {
+			svc.countLookup(&svc.stats.PersistentHits)
+			return entry, nil
+		}
+	}
+
+	svc.countLookup(&svc.stats.Misses)
+	entry, err := fetch(ctx)
+	if errors.Is(err, ErrPlaceNotFound) {
+		// Negative caching for places that don't exist
+		entry = &GeocodeCacheEntry{NotFound: true, ExpiresAt: svc.now().Add(svc.opts.NegativeTTL)}
+	} else if err != nil {
+		// Don't cache other failures
+		return nil, err
+	} else {
+		entry.ExpiresAt = svc.now().Add(svc.opts.TTL)
+	}
+
+	if svc.opts.Persistent != nil {
+		if err := svc.opts.Persistent.Set(key, entry); err != nil {
+			slog.WarnContext(ctx, "failed to write persistent geocode cache", "key", key, "error", err)
+		}
+	}
+
+	return entry, nil
+}
+
+// Stats returns counts of cache lookups, since the cache was created
+func (svc *CachingGeoService) Stats() CacheStats {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	return svc.stats
+}
+
+func (svc *CachingGeoService) countLookup(counter *uint64) {
+	svc.mu.Lock()
+	*counter++
+	svc.mu.Unlock()
+}
+
+// getLocked returns an unexpired cache entry, or nil.
+// svc.mu must be held.
+func (svc *CachingGeoService) getLocked(key string) *GeocodeCacheEntry {
+	elem, ok := svc.entries[key]
+	if !ok {
+		return nil
+	}
+
+	item := elem.Value.(*lruEntry)
+	if !svc.now().Before(item.entry.ExpiresAt) {
+		svc.lru.Remove(elem)
+		delete(svc.entries, key)
+		return nil
+	}
+
+	svc.lru.MoveToFront(elem)
+	return item.entry
+}
+
+// setLocked adds an entry to the cache, evicting the least recently used entries if full.
+// svc.mu must be held.
+func (svc *CachingGeoService) setLocked(key string, entry *GeocodeCacheEntry) {
+	if elem, ok := svc.entries[key]; ok {
+		elem.Value.(*lruEntry).entry = entry
+		svc.lru.MoveToFront(elem)
+		return
+	}
+
+	svc.entries[key] = svc.lru.PushFront(&lruEntry{key: key, entry: entry})
+	for svc.lru.Len() > svc.opts.MaxEntries {
+		oldest := svc.lru.Back()
+		svc.lru.Remove(oldest)
+		delete(svc.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// normalizePlace creates a cache key for a place name,
+// so that eg. "Minneapolis, MN" and " minneapolis,  mn" share an entry
+func normalizePlace(place string) string {
+	return strings.Join(strings.Fields(strings.ToLower(place)), " ")
+}
@@ -0,0 +1,336 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachingGeoService(t *testing.T) {
+	mock := &mockGeoService{places: map[string][2]float64{
+		"Minneapolis, MN": {44.97, -93.26},
+	}}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis, MN")
+	require.NoError(t, err)
+	require.Equal(t, 44.97, lat)
+	require.Equal(t, -93.26, lon)
+
+	// Should use the cache for repeated lookups,
+	// including place names which only differ by case or whitespace
+	lat, lon, err = svc.Geocode(context.Background(), "  minneapolis,   MN ")
+	require.NoError(t, err)
+	require.Equal(t, 44.97, lat)
+	require.Equal(t, -93.26, lon)
+	require.Equal(t, int32(1), mock.calls.Load())
+}
+
+func TestCachingGeoService_TTL(t *testing.T) {
+	mock := &mockGeoService{places: map[string][2]float64{
+		"Minneapolis": {44.97, -93.26},
+	}}
+	svc := NewCachingGeoService(mock, CacheOptions{TTL: time.Minute})
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	_, _, err = svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), mock.calls.Load())
+
+	// Should lookup the place again, after the entry expires
+	now = now.Add(2 * time.Minute)
+	_, _, err = svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), mock.calls.Load())
+}
+
+func TestCachingGeoService_LRU(t *testing.T) {
+	mock := &mockGeoService{places: map[string][2]float64{
+		"a": {1, 1},
+		"b": {2, 2},
+		"c": {3, 3},
+	}}
+	svc := NewCachingGeoService(mock, CacheOptions{MaxEntries: 2})
+
+	// Fill the cache, then use "a" so that "b" is the least recently used
+	for _, place := range []string{"a", "b", "a", "c"} {
+		_, _, err := svc.Geocode(context.Background(), place)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(3), mock.calls.Load())
+
+	// "a" should still be cached
+	_, _, err := svc.Geocode(context.Background(), "a")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), mock.calls.Load())
+
+	// "b" should have been evicted
+	_, _, err = svc.Geocode(context.Background(), "b")
+	require.NoError(t, err)
+	require.Equal(t, int32(4), mock.calls.Load())
+}
+
+func TestCachingGeoService_NegativeCaching(t *testing.T) {
+	mock := &mockGeoService{}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+
+	// Should cache places which could not be found
+	for i := 0; i < 2; i++ {
+		_, _, err := svc.Geocode(context.Background(), "Atlantis")
+		require.ErrorIs(t, err, ErrPlaceNotFound)
+	}
+	require.Equal(t, int32(1), mock.calls.Load())
+}
+
+func TestCachingGeoService_Errors(t *testing.T) {
+	mock := &mockGeoService{err: errors.New("mapbox is down")}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+
+	// Should not cache errors
+	for i := 0; i < 2; i++ {
+		_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+		require.EqualError(t, err, "mapbox is down")
+	}
+	require.Equal(t, int32(2), mock.calls.Load())
+}
+
+func TestCachingGeoService_Singleflight(t *testing.T) {
+	mock := &mockGeoService{
+		places: map[string][2]float64{"Minneapolis": {44.97, -93.26}},
+		block:  make(chan struct{}),
+	}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+
+	// Start several concurrent lookups for the same place
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lat, _, err := svc.Geocode(context.Background(), "Minneapolis")
+			require.NoError(t, err)
+			require.Equal(t, 44.97, lat)
+		}()
+	}
+
+	// Wait for the first lookup to reach the underlying service, then let it finish
+	require.Eventually(t, func() bool { return mock.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(mock.block)
+	wg.Wait()
+
+	require.Equal(t, int32(1), mock.calls.Load())
+}
+
+func TestCachingGeoService_SingleflightCancel(t *testing.T) {
+	mock := &mockGeoService{
+		places: map[string][2]float64{"Minneapolis": {44.97, -93.26}},
+		block:  make(chan struct{}),
+	}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+
+	// Start a lookup, which is then cancelled (eg. because the client disconnected)
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, _, err := svc.Geocode(leaderCtx, "Minneapolis")
+		leaderErr <- err
+	}()
+	require.Eventually(t, func() bool { return mock.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// A concurrent lookup shares the call
+	waiterLat := make(chan float64, 1)
+	go func() {
+		lat, _, err := svc.Geocode(context.Background(), "Minneapolis")
+		require.NoError(t, err)
+		waiterLat <- lat
+	}()
+	require.Eventually(t, func() bool { return svc.Stats().Shared == 1 }, time.Second, time.Millisecond)
+
+	// The cancelled caller stops waiting, without failing the shared call
+	cancelLeader()
+	require.ErrorIs(t, <-leaderErr, context.Canceled)
+
+	// Callers stop waiting at their own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := svc.Geocode(ctx, "Minneapolis")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(mock.block)
+	require.Equal(t, 44.97, <-waiterLat)
+	require.Equal(t, int32(1), mock.calls.Load())
+}
+
+func TestCachingGeoService_Keys(t *testing.T) {
+	mock := &mockGeoService{suggestions: map[string][]Suggestion{
+		"foo": {{Name: "Foo", Lat: 1, Lon: 2}},
+	}}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+
+	_, err := svc.Suggest(context.Background(), "foo", nil)
+	require.NoError(t, err)
+
+	// Geocoded places don't share entries with suggestions
+	_, _, err = svc.Geocode(context.Background(), "suggest:foo")
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+	require.Equal(t, int32(2), mock.calls.Load())
+}
+
+func TestCachingGeoService_ReverseGeocode(t *testing.T) {
+	mock := &mockGeoService{placeNames: map[string]string{
+		"44.97,-93.26": "Minneapolis, Minnesota, United States",
+	}}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+
+	placeName, err := svc.ReverseGeocode(context.Background(), 44.97, -93.26)
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, Minnesota, United States", placeName)
+
+	// Should use the cache for repeated lookups
+	placeName, err = svc.ReverseGeocode(context.Background(), 44.97, -93.26)
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, Minnesota, United States", placeName)
+	require.Equal(t, int32(1), mock.calls.Load())
+
+	// Should cache locations without a place
+	for i := 0; i < 2; i++ {
+		_, err = svc.ReverseGeocode(context.Background(), 0, 0)
+		require.ErrorIs(t, err, ErrPlaceNotFound)
+	}
+	require.Equal(t, int32(2), mock.calls.Load())
+}
+
+func TestCachingGeoService_Persistent(t *testing.T) {
+	persistent := &mockGeocodeCacheStore{entries: map[string]*GeocodeCacheEntry{}}
+	mock := &mockGeoService{places: map[string][2]float64{
+		"Minneapolis": {44.97, -93.26},
+	}}
+
+	// Lookup a place, which should be written to the persistent cache
+	svc := NewCachingGeoService(mock, CacheOptions{Persistent: persistent})
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Contains(t, persistent.entries, "geocode:minneapolis")
+
+	// A new cache instance (eg. after a restart) should use the persistent entry
+	svc = NewCachingGeoService(mock, CacheOptions{Persistent: persistent})
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.97, lat)
+	require.Equal(t, -93.26, lon)
+	require.Equal(t, int32(1), mock.calls.Load())
+}
+
+// mockGeoService geocodes places from a fixed map
+func TestCachingGeoService_Suggest(t *testing.T) {
+	mock := &mockGeoService{suggestions: map[string][]Suggestion{
+		"Minn": {{Name: "Minneapolis", Lat: 44.98, Lon: -93.27}},
+	}}
+	svc := NewCachingGeoService(mock, CacheOptions{})
+	near := &Point{Lat: 44.95, Lon: -93.1}
+
+	suggestions, err := svc.Suggest(context.Background(), "Minn", near)
+	require.NoError(t, err)
+	require.Equal(t, []Suggestion{{Name: "Minneapolis", Lat: 44.98, Lon: -93.27}}, suggestions)
+
+	// Should use the cache for repeated keystrokes from (about) the same location
+	suggestions, err = svc.Suggest(context.Background(), "minn ", &Point{Lat: 44.951, Lon: -93.101})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, int32(1), mock.calls.Load())
+
+	// Should not share results with a different location, or with no location
+	_, err = svc.Suggest(context.Background(), "Minn", &Point{Lat: 40, Lon: -90})
+	require.NoError(t, err)
+	_, err = svc.Suggest(context.Background(), "Minn", nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), mock.calls.Load())
+
+	// Should cache empty results
+	for i := 0; i < 2; i++ {
+		suggestions, err = svc.Suggest(context.Background(), "Atlantis", nil)
+		require.NoError(t, err)
+		require.Empty(t, suggestions)
+	}
+	require.Equal(t, int32(4), mock.calls.Load())
+}
+
+type mockGeoService struct {
+	places map[string][2]float64
+	// Reverse geocoded place names, by "{lat},{lon}"
+	placeNames map[string]string
+	// Suggestions, by query
+	suggestions map[string][]Suggestion
+	// If set, all calls will return this error
+	err error
+	// If set, calls will block until the channel is closed
+	block chan struct{}
+	calls atomic.Int32
+}
+
+func (svc *mockGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	svc.calls.Add(1)
+	if svc.block != nil {
+		<-svc.block
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	if svc.err != nil {
+		return 0, 0, svc.err
+	}
+
+	latLon, ok := svc.places[place]
+	if !ok {
+		return 0, 0, ErrPlaceNotFound
+	}
+	return latLon[0], latLon[1], nil
+}
+
+func (svc *mockGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	svc.calls.Add(1)
+	if svc.err != nil {
+		return "", svc.err
+	}
+
+	placeName, ok := svc.placeNames[fmt.Sprintf("%v,%v", lat, lon)]
+	if !ok {
+		return "", ErrPlaceNotFound
+	}
+	return placeName, nil
+}
+
+func (svc *mockGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	svc.calls.Add(1)
+	if svc.err != nil {
+		return nil, svc.err
+	}
+
+	suggestions, ok := svc.suggestions[query]
+	if !ok {
+		return []Suggestion{}, nil
+	}
+	return suggestions, nil
+}
+
+type mockGeocodeCacheStore struct {
+	entries map[string]*GeocodeCacheEntry
+}
+
+func (s *mockGeocodeCacheStore) Get(key string) (*GeocodeCacheEntry, error) {
+	return s.entries[key], nil
+}
+
+func (s *mockGeocodeCacheStore) Set(key string, entry *GeocodeCacheEntry) error {
+	s.entries[key] = entry
+	return nil
+}
@@ -0,0 +1,54 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrUnauthorized is returned when a provider rejects our credentials
+	ErrUnauthorized = errors.New("geocoding provider rejected credentials")
+	// ErrRateLimited is returned when a provider is throttling our requests
+	ErrRateLimited = errors.New("geocoding provider rate limit exceeded")
+	// ErrProviderUnavailable is returned when a provider has a server error
+	ErrProviderUnavailable = errors.New("geocoding provider unavailable")
+	// ErrUnsupported is returned when a provider can't be used for a kind of request,
+	// eg. autocomplete with the public Nominatim instance
+	ErrUnsupported = errors.New("not supported by the geocoding provider")
+)
+
+// ProviderError is an error response from a geocoding provider.
+// Use errors.Is to check for ErrUnauthorized, ErrRateLimited or ErrProviderUnavailable.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	// Error message from the response body, if any
+	Message string
+	// Value of the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("request to %s failed with status %d: %s", e.Provider, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("request to %s failed with status %d", e.Provider, e.StatusCode)
+}
+
+func (e *ProviderError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == 401 || e.StatusCode == 403
+	case ErrRateLimited:
+		return e.StatusCode == 429
+	case ErrProviderUnavailable:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// retryable checks if a request might succeed, if we try again later
+func (e *ProviderError) retryable() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
@@ -0,0 +1,224 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProviderTimeout  = 10 * time.Second
+	defaultFailureThreshold = 3
+	defaultUnhealthyPeriod  = 30 * time.Second
+)
+
+// FallbackProvider is a GeoService used by a FallbackGeoService
+type FallbackProvider struct {
+	// Name of the provider, for logging and health reporting
+	Name    string
+	Service GeoService
+	// Maximum time to wait for the provider (including any retries), before trying the next one.
+	// Defaults to 10s.
+	Timeout time.Duration
+}
+
+// ProviderStatus reports the health of a FallbackGeoService provider
+type ProviderStatus struct {
+	Name                string     `json:"name"`
+	Healthy             bool       `json:"healthy"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	UnhealthyUntil      *time.Time `json:"unhealthy_until,omitempty"`
+}
+
+// FallbackGeoService is a GeoService which tries several providers in order,
+// until one of them succeeds.
+//
+// Providers which fail repeatedly are marked as unhealthy, and are skipped for a cooldown period
+// (unless every provider is unhealthy). A provider which can't find a place is not considered a failure.
+type FallbackGeoService struct {
+	providers []*fallbackProvider
+	// Number of consecutive failures, before a provider is marked as unhealthy
+	FailureThreshold int
+	// How long an unhealthy provider is skipped for
+	UnhealthyPeriod time.Duration
+	// Returns the current time. Replaced in tests.
+	now func() time.Time
+}
+
+type fallbackProvider struct {
+	FallbackProvider
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	unhealthyUntil      time.Time
+}
+
+func NewFallbackGeoService(providers ...FallbackProvider) *FallbackGeoService {
+	svc := &FallbackGeoService{
+		FailureThreshold: defaultFailureThreshold,
+		UnhealthyPeriod:  defaultUnhealthyPeriod,
+		now:              time.Now,
+	}
+	for _, provider := range providers {
+		if provider.Timeout == 0 {
+			provider.Timeout = defaultProviderTimeout
+		}
+		svc.providers = append(svc.providers, &fallbackProvider{FallbackProvider: provider})
+	}
+
+	return svc
+}
+
+func (svc *FallbackGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	var lat, lon float64
+	err := svc.try(ctx, func(ctx context.Context, provider GeoService) error {
+		var err error
+		lat, lon, err = provider.Geocode(ctx, place)
+		return err
+	})
+
+	return lat, lon, err
+}
+
+func (svc *FallbackGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	var placeName string
+	err := svc.try(ctx, func(ctx context.Context, provider GeoService) error {
+		var err error
+		placeName, err = provider.ReverseGeocode(ctx, lat, lon)
+		return err
+	})
+
+	return placeName, err
+}
+
+func (svc *FallbackGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	var suggestions []Suggestion
+	err := svc.try(ctx, func(ctx context.Context, provider GeoService) error {
+		var err error
+		suggestions, err = provider.Suggest(ctx, query, near)
+		// Try the next provider, if this one has no suggestions
+		if err == nil && len(suggestions) == 0 {
+			return ErrPlaceNotFound
+		}
+		return err
+	})
+	if errors.Is(err, ErrPlaceNotFound) {
+		return []Suggestion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// Status reports the health of each provider
+func (svc *FallbackGeoService) Status() []ProviderStatus {
+	now := svc.now()
+	var statuses []ProviderStatus
+	for _, provider := range svc.providers {
+		provider.mu.Lock()
+		status := ProviderStatus{
+			Name:                provider.Name,
+			Healthy:             !now.Before(provider.unhealthyUntil),
+			ConsecutiveFailures: provider.consecutiveFailures,
+		}
+		if !status.Healthy {
+			unhealthyUntil := provider.unhealthyUntil
+			status.UnhealthyUntil = &unhealthyUntil
+		}
+		provider.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// try calls fn with each provider in turn, until one succeeds
+func (svc *FallbackGeoService) try(ctx context.Context, fn func(ctx context.Context, provider GeoService) error) error {
+	if len(svc.providers) == 0 {
+		return errors.New("no geocoding providers configured")
+	}
+
+	var errs []error
+	notFound := false
+	unsupported := false
+	for _, provider := range svc.ordered() {
+		providerCtx, cancel := context.WithTimeout(ctx, provider.Timeout)
+		err := fn(providerCtx, provider.Service)
+		cancel()
+
+		if err == nil {
+			provider.recordSuccess()
+			return nil
+		}
+		// The provider is working, it just doesn't know this place.
+		// Another provider might.
+		if errors.Is(err, ErrPlaceNotFound) {
+			provider.recordSuccess()
+			notFound = true
+			continue
+		}
+		// The provider can't answer this kind of request. It hasn't failed.
+		if errors.Is(err, ErrUnsupported) {
+			unsupported = true
+			continue
+		}
+		// Don't blame the provider if our caller gave up
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Logged with the request's context (eg. its request ID), as the next provider may succeed
+		slog.WarnContext(ctx, "geocoding provider failed", "provider", provider.Name, "error", err)
+		provider.recordFailure(svc.now(), svc.FailureThreshold, svc.UnhealthyPeriod)
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+	}
+
+	if notFound {
+		return ErrPlaceNotFound
+	}
+	if unsupported && len(errs) == 0 {
+		return ErrUnsupported
+	}
+	return fmt.Errorf("all geocoding providers failed: %w", errors.Join(errs...))
+}
+
+// ordered returns healthy providers (in configured order),
+// followed by unhealthy providers as a last resort
+func (svc *FallbackGeoService) ordered() []*fallbackProvider {
+	now := svc.now()
+	var healthy, unhealthy []*fallbackProvider
+	for _, provider := range svc.providers {
+		provider.mu.Lock()
+		isHealthy := !now.Before(provider.unhealthyUntil)
+		provider.mu.Unlock()
+
+		if isHealthy {
+			healthy = append(healthy, provider)
+		} else {
+			unhealthy = append(unhealthy, provider)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (provider *fallbackProvider) recordSuccess() {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.consecutiveFailures = 0
+	provider.unhealthyUntil = time.Time{}
+}
+
+func (provider *fallbackProvider) recordFailure(now time.Time, threshold int, unhealthyPeriod time.Duration) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.consecutiveFailures++
+	if provider.consecutiveFailures >= threshold {
+		provider.unhealthyUntil = now.Add(unhealthyPeriod)
+	}
+}
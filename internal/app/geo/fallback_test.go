@@ -0,0 +1,217 @@
+package geo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/logging"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestFallbackGeoService(t *testing.T) {
+	primary := &mockGeoService{places: map[string][2]float64{"Minneapolis": {44.97, -93.26}}}
+	secondary := &mockGeoService{places: map[string][2]float64{"Minneapolis": {44.98, -93.27}}}
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "primary", Service: primary},
+		FallbackProvider{Name: "secondary", Service: secondary},
+	)
+
+	// Should use the first provider
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.97, lat)
+	require.Equal(t, -93.26, lon)
+	require.Equal(t, int32(0), secondary.calls.Load())
+}
+
+func TestFallbackGeoService_Fallback(t *testing.T) {
+	primary := &mockGeoService{err: errors.New("primary is down")}
+	secondary := &mockGeoService{places: map[string][2]float64{"Minneapolis": {44.98, -93.27}}}
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "primary", Service: primary},
+		FallbackProvider{Name: "secondary", Service: secondary},
+	)
+
+	// Should fall back to the second provider
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.98, lat)
+	require.Equal(t, -93.27, lon)
+}
+
+func TestFallbackGeoService_LogsFailures(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", slog.LevelInfo)
+	require.NoError(t, err)
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "primary", Service: &mockGeoService{err: errors.New("primary is down")}},
+		FallbackProvider{Name: "secondary", Service: &mockGeoService{places: map[string][2]float64{"Minneapolis": {44.98, -93.27}}}},
+	)
+	ctx := logging.WithRequestID(context.Background(), "req-123")
+	_, _, err = svc.Geocode(ctx, "Minneapolis")
+	require.NoError(t, err)
+
+	// Failures are logged with the request ID
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, "geocoding provider failed", record["msg"])
+	require.Equal(t, "primary", record["provider"])
+	require.Equal(t, "primary is down", record["error"])
+	require.Equal(t, "req-123", record["request_id"])
+}
+
+func TestFallbackGeoService_NotFound(t *testing.T) {
+	primary := &mockGeoService{}
+	secondary := &mockGeoService{places: map[string][2]float64{"Minneapolis": {44.98, -93.27}}}
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "primary", Service: primary},
+		FallbackProvider{Name: "secondary", Service: secondary},
+	)
+
+	// A place unknown to the first provider should be looked up with the next
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+
+	// A place unknown to all providers should be not found
+	_, _, err = svc.Geocode(context.Background(), "Atlantis")
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+
+	// Not found isn't a failure
+	for _, status := range svc.Status() {
+		require.True(t, status.Healthy)
+		require.Equal(t, 0, status.ConsecutiveFailures)
+	}
+}
+
+func TestFallbackGeoService_AllFailed(t *testing.T) {
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "primary", Service: &mockGeoService{err: errors.New("primary is down")}},
+		FallbackProvider{Name: "secondary", Service: &mockGeoService{err: errors.New("secondary is down")}},
+	)
+
+	_, err := svc.ReverseGeocode(context.Background(), 44.97, -93.26)
+	require.EqualError(t, err, "all geocoding providers failed: primary: primary is down\nsecondary: secondary is down")
+}
+
+func TestFallbackGeoService_Timeout(t *testing.T) {
+	slow := &slowGeoService{}
+	secondary := &mockGeoService{places: map[string][2]float64{"Minneapolis": {44.98, -93.27}}}
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "slow", Service: slow, Timeout: 10 * time.Millisecond},
+		FallbackProvider{Name: "secondary", Service: secondary},
+	)
+
+	// Should give up on the slow provider, and use the next one
+	lat, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.98, lat)
+	require.Equal(t, 1, svc.Status()[0].ConsecutiveFailures)
+}
+
+func TestFallbackGeoService_Health(t *testing.T) {
+	primary := &mockGeoService{err: errors.New("primary is down")}
+	secondary := &mockGeoService{places: map[string][2]float64{"Minneapolis": {44.98, -93.27}}}
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "primary", Service: primary},
+		FallbackProvider{Name: "secondary", Service: secondary},
+	)
+	svc.FailureThreshold = 2
+	svc.UnhealthyPeriod = time.Minute
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	// Fail until the primary provider is marked unhealthy
+	for i := 0; i < 2; i++ {
+		_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(2), primary.calls.Load())
+	require.False(t, svc.Status()[0].Healthy)
+	require.True(t, svc.Status()[1].Healthy)
+
+	// Unhealthy providers should be skipped
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), primary.calls.Load())
+
+	// After the unhealthy period, the provider should be tried again
+	now = now.Add(2 * time.Minute)
+	primary.err = nil
+	primary.places = map[string][2]float64{"Minneapolis": {44.97, -93.26}}
+	lat, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.97, lat)
+	require.True(t, svc.Status()[0].Healthy)
+}
+
+// slowGeoService blocks until the context is cancelled
+type slowGeoService struct{}
+
+func (svc *slowGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	<-ctx.Done()
+	return 0, 0, ctx.Err()
+}
+
+func (svc *slowGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestFallbackGeoService_Suggest(t *testing.T) {
+	primary := &mockGeoService{}
+	secondary := &mockGeoService{suggestions: map[string][]Suggestion{
+		"Minn": {{Name: "Minneapolis", Lat: 44.98, Lon: -93.27}},
+	}}
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "primary", Service: primary},
+		FallbackProvider{Name: "secondary", Service: secondary},
+	)
+
+	// Should try the next provider, if the first has no suggestions
+	suggestions, err := svc.Suggest(context.Background(), "Minn", nil)
+	require.NoError(t, err)
+	require.Equal(t, []Suggestion{{Name: "Minneapolis", Lat: 44.98, Lon: -93.27}}, suggestions)
+
+	// Should return an empty list, if no provider has suggestions
+	suggestions, err = svc.Suggest(context.Background(), "Atlantis", nil)
+	require.NoError(t, err)
+	require.Empty(t, suggestions)
+	require.NotNil(t, suggestions)
+}
+
+func TestFallbackGeoService_SuggestUnsupported(t *testing.T) {
+	unsupported := &mockGeoService{err: ErrUnsupported}
+	secondary := &mockGeoService{suggestions: map[string][]Suggestion{
+		"Minn": {{Name: "Minneapolis", Lat: 44.98, Lon: -93.27}},
+	}}
+	svc := NewFallbackGeoService(
+		FallbackProvider{Name: "unsupported", Service: unsupported},
+		FallbackProvider{Name: "secondary", Service: secondary},
+	)
+	svc.FailureThreshold = 1
+
+	// Should use the next provider, without marking the first as unhealthy
+	suggestions, err := svc.Suggest(context.Background(), "Minn", nil)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	require.True(t, svc.Status()[0].Healthy)
+
+	// Should fail with ErrUnsupported, if no provider supports the request
+	svc = NewFallbackGeoService(FallbackProvider{Name: "unsupported", Service: unsupported})
+	_, err = svc.Suggest(context.Background(), "Minn", nil)
+	require.ErrorIs(t, err, ErrUnsupported)
+}
+
+func (svc *slowGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
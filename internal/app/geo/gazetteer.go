@@ -0,0 +1,614 @@
+package geo
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Maximum distance to a gazetteer entry, for reverse geocoding
+const defaultGazetteerMaxReverseDistance = 50e3
+
+// GazetteerEntry is a single named place in a gazetteer
+type GazetteerEntry struct {
+	Name           string
+	AlternateNames []string
+	Lat            float64
+	Lon            float64
+	// ISO country code, eg. "US"
+	CountryCode string
+	// First-level administrative division code, eg. "IL" for Illinois
+	Admin1Code string
+	// First-level administrative division name, eg. "Illinois" (if known)
+	Admin1Name string
+	Population int64
+}
+
+// Label returns a display name for the entry, eg. "Springfield, Illinois, US"
+func (entry *GazetteerEntry) Label() string {
+	parts := []string{entry.Name}
+	if entry.Admin1Name != "" {
+		parts = append(parts, entry.Admin1Name)
+	} else if entry.Admin1Code != "" {
+		parts = append(parts, entry.Admin1Code)
+	}
+	if entry.CountryCode != "" {
+		parts = append(parts, entry.CountryCode)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// GazetteerGeoService geocodes place names in-process, from a gazetteer file.
+// It requires no network access, so is suitable for air-gapped deployments.
+//
+// Place names are matched after normalization (case, accents, punctuation, common abbreviations),
+// falling back to fuzzy matching for misspellings. Ambiguous names are resolved by population,
+// and may be qualified by region or country, eg. "Springfield, IL" or "Paris, FR".
+//
+// Names are indexed when the service is created, so that lookups don't scan the whole gazetteer:
+// prefix matches (for suggestions) use a sorted list of names, and fuzzy matches only compare
+// names which share enough trigrams with the query to be within the allowed edit distance.
+type GazetteerGeoService struct {
+	entries []*GazetteerEntry
+	// Entries, by normalized name (including alternate names)
+	byName map[string][]*GazetteerEntry
+	// Normalized names, sorted, for prefix matching
+	names []string
+	// Indexes into names, by trigram, for fuzzy matching
+	byTrigram map[string][]int32
+	// Maximum distance to the nearest entry, for reverse geocoding
+	MaxReverseDistance float64
+}
+
+func NewGazetteerGeoService(entries []*GazetteerEntry) *GazetteerGeoService {
+	svc := &GazetteerGeoService{
+		entries:            entries,
+		byName:             make(map[string][]*GazetteerEntry),
+		byTrigram:          make(map[string][]int32),
+		MaxReverseDistance: defaultGazetteerMaxReverseDistance,
+	}
+	for _, entry := range entries {
+		entry.Name = strings.TrimSpace(entry.Name)
+		seen := make(map[string]bool)
+		for _, name := range append([]string{entry.Name}, entry.AlternateNames...) {
+			key := normalizePlaceName(name)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			svc.byName[key] = append(svc.byName[key], entry)
+		}
+	}
+
+	svc.names = make([]string, 0, len(svc.byName))
+	for name := range svc.byName {
+		svc.names = append(svc.names, name)
+	}
+	sort.Strings(svc.names)
+	for i, name := range svc.names {
+		for _, trigram := range trigrams(name) {
+			svc.byTrigram[trigram] = append(svc.byTrigram[trigram], int32(i))
+		}
+	}
+
+	return svc
+}
+
+// LoadGazetteer loads a gazetteer file.
+//
+// Files with a .csv extension are expected to have a header row, with columns:
+// name, lat, lon, and optionally country_code, admin1_code, admin1_name, population, alternate_names.
+// Other files are read as GeoNames tab-separated files (eg. cities15000.txt).
+// See https://download.geonames.org/export/dump/readme.txt
+func LoadGazetteer(path string) (*GazetteerGeoService, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gazetteer: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*GazetteerEntry
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		entries, err = readGazetteerCSV(file)
+	} else {
+		entries, err = readGeoNamesTSV(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gazetteer %s: %w", path, err)
+	}
+
+	return NewGazetteerGeoService(entries), nil
+}
+
+// LoadAdmin1Names adds region names to the gazetteer's entries,
+// from a GeoNames admin1CodesASCII.txt file.
+// This allows place names to be qualified by full region name, eg. "Springfield, Illinois"
+func (svc *GazetteerGeoService) LoadAdmin1Names(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open admin1 codes: %w", err)
+	}
+	defer file.Close()
+
+	// Rows look like "US.IL\tIllinois\tIllinois\t4896861"
+	names := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		cols := strings.Split(scanner.Text(), "\t")
+		if len(cols) >= 2 {
+			names[cols[0]] = cols[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read admin1 codes: %w", err)
+	}
+
+	for _, entry := range svc.entries {
+		if name, ok := names[entry.CountryCode+"."+entry.Admin1Code]; ok {
+			entry.Admin1Name = name
+		}
+	}
+
+	return nil
+}
+
+func (svc *GazetteerGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	entry := svc.Lookup(place)
+	if entry == nil {
+		return 0, 0, fmt.Errorf("no location found at %s: %w", place, ErrPlaceNotFound)
+	}
+
+	return entry.Lat, entry.Lon, nil
+}
+
+func (svc *GazetteerGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	var nearest *GazetteerEntry
+	nearestDistance := svc.MaxReverseDistance
+	for _, entry := range svc.entries {
+		distance := DistanceMeters(lat, lon, entry.Lat, entry.Lon)
+		if distance <= nearestDistance {
+			nearest = entry
+			nearestDistance = distance
+		}
+	}
+	if nearest == nil {
+		return "", fmt.Errorf("no place found at %v,%v: %w", lat, lon, ErrPlaceNotFound)
+	}
+
+	return nearest.Label(), nil
+}
+
+// Suggest returns entries whose name starts with the query, ranked by population.
+// If near is set, closer entries are ranked higher. Qualifiers are also matched by prefix, eg. "Springfield, Ill".
+func (svc *GazetteerGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	// Split "Springfield, IL" into a name prefix and qualifiers
+	parts := strings.Split(query, ",")
+	prefix := normalizePlaceName(parts[0])
+	suggestions := []Suggestion{}
+	if prefix == "" {
+		return suggestions, nil
+	}
+	// Qualifiers may be partially typed too, eg. "Springfield, Ill"
+	var qualifiers []string
+	for _, part := range parts[1:] {
+		if qualifier := normalizePlaceName(part); qualifier != "" {
+			qualifiers = append(qualifiers, qualifier)
+		}
+	}
+
+	// Find matching entries (an entry may match by several alternate names).
+	// Names with the prefix are adjacent in the sorted list of names.
+	matched := make(map[*GazetteerEntry]bool)
+	var matches []*GazetteerEntry
+	for i := sort.SearchStrings(svc.names, prefix); i < len(svc.names) && strings.HasPrefix(svc.names[i], prefix); i++ {
+		for _, entry := range svc.byName[svc.names[i]] {
+			if !matched[entry] && matchesQualifiers(entry, qualifiers, strings.HasPrefix) {
+				matched[entry] = true
+				matches = append(matches, entry)
+			}
+		}
+	}
+
+	scores := make(map[*GazetteerEntry]float64, len(matches))
+	for _, entry := range matches {
+		scores[entry] = suggestionScore(entry, near)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if scores[matches[i]] != scores[matches[j]] {
+			return scores[matches[i]] > scores[matches[j]]
+		}
+		return matches[i].Label() < matches[j].Label()
+	})
+
+	for _, entry := range matches {
+		if len(suggestions) == defaultSuggestLimit {
+			break
+		}
+		suggestions = append(suggestions, Suggestion{
+			Name: entry.Label(),
+			Lat:  entry.Lat,
+			Lon:  entry.Lon,
+			Type: "city",
+		})
+	}
+
+	return suggestions, nil
+}
+
+// Distance at which a suggestion's population score is halved, when biasing towards a location
+const suggestionDistanceScale = 100e3
+
+// suggestionScore ranks an entry by population, discounted by distance from near (if set)
+func suggestionScore(entry *GazetteerEntry, near *Point) float64 {
+	score := float64(entry.Population + 1)
+	if near != nil {
+		score /= 1 + DistanceMeters(near.Lat, near.Lon, entry.Lat, entry.Lon)/suggestionDistanceScale
+	}
+
+	return score
+}
+
+// Lookup returns the best matching entry for a place name, or nil
+func (svc *GazetteerGeoService) Lookup(place string) *GazetteerEntry {
+	// Split "Springfield, IL, US" into a name and qualifiers
+	parts := strings.Split(place, ",")
+	key := normalizePlaceName(parts[0])
+	if key == "" {
+		return nil
+	}
+	var qualifiers []string
+	for _, part := range parts[1:] {
+		if qualifier := normalizePlaceName(part); qualifier != "" {
+			qualifiers = append(qualifiers, qualifier)
+		}
+	}
+
+	// Prefer exact matches, then try fuzzy matches
+	if entry := bestEntry(svc.byName[key], qualifiers); entry != nil {
+		return entry
+	}
+
+	return svc.fuzzyLookup(key, qualifiers)
+}
+
+// fuzzyLookup finds the closest names within a small edit distance,
+// to allow for misspellings (eg. "Minneaplis")
+func (svc *GazetteerGeoService) fuzzyLookup(key string, qualifiers []string) *GazetteerEntry {
+	maxDistance := maxEditDistance(key)
+	if maxDistance == 0 {
+		return nil
+	}
+
+	// Collect candidate names, grouped by edit distance
+	candidates := make([][]*GazetteerEntry, maxDistance+1)
+	keyRunes := []rune(key)
+	for _, name := range svc.fuzzyCandidates(key, maxDistance) {
+		nameRunes := []rune(name)
+		if abs(len(nameRunes)-len(keyRunes)) > maxDistance {
+			continue
+		}
+		if distance := editDistance(keyRunes, nameRunes); distance <= maxDistance {
+			candidates[distance] = append(candidates[distance], svc.byName[name]...)
+		}
+	}
+
+	// Closest names win, then population
+	for _, entries := range candidates {
+		if entry := bestEntry(entries, qualifiers); entry != nil {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// fuzzyCandidates returns the names which might be within maxDistance edits of key.
+//
+// Each edit changes at most 3 of a name's trigrams, so a name within maxDistance edits
+// shares all but 3*maxDistance of the key's distinct trigrams. Only names sharing at least
+// that many trigrams are returned, so that we only compute the edit distance for a few names.
+func (svc *GazetteerGeoService) fuzzyCandidates(key string, maxDistance int) []string {
+	keyTrigrams := trigrams(key)
+	minShared := len(keyTrigrams) - 3*maxDistance
+	if minShared < 1 {
+		minShared = 1
+	}
+
+	shared := make(map[int32]int)
+	for _, trigram := range keyTrigrams {
+		for _, i := range svc.byTrigram[trigram] {
+			shared[i]++
+		}
+	}
+
+	var names []string
+	for i, count := range shared {
+		if count >= minShared {
+			names = append(names, svc.names[i])
+		}
+	}
+	return names
+}
+
+// trigrams returns the distinct 3-rune substrings of a name, padded so that its start and end are also indexed,
+// eg. "paris" => "$pa", "par", "ari", "ris", "is$"
+func trigrams(name string) []string {
+	runes := []rune("$" + name + "$")
+	seen := make(map[string]bool, len(runes))
+	var result []string
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+		if !seen[trigram] {
+			seen[trigram] = true
+			result = append(result, trigram)
+		}
+	}
+	return result
+}
+
+// bestEntry returns the most populous entry which matches all of the qualifiers
+func bestEntry(entries []*GazetteerEntry, qualifiers []string) *GazetteerEntry {
+	var best *GazetteerEntry
+	for _, entry := range entries {
+		if !matchesQualifiers(entry, qualifiers, equalStrings) {
+			continue
+		}
+		if best == nil || entry.Population > best.Population {
+			best = entry
+		}
+	}
+
+	return best
+}
+
+// matchesQualifiers checks that each qualifier names the entry's region or country.
+// match compares the normalized region or country to the qualifier, eg. strings.HasPrefix to allow partial qualifiers.
+func matchesQualifiers(entry *GazetteerEntry, qualifiers []string, match func(name string, qualifier string) bool) bool {
+	for _, qualifier := range qualifiers {
+		if !match(normalizePlaceName(entry.Admin1Code), qualifier) &&
+			!match(normalizePlaceName(entry.Admin1Name), qualifier) &&
+			!match(normalizePlaceName(entry.CountryCode), qualifier) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalStrings(a string, b string) bool {
+	return a == b
+}
+
+// Abbreviations which are expanded during normalization,
+// so that eg. "St. Paul" matches "Saint Paul"
+var placeNameAbbreviations = map[string]string{
+	"st":  "saint",
+	"ste": "sainte",
+	"mt":  "mount",
+	"ft":  "fort",
+	"pt":  "point",
+}
+
+// normalizePlaceName lowercases a place name, and removes accents and punctuation,
+// eg. "St. Paul" => "saint paul", "Zürich" => "zurich"
+func normalizePlaceName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if replacement, ok := accentReplacements[r]; ok {
+			b.WriteString(replacement)
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else if unicode.Is(unicode.Mn, r) {
+			// Drop combining marks (accents in decomposed form)
+		} else if r == '\'' || r == '’' {
+			// "Coeur d'Alene" => "coeur dalene"
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	for i, word := range words {
+		if expanded, ok := placeNameAbbreviations[word]; ok {
+			words[i] = expanded
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Common accented latin characters, and their unaccented equivalents
+var accentReplacements = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i",
+	'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// maxEditDistance is the number of typos we allow in a name, based on its length
+func maxEditDistance(key string) int {
+	length := len([]rune(key))
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// GeoNames tab-separated columns
+// See https://download.geonames.org/export/dump/readme.txt
+const (
+	geoNamesName           = 1
+	geoNamesAlternateNames = 3
+	geoNamesLat            = 4
+	geoNamesLon            = 5
+	geoNamesCountryCode    = 8
+	geoNamesAdmin1Code     = 10
+	geoNamesPopulation     = 14
+	geoNamesMinColumns     = 15
+)
+
+func readGeoNamesTSV(r io.Reader) ([]*GazetteerEntry, error) {
+	var entries []*GazetteerEntry
+	scanner := bufio.NewScanner(r)
+	// Some GeoNames rows have very long alternate name lists
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cols := strings.Split(text, "\t")
+		if len(cols) < geoNamesMinColumns {
+			return nil, fmt.Errorf("line %d: expected at least %d columns, got %d", line, geoNamesMinColumns, len(cols))
+		}
+		lat, err := strconv.ParseFloat(cols[geoNamesLat], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid latitude: %w", line, err)
+		}
+		lon, err := strconv.ParseFloat(cols[geoNamesLon], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid longitude: %w", line, err)
+		}
+		population, _ := strconv.ParseInt(cols[geoNamesPopulation], 10, 64)
+
+		entries = append(entries, &GazetteerEntry{
+			Name:           cols[geoNamesName],
+			AlternateNames: splitNonEmpty(cols[geoNamesAlternateNames], ","),
+			Lat:            lat,
+			Lon:            lon,
+			CountryCode:    cols[geoNamesCountryCode],
+			Admin1Code:     cols[geoNamesAdmin1Code],
+			Population:     population,
+		})
+	}
+
+	return entries, scanner.Err()
+}
+
+func readGazetteerCSV(r io.Reader) ([]*GazetteerEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// Map column names to indexes
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, required := range []string{"name", "lat", "lon"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing required column \"%s\"", required)
+		}
+	}
+	get := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var entries []*GazetteerEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		lat, err := strconv.ParseFloat(get(record, "lat"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid lat: %w", line, err)
+		}
+		lon, err := strconv.ParseFloat(get(record, "lon"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid lon: %w", line, err)
+		}
+		population, _ := strconv.ParseInt(get(record, "population"), 10, 64)
+
+		entries = append(entries, &GazetteerEntry{
+			Name:           get(record, "name"),
+			AlternateNames: splitNonEmpty(get(record, "alternate_names"), "|"),
+			Lat:            lat,
+			Lon:            lon,
+			CountryCode:    get(record, "country_code"),
+			Admin1Code:     get(record, "admin1_code"),
+			Admin1Name:     get(record, "admin1_name"),
+			Population:     population,
+		})
+	}
+
+	return entries, nil
+}
+
+func splitNonEmpty(s string, sep string) []string {
+	var parts []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
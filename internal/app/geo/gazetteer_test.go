@@ -0,0 +1,189 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGazetteerGeoService_Geocode(t *testing.T) {
+	svc, err := LoadGazetteer("testdata/gazetteer.txt")
+	require.NoError(t, err)
+
+	tests := []struct {
+		place string
+		lat   float64
+		lon   float64
+	}{
+		{"Minneapolis", 44.97997, -93.26384},
+		// Case and whitespace
+		{"  minneapolis ", 44.97997, -93.26384},
+		// Alternate names
+		{"MPLS", 44.97997, -93.26384},
+		// Abbreviations and punctuation
+		{"St. Paul", 44.94441, -93.09327},
+		// Accents
+		{"Zurich", 47.36667, 8.55},
+		{"Zürich", 47.36667, 8.55},
+		// Misspellings
+		{"Minneaplis", 44.97997, -93.26384},
+		// Ambiguous names should prefer the largest population
+		{"Springfield", 37.21533, -93.29824},
+		// Ambiguous names may be qualified by region or country
+		{"Springfield, IL", 39.80172, -89.64371},
+		{"Springfield, MA, US", 42.10148, -72.58981},
+		{"Zurich, CH", 47.36667, 8.55},
+	}
+	for _, test := range tests {
+		t.Run(test.place, func(t *testing.T) {
+			lat, lon, err := svc.Geocode(context.Background(), test.place)
+			require.NoError(t, err)
+			require.Equal(t, test.lat, lat)
+			require.Equal(t, test.lon, lon)
+		})
+	}
+}
+
+func TestGazetteerGeoService_GeocodeNotFound(t *testing.T) {
+	svc, err := LoadGazetteer("testdata/gazetteer.txt")
+	require.NoError(t, err)
+
+	for _, place := range []string{"Atlantis", "Springfield, CA", "", "Mpl"} {
+		_, _, err := svc.Geocode(context.Background(), place)
+		require.ErrorIs(t, err, ErrPlaceNotFound, place)
+	}
+}
+
+func TestGazetteerGeoService_ReverseGeocode(t *testing.T) {
+	svc, err := LoadGazetteer("testdata/gazetteer.txt")
+	require.NoError(t, err)
+
+	// Should find the nearest place
+	placeName, err := svc.ReverseGeocode(context.Background(), 44.95, -93.11)
+	require.NoError(t, err)
+	require.Equal(t, "Saint Paul, MN, US", placeName)
+
+	// Should not find places which are too far away
+	_, err = svc.ReverseGeocode(context.Background(), 0, 0)
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+}
+
+func TestGazetteerGeoService_Admin1Names(t *testing.T) {
+	svc, err := LoadGazetteer("testdata/gazetteer.txt")
+	require.NoError(t, err)
+	require.NoError(t, svc.LoadAdmin1Names("testdata/admin1CodesASCII.txt"))
+
+	// Should allow qualifying by region name
+	lat, _, err := svc.Geocode(context.Background(), "Springfield, Illinois")
+	require.NoError(t, err)
+	require.Equal(t, 39.80172, lat)
+
+	// Should use region names for reverse geocoding
+	placeName, err := svc.ReverseGeocode(context.Background(), 44.97, -93.26)
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, Minnesota, US", placeName)
+}
+
+func TestGazetteerGeoService_CSV(t *testing.T) {
+	svc, err := LoadGazetteer("testdata/gazetteer.csv")
+	require.NoError(t, err)
+
+	lat, lon, err := svc.Geocode(context.Background(), "Mill City")
+	require.NoError(t, err)
+	require.Equal(t, 44.97997, lat)
+	require.Equal(t, -93.26384, lon)
+
+	lat, _, err = svc.Geocode(context.Background(), "Springfield, Illinois")
+	require.NoError(t, err)
+	require.Equal(t, 39.80172, lat)
+}
+
+func TestNormalizePlaceName(t *testing.T) {
+	require.Equal(t, "saint paul", normalizePlaceName("St. Paul"))
+	require.Equal(t, "zurich", normalizePlaceName("Zürich"))
+	require.Equal(t, "coeur dalene", normalizePlaceName("Coeur d'Alene"))
+	require.Equal(t, "winston salem", normalizePlaceName("Winston-Salem"))
+}
+
+func TestDistanceMeters(t *testing.T) {
+	// Minneapolis to St. Paul is ~14km
+	distance := DistanceMeters(44.97997, -93.26384, 44.94441, -93.09327)
+	require.InDelta(t, 14e3, distance, 500)
+	require.Equal(t, 0.0, DistanceMeters(10, 10, 10, 10))
+}
+
+func TestGazetteerGeoService_Suggest(t *testing.T) {
+	svc, err := LoadGazetteer("testdata/gazetteer.txt")
+	require.NoError(t, err)
+
+	names := func(suggestions []Suggestion) []string {
+		var names []string
+		for _, suggestion := range suggestions {
+			names = append(names, suggestion.Name)
+		}
+		return names
+	}
+
+	// Should match name prefixes, ranked by population
+	suggestions, err := svc.Suggest(context.Background(), "spring", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Springfield, MO, US", "Springfield, MA, US", "Springfield, IL, US"}, names(suggestions))
+	require.Equal(t, Suggestion{Name: "Springfield, MO, US", Lat: 37.21533, Lon: -93.29824, Type: "city"}, suggestions[0])
+
+	// Should prefer places near the location
+	suggestions, err = svc.Suggest(context.Background(), "Spring", &Point{Lat: 39.8, Lon: -89.6})
+	require.NoError(t, err)
+	require.Equal(t, "Springfield, IL, US", suggestions[0].Name)
+
+	// Should match alternate names and qualifiers
+	suggestions, err = svc.Suggest(context.Background(), "St Pa", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Saint Paul, MN, US"}, names(suggestions))
+	suggestions, err = svc.Suggest(context.Background(), "Springfield, MA", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Springfield, MA, US"}, names(suggestions))
+
+	// Qualifiers may be partially typed
+	suggestions, err = svc.Suggest(context.Background(), "Springfield, I", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Springfield, IL, US"}, names(suggestions))
+	require.NoError(t, svc.LoadAdmin1Names("testdata/admin1CodesASCII.txt"))
+	suggestions, err = svc.Suggest(context.Background(), "Springfield, Miss", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Springfield, Missouri, US"}, names(suggestions))
+
+	// Should return an empty list, if nothing matches
+	for _, query := range []string{"Atlantis", "", " , "} {
+		suggestions, err = svc.Suggest(context.Background(), query, nil)
+		require.NoError(t, err)
+		require.NotNil(t, suggestions)
+		require.Empty(t, suggestions)
+	}
+}
+
+func TestGazetteerGeoService_LargeGazetteer(t *testing.T) {
+	// Many similar names, as in a full GeoNames file
+	var entries []*GazetteerEntry
+	for i := 0; i < 100000; i++ {
+		entries = append(entries, &GazetteerEntry{Name: fmt.Sprintf("Town %d", i), Population: int64(i)})
+	}
+	entries = append(entries, &GazetteerEntry{Name: "Minneapolis", Lat: 44.97997, Lon: -93.26384, CountryCode: "US", Admin1Code: "MN"})
+	svc := NewGazetteerGeoService(entries)
+
+	// Fuzzy matching only compares names which share trigrams with the query
+	require.Equal(t, []string{"minneapolis"}, svc.fuzzyCandidates("minneaplis", 2))
+	lat, _, err := svc.Geocode(context.Background(), "Minneaplis")
+	require.NoError(t, err)
+	require.Equal(t, 44.97997, lat)
+	lat, _, err = svc.Geocode(context.Background(), "Town 12345")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, lat)
+
+	suggestions, err := svc.Suggest(context.Background(), "Town 9999", nil)
+	require.NoError(t, err)
+	require.Equal(t, "Town 99999", suggestions[0].Name)
+	suggestions, err = svc.Suggest(context.Background(), "Minn, U", nil)
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, MN, US", suggestions[0].Name)
+}
@@ -1,6 +1,49 @@
 package geo
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrPlaceNotFound is returned when a place name cannot be geocoded,
+// or when there is no place at a location
+var ErrPlaceNotFound = errors.New("place not found")
+
+// Maximum number of suggestions returned by Suggest
+const defaultSuggestLimit = 5
+
 type GeoService interface {
 	// Returns lat/lon values, and an error
-	Geocode(place string) (float64, float64, error)
+	Geocode(ctx context.Context, place string) (float64, float64, error)
+	// Returns a human-readable place name (eg. an address or locality) for a location
+	ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error)
+	// Returns candidate places for a partial place name (eg. as a user types), best match first.
+	// If near is not nil, places near that location are preferred.
+	// Returns an empty slice if there are no matches.
+	Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error)
+}
+
+// Point is a lat/lon location
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// BoundingBox is a rectangular area, as [min lon, min lat, max lon, max lat]
+type BoundingBox [4]float64
+
+func (bbox BoundingBox) MinLon() float64 { return bbox[0] }
+func (bbox BoundingBox) MinLat() float64 { return bbox[1] }
+func (bbox BoundingBox) MaxLon() float64 { return bbox[2] }
+func (bbox BoundingBox) MaxLat() float64 { return bbox[3] }
+
+// Suggestion is a candidate place, matching a partial place name
+type Suggestion struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	// Extent of the place, if known by the provider
+	BBox *BoundingBox `json:"bbox,omitempty"`
+	// Kind of place, eg. "city", "address" or "poi"
+	Type string `json:"type,omitempty"`
 }
@@ -1,60 +1,139 @@
 package geo
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 )
 
+const (
+	defaultMapboxBaseURL = "https://api.mapbox.com"
+	// Mapbox allows 600 geocoding requests per minute, by default
+	// See https://docs.mapbox.com/api/search/geocoding/#geocoding-api-errors
+	defaultMapboxRateLimit = 10
+)
+
+// MapboxGeoService geocodes using the Mapbox Geocoding API.
+// See https://docs.mapbox.com/api/search/geocoding/
+//
+// Requests are rate limited client-side, and retried if rate limited or unavailable.
+// Errors may be checked for ErrUnauthorized, ErrRateLimited or ErrProviderUnavailable.
 type MapboxGeoService struct {
-	http              *http.Client
+	providerConfig
 	mapboxAccessToken string
 }
 
-func (svc *MapboxGeoService) Geocode(place string) (float64, float64, error) {
+func NewMapboxGeoService(accessToken string, opts ...ProviderOption) *MapboxGeoService {
+	// Stay within the Mapbox rate limit, unless overridden by opts
+	opts = append([]ProviderOption{WithRateLimit(defaultMapboxRateLimit, defaultMapboxRateLimit)}, opts...)
+
+	return &MapboxGeoService{
+		providerConfig:    newProviderConfig(defaultMapboxBaseURL, opts),
+		mapboxAccessToken: accessToken,
+	}
+}
+
+func (svc *MapboxGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
 	// Call mapbox API to geocode the place name
 	// into lat/lon coordinates
-	placeNameEncoded := url.PathEscape(place)
-	req, err := http.NewRequest(
-		"GET",
-		fmt.Sprintf("https://api.mapbox.com/geocoding/v5/mapbox.places/%s.json", placeNameEncoded),
-		nil,
-	)
+	geocodeResp, err := svc.query(ctx, url.PathEscape(place), nil)
 	if err != nil {
-		return 0.0, 0.0, fmt.Errorf("failed to prepare Mapbox request: %w", err)
+		return 0, 0, err
 	}
 
-	// Add the Mapbox access token
-	q := req.URL.Query()
-	q.Add("access_token", svc.mapboxAccessToken)
-	req.URL.RawQuery = q.Encode()
+	// Place not found
+	if len(geocodeResp.Features) == 0 {
+		return 0, 0, fmt.Errorf("no location found at %s: %w", place, ErrPlaceNotFound)
+	}
+	// Expect feature to have a center
+	if len(geocodeResp.Features[0].Center) != 2 {
+		return 0, 0, errors.New("mapbox geocode response has invalid center")
+	}
 
-	// Execute request
-	resp, err := svc.http.Do(req)
+	return geocodeResp.Features[0].Center[1], geocodeResp.Features[0].Center[0], nil
+}
+
+func (svc *MapboxGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	// Mapbox expects coordinates as {lon},{lat}
+	coords := strconv.FormatFloat(lon, 'f', -1, 64) + "," + strconv.FormatFloat(lat, 'f', -1, 64)
+	geocodeResp, err := svc.query(ctx, coords, nil)
 	if err != nil {
-		return 0.0, 0.0, fmt.Errorf("request to Mapbox Geocode service failed: %w", err)
+		return "", err
 	}
 
-	// TODO check status code in response
+	// No place at this location (eg. the middle of the ocean)
+	if len(geocodeResp.Features) == 0 {
+		return "", fmt.Errorf("no place found at %s: %w", coords, ErrPlaceNotFound)
+	}
 
-	var geocodeResp MapboxGeocodeResponse
-	err = json.NewDecoder(resp.Body).Decode(&geocodeResp)
+	return geocodeResp.Features[0].PlaceName, nil
+}
+
+func (svc *MapboxGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	params := url.Values{
+		"autocomplete": {"true"},
+		"limit":        {strconv.Itoa(defaultSuggestLimit)},
+	}
+	// Bias results towards places near the user
+	if near != nil {
+		params.Set("proximity", strconv.FormatFloat(near.Lon, 'f', -1, 64)+","+strconv.FormatFloat(near.Lat, 'f', -1, 64))
+	}
+	geocodeResp, err := svc.query(ctx, url.PathEscape(query), params)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to decode mapbox geocode response: %w", err)
+		return nil, err
 	}
 
-	// Place not found
-	if len(geocodeResp.Features) == 0 {
-		return 0, 0, fmt.Errorf("no location found at %s", place)
+	// Mapbox returns features ordered by relevance
+	suggestions := []Suggestion{}
+	for _, feature := range geocodeResp.Features {
+		if len(feature.Center) != 2 {
+			continue
+		}
+		suggestion := Suggestion{
+			Name: feature.PlaceName,
+			Lat:  feature.Center[1],
+			Lon:  feature.Center[0],
+		}
+		if len(feature.BBox) == 4 {
+			suggestion.BBox = &BoundingBox{feature.BBox[0], feature.BBox[1], feature.BBox[2], feature.BBox[3]}
+		}
+		if len(feature.PlaceType) > 0 {
+			suggestion.Type = feature.PlaceType[0]
+		}
+		suggestions = append(suggestions, suggestion)
 	}
-	// Expect feature to have a center
-	if len(geocodeResp.Features[0].Center) != 2 {
-		return 0, 0, errors.New("mapbox geocode response has invalid center")
+
+	return suggestions, nil
+}
+
+// query calls the Mapbox geocoding API, with a place name or "{lon},{lat}" search text,
+// and any additional query params
+func (svc *MapboxGeoService) query(ctx context.Context, searchText string, params url.Values) (*MapboxGeocodeResponse, error) {
+	req, err := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s/geocoding/v5/mapbox.places/%s.json", svc.baseURL, searchText),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare Mapbox request: %w", err)
 	}
 
-	return geocodeResp.Features[0].Center[1], geocodeResp.Features[0].Center[0], nil
+	// Add the Mapbox access token
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("access_token", svc.mapboxAccessToken)
+	req.URL.RawQuery = params.Encode()
+
+	var geocodeResp MapboxGeocodeResponse
+	if err := svc.getJSON(ctx, "Mapbox", req, &geocodeResp); err != nil {
+		return nil, err
+	}
+
+	return &geocodeResp, nil
 }
 
 type MapboxGeocodeResponse struct {
@@ -62,5 +141,10 @@ type MapboxGeocodeResponse struct {
 }
 
 type MapboxGeocodeResponseFeature struct {
-	Center []float64 `json:"center"`
+	Center    []float64 `json:"center"`
+	PlaceName string    `json:"place_name"`
+	// [min lon, min lat, max lon, max lat]. Not set for addresses and POIs.
+	BBox []float64 `json:"bbox"`
+	// eg. ["place"], ["address"] or ["poi"]
+	PlaceType []string `json:"place_type"`
 }
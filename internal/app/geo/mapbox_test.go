@@ -1,10 +1,13 @@
 package geo
 
 import (
+	"context"
 	"github.com/stretchr/testify/require"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestMapboxGeoService(t *testing.T) {
@@ -13,13 +16,265 @@ func TestMapboxGeoService(t *testing.T) {
 		t.Skip("Skipping MapboxGeoService live integration test. Missing MAPBOX_ACCESS_TOKEN")
 	}
 
-	svc := &MapboxGeoService{
-		http:              &http.Client{},
-		mapboxAccessToken: mapboxToken,
-	}
+	svc := NewMapboxGeoService(mapboxToken)
 
-	lat, lon, err := svc.Geocode("Minneapolis")
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis")
 	require.NoError(t, err)
 	require.NotEqual(t, 0.0, lat)
 	require.NotEqual(t, 0.0, lon)
+
+	placeName, err := svc.ReverseGeocode(context.Background(), lat, lon)
+	require.NoError(t, err)
+	require.NotEmpty(t, placeName)
+}
+
+func TestMapboxGeoService_Geocode(t *testing.T) {
+	svc, requests := newMockMapbox(t, `{
+		"features": [
+			{"center": [-93.2650, 44.9778], "place_name": "Minneapolis, Minnesota, United States"}
+		]
+	}`)
+
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis, MN")
+	require.NoError(t, err)
+	require.Equal(t, 44.9778, lat)
+	require.Equal(t, -93.2650, lon)
+
+	// Should send the place name and access token to Mapbox
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/geocoding/v5/mapbox.places/Minneapolis, MN.json", (*requests)[0].URL.Path)
+	require.Equal(t, "test-token", (*requests)[0].URL.Query().Get("access_token"))
+}
+
+func TestMapboxGeoService_GeocodeNotFound(t *testing.T) {
+	svc, _ := newMockMapbox(t, `{"features": []}`)
+
+	_, _, err := svc.Geocode(context.Background(), "Atlantis")
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+}
+
+func TestMapboxGeoService_ReverseGeocode(t *testing.T) {
+	svc, requests := newMockMapbox(t, `{
+		"features": [
+			{"center": [-93.2650, 44.9778], "place_name": "Minneapolis, Minnesota, United States"}
+		]
+	}`)
+
+	placeName, err := svc.ReverseGeocode(context.Background(), 44.9778, -93.265)
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, Minnesota, United States", placeName)
+
+	// Mapbox expects {lon},{lat}
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/geocoding/v5/mapbox.places/-93.265,44.9778.json", (*requests)[0].URL.Path)
+}
+
+func TestMapboxGeoService_ReverseGeocodeNotFound(t *testing.T) {
+	svc, _ := newMockMapbox(t, `{"features": []}`)
+
+	_, err := svc.ReverseGeocode(context.Background(), 0, 0)
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+}
+
+// newMockMapbox creates a MapboxGeoService which calls a test server.
+// The test server responds to all requests with the given body.
+func newMockMapbox(t *testing.T, body string) (*MapboxGeoService, *[]*http.Request) {
+	server, requests := newMockProviderServer(t, http.StatusOK, body)
+	svc := NewMapboxGeoService("test-token", WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+	return svc, requests
+}
+
+func TestMapboxGeoService_Suggest(t *testing.T) {
+	svc, requests := newMockMapbox(t, `{
+		"features": [
+			{
+				"center": [-93.2650, 44.9778],
+				"place_name": "Minneapolis, Minnesota, United States",
+				"place_type": ["place"],
+				"bbox": [-93.3293, 44.8905, -93.1936, 45.0512]
+			},
+			{
+				"center": [-93.2, 44.9],
+				"place_name": "Minnehaha Falls, Minneapolis, Minnesota, United States",
+				"place_type": ["poi"]
+			}
+		]
+	}`)
+
+	suggestions, err := svc.Suggest(context.Background(), "Minne", &Point{Lat: 44.95, Lon: -93.1})
+	require.NoError(t, err)
+	require.Equal(t, []Suggestion{
+		{
+			Name: "Minneapolis, Minnesota, United States",
+			Lat:  44.9778,
+			Lon:  -93.2650,
+			BBox: &BoundingBox{-93.3293, 44.8905, -93.1936, 45.0512},
+			Type: "place",
+		},
+		{
+			Name: "Minnehaha Falls, Minneapolis, Minnesota, United States",
+			Lat:  44.9,
+			Lon:  -93.2,
+			Type: "poi",
+		},
+	}, suggestions)
+
+	// Should request autocomplete results, biased towards the location
+	require.Len(t, *requests, 1)
+	query := (*requests)[0].URL.Query()
+	require.Equal(t, "/geocoding/v5/mapbox.places/Minne.json", (*requests)[0].URL.Path)
+	require.Equal(t, "true", query.Get("autocomplete"))
+	require.Equal(t, "-93.1,44.95", query.Get("proximity"))
+	require.Equal(t, "test-token", query.Get("access_token"))
+}
+
+func TestMapboxGeoService_SuggestNoResults(t *testing.T) {
+	svc, requests := newMockMapbox(t, `{"features": []}`)
+
+	suggestions, err := svc.Suggest(context.Background(), "Atlantis", nil)
+	require.NoError(t, err)
+	require.NotNil(t, suggestions)
+	require.Empty(t, suggestions)
+
+	// No proximity bias, without a location
+	require.False(t, (*requests)[0].URL.Query().Has("proximity"))
+}
+
+func TestMapboxGeoService_Unauthorized(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusUnauthorized, `{"message": "Not Authorized - Invalid Token"}`)
+	svc := NewMapboxGeoService("bad-token", WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.ErrorIs(t, err, ErrUnauthorized)
+	require.EqualError(t, err, "request to Mapbox failed with status 401: Not Authorized - Invalid Token")
+
+	// Should not retry auth failures
+	require.Len(t, *requests, 1)
+}
+
+func TestMapboxGeoService_RetryRateLimited(t *testing.T) {
+	server, requests := newSequenceServer(t, []mockResponse{
+		{status: http.StatusTooManyRequests, body: `{"message": "Too Many Requests"}`, retryAfter: "2"},
+		{status: http.StatusOK, body: `{"features": [{"center": [-93.2650, 44.9778]}]}`},
+	})
+	svc := NewMapboxGeoService("test-token", WithBaseURL(server.URL), WithHTTPClient(server.Client()),
+		WithRetries(2, time.Millisecond, 5*time.Second))
+	sleeps := recordSleeps(&svc.providerConfig)
+
+	lat, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.9778, lat)
+	require.Len(t, *requests, 2)
+
+	// Should wait for the Retry-After duration
+	require.Equal(t, []time.Duration{2 * time.Second}, *sleeps)
+}
+
+func TestMapboxGeoService_RetryAfterTooLong(t *testing.T) {
+	server, requests := newSequenceServer(t, []mockResponse{
+		{status: http.StatusTooManyRequests, retryAfter: "3600"},
+	})
+	svc := NewMapboxGeoService("test-token", WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	// Should give up immediately, rather than waiting an hour
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.ErrorIs(t, err, ErrRateLimited)
+	var providerErr *ProviderError
+	require.ErrorAs(t, err, &providerErr)
+	require.Equal(t, time.Hour, providerErr.RetryAfter)
+	require.Len(t, *requests, 1)
+}
+
+func TestMapboxGeoService_RetryServerError(t *testing.T) {
+	server, requests := newSequenceServer(t, []mockResponse{
+		{status: http.StatusInternalServerError},
+		{status: http.StatusBadGateway},
+		{status: http.StatusOK, body: `{"features": [{"center": [-93.2650, 44.9778]}]}`},
+	})
+	svc := NewMapboxGeoService("test-token", WithBaseURL(server.URL), WithHTTPClient(server.Client()),
+		WithRetries(2, 100*time.Millisecond, time.Second))
+	sleeps := recordSleeps(&svc.providerConfig)
+
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Len(t, *requests, 3)
+
+	// Should backoff exponentially, with jitter
+	require.Len(t, *sleeps, 2)
+	require.LessOrEqual(t, (*sleeps)[0], 100*time.Millisecond)
+	require.LessOrEqual(t, (*sleeps)[1], 200*time.Millisecond)
+}
+
+func TestMapboxGeoService_RetriesExhausted(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusServiceUnavailable, ``)
+	svc := NewMapboxGeoService("test-token", WithBaseURL(server.URL), WithHTTPClient(server.Client()),
+		WithRetries(2, time.Millisecond, time.Millisecond))
+
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.ErrorIs(t, err, ErrProviderUnavailable)
+	require.Len(t, *requests, 3)
+}
+
+func TestMapboxGeoService_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	svc := NewMapboxGeoService("test-token", WithBaseURL(server.URL), WithHTTPClient(server.Client()),
+		WithTimeout(10*time.Millisecond), WithRetries(0, 0, 0))
+
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMapboxGeoService_RateLimit(t *testing.T) {
+	svc, requests := newMockMapbox(t, `{"features": [{"center": [-93.2650, 44.9778]}]}`)
+	// Allow a request every 20ms
+	WithRateLimit(50, 1)(&svc.providerConfig)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+		require.NoError(t, err)
+	}
+	require.Len(t, *requests, 3)
+	require.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+}
+
+type mockResponse struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+// newSequenceServer creates a test server which sends each of the responses in turn,
+// then repeats the last response
+func newSequenceServer(t *testing.T, responses []mockResponse) (*httptest.Server, *[]*http.Request) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[len(responses)-1]
+		if len(requests) < len(responses) {
+			resp = responses[len(requests)]
+		}
+		requests = append(requests, r)
+
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+		_, _ = w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+// recordSleeps replaces the provider's retry sleep, to record delays without waiting
+func recordSleeps(cfg *providerConfig) *[]time.Duration {
+	var sleeps []time.Duration
+	cfg.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return &sleeps
 }
@@ -0,0 +1,177 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultNominatimBaseURL = "https://nominatim.openstreetmap.org"
+
+// NominatimGeoService geocodes using a Nominatim-compatible API
+// See https://nominatim.org/release-docs/latest/api/Overview/
+type NominatimGeoService struct {
+	providerConfig
+}
+
+// NewNominatimGeoService uses the public OpenStreetMap instance, unless WithBaseURL is given.
+// The public instance allows at most one request per second
+// (see https://operations.osmfoundation.org/policies/nominatim/), so requests to it are
+// limited to that rate by default. Use WithUserAgent to identify your deployment.
+func NewNominatimGeoService(opts ...ProviderOption) *NominatimGeoService {
+	svc := &NominatimGeoService{
+		providerConfig: newProviderConfig(defaultNominatimBaseURL, opts),
+	}
+	if svc.limiter == nil && svc.isPublicInstance() {
+		WithRateLimit(1, 1)(&svc.providerConfig)
+	}
+	return svc
+}
+
+// isPublicInstance checks if the service uses the public OpenStreetMap Nominatim instance
+func (svc *NominatimGeoService) isPublicInstance() bool {
+	u, err := url.Parse(svc.baseURL)
+	return err == nil && strings.EqualFold(u.Hostname(), "nominatim.openstreetmap.org")
+}
+
+func (svc *NominatimGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	req, err := http.NewRequest("GET", svc.baseURL+"/search", nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prepare Nominatim request: %w", err)
+	}
+	req.URL.RawQuery = url.Values{
+		"q":      {place},
+		"format": {"jsonv2"},
+		"limit":  {"1"},
+	}.Encode()
+
+	var results []NominatimPlace
+	if err := svc.getJSON(ctx, "Nominatim", req, &results); err != nil {
+		return 0, 0, err
+	}
+
+	// Place not found
+	if len(results) == 0 {
+		return 0, 0, fmt.Errorf("no location found at %s: %w", place, ErrPlaceNotFound)
+	}
+
+	return results[0].latLon()
+}
+
+func (svc *NominatimGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	req, err := http.NewRequest("GET", svc.baseURL+"/reverse", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare Nominatim request: %w", err)
+	}
+	req.URL.RawQuery = url.Values{
+		"lat":    {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon":    {strconv.FormatFloat(lon, 'f', -1, 64)},
+		"format": {"jsonv2"},
+	}.Encode()
+
+	var result NominatimPlace
+	if err := svc.getJSON(ctx, "Nominatim", req, &result); err != nil {
+		return "", err
+	}
+
+	// Nominatim responds with an error message (and a 200), if there is no place here
+	if result.Error != "" || result.DisplayName == "" {
+		return "", fmt.Errorf("no place found at %v,%v: %w", lat, lon, ErrPlaceNotFound)
+	}
+
+	return result.DisplayName, nil
+}
+
+// Suggest fails with ErrUnsupported for the public OpenStreetMap instance, whose usage policy forbids autocomplete.
+// Use a self-hosted instance, or another provider.
+func (svc *NominatimGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	if svc.isPublicInstance() {
+		return nil, fmt.Errorf("autocomplete with the public Nominatim instance: %w", ErrUnsupported)
+	}
+
+	req, err := http.NewRequest("GET", svc.baseURL+"/search", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare Nominatim request: %w", err)
+	}
+	params := url.Values{
+		"q":      {query},
+		"format": {"jsonv2"},
+		"limit":  {strconv.Itoa(defaultSuggestLimit)},
+	}
+	// Nominatim prefers results within the viewbox (but doesn't exclude other results)
+	if near != nil {
+		params.Set("viewbox", fmt.Sprintf("%v,%v,%v,%v",
+			near.Lon-nominatimViewboxDegrees, near.Lat+nominatimViewboxDegrees,
+			near.Lon+nominatimViewboxDegrees, near.Lat-nominatimViewboxDegrees))
+	}
+	req.URL.RawQuery = params.Encode()
+
+	var results []NominatimPlace
+	if err := svc.getJSON(ctx, "Nominatim", req, &results); err != nil {
+		return nil, err
+	}
+
+	suggestions := []Suggestion{}
+	for _, result := range results {
+		lat, lon, err := result.latLon()
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, Suggestion{
+			Name: result.DisplayName,
+			Lat:  lat,
+			Lon:  lon,
+			BBox: result.bbox(),
+			Type: result.AddressType,
+		})
+	}
+
+	return suggestions, nil
+}
+
+// Size of the viewbox used to bias suggestions, in degrees either side of the location (~50km)
+const nominatimViewboxDegrees = 0.5
+
+type NominatimPlace struct {
+	// Nominatim encodes coordinates as strings
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	DisplayName string `json:"display_name"`
+	// eg. "city", "road" or "amenity"
+	AddressType string `json:"addresstype"`
+	// [min lat, max lat, min lon, max lon], as strings
+	BoundingBox []string `json:"boundingbox"`
+	Error       string   `json:"error"`
+}
+
+// bbox returns the place's bounding box, or nil if it is missing or invalid
+func (place *NominatimPlace) bbox() *BoundingBox {
+	if len(place.BoundingBox) != 4 {
+		return nil
+	}
+	var values [4]float64
+	for i, value := range place.BoundingBox {
+		var err error
+		if values[i], err = strconv.ParseFloat(value, 64); err != nil {
+			return nil
+		}
+	}
+
+	return &BoundingBox{values[2], values[0], values[3], values[1]}
+}
+
+func (place *NominatimPlace) latLon() (float64, float64, error) {
+	lat, err := strconv.ParseFloat(place.Lat, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("nominatim response has invalid lat: %w", err)
+	}
+	lon, err := strconv.ParseFloat(place.Lon, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("nominatim response has invalid lon: %w", err)
+	}
+
+	return lat, lon, nil
+}
@@ -0,0 +1,111 @@
+package geo
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestNominatimGeoService_Geocode(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, `[
+		{"lat": "44.9772995", "lon": "-93.2654692", "display_name": "Minneapolis, Hennepin County, Minnesota, United States"}
+	]`)
+	svc := NewNominatimGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.9772995, lat)
+	require.Equal(t, -93.2654692, lon)
+
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/search", (*requests)[0].URL.Path)
+	require.Equal(t, "Minneapolis", (*requests)[0].URL.Query().Get("q"))
+	require.Equal(t, "go-sensor-api", (*requests)[0].Header.Get("User-Agent"))
+}
+
+func TestNominatimGeoService_GeocodeNotFound(t *testing.T) {
+	server, _ := newMockProviderServer(t, http.StatusOK, `[]`)
+	svc := NewNominatimGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	_, _, err := svc.Geocode(context.Background(), "Atlantis")
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+}
+
+func TestNominatimGeoService_Suggest(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, `[
+		{
+			"lat": "44.9772995",
+			"lon": "-93.2654692",
+			"display_name": "Minneapolis, Hennepin County, Minnesota, United States",
+			"addresstype": "city",
+			"boundingbox": ["44.8904", "45.0512", "-93.3292", "-93.1935"]
+		}
+	]`)
+	svc := NewNominatimGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	suggestions, err := svc.Suggest(context.Background(), "Minne", &Point{Lat: 45, Lon: -93})
+	require.NoError(t, err)
+	require.Equal(t, []Suggestion{{
+		Name: "Minneapolis, Hennepin County, Minnesota, United States",
+		Lat:  44.9772995,
+		Lon:  -93.2654692,
+		BBox: &BoundingBox{-93.3292, 44.8904, -93.1935, 45.0512},
+		Type: "city",
+	}}, suggestions)
+
+	// Should bias results towards the location, with a viewbox
+	require.Len(t, *requests, 1)
+	require.Equal(t, "Minne", (*requests)[0].URL.Query().Get("q"))
+	require.Equal(t, "-93.5,45.5,-92.5,44.5", (*requests)[0].URL.Query().Get("viewbox"))
+}
+
+func TestNominatimGeoService_ReverseGeocode(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, `
+		{"lat": "44.9772995", "lon": "-93.2654692", "display_name": "Minneapolis, Hennepin County, Minnesota, United States"}
+	`)
+	svc := NewNominatimGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	placeName, err := svc.ReverseGeocode(context.Background(), 44.9772995, -93.2654692)
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, Hennepin County, Minnesota, United States", placeName)
+
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/reverse", (*requests)[0].URL.Path)
+	require.Equal(t, "44.9772995", (*requests)[0].URL.Query().Get("lat"))
+	require.Equal(t, "-93.2654692", (*requests)[0].URL.Query().Get("lon"))
+}
+
+func TestNominatimGeoService_ReverseGeocodeNotFound(t *testing.T) {
+	server, _ := newMockProviderServer(t, http.StatusOK, `{"error": "Unable to geocode"}`)
+	svc := NewNominatimGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	_, err := svc.ReverseGeocode(context.Background(), 0, 0)
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+}
+
+func TestNominatimGeoService_PublicInstanceRateLimit(t *testing.T) {
+	// The public instance's usage policy allows one request per second
+	svc := NewNominatimGeoService()
+	require.NotNil(t, svc.limiter)
+
+	// Self-hosted instances aren't limited, unless configured
+	svc = NewNominatimGeoService(WithBaseURL("https://nominatim.example.com"))
+	require.Nil(t, svc.limiter)
+}
+
+func TestNominatimGeoService_UserAgent(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, `[]`)
+	svc := NewNominatimGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()), WithUserAgent("acme-sensors (ops@example.com)"))
+
+	_, _, _ = svc.Geocode(context.Background(), "Minneapolis")
+	require.Len(t, *requests, 1)
+	require.Equal(t, "acme-sensors (ops@example.com)", (*requests)[0].Header.Get("User-Agent"))
+}
+
+func TestNominatimGeoService_SuggestPublicInstance(t *testing.T) {
+	// The public instance's usage policy forbids autocomplete
+	svc := NewNominatimGeoService()
+	_, err := svc.Suggest(context.Background(), "Minne", nil)
+	require.ErrorIs(t, err, ErrUnsupported)
+}
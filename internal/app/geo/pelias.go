@@ -0,0 +1,92 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const defaultPeliasBaseURL = "https://api.geocode.earth"
+
+// PeliasGeoService geocodes using a Pelias-compatible API
+// See https://github.com/pelias/documentation
+type PeliasGeoService struct {
+	providerConfig
+	// Optional API key, for hosted Pelias services
+	apiKey string
+}
+
+func NewPeliasGeoService(apiKey string, opts ...ProviderOption) *PeliasGeoService {
+	return &PeliasGeoService{
+		providerConfig: newProviderConfig(defaultPeliasBaseURL, opts),
+		apiKey:         apiKey,
+	}
+}
+
+func (svc *PeliasGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	var resp geoJSONResponse
+	err := svc.query(ctx, "/v1/search", url.Values{
+		"text": {place},
+		"size": {"1"},
+	}, &resp)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return resp.firstPoint("Pelias", place)
+}
+
+func (svc *PeliasGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	var resp geoJSONResponse
+	err := svc.query(ctx, "/v1/reverse", url.Values{
+		"point.lat": {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"point.lon": {strconv.FormatFloat(lon, 'f', -1, 64)},
+		"size":      {"1"},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Features) == 0 {
+		return "", fmt.Errorf("no place found at %v,%v: %w", lat, lon, ErrPlaceNotFound)
+	}
+
+	return resp.Features[0].property("label"), nil
+}
+
+func (svc *PeliasGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	params := url.Values{
+		"text": {query},
+		"size": {strconv.Itoa(defaultSuggestLimit)},
+	}
+	// Prefer results near the focus point
+	if near != nil {
+		params.Set("focus.point.lat", strconv.FormatFloat(near.Lat, 'f', -1, 64))
+		params.Set("focus.point.lon", strconv.FormatFloat(near.Lon, 'f', -1, 64))
+	}
+
+	var resp geoJSONResponse
+	if err := svc.query(ctx, "/v1/autocomplete", params, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.suggestions(
+		func(feature *geoJSONFeature) string { return feature.property("label") },
+		// eg. "locality", "address" or "venue"
+		func(feature *geoJSONFeature) string { return feature.property("layer") },
+	), nil
+}
+
+func (svc *PeliasGeoService) query(ctx context.Context, path string, params url.Values, dest interface{}) error {
+	req, err := http.NewRequest("GET", svc.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to prepare Pelias request: %w", err)
+	}
+	if svc.apiKey != "" {
+		params.Set("api_key", svc.apiKey)
+	}
+	req.URL.RawQuery = params.Encode()
+
+	return svc.getJSON(ctx, "Pelias", req, dest)
+}
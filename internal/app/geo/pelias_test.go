@@ -0,0 +1,89 @@
+package geo
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+const peliasMinneapolisResponse = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [-93.26384, 44.98]},
+			"properties": {"label": "Minneapolis, MN, USA"}
+		}
+	]
+}`
+
+func TestPeliasGeoService_Geocode(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, peliasMinneapolisResponse)
+	svc := NewPeliasGeoService("test-key", WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.98, lat)
+	require.Equal(t, -93.26384, lon)
+
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/v1/search", (*requests)[0].URL.Path)
+	require.Equal(t, "Minneapolis", (*requests)[0].URL.Query().Get("text"))
+	require.Equal(t, "test-key", (*requests)[0].URL.Query().Get("api_key"))
+}
+
+func TestPeliasGeoService_GeocodeNotFound(t *testing.T) {
+	server, _ := newMockProviderServer(t, http.StatusOK, `{"type": "FeatureCollection", "features": []}`)
+	svc := NewPeliasGeoService("", WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	_, _, err := svc.Geocode(context.Background(), "Atlantis")
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+}
+
+func TestPeliasGeoService_ReverseGeocode(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, peliasMinneapolisResponse)
+	svc := NewPeliasGeoService("", WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	placeName, err := svc.ReverseGeocode(context.Background(), 44.98, -93.26384)
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, MN, USA", placeName)
+
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/v1/reverse", (*requests)[0].URL.Path)
+	require.Equal(t, "44.98", (*requests)[0].URL.Query().Get("point.lat"))
+	// No API key, if not configured
+	require.False(t, (*requests)[0].URL.Query().Has("api_key"))
+}
+
+func TestPeliasGeoService_Suggest(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, `{
+		"type": "FeatureCollection",
+		"features": [
+			{
+				"type": "Feature",
+				"geometry": {"type": "Point", "coordinates": [-93.26384, 44.98]},
+				"properties": {"label": "Minneapolis, MN, USA", "layer": "locality"},
+				"bbox": [-93.3292, 44.8904, -93.1935, 45.0512]
+			}
+		]
+	}`)
+	svc := NewPeliasGeoService("", WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	suggestions, err := svc.Suggest(context.Background(), "Minne", &Point{Lat: 45, Lon: -93})
+	require.NoError(t, err)
+	require.Equal(t, []Suggestion{{
+		Name: "Minneapolis, MN, USA",
+		Lat:  44.98,
+		Lon:  -93.26384,
+		BBox: &BoundingBox{-93.3292, 44.8904, -93.1935, 45.0512},
+		Type: "locality",
+	}}, suggestions)
+
+	// Should use the autocomplete endpoint, focused on the location
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/v1/autocomplete", (*requests)[0].URL.Path)
+	require.Equal(t, "Minne", (*requests)[0].URL.Query().Get("text"))
+	require.Equal(t, "45", (*requests)[0].URL.Query().Get("focus.point.lat"))
+	require.Equal(t, "-93", (*requests)[0].URL.Query().Get("focus.point.lon"))
+}
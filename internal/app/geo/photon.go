@@ -0,0 +1,129 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultPhotonBaseURL = "https://photon.komoot.io"
+
+// PhotonGeoService geocodes using a Photon-compatible API
+// See https://github.com/komoot/photon
+type PhotonGeoService struct {
+	providerConfig
+}
+
+func NewPhotonGeoService(opts ...ProviderOption) *PhotonGeoService {
+	return &PhotonGeoService{
+		providerConfig: newProviderConfig(defaultPhotonBaseURL, opts),
+	}
+}
+
+func (svc *PhotonGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	req, err := http.NewRequest("GET", svc.baseURL+"/api", nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prepare Photon request: %w", err)
+	}
+	req.URL.RawQuery = url.Values{
+		"q":     {place},
+		"limit": {"1"},
+	}.Encode()
+
+	var resp geoJSONResponse
+	if err := svc.getJSON(ctx, "Photon", req, &resp); err != nil {
+		return 0, 0, err
+	}
+
+	return resp.firstPoint("Photon", place)
+}
+
+func (svc *PhotonGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	req, err := http.NewRequest("GET", svc.baseURL+"/reverse", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare Photon request: %w", err)
+	}
+	req.URL.RawQuery = url.Values{
+		"lat":   {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon":   {strconv.FormatFloat(lon, 'f', -1, 64)},
+		"limit": {"1"},
+	}.Encode()
+
+	var resp geoJSONResponse
+	if err := svc.getJSON(ctx, "Photon", req, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Features) == 0 {
+		return "", fmt.Errorf("no place found at %v,%v: %w", lat, lon, ErrPlaceNotFound)
+	}
+
+	return photonLabel(&resp.Features[0]), nil
+}
+
+func (svc *PhotonGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	req, err := http.NewRequest("GET", svc.baseURL+"/api", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare Photon request: %w", err)
+	}
+	params := url.Values{
+		"q":     {query},
+		"limit": {strconv.Itoa(defaultSuggestLimit)},
+	}
+	// Photon prefers results near the lat/lon (location bias)
+	if near != nil {
+		params.Set("lat", strconv.FormatFloat(near.Lat, 'f', -1, 64))
+		params.Set("lon", strconv.FormatFloat(near.Lon, 'f', -1, 64))
+	}
+	req.URL.RawQuery = params.Encode()
+
+	var resp geoJSONResponse
+	if err := svc.getJSON(ctx, "Photon", req, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Features {
+		resp.Features[i].BBox = photonExtent(&resp.Features[i])
+	}
+
+	return resp.suggestions(photonLabel, func(feature *geoJSONFeature) string {
+		// Older versions of Photon don't have a "type" property
+		if placeType := feature.property("type"); placeType != "" {
+			return placeType
+		}
+		return feature.property("osm_value")
+	}), nil
+}
+
+// photonExtent converts a Photon feature's "extent" property,
+// [min lon, max lat, max lon, min lat], to a bounding box
+func photonExtent(feature *geoJSONFeature) []float64 {
+	extent, ok := feature.Properties["extent"].([]interface{})
+	if !ok || len(extent) != 4 {
+		return nil
+	}
+	var values [4]float64
+	for i, value := range extent {
+		if values[i], ok = value.(float64); !ok {
+			return nil
+		}
+	}
+
+	return []float64{values[0], values[3], values[2], values[1]}
+}
+
+// photonLabel builds a display name from a Photon feature's address properties,
+// eg. "Minneapolis, Minnesota, United States"
+func photonLabel(feature *geoJSONFeature) string {
+	var parts []string
+	for _, prop := range []string{"name", "street", "city", "state", "country"} {
+		value := feature.property(prop)
+		// Skip empty and duplicate values (eg. a city's name is also its "city")
+		if value != "" && (len(parts) == 0 || parts[len(parts)-1] != value) {
+			parts = append(parts, value)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,90 @@
+package geo
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+const photonMinneapolisResponse = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [-93.2654692, 44.9772995]},
+			"properties": {"name": "Minneapolis", "city": "Minneapolis", "state": "Minnesota", "country": "United States"}
+		}
+	]
+}`
+
+func TestPhotonGeoService_Geocode(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, photonMinneapolisResponse)
+	svc := NewPhotonGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	lat, lon, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.9772995, lat)
+	require.Equal(t, -93.2654692, lon)
+
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/api", (*requests)[0].URL.Path)
+	require.Equal(t, "Minneapolis", (*requests)[0].URL.Query().Get("q"))
+}
+
+func TestPhotonGeoService_GeocodeNotFound(t *testing.T) {
+	server, _ := newMockProviderServer(t, http.StatusOK, `{"type": "FeatureCollection", "features": []}`)
+	svc := NewPhotonGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	_, _, err := svc.Geocode(context.Background(), "Atlantis")
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+}
+
+func TestPhotonGeoService_Suggest(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, `{
+		"type": "FeatureCollection",
+		"features": [
+			{
+				"type": "Feature",
+				"geometry": {"type": "Point", "coordinates": [-93.2654692, 44.9772995]},
+				"properties": {
+					"name": "Minneapolis",
+					"state": "Minnesota",
+					"country": "United States",
+					"osm_value": "city",
+					"extent": [-93.3292, 45.0512, -93.1935, 44.8904]
+				}
+			}
+		]
+	}`)
+	svc := NewPhotonGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	suggestions, err := svc.Suggest(context.Background(), "Minne", &Point{Lat: 45, Lon: -93})
+	require.NoError(t, err)
+	require.Equal(t, []Suggestion{{
+		Name: "Minneapolis, Minnesota, United States",
+		Lat:  44.9772995,
+		Lon:  -93.2654692,
+		BBox: &BoundingBox{-93.3292, 44.8904, -93.1935, 45.0512},
+		Type: "city",
+	}}, suggestions)
+
+	// Should bias results towards the location
+	require.Len(t, *requests, 1)
+	require.Equal(t, "Minne", (*requests)[0].URL.Query().Get("q"))
+	require.Equal(t, "45", (*requests)[0].URL.Query().Get("lat"))
+	require.Equal(t, "-93", (*requests)[0].URL.Query().Get("lon"))
+}
+
+func TestPhotonGeoService_ReverseGeocode(t *testing.T) {
+	server, requests := newMockProviderServer(t, http.StatusOK, photonMinneapolisResponse)
+	svc := NewPhotonGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()))
+
+	placeName, err := svc.ReverseGeocode(context.Background(), 44.9772995, -93.2654692)
+	require.NoError(t, err)
+	// Duplicate name/city should be collapsed
+	require.Equal(t, "Minneapolis, Minnesota, United States", placeName)
+
+	require.Len(t, *requests, 1)
+	require.Equal(t, "/reverse", (*requests)[0].URL.Path)
+}
@@ -0,0 +1,77 @@
+package geo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	_ "github.com/lib/pq"
+)
+
+// PostgresGeocodeCache is a persistent GeocodeCacheStore,
+// backed by the geocode_cache table
+type PostgresGeocodeCache struct {
+	db *sql.DB
+}
+
+func NewPostgresGeocodeCache(dbUrl string) (*PostgresGeocodeCache, error) {
+	db, err := sql.Open("postgres", dbUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostgresGeocodeCache{
+		db: db,
+	}, nil
+}
+
+func (cache *PostgresGeocodeCache) Get(key string) (*GeocodeCacheEntry, error) {
+	var entry GeocodeCacheEntry
+	var suggestions []byte
+	err := cache.db.QueryRow(`
+		SELECT lat, lon, place_name, suggestions, not_found, expires_at
+		FROM geocode_cache
+		WHERE key = $1
+	`, key).Scan(&entry.Lat, &entry.Lon, &entry.PlaceName, &suggestions, &entry.NotFound, &entry.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if suggestions != nil {
+		if err := json.Unmarshal(suggestions, &entry.Suggestions); err != nil {
+			return nil, fmt.Errorf("invalid cached suggestions: %w", err)
+		}
+	}
+
+	return &entry, nil
+}
+
+func (cache *PostgresGeocodeCache) Set(key string, entry *GeocodeCacheEntry) error {
+	var suggestions []byte
+	if entry.Suggestions != nil {
+		var err error
+		suggestions, err = json.Marshal(entry.Suggestions)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := cache.db.Exec(`
+		INSERT INTO geocode_cache (key, lat, lon, place_name, suggestions, not_found, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (key) DO UPDATE
+		SET lat = $2, lon = $3, place_name = $4, suggestions = $5, not_found = $6, expires_at = $7
+	`, key, entry.Lat, entry.Lon, entry.PlaceName, suggestions, entry.NotFound, entry.ExpiresAt)
+	return err
+}
+
+// DeleteExpired removes expired entries from the cache
+func (cache *PostgresGeocodeCache) DeleteExpired() error {
+	_, err := cache.db.Exec(`DELETE FROM geocode_cache WHERE expires_at < now()`)
+	return err
+}
+
+func (cache *PostgresGeocodeCache) Close() error {
+	return cache.db.Close()
+}
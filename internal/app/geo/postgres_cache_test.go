@@ -0,0 +1,64 @@
+package geo
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPostgresGeocodeCache(t *testing.T) {
+	// Skip tests unless the test DB env var is set
+	dbUrl := os.Getenv("TEST_DATABASE_URL")
+	if dbUrl == "" {
+		t.Skip("Skipping database tests")
+	}
+
+	cache, err := NewPostgresGeocodeCache(dbUrl)
+	require.NoError(t, err)
+	defer cache.Close()
+	_, err = cache.db.Exec(`TRUNCATE geocode_cache`)
+	require.NoError(t, err)
+
+	// Missing entries should return nil
+	entry, err := cache.Get("minneapolis")
+	require.NoError(t, err)
+	require.Nil(t, entry)
+
+	// Set, then overwrite an entry
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, cache.Set("minneapolis", &GeocodeCacheEntry{NotFound: true, ExpiresAt: expiresAt}))
+	require.NoError(t, cache.Set("minneapolis", &GeocodeCacheEntry{Lat: 44.97, Lon: -93.26, ExpiresAt: expiresAt}))
+
+	entry, err = cache.Get("minneapolis")
+	require.NoError(t, err)
+	require.Equal(t, 44.97, entry.Lat)
+	require.Equal(t, -93.26, entry.Lon)
+	require.False(t, entry.NotFound)
+	require.True(t, expiresAt.Equal(entry.ExpiresAt))
+}
+
+func TestPostgresGeocodeCache_Suggestions(t *testing.T) {
+	// Skip tests unless the test DB env var is set
+	dbUrl := os.Getenv("TEST_DATABASE_URL")
+	if dbUrl == "" {
+		t.Skip("Skipping database tests")
+	}
+
+	cache, err := NewPostgresGeocodeCache(dbUrl)
+	require.NoError(t, err)
+	defer cache.Close()
+	_, err = cache.db.Exec(`TRUNCATE geocode_cache`)
+	require.NoError(t, err)
+
+	suggestions := []Suggestion{
+		{Name: "Minneapolis, Minnesota", Lat: 44.97, Lon: -93.26, BBox: &BoundingBox{-93.33, 44.89, -93.19, 45.05}, Type: "place"},
+		{Name: "Minnetonka, Minnesota", Lat: 44.92, Lon: -93.46},
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, cache.Set("suggest:minn", &GeocodeCacheEntry{Suggestions: suggestions, ExpiresAt: expiresAt}))
+
+	entry, err := cache.Get("suggest:minn")
+	require.NoError(t, err)
+	require.Equal(t, suggestions, entry.Suggestions)
+}
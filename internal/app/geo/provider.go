@@ -0,0 +1,325 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/ratelimit"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries     = 2
+	defaultRetryBaseDelay = 200 * time.Millisecond
+	defaultRetryMaxDelay  = 5 * time.Second
+	maxErrorMessageBytes  = 4096
+	defaultRequestTimeout = 5 * time.Second
+	defaultUserAgent      = "go-sensor-api"
+)
+
+// providerConfig is configuration shared by HTTP geocoding providers
+type providerConfig struct {
+	http    *http.Client
+	baseURL string
+	// Timeout for each HTTP request
+	timeout time.Duration
+	// Number of times to retry rate limited or failed requests
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	// Optional client-side rate limit
+	limiter *ratelimit.TokenBucket
+	// Identifies the API to the provider
+	userAgent string
+	// Waits between retries. Replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// ProviderOption configures an HTTP geocoding provider
+type ProviderOption func(*providerConfig)
+
+// WithBaseURL overrides the provider's API URL,
+// eg. to use a self-hosted instance or a test server
+func WithBaseURL(baseURL string) ProviderOption {
+	return func(cfg *providerConfig) {
+		cfg.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to call the provider's API
+func WithHTTPClient(client *http.Client) ProviderOption {
+	return func(cfg *providerConfig) {
+		cfg.http = client
+	}
+}
+
+// WithTimeout sets the timeout for each request to the provider. Defaults to 5s.
+func WithTimeout(timeout time.Duration) ProviderOption {
+	return func(cfg *providerConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithRetries sets the number of times to retry requests which were rate limited,
+// or failed with a server or network error. Defaults to 2.
+//
+// Retries use exponential backoff with jitter, starting from baseDelay and capped at maxDelay.
+// If the provider sends a Retry-After header, we wait at least that long.
+func WithRetries(maxRetries int, baseDelay time.Duration, maxDelay time.Duration) ProviderOption {
+	return func(cfg *providerConfig) {
+		cfg.maxRetries = maxRetries
+		cfg.retryBaseDelay = baseDelay
+		cfg.retryMaxDelay = maxDelay
+	}
+}
+
+// WithRateLimit limits requests to the provider, using a token bucket.
+// Requests over the limit wait for capacity, rather than failing.
+func WithRateLimit(requestsPerSecond float64, burst int) ProviderOption {
+	return func(cfg *providerConfig) {
+		cfg.limiter = ratelimit.NewTokenBucket(requestsPerSecond, burst)
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent to the provider. Defaults to "go-sensor-api".
+// Some providers (eg. the public Nominatim instance) require a User-Agent identifying the application.
+func WithUserAgent(userAgent string) ProviderOption {
+	return func(cfg *providerConfig) {
+		cfg.userAgent = userAgent
+	}
+}
+
+func newProviderConfig(defaultBaseURL string, opts []ProviderOption) providerConfig {
+	cfg := providerConfig{
+		http:           &http.Client{},
+		baseURL:        defaultBaseURL,
+		timeout:        defaultRequestTimeout,
+		maxRetries:     defaultMaxRetries,
+		retryBaseDelay: defaultRetryBaseDelay,
+		retryMaxDelay:  defaultRetryMaxDelay,
+		userAgent:      defaultUserAgent,
+		sleep:          sleepContext,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// getJSON sends a GET request to the provider, and decodes the JSON response into dest.
+// Failed requests are retried, according to the provider's retry options.
+func (cfg *providerConfig) getJSON(ctx context.Context, provider string, req *http.Request, dest interface{}) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = cfg.tryGetJSON(ctx, provider, req, dest)
+		if err == nil || attempt >= cfg.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		// Only retry errors which might be temporary
+		var providerErr *ProviderError
+		var retryAfter time.Duration
+		if errors.As(err, &providerErr) {
+			if !providerErr.retryable() {
+				return err
+			}
+			// Give up, if the provider wants us to wait longer than we're willing to
+			if providerErr.RetryAfter > cfg.retryMaxDelay {
+				return err
+			}
+			retryAfter = providerErr.RetryAfter
+		} else if errors.Is(err, errDecodeResponse) {
+			return err
+		}
+
+		if sleepErr := cfg.sleep(ctx, cfg.retryDelay(attempt, retryAfter)); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// errDecodeResponse wraps errors decoding the provider's response,
+// which will not be fixed by retrying
+var errDecodeResponse = errors.New("failed to decode response")
+
+func (cfg *providerConfig) tryGetJSON(ctx context.Context, provider string, req *http.Request, dest interface{}) error {
+	if cfg.limiter != nil {
+		if err := cfg.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("request to %s failed: %w", provider, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+	req = req.Clone(ctx)
+	req.Header.Set("Accept", "application/json")
+	// Some providers (eg. Nominatim) require an identifying user agent
+	req.Header.Set("User-Agent", cfg.userAgent)
+
+	resp, err := cfg.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", provider, redactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newProviderError(provider, resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("%w from %s: %s", errDecodeResponse, provider, err)
+	}
+
+	return nil
+}
+
+// redactURLError removes the query string from the URL of a failed request,
+// as it may contain credentials (eg. Mapbox's access_token, or Pelias's api_key)
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := "(invalid URL)"
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		u.RawQuery = ""
+		u.ForceQuery = false
+		redacted = u.Redacted()
+	}
+	return &url.Error{Op: urlErr.Op, URL: redacted, Err: urlErr.Err}
+}
+
+// retryDelay returns how long to wait before the next attempt,
+// using exponential backoff with full jitter
+func (cfg *providerConfig) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	backoff := cfg.retryBaseDelay << attempt
+	if backoff <= 0 || backoff > cfg.retryMaxDelay {
+		backoff = cfg.retryMaxDelay
+	}
+	delay := time.Duration(rand.Int63n(int64(backoff) + 1))
+
+	// Respect the provider's Retry-After, if it asks us to wait longer
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	return delay
+}
+
+func newProviderError(provider string, resp *http.Response) *ProviderError {
+	providerErr := &ProviderError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	// Most providers send a JSON error message, eg. {"message": "Not Authorized - Invalid Token"}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorMessageBytes))
+	var errorBody struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(body, &errorBody) == nil {
+		providerErr.Message = errorBody.Message
+		if providerErr.Message == "" {
+			providerErr.Message = errorBody.Error
+		}
+	}
+
+	return providerErr
+}
+
+// parseRetryAfter parses a Retry-After header, which may be a number of seconds or an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// geoJSONResponse is a GeoJSON FeatureCollection, as returned by Pelias and Photon
+type geoJSONResponse struct {
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Geometry struct {
+		// [lon, lat]
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+	// [min lon, min lat, max lon, max lat], if known
+	BBox []float64 `json:"bbox"`
+}
+
+// firstPoint returns the lat/lon of the first feature in the response
+func (resp *geoJSONResponse) firstPoint(provider string, place string) (float64, float64, error) {
+	if len(resp.Features) == 0 {
+		return 0, 0, fmt.Errorf("no location found at %s: %w", place, ErrPlaceNotFound)
+	}
+	coords := resp.Features[0].Geometry.Coordinates
+	if len(coords) != 2 {
+		return 0, 0, fmt.Errorf("%s response has invalid coordinates", provider)
+	}
+
+	return coords[1], coords[0], nil
+}
+
+// suggestions converts the response's features to suggestions,
+// using label and placeType to describe each feature
+func (resp *geoJSONResponse) suggestions(label func(*geoJSONFeature) string, placeType func(*geoJSONFeature) string) []Suggestion {
+	suggestions := []Suggestion{}
+	for i := range resp.Features {
+		feature := &resp.Features[i]
+		coords := feature.Geometry.Coordinates
+		if len(coords) != 2 {
+			continue
+		}
+		suggestion := Suggestion{
+			Name: label(feature),
+			Lat:  coords[1],
+			Lon:  coords[0],
+			Type: placeType(feature),
+		}
+		if len(feature.BBox) == 4 {
+			suggestion.BBox = &BoundingBox{feature.BBox[0], feature.BBox[1], feature.BBox[2], feature.BBox[3]}
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions
+}
+
+// property returns a string property of the feature, or an empty string
+func (feature *geoJSONFeature) property(name string) string {
+	value, _ := feature.Properties[name].(string)
+	return value
+}
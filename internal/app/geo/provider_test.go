@@ -0,0 +1,56 @@
+package geo
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProvider_ErrorStatus(t *testing.T) {
+	server, _ := newMockProviderServer(t, http.StatusServiceUnavailable, `{}`)
+	svc := NewNominatimGeoService(WithBaseURL(server.URL), WithHTTPClient(server.Client()), WithRetries(0, 0, 0))
+
+	_, _, err := svc.Geocode(context.Background(), "Minneapolis")
+	require.EqualError(t, err, "request to Nominatim failed with status 503")
+	require.ErrorIs(t, err, ErrProviderUnavailable)
+	require.NotErrorIs(t, err, ErrPlaceNotFound)
+}
+
+func TestProvider_NetworkErrorRedactsCredentials(t *testing.T) {
+	// Nothing is listening at the server's URL, once it is closed
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	for _, svc := range []GeoService{
+		NewMapboxGeoService("pk.SECRET", WithBaseURL(server.URL), WithRetries(0, 0, 0)),
+		NewPeliasGeoService("pk.SECRET", WithBaseURL(server.URL), WithRetries(0, 0, 0)),
+	} {
+		_, _, err := svc.Geocode(context.Background(), "London")
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), "SECRET")
+		require.True(t, strings.HasPrefix(err.Error(), "request to "), err.Error())
+
+		_, err = svc.ReverseGeocode(context.Background(), 51.5, -0.12)
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), "SECRET")
+	}
+}
+
+// newMockProviderServer creates a test server standing in for a geocoding provider.
+// The server responds to all requests with the given status and body,
+// and records the requests it receives.
+func newMockProviderServer(t *testing.T, status int, body string) (*httptest.Server, *[]*http.Request) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
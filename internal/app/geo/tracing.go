@@ -0,0 +1,60 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+)
+
+// NewTracedGeoService wraps a GeoService, recording a span for each call.
+// name identifies the service in span names, eg. "mapbox" for a provider, or "GeoService" for the cached geocoder.
+func NewTracedGeoService(name string, next GeoService) GeoService {
+	return &tracedGeoService{next: next, name: name}
+}
+
+// tracedGeoService is a GeoService decorator, which records a span for each call
+type tracedGeoService struct {
+	next GeoService
+	name string
+}
+
+func (svc *tracedGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	ctx, span := svc.start(ctx, "Geocode")
+	defer span.End()
+	lat, lon, err := svc.next.Geocode(ctx, place)
+	svc.end(span, err)
+	return lat, lon, err
+}
+
+func (svc *tracedGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	ctx, span := svc.start(ctx, "ReverseGeocode")
+	defer span.End()
+	placeName, err := svc.next.ReverseGeocode(ctx, lat, lon)
+	svc.end(span, err)
+	return placeName, err
+}
+
+func (svc *tracedGeoService) Suggest(ctx context.Context, query string, near *Point) ([]Suggestion, error) {
+	ctx, span := svc.start(ctx, "Suggest")
+	defer span.End()
+	suggestions, err := svc.next.Suggest(ctx, query, near)
+	span.SetAttributes(tracing.Int("geocoder.suggestions", len(suggestions)))
+	svc.end(span, err)
+	return suggestions, err
+}
+
+func (svc *tracedGeoService) start(ctx context.Context, method string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, svc.name+"."+method, tracing.SpanKindInternal,
+		tracing.String("geocoder.name", svc.name),
+		tracing.String("code.function", method),
+	)
+}
+
+// end records the outcome of a call. Places which can't be found are not errors.
+func (svc *tracedGeoService) end(span *tracing.Span, err error) {
+	if errors.Is(err, ErrPlaceNotFound) {
+		span.SetAttributes(tracing.Bool("geocoder.found", false))
+		return
+	}
+	span.RecordError(err)
+}
@@ -0,0 +1,34 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing/tracingtest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTracedGeoService(t *testing.T) {
+	recorder := tracingtest.Install(t)
+	mock := &mockGeoService{}
+	svc := NewTracedGeoService("mapbox", mock)
+	ctx := context.Background()
+
+	_, _, err := svc.Geocode(ctx, "Atlantis")
+	require.ErrorIs(t, err, ErrPlaceNotFound)
+	mock.err = errors.New("provider unavailable")
+	_, _, err = svc.Geocode(ctx, "Minneapolis")
+	require.Error(t, err)
+
+	spans := recorder.Spans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "mapbox.Geocode", spans[0].Name)
+	require.Equal(t, "mapbox", tracingtest.Attribute(spans[0], "geocoder.name"))
+
+	// Places which can't be found aren't errors
+	require.Equal(t, tracing.StatusUnset, spans[0].Status)
+	require.Equal(t, false, tracingtest.Attribute(spans[0], "geocoder.found"))
+	require.Equal(t, tracing.StatusError, spans[1].Status)
+	require.Equal(t, "provider unavailable", spans[1].StatusMessage)
+}
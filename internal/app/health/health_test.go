@@ -0,0 +1,42 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	// Ignores its context, so must be cut off by the checker
+	hanging := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	// Every required check passes
+	report := NewChecker(50*time.Millisecond,
+		Check{Name: "database", Run: ok},
+		Check{Name: "geocoder", Run: failing, Optional: true},
+	).Run(context.Background())
+	require.Equal(t, StatusOK, report.Status)
+	require.Equal(t, StatusOK, report.Checks["database"].Status)
+	require.Equal(t, Result{Status: StatusError, LatencyMs: report.Checks["geocoder"].LatencyMs, Error: "connection refused", Optional: true}, report.Checks["geocoder"])
+
+	// Checks run concurrently, with a timeout
+	start := time.Now()
+	report = NewChecker(50*time.Millisecond,
+		Check{Name: "database", Run: hanging},
+		Check{Name: "postgis", Run: hanging},
+		Check{Name: "schema", Run: failing},
+	).Run(context.Background())
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Equal(t, StatusError, report.Status)
+	require.Equal(t, "timed out after 50ms", report.Checks["database"].Error)
+	require.GreaterOrEqual(t, report.Checks["database"].LatencyMs, float64(50))
+	require.Equal(t, "timed out after 50ms", report.Checks["postgis"].Error)
+	require.Equal(t, "connection refused", report.Checks["schema"].Error)
+}
@@ -0,0 +1,70 @@
+// Package logging configures structured (JSON) logging,
+// and carries request IDs through contexts so they appear in every log line for a request.
+// Records logged during a traced span include its trace and span IDs.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"io"
+	"log/slog"
+)
+
+// New creates a logger, writing records in the given format ("json" or "text").
+// The level may be a *slog.LevelVar, to change it while running.
+func New(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format \"%s\": must be \"json\" or \"text\"", format)
+	}
+
+	return slog.New(&contextHandler{handler}), nil
+}
+
+type requestIDContextKey struct{}
+
+// WithRequestID returns a context carrying a request ID.
+// Records logged with the context (eg. using slog.ErrorContext) include the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by the context, or "" if there is none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// contextHandler adds the request ID and trace context from the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	// Link log records to traces
+	if span := tracing.SpanFromContext(ctx); span.IsRecording() {
+		record.AddAttrs(
+			slog.String("trace_id", span.SpanContext().TraceID.String()),
+			slog.String("span_id", span.SpanContext().SpanID.String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func TestLogger_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", slog.LevelInfo)
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	logger.With("component", "store").ErrorContext(ctx, "query failed", "error", "boom")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "ERROR", record["level"])
+	require.Equal(t, "query failed", record["msg"])
+	require.Equal(t, "store", record["component"])
+	require.Equal(t, "boom", record["error"])
+	require.Equal(t, "req-123", record["request_id"])
+
+	// Records without a request ID
+	buf.Reset()
+	logger.Info("started")
+	record = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.NotContains(t, record, "request_id")
+
+	// Records below the level are dropped
+	buf.Reset()
+	logger.Debug("noisy")
+	require.Empty(t, buf.String())
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo)
+	require.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"time"
+)
+
+// GeoMetrics records calls to geocoding providers
+type GeoMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+func NewGeoMetrics(reg *Registry) *GeoMetrics {
+	return &GeoMetrics{
+		requests: reg.NewCounterVec("sensor_api_geocoder_requests_total",
+			"Calls to geocoding providers", "provider", "method", "outcome"),
+		duration: reg.NewHistogramVec("sensor_api_geocoder_duration_seconds",
+			"Latency of calls to geocoding providers", DefaultBuckets, "provider", "method"),
+	}
+}
+
+// Instrument wraps a geocoding provider, so that its calls are counted and timed
+func (m *GeoMetrics) Instrument(provider string, next geo.GeoService) geo.GeoService {
+	return &instrumentedGeoService{next: next, provider: provider, metrics: m}
+}
+
+// instrumentedGeoService is a GeoService decorator, which records every call
+type instrumentedGeoService struct {
+	next     geo.GeoService
+	provider string
+	metrics  *GeoMetrics
+}
+
+func (svc *instrumentedGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	start := time.Now()
+	lat, lon, err := svc.next.Geocode(ctx, place)
+	svc.record("Geocode", start, err)
+	return lat, lon, err
+}
+
+func (svc *instrumentedGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	start := time.Now()
+	placeName, err := svc.next.ReverseGeocode(ctx, lat, lon)
+	svc.record("ReverseGeocode", start, err)
+	return placeName, err
+}
+
+func (svc *instrumentedGeoService) Suggest(ctx context.Context, query string, near *geo.Point) ([]geo.Suggestion, error) {
+	start := time.Now()
+	suggestions, err := svc.next.Suggest(ctx, query, near)
+	svc.record("Suggest", start, err)
+	return suggestions, err
+}
+
+func (svc *instrumentedGeoService) record(method string, start time.Time, err error) {
+	outcome := "ok"
+	if errors.Is(err, geo.ErrPlaceNotFound) {
+		outcome = "not_found"
+	} else if err != nil {
+		outcome = "error"
+	}
+	svc.metrics.requests.Inc(svc.provider, method, outcome)
+	svc.metrics.duration.Observe(time.Since(start).Seconds(), svc.provider, method)
+}
+
+// RegisterGeocodeCache registers a counter of geocoding cache lookups, by result
+func RegisterGeocodeCache(reg *Registry, cache *geo.CachingGeoService) {
+	reg.NewCounterFunc("sensor_api_geocode_cache_lookups_total",
+		"Geocoding cache lookups, by result (hit, persistent_hit, miss or shared)", []string{"result"}, func() []Sample {
+			stats := cache.Stats()
+			return []Sample{
+				{LabelValues: []string{"hit"}, Value: float64(stats.Hits)},
+				{LabelValues: []string{"persistent_hit"}, Value: float64(stats.PersistentHits)},
+				{LabelValues: []string{"miss"}, Value: float64(stats.Misses)},
+				{LabelValues: []string{"shared"}, Value: float64(stats.Shared)},
+			}
+		})
+}
@@ -0,0 +1,304 @@
+// Package metrics collects application metrics,
+// and exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets (in seconds) suitable for request latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds a set of metrics
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	// write writes the metric's HELP, TYPE and samples
+	write(w io.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (reg *Registry) register(m metric) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.metrics = append(reg.metrics, m)
+}
+
+// Write writes every metric, in the Prometheus text exposition format
+func (reg *Registry) Write(w io.Writer) error {
+	reg.mu.Lock()
+	metrics := append([]metric{}, reg.metrics...)
+	reg.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	return buf.Flush()
+}
+
+// Handler serves the metrics, eg. at GET /metrics
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = reg.Write(w)
+	})
+}
+
+// desc describes a metric
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (d *desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.ReplaceAll(d.help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// writeSample writes a single sample line, eg. `name{label="value"} 1`
+func (d *desc) writeSample(w io.Writer, suffix string, labelValues []string, extraLabel string, extraValue string, value float64) {
+	var labels []string
+	for i, name := range d.labelNames {
+		labels = append(labels, name+`="`+escapeLabelValue(labelValues[i])+`"`)
+	}
+	if extraLabel != "" {
+		labels = append(labels, extraLabel+`="`+extraValue+`"`)
+	}
+
+	line := d.name + suffix
+	if len(labels) > 0 {
+		line += "{" + strings.Join(labels, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", line, formatValue(value))
+}
+
+func (d *desc) checkLabels(labelValues []string) {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", d.name, len(d.labelNames), len(labelValues)))
+	}
+}
+
+// CounterVec is a counter, partitioned by labels
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec registers a counter
+func (reg *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{name: name, help: help, typ: "counter", labelNames: labelNames},
+		series: make(map[string]*counterSeries),
+	}
+	reg.register(c)
+	return c
+}
+
+// Inc adds 1 to the counter with the given label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a (non-negative) value to the counter with the given label values
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	c.checkLabels(labelValues)
+	key := seriesKey(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	series, ok := c.series[key]
+	if !ok {
+		series = &counterSeries{labelValues: labelValues}
+		c.series[key] = series
+	}
+	series.value += value
+}
+
+// Value returns the current value of the counter with the given label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if series, ok := c.series[seriesKey(labelValues)]; ok {
+		return series.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range sortedKeys(c.series) {
+		series := c.series[key]
+		c.writeSample(w, "", series.labelValues, "", "", series.value)
+	}
+}
+
+// HistogramVec is a histogram, partitioned by labels
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	// Count of observations in each bucket (not cumulative)
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec registers a histogram, with the given (sorted) bucket upper bounds
+func (reg *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labelNames: labelNames},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	reg.register(h)
+	return h
+}
+
+// Observe records a value in the histogram with the given label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := seriesKey(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+			break
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+// Count returns the number of observations in the histogram with the given label values
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if series, ok := h.series[seriesKey(labelValues)]; ok {
+		return series.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			h.writeSample(w, "_bucket", series.labelValues, "le", formatValue(bound), float64(cumulative))
+		}
+		h.writeSample(w, "_bucket", series.labelValues, "le", "+Inf", float64(series.count))
+		h.writeSample(w, "_sum", series.labelValues, "", "", series.sum)
+		h.writeSample(w, "_count", series.labelValues, "", "", float64(series.count))
+	}
+}
+
+// Sample is a value collected by a function metric
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// funcMetric is a metric whose samples are collected when the metrics are written
+type funcMetric struct {
+	desc
+	collect func() []Sample
+}
+
+// NewGaugeFunc registers a gauge, whose values are returned by collect when the metrics are written
+func (reg *Registry) NewGaugeFunc(name string, help string, labelNames []string, collect func() []Sample) {
+	reg.register(&funcMetric{
+		desc:    desc{name: name, help: help, typ: "gauge", labelNames: labelNames},
+		collect: collect,
+	})
+}
+
+// NewCounterFunc registers a counter, whose values are returned by collect when the metrics are written.
+// Useful for exposing counters maintained elsewhere (eg. sql.DBStats).
+func (reg *Registry) NewCounterFunc(name string, help string, labelNames []string, collect func() []Sample) {
+	reg.register(&funcMetric{
+		desc:    desc{name: name, help: help, typ: "counter", labelNames: labelNames},
+		collect: collect,
+	})
+}
+
+func (m *funcMetric) write(w io.Writer) {
+	samples := m.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return seriesKey(samples[i].LabelValues) < seriesKey(samples[j].LabelValues)
+	})
+
+	m.writeHeader(w)
+	for _, sample := range samples {
+		m.checkLabels(sample.LabelValues)
+		m.writeSample(w, "", sample.LabelValues, "", "", sample.Value)
+	}
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
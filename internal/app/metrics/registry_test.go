@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("requests_total", "Requests", "route", "status")
+	duration := reg.NewHistogramVec("duration_seconds", "Latency", []float64{0.1, 1}, "route")
+	reg.NewGaugeFunc("sensors", "Sensors", []string{"tenant_id"}, func() []Sample {
+		return []Sample{
+			{LabelValues: []string{"globex"}, Value: 2},
+			{LabelValues: []string{"acme"}, Value: 5},
+		}
+	})
+
+	requests.Inc("/sensors/{name}", "200")
+	requests.Inc("/sensors/{name}", "200")
+	requests.Add(3, "/sensors/\"quoted\"", "500")
+	duration.Observe(0.05, "/sensors")
+	duration.Observe(0.5, "/sensors")
+	duration.Observe(5, "/sensors")
+	require.Equal(t, float64(2), requests.Value("/sensors/{name}", "200"))
+	require.Equal(t, uint64(3), duration.Count("/sensors"))
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.Write(&buf))
+	require.Equal(t, `# HELP requests_total Requests
+# TYPE requests_total counter
+requests_total{route="/sensors/\"quoted\"",status="500"} 3
+requests_total{route="/sensors/{name}",status="200"} 2
+# HELP duration_seconds Latency
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/sensors",le="0.1"} 1
+duration_seconds_bucket{route="/sensors",le="1"} 2
+duration_seconds_bucket{route="/sensors",le="+Inf"} 3
+duration_seconds_sum{route="/sensors"} 5.55
+duration_seconds_count{route="/sensors"} 3
+# HELP sensors Sensors
+# TYPE sensors gauge
+sensors{tenant_id="acme"} 5
+sensors{tenant_id="globex"} 2
+`, buf.String())
+
+	// Served over HTTP
+	rr := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Equal(t, buf.String(), rr.Body.String())
+}
+
+func TestCounterVec_WrongLabels(t *testing.T) {
+	counter := NewRegistry().NewCounterVec("requests_total", "Requests", "route")
+	require.Panics(t, func() { counter.Inc() })
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// StoreMetrics records the latency of SensorStore methods
+type StoreMetrics struct {
+	duration *HistogramVec
+}
+
+func NewStoreMetrics(reg *Registry) *StoreMetrics {
+	return &StoreMetrics{
+		duration: reg.NewHistogramVec("sensor_api_store_duration_seconds",
+			"Latency of sensor store methods", DefaultBuckets, "method", "outcome"),
+	}
+}
+
+// Instrument wraps a SensorStore, so that its methods are timed
+func (m *StoreMetrics) Instrument(next store.SensorStore) store.SensorStore {
+	return &instrumentedStore{next: next, metrics: m}
+}
+
+// instrumentedStore is a SensorStore decorator, which records the latency of every method
+type instrumentedStore struct {
+	next    store.SensorStore
+	metrics *StoreMetrics
+}
+
+func (s *instrumentedStore) ForTenant(tenantID string) store.SensorStore {
+	return &instrumentedStore{next: s.next.ForTenant(tenantID), metrics: s.metrics}
+}
+
+func (s *instrumentedStore) Create(ctx context.Context, sensor *store.Sensor) (*store.Sensor, error) {
+	start := time.Now()
+	created, err := s.next.Create(ctx, sensor)
+	s.metrics.record("Create", start, err)
+	return created, err
+}
+
+func (s *instrumentedStore) GetByName(ctx context.Context, name string) (*store.Sensor, error) {
+	start := time.Now()
+	sensor, err := s.next.GetByName(ctx, name)
+	s.metrics.record("GetByName", start, err)
+	return sensor, err
+}
+
+func (s *instrumentedStore) List(ctx context.Context, after string, limit int) ([]*store.Sensor, error) {
+	start := time.Now()
+	sensors, err := s.next.List(ctx, after, limit)
+	s.metrics.record("List", start, err)
+	return sensors, err
+}
+
+func (s *instrumentedStore) UpdateByName(ctx context.Context, name string, sensor *store.Sensor) (*store.Sensor, error) {
+	start := time.Now()
+	updated, err := s.next.UpdateByName(ctx, name, sensor)
+	s.metrics.record("UpdateByName", start, err)
+	return updated, err
+}
+
+func (s *instrumentedStore) DeleteByName(ctx context.Context, name string) (*store.Sensor, error) {
+	start := time.Now()
+	deleted, err := s.next.DeleteByName(ctx, name)
+	s.metrics.record("DeleteByName", start, err)
+	return deleted, err
+}
+
+func (s *instrumentedStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*store.Sensor, error) {
+	start := time.Now()
+	sensors, err := s.next.FindClosest(ctx, lat, lon, radiusMeters)
+	s.metrics.record("FindClosest", start, err)
+	return sensors, err
+}
+
+func (s *instrumentedStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	start := time.Now()
+	count, err := s.next.CountWithinBounds(ctx, minLat, minLon, maxLat, maxLon)
+	s.metrics.record("CountWithinBounds", start, err)
+	return count, err
+}
+
+func (m *StoreMetrics) record(method string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.duration.Observe(time.Since(start).Seconds(), method, outcome)
+}
+
+// How long to cache the number of sensors, which is expensive to count
+const sensorTotalsTTL = time.Minute
+
+// RegisterSensorTotals registers a gauge of the total number of sensors.
+// Tenants aren't labelled, so that the metrics don't reveal which tenants exist.
+// The total is counted at most once per minute, however often the metrics are scraped.
+func RegisterSensorTotals(reg *Registry, counter store.SensorCounter) {
+	var (
+		mu        sync.Mutex
+		total     float64
+		countedAt time.Time
+	)
+	reg.NewGaugeFunc("sensor_api_sensors", "Number of sensors, across every tenant", nil, func() []Sample {
+		mu.Lock()
+		defer mu.Unlock()
+		if !countedAt.IsZero() && time.Since(countedAt) < sensorTotalsTTL {
+			return []Sample{{Value: total}}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		counts, err := counter.CountByTenant(ctx)
+		if err != nil {
+			slog.Error("failed to count sensors for metrics", "error", err)
+			return nil
+		}
+
+		total = 0
+		for _, count := range counts {
+			total += float64(count)
+		}
+		countedAt = time.Now()
+		return []Sample{{Value: total}}
+	})
+}
+
+// RegisterDBStats registers metrics for a database connection pool
+func RegisterDBStats(reg *Registry, stats func() sql.DBStats) {
+	gauge := func(name string, help string, value func(s sql.DBStats) float64) {
+		reg.NewGaugeFunc(name, help, nil, func() []Sample {
+			return []Sample{{Value: value(stats())}}
+		})
+	}
+	counter := func(name string, help string, value func(s sql.DBStats) float64) {
+		reg.NewCounterFunc(name, help, nil, func() []Sample {
+			return []Sample{{Value: value(stats())}}
+		})
+	}
+
+	gauge("sensor_api_db_max_open_connections", "Maximum number of open connections to the database",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("sensor_api_db_open_connections", "Number of established connections, both in use and idle",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("sensor_api_db_in_use_connections", "Number of connections currently in use",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("sensor_api_db_idle_connections", "Number of idle connections",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("sensor_api_db_wait_count_total", "Total number of connections waited for",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("sensor_api_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("sensor_api_db_max_idle_closed_total", "Total number of connections closed due to the idle connection limit",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("sensor_api_db_max_lifetime_closed_total", "Total number of connections closed due to the connection lifetime limit",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+}
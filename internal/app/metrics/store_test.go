@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/geo"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestStoreMetrics(t *testing.T) {
+	reg := NewRegistry()
+	memoryStore := store.NewMemorySensorStore()
+	sensors := NewStoreMetrics(reg).Instrument(memoryStore)
+	RegisterSensorTotals(reg, memoryStore)
+	ctx := context.Background()
+
+	_, err := sensors.ForTenant("acme").Create(ctx, &store.Sensor{Name: "abc123"})
+	require.NoError(t, err)
+	sensor, err := sensors.ForTenant("acme").GetByName(ctx, "abc123")
+	require.NoError(t, err)
+	require.Equal(t, "abc123", sensor.Name)
+	_, err = sensors.FindClosest(ctx, 0, 0, 1000)
+	require.NoError(t, err)
+	// The sensor belongs to acme, so can't be updated in the default tenant
+	_, err = sensors.UpdateByName(ctx, "abc123", &store.Sensor{Name: "abc123"})
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.Write(&buf))
+	require.Contains(t, buf.String(), `sensor_api_store_duration_seconds_count{method="Create",outcome="ok"} 1`)
+	require.Contains(t, buf.String(), `sensor_api_store_duration_seconds_count{method="GetByName",outcome="ok"} 1`)
+	require.Contains(t, buf.String(), `sensor_api_store_duration_seconds_count{method="FindClosest",outcome="ok"} 1`)
+	require.Contains(t, buf.String(), `sensor_api_store_duration_seconds_count{method="UpdateByName",outcome="error"} 1`)
+	require.Contains(t, buf.String(), "sensor_api_sensors 1\n")
+	require.NotContains(t, buf.String(), "acme")
+
+	// The total is cached between scrapes
+	_, err = memoryStore.ForTenant("acme").Create(ctx, &store.Sensor{Name: "def456"})
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, reg.Write(&buf))
+	require.Contains(t, buf.String(), "sensor_api_sensors 1\n")
+}
+
+func TestGeoMetrics(t *testing.T) {
+	reg := NewRegistry()
+	provider := NewGeoMetrics(reg).Instrument("mapbox", &stubGeoService{})
+	cache := geo.NewCachingGeoService(provider, geo.CacheOptions{})
+	RegisterGeocodeCache(reg, cache)
+	ctx := context.Background()
+
+	// Two lookups of the same place should only call the provider once
+	for i := 0; i < 2; i++ {
+		_, _, err := cache.Geocode(ctx, "Minneapolis")
+		require.NoError(t, err)
+	}
+	_, _, err := cache.Geocode(ctx, "Atlantis")
+	require.ErrorIs(t, err, geo.ErrPlaceNotFound)
+	_, err = cache.ReverseGeocode(ctx, 0, 0)
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, reg.Write(&buf))
+	output := buf.String()
+	require.Contains(t, output, `sensor_api_geocoder_requests_total{provider="mapbox",method="Geocode",outcome="ok"} 1`)
+	require.Contains(t, output, `sensor_api_geocoder_requests_total{provider="mapbox",method="Geocode",outcome="not_found"} 1`)
+	require.Contains(t, output, `sensor_api_geocoder_requests_total{provider="mapbox",method="ReverseGeocode",outcome="error"} 1`)
+	require.Contains(t, output, `sensor_api_geocode_cache_lookups_total{result="hit"} 1`)
+	require.Contains(t, output, `sensor_api_geocode_cache_lookups_total{result="miss"} 3`)
+	require.Equal(t, 1, strings.Count(output, "# TYPE sensor_api_geocoder_requests_total counter"))
+}
+
+type stubGeoService struct{}
+
+func (s *stubGeoService) Geocode(ctx context.Context, place string) (float64, float64, error) {
+	if place == "Atlantis" {
+		return 0, 0, geo.ErrPlaceNotFound
+	}
+	return 44.97, -93.26, nil
+}
+
+func (s *stubGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64) (string, error) {
+	return "", errors.New("stubGeoService.ReverseGeocode() failing for tests, on purpose")
+}
+
+func (s *stubGeoService) Suggest(ctx context.Context, query string, near *geo.Point) ([]geo.Suggestion, error) {
+	return []geo.Suggestion{}, nil
+}
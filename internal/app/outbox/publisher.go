@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// EventPublisher delivers outbox events to some downstream system.
+// Events may be delivered more than once, so consumers should de-duplicate using the event ID.
+type EventPublisher interface {
+	Publish(ctx context.Context, event *store.OutboxEvent) error
+}
+
+// WriterPublisher writes events to an io.Writer, as newline-delimited JSON
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// NewStdoutPublisher writes events to stdout, as newline-delimited JSON
+func NewStdoutPublisher() *WriterPublisher {
+	return NewWriterPublisher(os.Stdout)
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, event *store.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %d: %w", event.ID, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+// FilePublisher appends events to a file, as newline-delimited JSON.
+// Each event is synced to disk before Publish returns.
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox file: %w", err)
+	}
+
+	return &FilePublisher{file: file}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, event *store.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %d: %w", event.ID, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
+
+// HTTPPublisher POSTs each event as JSON to a webhook URL.
+// Any non-2xx response is treated as a failed delivery.
+type HTTPPublisher struct {
+	http *http.Client
+	url  string
+}
+
+func NewHTTPPublisher(url string, client *http.Client) *HTTPPublisher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPPublisher{http: client, url: url}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event *store.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %d: %w", event.ID, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to prepare event request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Allows the receiver to de-duplicate redelivered events
+	req.Header.Set("Idempotency-Key", strconv.FormatInt(event.ID, 10))
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish event %d: %w", event.ID, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to publish event %d: unexpected status %d", event.ID, resp.StatusCode)
+	}
+
+	return nil
+}
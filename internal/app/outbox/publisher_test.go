@@ -0,0 +1,91 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testEvent = &store.OutboxEvent{
+	ID:       42,
+	Type:     store.SensorCreatedEvent,
+	SensorID: 7,
+	Sensor: &store.Sensor{
+		ID:   7,
+		Name: "abc123",
+		Lat:  44.916241209323736,
+		Lon:  -93.21112681214602,
+		Tags: []string{"x"},
+	},
+}
+
+func TestWriterPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	err := publisher.Publish(context.Background(), testEvent)
+	require.NoError(t, err)
+
+	// Should write a single JSON line
+	require.True(t, strings.HasSuffix(buf.String(), "\n"))
+	var event store.OutboxEvent
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &event))
+	require.Equal(t, int64(42), event.ID)
+	require.Equal(t, "abc123", event.Sensor.Name)
+}
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	publisher, err := NewFilePublisher(path)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// Publish twice, events should be appended
+	require.NoError(t, publisher.Publish(context.Background(), testEvent))
+	require.NoError(t, publisher.Publish(context.Background(), testEvent))
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2)
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	var received store.OutboxEvent
+	var idempotencyKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	publisher := NewHTTPPublisher(server.URL, server.Client())
+	err := publisher.Publish(context.Background(), testEvent)
+	require.NoError(t, err)
+
+	require.Equal(t, "42", idempotencyKey)
+	require.Equal(t, "abc123", received.Sensor.Name)
+}
+
+func TestHTTPPublisher_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	publisher := NewHTTPPublisher(server.URL, server.Client())
+	err := publisher.Publish(context.Background(), testEvent)
+	require.Error(t, err)
+	require.Equal(t, "failed to publish event 42: unexpected status 502", err.Error())
+}
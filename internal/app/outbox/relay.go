@@ -0,0 +1,153 @@
+package outbox
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultPollInterval  = time.Second
+	defaultBatchSize     = 100
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = 5 * time.Minute
+)
+
+// Relay publishes events from the outbox, with at-least-once semantics.
+//
+// Events are published in the order they were written. If publishing an event fails,
+// later events for the same sensor are held back until it succeeds, so consumers
+// always see changes to a sensor in order. The failed event is retried with exponential backoff,
+// and events for other sensors continue to be published in the meantime.
+//
+// Several relays (eg. one per instance of the API) may run against an outbox.
+// They take turns to publish batches, using the store's outbox lock.
+type Relay struct {
+	store     store.OutboxStore
+	publisher EventPublisher
+	// How long to wait between polls, when the outbox is empty
+	PollInterval time.Duration
+	// Maximum number of events to read from the outbox at once
+	BatchSize int
+	// How long to wait before retrying a sensor's event, after it fails to publish.
+	// The delay doubles after each consecutive failure, up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// Sensors with an event which failed to publish, by sensor ID
+	backoff map[int]*sensorBackoff
+	// Returns the current time. Replaced in tests.
+	now func() time.Time
+}
+
+// sensorBackoff delays retrying a sensor's event, which failed to publish
+type sensorBackoff struct {
+	failures int
+	retryAt  time.Time
+}
+
+func NewRelay(outboxStore store.OutboxStore, publisher EventPublisher) *Relay {
+	return &Relay{
+		store:         outboxStore,
+		publisher:     publisher,
+		PollInterval:  defaultPollInterval,
+		BatchSize:     defaultBatchSize,
+		RetryDelay:    defaultRetryDelay,
+		MaxRetryDelay: defaultMaxRetryDelay,
+		backoff:       make(map[int]*sensorBackoff),
+		now:           time.Now,
+	}
+}
+
+// Run publishes events until the context is cancelled
+func (relay *Relay) Run(ctx context.Context) {
+	for {
+		published, err := relay.PublishPending(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "outbox relay failed to publish events", "error", err)
+		}
+
+		// Keep going while we're making progress on a full batch,
+		// otherwise wait for new events
+		if published < relay.BatchSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(relay.PollInterval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// PublishPending publishes a single batch of pending events,
+// and returns the number of events which were published
+func (relay *Relay) PublishPending(ctx context.Context) (int, error) {
+	unlock, ok, err := relay.store.LockOutbox(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		// Another relay is publishing the outbox
+		return 0, nil
+	}
+	defer unlock()
+
+	// Skip sensors which are waiting to retry a failed event,
+	// so that their held back events don't fill the batch
+	now := relay.now()
+	var waiting []int
+	for sensorID, backoff := range relay.backoff {
+		if now.Before(backoff.retryAt) {
+			waiting = append(waiting, sensorID)
+		}
+	}
+	events, err := relay.store.PendingEvents(ctx, relay.BatchSize, waiting)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sensors with an event which failed to publish.
+	// Later events for these sensors are skipped, to preserve ordering.
+	blocked := make(map[int]bool)
+	var publishedIds []int64
+	for _, event := range events {
+		if blocked[event.SensorID] {
+			continue
+		}
+
+		if err := relay.publisher.Publish(ctx, event); err != nil {
+			slog.ErrorContext(ctx, "outbox relay failed to publish event", "event_id", event.ID, "sensor_id", event.SensorID, "error", err)
+			blocked[event.SensorID] = true
+			relay.recordFailure(event.SensorID, now)
+			continue
+		}
+		delete(relay.backoff, event.SensorID)
+		publishedIds = append(publishedIds, event.ID)
+	}
+
+	// If this fails, the events will be published again on the next run
+	if err := relay.store.MarkPublished(ctx, publishedIds); err != nil {
+		return 0, err
+	}
+
+	return len(publishedIds), nil
+}
+
+// recordFailure delays retrying a sensor's events, with exponential backoff
+func (relay *Relay) recordFailure(sensorID int, now time.Time) {
+	backoff, ok := relay.backoff[sensorID]
+	if !ok {
+		backoff = &sensorBackoff{}
+		relay.backoff[sensorID] = backoff
+	}
+	backoff.failures++
+
+	delay := relay.RetryDelay << (backoff.failures - 1)
+	if delay <= 0 || delay > relay.MaxRetryDelay {
+		delay = relay.MaxRetryDelay
+	}
+	backoff.retryAt = now.Add(delay)
+}
@@ -0,0 +1,215 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/eschwartz/go-sensor-api/internal/app/store"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRelay_PublishPending(t *testing.T) {
+	outbox := &mockOutboxStore{
+		events: []*store.OutboxEvent{
+			{ID: 1, Type: store.SensorCreatedEvent, SensorID: 10},
+			{ID: 2, Type: store.SensorCreatedEvent, SensorID: 20},
+			{ID: 3, Type: store.SensorUpdatedEvent, SensorID: 10},
+		},
+	}
+	publisher := &mockPublisher{}
+	relay := NewRelay(outbox, publisher)
+
+	published, err := relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, published)
+
+	// Should publish all events, in order
+	require.Equal(t, []int64{1, 2, 3}, publisher.publishedIds)
+	require.Equal(t, []int64{1, 2, 3}, outbox.publishedIds)
+
+	// Should have nothing left to publish
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, published)
+}
+
+func TestRelay_PublishPending_PreservesSensorOrder(t *testing.T) {
+	outbox := &mockOutboxStore{
+		events: []*store.OutboxEvent{
+			{ID: 1, Type: store.SensorCreatedEvent, SensorID: 10},
+			{ID: 2, Type: store.SensorCreatedEvent, SensorID: 20},
+			{ID: 3, Type: store.SensorUpdatedEvent, SensorID: 10},
+			{ID: 4, Type: store.SensorUpdatedEvent, SensorID: 20},
+		},
+	}
+	// Fail to publish the first event for sensor 10
+	publisher := &mockPublisher{failIds: map[int64]bool{1: true}}
+	relay := NewRelay(outbox, publisher)
+	clock := time.Now()
+	relay.now = func() time.Time { return clock }
+
+	published, err := relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, published)
+
+	// Should hold back later events for sensor 10,
+	// but continue publishing events for other sensors
+	require.Equal(t, []int64{2, 4}, publisher.publishedIds)
+	require.Equal(t, []int64{2, 4}, outbox.publishedIds)
+
+	// Once the publisher recovers, sensor 10 events should be published in order
+	publisher.failIds = nil
+	clock = clock.Add(relay.RetryDelay)
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, published)
+	require.Equal(t, []int64{2, 4, 1, 3}, publisher.publishedIds)
+}
+
+func TestRelay_PublishPending_FailingSensor(t *testing.T) {
+	// Sensor 10 has a full batch of events, the first of which fails to publish
+	outbox := &mockOutboxStore{
+		events: []*store.OutboxEvent{
+			{ID: 1, Type: store.SensorCreatedEvent, SensorID: 10},
+			{ID: 2, Type: store.SensorUpdatedEvent, SensorID: 10},
+			{ID: 3, Type: store.SensorCreatedEvent, SensorID: 20},
+		},
+	}
+	publisher := &mockPublisher{failIds: map[int64]bool{1: true}}
+	relay := NewRelay(outbox, publisher)
+	relay.BatchSize = 2
+	clock := time.Now()
+	relay.now = func() time.Time { return clock }
+
+	published, err := relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, published)
+
+	// Sensor 10 waits to retry, so it doesn't hold up events for other sensors
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+	require.Equal(t, []int64{3}, publisher.publishedIds)
+
+	// Retries back off exponentially
+	clock = clock.Add(relay.RetryDelay)
+	_, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 3, 1}, publisher.attemptedIds)
+	clock = clock.Add(relay.RetryDelay)
+	_, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 3, 1}, publisher.attemptedIds)
+	clock = clock.Add(relay.RetryDelay)
+	publisher.failIds = nil
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, published)
+	require.Equal(t, []int64{3, 1, 2}, publisher.publishedIds)
+}
+
+func TestRelay_PublishPending_Locked(t *testing.T) {
+	// Another relay is publishing the outbox
+	outbox := &mockOutboxStore{
+		events: []*store.OutboxEvent{{ID: 1, Type: store.SensorCreatedEvent, SensorID: 10}},
+		locked: true,
+	}
+	publisher := &mockPublisher{}
+	relay := NewRelay(outbox, publisher)
+
+	published, err := relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, published)
+	require.Empty(t, publisher.publishedIds)
+
+	// The relay releases the lock after publishing
+	outbox.locked = false
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+	require.False(t, outbox.locked)
+}
+
+func TestRelay_PublishPending_MarkFailure(t *testing.T) {
+	outbox := &mockOutboxStore{
+		events: []*store.OutboxEvent{
+			{ID: 1, Type: store.SensorCreatedEvent, SensorID: 10},
+		},
+		failMark: true,
+	}
+	publisher := &mockPublisher{}
+	relay := NewRelay(outbox, publisher)
+
+	_, err := relay.PublishPending(context.Background())
+	require.Error(t, err)
+
+	// The event should be published again on the next run (at-least-once)
+	outbox.failMark = false
+	_, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 1}, publisher.publishedIds)
+}
+
+// mockOutboxStore is an in-memory implementation of store.OutboxStore
+type mockOutboxStore struct {
+	events       []*store.OutboxEvent
+	publishedIds []int64
+	// If true, MarkPublished will fail
+	failMark bool
+	// Whether a relay holds the outbox lock
+	locked bool
+}
+
+func (s *mockOutboxStore) LockOutbox(ctx context.Context) (func(), bool, error) {
+	if s.locked {
+		return nil, false, nil
+	}
+	s.locked = true
+	return func() { s.locked = false }, true, nil
+}
+
+func (s *mockOutboxStore) PendingEvents(ctx context.Context, limit int, skipSensorIDs []int) ([]*store.OutboxEvent, error) {
+	published := make(map[int64]bool)
+	for _, id := range s.publishedIds {
+		published[id] = true
+	}
+	skipped := make(map[int]bool)
+	for _, sensorID := range skipSensorIDs {
+		skipped[sensorID] = true
+	}
+
+	var pending []*store.OutboxEvent
+	for _, event := range s.events {
+		if !published[event.ID] && !skipped[event.SensorID] && len(pending) < limit {
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+func (s *mockOutboxStore) MarkPublished(ctx context.Context, ids []int64) error {
+	if s.failMark {
+		return errors.New("mockOutboxStore.MarkPublished() failing for tests, on purpose")
+	}
+	s.publishedIds = append(s.publishedIds, ids...)
+	return nil
+}
+
+// mockPublisher records published events
+type mockPublisher struct {
+	publishedIds []int64
+	// Every event the relay tried to publish, including failures
+	attemptedIds []int64
+	// Events which will fail to publish
+	failIds map[int64]bool
+}
+
+func (p *mockPublisher) Publish(ctx context.Context, event *store.OutboxEvent) error {
+	p.attemptedIds = append(p.attemptedIds, event.ID)
+	if p.failIds[event.ID] {
+		return errors.New("mockPublisher.Publish() failing for tests, on purpose")
+	}
+	p.publishedIds = append(p.publishedIds, event.ID)
+	return nil
+}
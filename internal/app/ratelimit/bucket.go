@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket rate limiter.
+//
+// The bucket holds up to `burst` tokens, and is refilled at `rate` tokens per second.
+// Each request takes a token, and is rejected (or waits) when the bucket is empty.
+type TokenBucket struct {
+	rate  float64
+	burst float64
+	// Returns the current time. Replaced in tests.
+	now func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		now:    time.Now,
+		tokens: float64(burst),
+	}
+}
+
+// Result describes the state of a bucket, after trying to take a token
+type Result struct {
+	Allowed bool
+	// Maximum number of tokens in the bucket
+	Limit int
+	// Tokens remaining in the bucket
+	Remaining int
+	// How long until a token is available (if not allowed)
+	RetryAfter time.Duration
+	// How long until the bucket is full
+	ResetAfter time.Duration
+}
+
+// Allow takes a token from the bucket, if one is available
+func (b *TokenBucket) Allow() Result {
+	return b.AllowN(1)
+}
+
+// AllowN takes n tokens from the bucket, if they are available
+func (b *TokenBucket) AllowN(n float64) Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refillLocked()
+	result := Result{Limit: int(b.burst)}
+	if b.tokens >= n {
+		b.tokens -= n
+		result.Allowed = true
+	} else {
+		result.RetryAfter = b.durationFor(n - b.tokens)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.ResetAfter = b.durationFor(b.burst - b.tokens)
+
+	return result
+}
+
+// Wait blocks until a token is available, or the context is done
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	for {
+		result := b.Allow()
+		if result.Allowed {
+			return nil
+		}
+
+		timer := time.NewTimer(result.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// refillLocked adds tokens for the time elapsed since the last refill.
+// b.mu must be held.
+func (b *TokenBucket) refillLocked() {
+	now := b.now()
+	if !b.last.IsZero() {
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// durationFor returns how long it takes to refill the given number of tokens
+func (b *TokenBucket) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / b.rate * float64(time.Second)))
+}
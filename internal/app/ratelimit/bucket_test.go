@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(1, 2)
+	now := time.Now()
+	bucket.now = func() time.Time { return now }
+
+	// Should allow a burst of requests
+	result := bucket.Allow()
+	require.True(t, result.Allowed)
+	require.Equal(t, 2, result.Limit)
+	require.Equal(t, 1, result.Remaining)
+	result = bucket.Allow()
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	require.Equal(t, 2*time.Second, result.ResetAfter)
+
+	// Should reject requests once the bucket is empty
+	result = bucket.Allow()
+	require.False(t, result.Allowed)
+	require.Equal(t, time.Second, result.RetryAfter)
+
+	// Should refill over time
+	now = now.Add(time.Second)
+	result = bucket.Allow()
+	require.True(t, result.Allowed)
+
+	// Should not refill beyond the burst size
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		require.True(t, bucket.Allow().Allowed)
+	}
+	require.False(t, bucket.Allow().Allowed)
+}
+
+func TestTokenBucket_Wait(t *testing.T) {
+	bucket := NewTokenBucket(100, 1)
+
+	// First token is available immediately, the second after ~10ms
+	start := time.Now()
+	require.NoError(t, bucket.Wait(context.Background()))
+	require.NoError(t, bucket.Wait(context.Background()))
+	require.GreaterOrEqual(t, time.Since(start), 5*time.Millisecond)
+}
+
+func TestTokenBucket_WaitCancelled(t *testing.T) {
+	bucket := NewTokenBucket(0.001, 1)
+	require.True(t, bucket.Allow().Allowed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, bucket.Wait(ctx), context.DeadlineExceeded)
+}
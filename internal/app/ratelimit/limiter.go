@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter rate limits requests by key (eg. per API key, or per IP address)
+type Limiter interface {
+	// Allow takes a token from the key's bucket, if one is available
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// AdjustableLimiter is a Limiter whose limits can be changed while it is in use, eg. when reloading configuration
+type AdjustableLimiter interface {
+	Limiter
+	// SetLimit changes the rate (in tokens per second) and burst of every key's bucket
+	SetLimit(rate float64, burst int)
+}
+
+// How often MemoryLimiter removes idle buckets
+const sweepInterval = time.Minute
+
+// MemoryLimiter is a Limiter with an in-memory token bucket for each key.
+// Limits are not shared between instances of the API.
+type MemoryLimiter struct {
+	rate  float64
+	burst int
+	// Returns the current time. Replaced in tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*TokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter(rate float64, burst int) *MemoryLimiter {
+	return &MemoryLimiter{
+		rate:    rate,
+		burst:   burst,
+		now:     time.Now,
+		buckets: make(map[string]*TokenBucket),
+	}
+}
+
+// SetLimit changes the limits. Buckets start again full, so clients may make a burst of requests at the new limit.
+func (l *MemoryLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = burst
+	l.buckets = make(map[string]*TokenBucket)
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweepLocked(now)
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = NewTokenBucket(l.rate, l.burst)
+		bucket.now = l.now
+		l.buckets[key] = bucket
+	}
+	l.mu.Unlock()
+
+	return bucket.Allow(), nil
+}
+
+// sweepLocked removes buckets which have refilled since they were last used.
+// A new bucket starts full, so removing them does not change any limits.
+// l.mu must be held.
+func (l *MemoryLimiter) sweepLocked(now time.Time) {
+	fillTime := time.Duration(float64(l.burst) / l.rate * float64(time.Second))
+	for key, bucket := range l.buckets {
+		bucket.mu.Lock()
+		idle := now.Sub(bucket.last) >= fillTime
+		bucket.mu.Unlock()
+		if idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	limiter := NewMemoryLimiter(1, 1)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Each key has its own bucket
+	result, err := limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	result, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, time.Second, result.RetryAfter)
+
+	result, err = limiter.Allow(ctx, "b")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// Idle buckets are removed, once they have refilled
+	now = now.Add(sweepInterval)
+	result, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Len(t, limiter.buckets, 1)
+}
+
+func TestMemoryLimiter_SetLimit(t *testing.T) {
+	limiter := NewMemoryLimiter(1, 1)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	result, err := limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	limiter.SetLimit(10, 3)
+	for i := 0; i < 3; i++ {
+		result, err = limiter.Allow(ctx, "a")
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, 3, result.Limit)
+	}
+	result, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 100*time.Millisecond, result.RetryAfter)
+}
+
+func TestPostgresLimiter(t *testing.T) {
+	// Skip tests unless the test DB env var is set
+	dbUrl := os.Getenv("TEST_DATABASE_URL")
+	if dbUrl == "" {
+		t.Skip("Skipping database tests")
+	}
+
+	limiter, err := NewPostgresLimiter(dbUrl, 0.01, 2)
+	require.NoError(t, err)
+	defer limiter.Close()
+	_, err = limiter.db.Exec(`TRUNCATE rate_limits`)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Should allow a burst of requests
+	result, err := limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 2, result.Limit)
+	require.Equal(t, 1, result.Remaining)
+	result, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	// Should reject requests once the bucket is empty
+	result, err = limiter.Allow(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.InDelta(t, 100*time.Second, result.RetryAfter, float64(time.Second))
+
+	// Other keys have their own bucket
+	result, err = limiter.Allow(ctx, "b")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+}
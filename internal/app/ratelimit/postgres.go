@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/lib/pq"
+	"math"
+	"sync"
+	"time"
+)
+
+// PostgresLimiter is a Limiter which stores token buckets in the rate_limits table,
+// so that limits hold across multiple instances of the API.
+type PostgresLimiter struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	lastSweep time.Time
+}
+
+func NewPostgresLimiter(dbUrl string, rate float64, burst int) (*PostgresLimiter, error) {
+	db, err := sql.Open("postgres", dbUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostgresLimiter{
+		db:    db,
+		rate:  rate,
+		burst: burst,
+	}, nil
+}
+
+// SetLimit changes the limits. Stored buckets keep their tokens, up to the new burst.
+func (l *PostgresLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = burst
+}
+
+func (l *PostgresLimiter) limit() (float64, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, l.burst
+}
+
+func (l *PostgresLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	rate, burst := l.limit()
+	l.sweep(ctx, rate, burst)
+	result := Result{Limit: burst}
+
+	// Refill the bucket and take a token, in a single atomic statement.
+	// The update is skipped (returning no rows) if the bucket has no tokens.
+	var tokens float64
+	err := l.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limits AS bucket (key, tokens, updated_at)
+		VALUES ($1, $2 - 1, now())
+		ON CONFLICT (key) DO UPDATE
+		SET tokens = LEAST($2, bucket.tokens + EXTRACT(EPOCH FROM now() - bucket.updated_at) * $3) - 1,
+			updated_at = now()
+		WHERE LEAST($2, bucket.tokens + EXTRACT(EPOCH FROM now() - bucket.updated_at) * $3) >= 1
+		RETURNING tokens
+	`, key, burst, rate).Scan(&tokens)
+	if err == nil {
+		result.Allowed = true
+	} else if err == sql.ErrNoRows {
+		// The bucket is empty: find out how long until it has a token
+		err = l.db.QueryRowContext(ctx, `
+			SELECT LEAST($2, tokens + EXTRACT(EPOCH FROM now() - updated_at) * $3)
+			FROM rate_limits
+			WHERE key = $1
+		`, key, burst, rate).Scan(&tokens)
+		if err != nil {
+			return Result{}, err
+		}
+		result.RetryAfter = durationFor(rate, 1-tokens)
+	} else {
+		return Result{}, err
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAfter = durationFor(rate, float64(burst)-tokens)
+	return result, nil
+}
+
+// sweep occasionally deletes buckets which have refilled since they were last used.
+// A new bucket starts full, so deleting them does not change any limits.
+func (l *PostgresLimiter) sweep(ctx context.Context, rate float64, burst int) {
+	l.mu.Lock()
+	if time.Since(l.lastSweep) < sweepInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.lastSweep = time.Now()
+	l.mu.Unlock()
+
+	fillSeconds := float64(burst) / rate
+	// Failing to sweep is harmless, so errors are ignored
+	_, _ = l.db.ExecContext(ctx, `
+		DELETE FROM rate_limits
+		WHERE updated_at < now() - make_interval(secs => $1)
+	`, fillSeconds)
+}
+
+// durationFor returns how long it takes to refill the given number of tokens, at a rate of tokens per second
+func durationFor(rate float64, tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / rate * float64(time.Second)))
+}
+
+func (l *PostgresLimiter) Close() error {
+	return l.db.Close()
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// APIKey is a credential for accessing the API.
+// Only a hash of the key is stored, so the key itself cannot be recovered.
+type APIKey struct {
+	ID int64 `json:"id"`
+	// Tenant which the key grants access to
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+	// Non-secret start of the key, used to look up the key and to identify it in listings
+	Prefix string `json:"prefix"`
+	// SHA-256 hash of the full key
+	Hash      []byte     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) (*APIKey, error)
+	// GetAPIKeyByPrefix returns nil if there is no matching key
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	// ListAPIKeys returns the keys belonging to a tenant
+	ListAPIKeys(ctx context.Context, tenantID string) ([]*APIKey, error)
+	// RotateAPIKey replaces the prefix and hash of an active key.
+	// Returns a MissingResourceError if the tenant has no such key, or it has been revoked.
+	RotateAPIKey(ctx context.Context, tenantID string, id int64, prefix string, hash []byte) (*APIKey, error)
+	// RevokeAPIKey permanently disables a key.
+	// Returns a MissingResourceError if the tenant has no such key.
+	RevokeAPIKey(ctx context.Context, tenantID string, id int64) (*APIKey, error)
+}
+
+// MemoryAPIKeyStore is an in-memory APIKeyStore, for testing
+type MemoryAPIKeyStore struct {
+	mu     sync.Mutex
+	byID   map[int64]*APIKey
+	nextID int64
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{
+		byID:   make(map[int64]*APIKey),
+		nextID: 1,
+	}
+}
+
+func (s *MemoryAPIKeyStore) CreateAPIKey(ctx context.Context, key *APIKey) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.ID = s.nextID
+	key.CreatedAt = time.Now()
+	s.nextID++
+	s.byID[key.ID] = key
+
+	return key, nil
+}
+
+func (s *MemoryAPIKeyStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.byID {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryAPIKeyStore) ListAPIKeys(ctx context.Context, tenantID string) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []*APIKey{}
+	for _, key := range s.byID {
+		if key.TenantID == tenantID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys, nil
+}
+
+func (s *MemoryAPIKeyStore) RotateAPIKey(ctx context.Context, tenantID string, id int64, prefix string, hash []byte) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.byID[id]
+	if !ok || key.TenantID != tenantID || key.RevokedAt != nil {
+		return nil, &MissingResourceError{ID: strconv.FormatInt(id, 10), ResourceType: "api key"}
+	}
+	key.Prefix = prefix
+	key.Hash = hash
+
+	return key, nil
+}
+
+func (s *MemoryAPIKeyStore) RevokeAPIKey(ctx context.Context, tenantID string, id int64) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.byID[id]
+	if !ok || key.TenantID != tenantID {
+		return nil, &MissingResourceError{ID: strconv.FormatInt(id, 10), ResourceType: "api key"}
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+
+	return key, nil
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ChangeFeed notifies watchers of changes to sensors, eg. to stream them to gRPC clients.
+// Changes are recorded by stores wrapped with Wrap.
+//
+// Only changes made through this process are seen. To follow changes made by every
+// instance of the API, publish the PostGIS store's outbox instead (see outbox.Relay).
+type ChangeFeed struct {
+	mu       sync.Mutex
+	lastID   int64
+	watchers map[*Watcher]struct{}
+	now      func() time.Time
+}
+
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{watchers: make(map[*Watcher]struct{}), now: time.Now}
+}
+
+// Watcher receives the changes to a tenant's sensors
+type Watcher struct {
+	feed     *ChangeFeed
+	tenantID string
+	events   chan *OutboxEvent
+	// Closed when the watcher stops, because it was closed or fell behind
+	done    chan struct{}
+	stopped bool
+	lagged  bool
+}
+
+// Watch starts watching changes to a tenant's sensors. Up to buffer changes are queued for the watcher.
+// If the watcher falls further behind, it is stopped (see Watcher.Lagged), so that it can't hold up changes.
+// The watcher must be closed when it is no longer needed.
+func (feed *ChangeFeed) Watch(tenantID string, buffer int) *Watcher {
+	w := &Watcher{
+		feed:     feed,
+		tenantID: tenantID,
+		events:   make(chan *OutboxEvent, buffer),
+		done:     make(chan struct{}),
+	}
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	feed.watchers[w] = struct{}{}
+	return w
+}
+
+// Wrap returns a store which records its changes in the feed
+func (feed *ChangeFeed) Wrap(next SensorStore) SensorStore {
+	return &feedStore{next: next, feed: feed, tenantID: DefaultTenant}
+}
+
+// publish sends a change to the tenant's watchers
+func (feed *ChangeFeed) publish(eventType string, tenantID string, sensor *Sensor) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	feed.lastID++
+	event := &OutboxEvent{
+		ID:        feed.lastID,
+		Type:      eventType,
+		TenantID:  tenantID,
+		SensorID:  sensor.ID,
+		Sensor:    copySensor(sensor),
+		CreatedAt: feed.now(),
+	}
+
+	for w := range feed.watchers {
+		if w.tenantID != tenantID {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			w.lagged = true
+			w.stop()
+		}
+	}
+}
+
+// Events returns the watched changes, oldest first
+func (w *Watcher) Events() <-chan *OutboxEvent {
+	return w.events
+}
+
+// Done is closed when the watcher stops
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Lagged returns true if the watcher was stopped because it fell behind, and missed changes
+func (w *Watcher) Lagged() bool {
+	w.feed.mu.Lock()
+	defer w.feed.mu.Unlock()
+	return w.lagged
+}
+
+// Close stops watching changes
+func (w *Watcher) Close() {
+	w.feed.mu.Lock()
+	defer w.feed.mu.Unlock()
+	w.stop()
+}
+
+// stop removes the watcher from the feed. The feed must be locked.
+func (w *Watcher) stop() {
+	if w.stopped {
+		return
+	}
+	w.stopped = true
+	delete(w.feed.watchers, w)
+	close(w.done)
+}
+
+// feedStore is a SensorStore decorator, which records changes in a feed
+type feedStore struct {
+	next     SensorStore
+	feed     *ChangeFeed
+	tenantID string
+}
+
+func (s *feedStore) ForTenant(tenantID string) SensorStore {
+	return &feedStore{next: s.next.ForTenant(tenantID), feed: s.feed, tenantID: tenantID}
+}
+
+func (s *feedStore) Create(ctx context.Context, sensor *Sensor) (*Sensor, error) {
+	created, err := s.next.Create(ctx, sensor)
+	if err == nil {
+		s.feed.publish(SensorCreatedEvent, s.tenantID, created)
+	}
+	return created, err
+}
+
+func (s *feedStore) GetByName(ctx context.Context, name string) (*Sensor, error) {
+	return s.next.GetByName(ctx, name)
+}
+
+func (s *feedStore) List(ctx context.Context, after string, limit int) ([]*Sensor, error) {
+	return s.next.List(ctx, after, limit)
+}
+
+func (s *feedStore) UpdateByName(ctx context.Context, name string, sensor *Sensor) (*Sensor, error) {
+	updated, err := s.next.UpdateByName(ctx, name, sensor)
+	if err == nil {
+		s.feed.publish(SensorUpdatedEvent, s.tenantID, updated)
+	}
+	return updated, err
+}
+
+func (s *feedStore) DeleteByName(ctx context.Context, name string) (*Sensor, error) {
+	deleted, err := s.next.DeleteByName(ctx, name)
+	if err == nil {
+		s.feed.publish(SensorDeletedEvent, s.tenantID, deleted)
+	}
+	return deleted, err
+}
+
+func (s *feedStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
+	return s.next.FindClosest(ctx, lat, lon, radiusMeters)
+}
+
+func (s *feedStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	return s.next.CountWithinBounds(ctx, minLat, minLon, maxLat, maxLon)
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestChangeFeed(t *testing.T) {
+	feed := NewChangeFeed()
+	sensors := feed.Wrap(NewMemorySensorStore())
+	watcher := feed.Watch(DefaultTenant, 10)
+	defer watcher.Close()
+	otherWatcher := feed.Watch("other", 10)
+	defer otherWatcher.Close()
+
+	_, err := sensors.Create(context.Background(), &Sensor{Name: "abc123", Tags: []string{}})
+	require.NoError(t, err)
+	_, err = sensors.UpdateByName(context.Background(), "abc123", &Sensor{Name: "xyz789", Lat: 10, Tags: []string{}})
+	require.NoError(t, err)
+	_, err = sensors.DeleteByName(context.Background(), "xyz789")
+	require.NoError(t, err)
+	// Failed changes are not recorded
+	_, err = sensors.DeleteByName(context.Background(), "xyz789")
+	require.Error(t, err)
+
+	var changes []string
+	for i := int64(1); i <= 3; i++ {
+		event := <-watcher.Events()
+		require.Equal(t, i, event.ID)
+		require.Equal(t, DefaultTenant, event.TenantID)
+		changes = append(changes, event.Type+" "+event.Sensor.Name)
+	}
+	require.Equal(t, []string{"sensor.created abc123", "sensor.updated xyz789", "sensor.deleted xyz789"}, changes)
+	require.Empty(t, watcher.Events())
+
+	// Other tenants' watchers only see their own changes
+	require.Empty(t, otherWatcher.Events())
+	_, err = sensors.ForTenant("other").Create(context.Background(), &Sensor{Name: "other", Tags: []string{}})
+	require.NoError(t, err)
+	event := <-otherWatcher.Events()
+	require.Equal(t, "other", event.TenantID)
+	require.Empty(t, watcher.Events())
+}
+
+func TestChangeFeed_Lagged(t *testing.T) {
+	feed := NewChangeFeed()
+	sensors := feed.Wrap(NewMemorySensorStore())
+	watcher := feed.Watch(DefaultTenant, 1)
+	defer watcher.Close()
+
+	_, err := sensors.Create(context.Background(), &Sensor{Name: "a", Tags: []string{}})
+	require.NoError(t, err)
+	require.False(t, watcher.Lagged())
+
+	// The watcher is stopped, rather than holding up the change
+	_, err = sensors.Create(context.Background(), &Sensor{Name: "b", Tags: []string{}})
+	require.NoError(t, err)
+	<-watcher.Done()
+	require.True(t, watcher.Lagged())
+	require.Equal(t, "a", (<-watcher.Events()).Sensor.Name)
+}
+
+func TestChangeFeed_Close(t *testing.T) {
+	feed := NewChangeFeed()
+	sensors := feed.Wrap(NewMemorySensorStore())
+	watcher := feed.Watch(DefaultTenant, 1)
+	watcher.Close()
+	watcher.Close()
+	<-watcher.Done()
+
+	_, err := sensors.Create(context.Background(), &Sensor{Name: "a", Tags: []string{}})
+	require.NoError(t, err)
+	require.Empty(t, watcher.Events())
+	require.False(t, watcher.Lagged())
+}
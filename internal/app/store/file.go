@@ -0,0 +1,672 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy controls when a FileSensorStore flushes its write-ahead log to disk
+type SyncPolicy string
+
+const (
+	// SyncAlways flushes each change before it is acknowledged, so no acknowledged change is lost in a crash
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval flushes periodically (see WithSyncInterval). Changes since the last flush survive the process crashing,
+	// but not the operating system crashing or the machine losing power.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system
+	SyncNever SyncPolicy = "never"
+)
+
+// FileSensorStore is a durable SensorStore, which needs no external database.
+// Sensors are kept in memory, and each change is appended to a write-ahead log (WAL) in a directory before it is applied.
+// Snapshots of every sensor are written periodically, after which older logs are deleted.
+// On startup, the latest snapshot is loaded and the log replayed.
+//
+// Every record is checksummed. A record left incomplete by a crash at the end of the log is discarded,
+// but any other damage fails with ErrCorrupt, rather than silently losing sensors.
+//
+// The directory is locked while the store is open, so that a second process (eg. during an overlapping deploy)
+// fails with ErrLocked, rather than interleaving its log with ours.
+type FileSensorStore struct {
+	// Tenant which owns the sensors accessed through this store
+	tenantID string
+	// Shared by all tenant views of the store
+	db *fileDB
+}
+
+// fileDB is the state shared by the tenant views of a FileSensorStore
+type fileDB struct {
+	dir              string
+	syncPolicy       SyncPolicy
+	syncInterval     time.Duration
+	snapshotInterval time.Duration
+	snapshotEvery    int
+	// Holds the directory's lock, until the store is closed
+	lock *os.File
+
+	// Guards the fields below, and serializes changes, so that the log's order matches the order changes are applied
+	mu    sync.Mutex
+	mem   *MemorySensorStore
+	wal   *os.File
+	walAt walSegment
+	// Sequence number of the latest record
+	seq uint64
+	// Records written since the last snapshot
+	sinceSnapshot int
+	// Whether records have been written since the log was last flushed
+	dirty bool
+	// Set if the log can't be written, after which changes are rejected.
+	// The log's state is unknown after a failed write or flush, so the store must be reopened to recover.
+	err error
+
+	// Serializes snapshots, and guards snapshotSeq
+	snapshotMu sync.Mutex
+	// Sequence number of the latest record in the snapshot
+	snapshotSeq uint64
+
+	snapshotRequests chan struct{}
+	done             chan struct{}
+	wg               sync.WaitGroup
+	closeOnce        sync.Once
+}
+
+// walRecord is a change to a sensor, in the write-ahead log
+type walRecord struct {
+	Seq uint64 `json:"seq"`
+	// "create", "update" or "delete"
+	Op       string `json:"op"`
+	TenantID string `json:"tenant_id"`
+	// Name of the sensor before an update, or of the deleted sensor
+	Name   string  `json:"name,omitempty"`
+	Sensor *Sensor `json:"sensor,omitempty"`
+}
+
+// fileSnapshot is every sensor, as of a record in the write-ahead log
+type fileSnapshot struct {
+	Seq uint64 `json:"seq"`
+	// Sensors by tenant ID
+	Sensors map[string][]*Sensor `json:"sensors"`
+	// Largest sensor ID issued, including deleted sensors, so that IDs aren't reused
+	LastID int `json:"last_id"`
+}
+
+// FileStoreOption configures a FileSensorStore
+type FileStoreOption func(db *fileDB)
+
+// WithSyncPolicy sets when the write-ahead log is flushed to disk. Defaults to SyncAlways.
+func WithSyncPolicy(policy SyncPolicy) FileStoreOption {
+	return func(db *fileDB) {
+		db.syncPolicy = policy
+	}
+}
+
+// WithSyncInterval sets how often the log is flushed with SyncInterval. Defaults to 1s.
+func WithSyncInterval(interval time.Duration) FileStoreOption {
+	return func(db *fileDB) {
+		db.syncInterval = interval
+	}
+}
+
+// WithSnapshotInterval sets how often a snapshot is written, if sensors have changed. Defaults to 10m. 0 disables periodic snapshots.
+func WithSnapshotInterval(interval time.Duration) FileStoreOption {
+	return func(db *fileDB) {
+		db.snapshotInterval = interval
+	}
+}
+
+// WithSnapshotEvery writes a snapshot after every n changes, to limit the size of the log. Defaults to 10000. 0 disables this.
+func WithSnapshotEvery(n int) FileStoreOption {
+	return func(db *fileDB) {
+		db.snapshotEvery = n
+	}
+}
+
+// NewFileSensorStore opens the store in dir, creating the directory if it doesn't exist,
+// and recovers the sensors from its snapshot and write-ahead log.
+func NewFileSensorStore(dir string, opts ...FileStoreOption) (*FileSensorStore, error) {
+	if dir == "" {
+		return nil, errors.New("must set the sensor store directory")
+	}
+
+	db := &fileDB{
+		dir:              dir,
+		syncPolicy:       SyncAlways,
+		syncInterval:     time.Second,
+		snapshotInterval: 10 * time.Minute,
+		snapshotEvery:    10000,
+		mem:              NewMemorySensorStore(),
+		snapshotRequests: make(chan struct{}, 1),
+		done:             make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	switch db.syncPolicy {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("invalid sync policy \"%s\": must be \"always\", \"interval\" or \"never\"", db.syncPolicy)
+	}
+	if db.syncPolicy == SyncInterval && db.syncInterval <= 0 {
+		return nil, errors.New("the sync interval must be positive")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	db.lock = lock
+	if err := db.recover(); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+
+	db.wg.Add(1)
+	go db.run()
+
+	return &FileSensorStore{tenantID: DefaultTenant, db: db}, nil
+}
+
+// ForTenant returns a view of the store, scoped to the given tenant.
+// The view shares the sensors and log with the original store.
+func (store *FileSensorStore) ForTenant(tenantID string) SensorStore {
+	return &FileSensorStore{tenantID: tenantID, db: store.db}
+}
+
+func (store *FileSensorStore) Create(ctx context.Context, sensor *Sensor) (*Sensor, error) {
+	db := store.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	sensors := db.mem.ForTenant(store.tenantID)
+	if existing, _ := sensors.GetByName(ctx, sensor.Name); existing != nil {
+		return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+	}
+
+	stored := copySensor(sensor)
+	stored.ID = *db.mem.lastID + 1
+	if err := db.append(walRecord{Op: "create", TenantID: store.tenantID, Sensor: stored}); err != nil {
+		return nil, store.writeError(ctx, "Create", err)
+	}
+	_, _ = sensors.Create(ctx, stored)
+
+	sensor.ID = stored.ID
+	return sensor, nil
+}
+
+func (store *FileSensorStore) GetByName(ctx context.Context, name string) (*Sensor, error) {
+	sensor, err := store.db.mem.ForTenant(store.tenantID).GetByName(ctx, name)
+	if sensor == nil || err != nil {
+		return nil, err
+	}
+
+	// Return a copy, so that changes can only be made through the log
+	return copySensor(sensor), nil
+}
+
+func (store *FileSensorStore) List(ctx context.Context, after string, limit int) ([]*Sensor, error) {
+	sensors, err := store.db.mem.ForTenant(store.tenantID).List(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, sensor := range sensors {
+		sensors[i] = copySensor(sensor)
+	}
+
+	return sensors, nil
+}
+
+func (store *FileSensorStore) UpdateByName(ctx context.Context, name string, sensor *Sensor) (*Sensor, error) {
+	db := store.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	sensors := db.mem.ForTenant(store.tenantID)
+	existing, _ := sensors.GetByName(ctx, name)
+	if existing == nil {
+		return nil, &MissingResourceError{ID: name, ResourceType: "sensor"}
+	}
+	if sensor.Name != name {
+		if other, _ := sensors.GetByName(ctx, sensor.Name); other != nil {
+			return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+		}
+	}
+
+	stored := copySensor(sensor)
+	stored.ID = existing.ID
+	if err := db.append(walRecord{Op: "update", TenantID: store.tenantID, Name: name, Sensor: stored}); err != nil {
+		return nil, store.writeError(ctx, "UpdateByName", err)
+	}
+	_, _ = sensors.UpdateByName(ctx, name, stored)
+
+	sensor.ID = stored.ID
+	return sensor, nil
+}
+
+func (store *FileSensorStore) DeleteByName(ctx context.Context, name string) (*Sensor, error) {
+	db := store.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	sensors := db.mem.ForTenant(store.tenantID)
+	existing, _ := sensors.GetByName(ctx, name)
+	if existing == nil {
+		return nil, &MissingResourceError{ID: name, ResourceType: "sensor"}
+	}
+	if err := db.append(walRecord{Op: "delete", TenantID: store.tenantID, Name: name}); err != nil {
+		return nil, store.writeError(ctx, "DeleteByName", err)
+	}
+	_, _ = sensors.DeleteByName(ctx, name)
+
+	return existing, nil
+}
+
+func (store *FileSensorStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
+	sensors, err := store.db.mem.ForTenant(store.tenantID).FindClosest(ctx, lat, lon, radiusMeters)
+	if err != nil {
+		return []*Sensor{}, err
+	}
+	for i, sensor := range sensors {
+		sensors[i] = copySensor(sensor)
+	}
+
+	return sensors, nil
+}
+
+func (store *FileSensorStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	return store.db.mem.ForTenant(store.tenantID).CountWithinBounds(ctx, minLat, minLon, maxLat, maxLon)
+}
+
+// CountByTenant counts sensors in every tenant
+func (store *FileSensorStore) CountByTenant(ctx context.Context) (map[string]int, error) {
+	return store.db.mem.CountByTenant(ctx)
+}
+
+// Ping checks that changes can be written to the log
+func (store *FileSensorStore) Ping(ctx context.Context) error {
+	store.db.mu.Lock()
+	defer store.db.mu.Unlock()
+	return store.db.err
+}
+
+// Snapshot writes every sensor to a new snapshot, and deletes the logs it replaces.
+// Snapshots are also written periodically (see WithSnapshotInterval and WithSnapshotEvery), and when the store is closed.
+func (store *FileSensorStore) Snapshot() error {
+	return store.db.snapshot()
+}
+
+// Close writes a final snapshot, and closes the log. The store can't be changed once closed.
+func (store *FileSensorStore) Close() error {
+	db := store.db
+	var err error
+	db.closeOnce.Do(func() {
+		close(db.done)
+		db.wg.Wait()
+
+		err = db.snapshot()
+
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if db.err == nil && db.dirty {
+			err = errors.Join(err, db.wal.Sync())
+		}
+		err = errors.Join(err, db.wal.Close())
+		// Release the directory, once we're done writing to it
+		err = errors.Join(err, db.lock.Close())
+		if db.err == nil {
+			db.err = errors.New("the sensor store is closed")
+		}
+	})
+	return err
+}
+
+// writeError logs a failed change, including the request ID from the context, and returns the error
+func (store *FileSensorStore) writeError(ctx context.Context, method string, err error) error {
+	slog.ErrorContext(ctx, "sensor store write failed",
+		"store", "file",
+		"method", method,
+		"tenant_id", store.tenantID,
+		"error", err,
+	)
+	return err
+}
+
+// append writes a record to the log, assigning its sequence number. db.mu must be held.
+func (db *fileDB) append(record walRecord) error {
+	if db.err != nil {
+		return db.err
+	}
+
+	record.Seq = db.seq + 1
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(db.wal, payload); err != nil {
+		return db.fail(err)
+	}
+	if db.syncPolicy == SyncAlways {
+		if err := db.wal.Sync(); err != nil {
+			return db.fail(err)
+		}
+	} else {
+		db.dirty = true
+	}
+
+	db.seq = record.Seq
+	db.sinceSnapshot++
+	if db.snapshotEvery > 0 && db.sinceSnapshot >= db.snapshotEvery {
+		// Snapshot in the background. A snapshot may already be pending.
+		select {
+		case db.snapshotRequests <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// fail stops further changes, after the log couldn't be written. db.mu must be held.
+func (db *fileDB) fail(err error) error {
+	db.err = fmt.Errorf("failed to write the sensor store's log, reopen the store to recover: %w", err)
+	return db.err
+}
+
+// apply makes a change recorded in the log
+func (db *fileDB) apply(record walRecord) error {
+	sensors := db.mem.ForTenant(record.TenantID)
+	switch record.Op {
+	case "create":
+		_, err := sensors.Create(context.Background(), record.Sensor)
+		return err
+	case "update":
+		_, err := sensors.UpdateByName(context.Background(), record.Name, record.Sensor)
+		return err
+	case "delete":
+		_, err := sensors.DeleteByName(context.Background(), record.Name)
+		return err
+	default:
+		return fmt.Errorf("unknown operation \"%s\"", record.Op)
+	}
+}
+
+// sync flushes the log to disk, if it has changed
+func (db *fileDB) sync() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.err != nil || !db.dirty {
+		return
+	}
+	if err := db.wal.Sync(); err != nil {
+		slog.Error("failed to flush the sensor store's log", "dir", db.dir, "error", db.fail(err))
+		return
+	}
+	db.dirty = false
+}
+
+// run flushes the log and writes snapshots in the background, until the store is closed
+func (db *fileDB) run() {
+	defer db.wg.Done()
+
+	var syncTicks, snapshotTicks <-chan time.Time
+	if db.syncPolicy == SyncInterval {
+		ticker := time.NewTicker(db.syncInterval)
+		defer ticker.Stop()
+		syncTicks = ticker.C
+	}
+	if db.snapshotInterval > 0 {
+		ticker := time.NewTicker(db.snapshotInterval)
+		defer ticker.Stop()
+		snapshotTicks = ticker.C
+	}
+
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-syncTicks:
+			db.sync()
+		case <-snapshotTicks:
+			db.snapshotInBackground()
+		case <-db.snapshotRequests:
+			db.snapshotInBackground()
+		}
+	}
+}
+
+func (db *fileDB) snapshotInBackground() {
+	if err := db.snapshot(); err != nil {
+		slog.Error("failed to write sensor store snapshot", "dir", db.dir, "error", err)
+	}
+}
+
+// snapshot writes every sensor to a new snapshot, then deletes the log segments which it replaces
+func (db *fileDB) snapshot() error {
+	db.snapshotMu.Lock()
+	defer db.snapshotMu.Unlock()
+
+	// Copy the sensors, and start a new log segment, so that changes can continue while the snapshot is written
+	db.mu.Lock()
+	if db.err != nil {
+		db.mu.Unlock()
+		return db.err
+	}
+	if db.seq == db.snapshotSeq {
+		// Nothing has changed
+		db.mu.Unlock()
+		return nil
+	}
+	sensors, lastID := db.mem.export()
+	snapshot := fileSnapshot{Seq: db.seq, Sensors: sensors, LastID: lastID}
+	if err := db.rotate(); err != nil {
+		err = db.fail(err)
+		db.mu.Unlock()
+		return err
+	}
+	db.sinceSnapshot = 0
+	db.mu.Unlock()
+
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(db.dir, "snapshot"), payload); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	db.snapshotSeq = snapshot.Seq
+
+	// Older segments' records are all in the snapshot
+	segments, err := walSegments(db.dir)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.firstSeq <= snapshot.Seq {
+			if err := os.Remove(segment.path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// rotate starts a new log segment, for records after db.seq. db.mu must be held.
+func (db *fileDB) rotate() error {
+	if db.walAt.firstSeq == db.seq+1 {
+		// The current segment is empty
+		return nil
+	}
+	if err := db.wal.Sync(); err != nil {
+		return err
+	}
+	db.dirty = false
+	if err := db.wal.Close(); err != nil {
+		return err
+	}
+	return db.openSegment(db.seq + 1)
+}
+
+// openSegment opens a log segment for appending, creating it if it doesn't exist. db.mu must be held.
+func (db *fileDB) openSegment(firstSeq uint64) error {
+	segment := walSegment{path: walSegmentPath(db.dir, firstSeq), firstSeq: firstSeq}
+	f, err := os.OpenFile(segment.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(db.dir); err != nil {
+		_ = f.Close()
+		return err
+	}
+	db.wal = f
+	db.walAt = segment
+	return nil
+}
+
+// recover loads the snapshot, and replays the log.
+// A truncated or damaged record at the end of the log is assumed to be a write interrupted by a crash, so is removed.
+func (db *fileDB) recover() error {
+	// A snapshot may have been interrupted. The previous snapshot, if any, is intact.
+	_ = os.Remove(filepath.Join(db.dir, "snapshot.tmp"))
+
+	data, err := os.ReadFile(filepath.Join(db.dir, "snapshot"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		payload, size, err := readFrame(data)
+		if err == nil && size != len(data) {
+			err = errors.New("unexpected data after snapshot")
+		}
+		var snapshot fileSnapshot
+		if err == nil {
+			err = json.Unmarshal(payload, &snapshot)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: snapshot: %v", ErrCorrupt, err)
+		}
+		for tenantID, sensors := range snapshot.Sensors {
+			for _, sensor := range sensors {
+				_, _ = db.mem.ForTenant(tenantID).Create(context.Background(), sensor)
+			}
+		}
+		// The sensor with the largest ID may have been deleted
+		if snapshot.LastID > *db.mem.lastID {
+			*db.mem.lastID = snapshot.LastID
+		}
+		db.seq = snapshot.Seq
+		db.snapshotSeq = snapshot.Seq
+	}
+
+	segments, err := walSegments(db.dir)
+	if err != nil {
+		return err
+	}
+	replayed := 0
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		n, err := db.replay(segment, last)
+		if err != nil {
+			return err
+		}
+		replayed += n
+	}
+
+	if len(segments) == 0 {
+		err = db.openSegment(db.seq + 1)
+	} else {
+		err = db.openSegment(segments[len(segments)-1].firstSeq)
+	}
+	if err != nil {
+		return err
+	}
+	db.sinceSnapshot = int(db.seq - db.snapshotSeq)
+
+	counts, _ := db.mem.CountByTenant(context.Background())
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	slog.Info("opened sensor store", "dir", db.dir, "sensors", total, "replayed_records", replayed)
+	return nil
+}
+
+// replay applies the records in a log segment which aren't in the snapshot, returning how many were applied.
+// If last, a damaged final record is removed, rather than failing.
+func (db *fileDB) replay(segment walSegment, last bool) (int, error) {
+	data, err := os.ReadFile(segment.path)
+	if err != nil {
+		return 0, err
+	}
+	name := filepath.Base(segment.path)
+
+	replayed := 0
+	for offset := 0; offset < len(data); {
+		payload, size, err := readFrame(data[offset:])
+		if err != nil {
+			interrupted := errors.Is(err, errTruncatedFrame) || offset+size == len(data) || allZero(data[offset:])
+			if !last || !interrupted {
+				return 0, fmt.Errorf("%w: %s at offset %d: %v", ErrCorrupt, name, offset, err)
+			}
+			slog.Warn("removing incomplete record from the end of the sensor store's log",
+				"file", segment.path, "offset", offset, "error", err)
+			if err := os.Truncate(segment.path, int64(offset)); err != nil {
+				return 0, err
+			}
+			break
+		}
+		offset += size
+
+		var record walRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return 0, fmt.Errorf("%w: %s at offset %d: %v", ErrCorrupt, name, offset-size, err)
+		}
+		if record.Seq <= db.seq {
+			// Already in the snapshot
+			continue
+		}
+		if record.Seq != db.seq+1 {
+			return 0, fmt.Errorf("%w: %s: expected record %d, found %d", ErrCorrupt, name, db.seq+1, record.Seq)
+		}
+		if err := db.apply(record); err != nil {
+			return 0, fmt.Errorf("%w: %s: record %d: %v", ErrCorrupt, name, record.Seq, err)
+		}
+		db.seq = record.Seq
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// export copies every sensor, by tenant ID, and returns the largest ID issued
+func (s *MemorySensorStore) export() (map[string][]*Sensor, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sensors := make(map[string][]*Sensor, len(s.byTenant))
+	for tenantID, byName := range s.byTenant {
+		for _, sensor := range byName {
+			sensors[tenantID] = append(sensors[tenantID], copySensor(sensor))
+		}
+	}
+	return sensors, *s.lastID
+}
+
+// copySensor returns a copy of a sensor, which doesn't share its tags
+func copySensor(sensor *Sensor) *Sensor {
+	copied := *sensor
+	if sensor.Tags != nil {
+		copied.Tags = append([]string{}, sensor.Tags...)
+	}
+	return &copied
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// lockDir creates the LOCK file in dir. File locks aren't supported on this platform,
+// so the directory must not be shared by two processes.
+func lockDir(dir string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(dir, "LOCK"), os.O_CREATE|os.O_RDWR, 0600)
+}
@@ -0,0 +1,299 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openFileStore(t *testing.T, dir string, opts ...FileStoreOption) *FileSensorStore {
+	store, err := NewFileSensorStore(dir, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// crash abandons a store without closing it, as if the process had crashed.
+// Background work is stopped, so that it can't change the files, and the directory's lock is released, as on exit.
+func crash(store *FileSensorStore) {
+	close(store.db.done)
+	store.db.wg.Wait()
+	_ = store.db.wal.Close()
+	_ = store.db.lock.Close()
+	store.db.closeOnce.Do(func() {})
+}
+
+func TestFileSensorStore(t *testing.T) {
+	store := openFileStore(t, t.TempDir())
+	acme := store.ForTenant("acme")
+	ctx := context.Background()
+
+	created, err := acme.Create(ctx, &Sensor{Name: "sensor-abc", Lat: 44.97, Lon: -93.26, Tags: []string{"a", "b"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, created.ID)
+
+	// Names are unique per tenant
+	_, err = acme.Create(ctx, &Sensor{Name: "sensor-abc", Lat: 44.97, Lon: -93.26})
+	require.IsType(t, &DuplicateResourceError{}, err)
+	require.EqualError(t, err, "a sensor resource already exists: sensor-abc")
+	_, err = store.Create(ctx, &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09})
+	require.NoError(t, err)
+
+	// Changing a returned sensor doesn't change the store
+	sensor, err := acme.GetByName(ctx, "sensor-abc")
+	require.NoError(t, err)
+	sensor.Tags[0] = "changed"
+	sensor, err = acme.GetByName(ctx, "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, sensor.Tags)
+
+	// Rename a sensor
+	_, err = acme.Create(ctx, &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 1})
+	require.NoError(t, err)
+	_, err = acme.UpdateByName(ctx, "sensor-abc", &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 1})
+	require.IsType(t, &DuplicateResourceError{}, err)
+	require.EqualError(t, err, "a sensor resource already exists: sensor-xyz")
+	updated, err := acme.UpdateByName(ctx, "sensor-abc", &Sensor{Name: "sensor-def", Lat: 44.98, Lon: -93.27, Tags: []string{"c"}})
+	require.NoError(t, err)
+	require.Equal(t, created.ID, updated.ID)
+
+	_, err = acme.UpdateByName(ctx, "sensor-abc", &Sensor{Name: "sensor-abc"})
+	require.IsType(t, &MissingResourceError{}, err)
+
+	sensors, err := acme.FindClosest(ctx, 44.97, -93.26, 10e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	require.Equal(t, "sensor-def", sensors[0].Name)
+
+	sensors, err = acme.List(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, sensors, 2)
+	require.Equal(t, "sensor-def", sensors[0].Name)
+	require.Equal(t, "sensor-xyz", sensors[1].Name)
+
+	count, err := store.CountWithinBounds(ctx, 44.8, -93.4, 45.1, -92.9)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	counts, err := store.CountByTenant(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"acme": 2, DefaultTenant: 1}, counts)
+	require.NoError(t, store.Ping(ctx))
+}
+
+func TestFileSensorStore_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// Sensors are recovered from the log, after a crash
+	store := openFileStore(t, dir, WithSnapshotInterval(0))
+	_, err := store.ForTenant("acme").Create(ctx, &Sensor{Name: "sensor-abc", Lat: 44.97, Lon: -93.26, Tags: []string{"a"}})
+	require.NoError(t, err)
+	_, err = store.ForTenant("acme").UpdateByName(ctx, "sensor-abc", &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"b"}})
+	require.NoError(t, err)
+	crash(store)
+
+	store = openFileStore(t, dir, WithSnapshotInterval(0))
+	sensor, err := store.ForTenant("acme").GetByName(ctx, "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, &Sensor{ID: 1, Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"b"}}, sensor)
+
+	// ...and from a snapshot, followed by the log
+	require.NoError(t, store.Snapshot())
+	created, err := store.Create(ctx, &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	require.Equal(t, 2, created.ID)
+	_, err = store.Create(ctx, &Sensor{Name: "sensor-deleted", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	deleted, err := store.DeleteByName(ctx, "sensor-deleted")
+	require.NoError(t, err)
+	require.Equal(t, 3, deleted.ID)
+	crash(store)
+
+	store = openFileStore(t, dir, WithSnapshotInterval(0))
+	sensor, err = store.ForTenant("acme").GetByName(ctx, "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, sensor.Tags)
+	sensor, err = store.GetByName(ctx, "sensor-xyz")
+	require.NoError(t, err)
+	require.Equal(t, 2, sensor.ID)
+	sensor, err = store.GetByName(ctx, "sensor-deleted")
+	require.NoError(t, err)
+	require.Nil(t, sensor)
+
+	// IDs continue after the recovered sensors
+	created, err = store.Create(ctx, &Sensor{Name: "sensor-123", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	require.Equal(t, 4, created.ID)
+
+	// IDs of deleted sensors aren't reused, once they're only in the snapshot
+	_, err = store.DeleteByName(ctx, "sensor-123")
+	require.NoError(t, err)
+	require.NoError(t, store.Snapshot())
+	crash(store)
+
+	store = openFileStore(t, dir, WithSnapshotInterval(0))
+	created, err = store.Create(ctx, &Sensor{Name: "sensor-456", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	require.Equal(t, 5, created.ID)
+}
+
+func TestFileSensorStore_Snapshots(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// Snapshot after every 2 changes
+	store := openFileStore(t, dir, WithSnapshotEvery(2), WithSnapshotInterval(0))
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := store.Create(ctx, &Sensor{Name: name, Lat: 1, Lon: 2})
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "snapshot"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// Closing the store writes a final snapshot, and removes the logs it replaces
+	require.NoError(t, store.Close())
+	segments, err := walSegments(dir)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	info, err := os.Stat(segments[0].path)
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	store = openFileStore(t, dir)
+	counts, err := store.CountByTenant(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{DefaultTenant: 3}, counts)
+}
+
+func TestFileSensorStore_InterruptedWrite(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := openFileStore(t, dir, WithSnapshotInterval(0))
+	_, err := store.Create(ctx, &Sensor{Name: "sensor-abc", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	_, err = store.Create(ctx, &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	crash(store)
+
+	// Cut the last record short, as if the process crashed while writing it
+	segments, err := walSegments(dir)
+	require.NoError(t, err)
+	path := segments[len(segments)-1].path
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-5))
+
+	// The incomplete record is removed
+	store = openFileStore(t, dir, WithSnapshotInterval(0))
+	sensor, err := store.GetByName(ctx, "sensor-abc")
+	require.NoError(t, err)
+	require.NotNil(t, sensor)
+	sensor, err = store.GetByName(ctx, "sensor-xyz")
+	require.NoError(t, err)
+	require.Nil(t, sensor)
+
+	// New records follow the last complete record
+	_, err = store.Create(ctx, &Sensor{Name: "sensor-123", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	crash(store)
+	store = openFileStore(t, dir, WithSnapshotInterval(0))
+	sensor, err = store.GetByName(ctx, "sensor-123")
+	require.NoError(t, err)
+	require.NotNil(t, sensor)
+}
+
+func TestFileSensorStore_Corruption(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store := openFileStore(t, dir, WithSnapshotInterval(0))
+	_, err := store.Create(ctx, &Sensor{Name: "sensor-abc", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	_, err = store.Create(ctx, &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	crash(store)
+
+	// Damage the first record. Later records are intact, so this isn't an interrupted write.
+	segments, err := walSegments(dir)
+	require.NoError(t, err)
+	data, err := os.ReadFile(segments[0].path)
+	require.NoError(t, err)
+	data[frameHeaderSize+2] ^= 0xff
+	require.NoError(t, os.WriteFile(segments[0].path, data, 0600))
+
+	_, err = NewFileSensorStore(dir)
+	require.ErrorIs(t, err, ErrCorrupt)
+	require.ErrorContains(t, err, "checksum mismatch")
+
+	// Damaged snapshots are also detected
+	dir = t.TempDir()
+	store = openFileStore(t, dir)
+	_, err = store.Create(ctx, &Sensor{Name: "sensor-abc", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+	data, err = os.ReadFile(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+	data[len(data)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot"), data, 0600))
+
+	_, err = NewFileSensorStore(dir)
+	require.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestFileSensorStore_SyncPolicies(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		t.Run(string(policy), func(t *testing.T) {
+			dir := t.TempDir()
+			store := openFileStore(t, dir, WithSyncPolicy(policy), WithSyncInterval(10*time.Millisecond))
+			_, err := store.Create(ctx, &Sensor{Name: "sensor-abc", Lat: 1, Lon: 2})
+			require.NoError(t, err)
+			if policy == SyncInterval {
+				require.Eventually(t, func() bool {
+					store.db.mu.Lock()
+					defer store.db.mu.Unlock()
+					return !store.db.dirty
+				}, time.Second, 10*time.Millisecond)
+			}
+			crash(store)
+
+			// A process crash loses nothing, whatever the policy
+			store = openFileStore(t, dir)
+			sensor, err := store.GetByName(ctx, "sensor-abc")
+			require.NoError(t, err)
+			require.NotNil(t, sensor)
+		})
+	}
+
+	_, err := NewFileSensorStore(t.TempDir(), WithSyncPolicy("sometimes"))
+	require.EqualError(t, err, "invalid sync policy \"sometimes\": must be \"always\", \"interval\" or \"never\"")
+}
+
+func TestFileSensorStore_Locked(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+
+	// A second process can't use the directory while the store is open
+	_, err := NewFileSensorStore(dir)
+	require.ErrorIs(t, err, ErrLocked)
+
+	require.NoError(t, store.Close())
+	openFileStore(t, dir)
+}
+
+func TestFileSensorStore_Closed(t *testing.T) {
+	store := openFileStore(t, t.TempDir())
+	require.NoError(t, store.Close())
+	require.NoError(t, store.Close())
+
+	_, err := store.Create(context.Background(), &Sensor{Name: "sensor-abc"})
+	require.EqualError(t, err, "the sensor store is closed")
+	require.Error(t, store.Ping(context.Background()))
+}
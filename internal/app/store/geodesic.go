@@ -0,0 +1,118 @@
+package store
+
+import "math"
+
+// WGS 84 ellipsoid (SRID 4326), as used by PostGIS for geography distances
+const (
+	wgs84SemiMajorAxis = 6378137.0
+	wgs84Flattening    = 1 / 298.257223563
+	wgs84SemiMinorAxis = wgs84SemiMajorAxis * (1 - wgs84Flattening)
+)
+
+// geodesicDistance returns the distance in meters between two points on the WGS 84 ellipsoid,
+// using Vincenty's inverse formula. This matches PostGIS's ST_Distance for geography to well under a millimeter.
+//
+// Vincenty's formula may not converge for nearly antipodal points,
+// in which case the great-circle distance is returned (which is within 0.5% of the geodesic).
+func geodesicDistance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	const (
+		a = wgs84SemiMajorAxis
+		b = wgs84SemiMinorAxis
+		f = wgs84Flattening
+	)
+	toRadians := math.Pi / 180
+
+	L := (lon2 - lon1) * toRadians
+	// Reduced latitudes
+	U1 := math.Atan((1 - f) * math.Tan(lat1*toRadians))
+	U2 := math.Atan((1 - f) * math.Tan(lat2*toRadians))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma := math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			// Coincident points
+			return 0
+		}
+		cosSigma := sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma := math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha := 1 - sinAlpha*sinAlpha
+		cos2SigmaM := 0.0
+		if cosSqAlpha != 0 {
+			// Otherwise, both points are on the equator
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+
+		previous := lambda
+		lambda = L + (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) > 1e-12 {
+			continue
+		}
+
+		uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+		A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+		B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		return b * A * (sigma - deltaSigma)
+	}
+
+	return greatCircleDistance(lat1, lon1, lat2, lon2)
+}
+
+// greatCircleDistance returns the distance in meters between two points, on a sphere with the earth's mean radius
+func greatCircleDistance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	const earthRadius = (2*wgs84SemiMajorAxis + wgs84SemiMinorAxis) / 3
+	toRadians := math.Pi / 180
+
+	deltaLat := (lat2 - lat1) * toRadians
+	deltaLon := (lon2 - lon1) * toRadians
+	h := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(lat1*toRadians)*math.Cos(lat2*toRadians)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// boundingBox is a lat/lon rectangle, which doesn't cross the antimeridian
+type boundingBox struct {
+	minLat, minLon, maxLat, maxLon float64
+}
+
+// boundingBoxes returns rectangles containing every point within radiusMeters of a point,
+// for filtering with an index before calculating exact distances.
+// The rectangles may include points further away, but never exclude nearer points.
+// Circles which cross the antimeridian are split into two rectangles, and circles which include a pole span every longitude.
+func boundingBoxes(lat float64, lon float64, radiusMeters float64) []boundingBox {
+	// No point on the ellipsoid is closer to its center than the semi-minor axis,
+	// so a geodesic of radiusMeters subtends at most this angle. Padded for rounding.
+	angle := radiusMeters / wgs84SemiMinorAxis * 1.001
+	if angle >= math.Pi {
+		return []boundingBox{{-90, -180, 90, 180}}
+	}
+	toDegrees := 180 / math.Pi
+
+	minLat := lat - angle*toDegrees
+	maxLat := lat + angle*toDegrees
+	if minLat <= -90 || maxLat >= 90 {
+		return []boundingBox{{math.Max(minLat, -90), -180, math.Min(maxLat, 90), 180}}
+	}
+
+	// See "Finding Points Within a Distance of a Latitude/Longitude Using Bounding Coordinates", Jan Philip Matuschek
+	deltaLon := math.Asin(math.Min(1, math.Sin(angle)/math.Cos(lat*math.Pi/180))) * toDegrees
+	minLon := lon - deltaLon
+	maxLon := lon + deltaLon
+	switch {
+	case deltaLon >= 180 || maxLon-minLon >= 360:
+		return []boundingBox{{minLat, -180, maxLat, 180}}
+	case minLon < -180:
+		return []boundingBox{{minLat, minLon + 360, maxLat, 180}, {minLat, -180, maxLat, maxLon}}
+	case maxLon > 180:
+		return []boundingBox{{minLat, minLon, maxLat, 180}, {minLat, -180, maxLat, maxLon - 360}}
+	default:
+		return []boundingBox{{minLat, minLon, maxLat, maxLon}}
+	}
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGeodesicDistance(t *testing.T) {
+	// Minneapolis to St. Paul is ~14km, and within 0.5% of the great-circle distance
+	distance := geodesicDistance(44.97997, -93.26384, 44.94441, -93.09327)
+	require.InDelta(t, greatCircleDistance(44.97997, -93.26384, 44.94441, -93.09327), distance, 0.005*distance)
+	// Flinders Peak to Buninyong (Vincenty's example)
+	require.InDelta(t, 54_972.271, geodesicDistance(-37.95103341666667, 144.42486788888888, -37.65282113888889, 143.92649552777777), 0.001)
+	require.Equal(t, 0.0, geodesicDistance(10, 10, 10, 10))
+	// Along the equator, a degree of longitude is 1/360 of the ellipsoid's circumference
+	require.InDelta(t, 111_319.49, geodesicDistance(0, 0, 0, 1), 0.01)
+	// Nearly antipodal points don't converge, so use the great-circle distance
+	require.InDelta(t, 20_003_931, geodesicDistance(0, 0, 0.5, 179.7), 0.005*20_003_931)
+}
+
+func TestBoundingBoxes(t *testing.T) {
+	boxes := boundingBoxes(45, -93, 10e3)
+	require.Len(t, boxes, 1)
+	require.InDelta(t, 45-0.09, boxes[0].minLat, 0.01)
+	require.InDelta(t, -93-0.127, boxes[0].minLon, 0.01)
+
+	// Crossing the antimeridian
+	boxes = boundingBoxes(-17.5, 179.95, 20e3)
+	require.Len(t, boxes, 2)
+	require.Equal(t, 180.0, boxes[0].maxLon)
+	require.Equal(t, -180.0, boxes[1].minLon)
+
+	// Including a pole
+	boxes = boundingBoxes(89.9, 0, 25e3)
+	require.Equal(t, []boundingBox{{boxes[0].minLat, -180, 90, 180}}, boxes)
+}
@@ -1,30 +1,79 @@
 package store
 
-import "errors"
+import (
+	"context"
+	"sort"
+	"sync"
+)
 
 // MemorySensorStore is an in-memory store of Sensor models
 // Future iterations should transition to a persistent data store
 // (though the in-memory may continue to be useful for testing)
 type MemorySensorStore struct {
-	byName map[string]*Sensor
+	tenantID string
+	// Guards byTenant and lastID. Shared by all tenant views of the store.
+	mu *sync.RWMutex
+	// Sensors by tenant ID, then by name.
+	// Shared by all tenant views of the store.
+	byTenant map[string]map[string]*Sensor
+	// Largest sensor ID in the store. Shared by all tenant views of the store.
+	lastID *int
 }
 
 func NewMemorySensorStore() *MemorySensorStore {
 	return &MemorySensorStore{
-		byName: make(map[string]*Sensor),
+		tenantID: DefaultTenant,
+		mu:       &sync.RWMutex{},
+		byTenant: make(map[string]map[string]*Sensor),
+		lastID:   new(int),
 	}
 }
 
-func (s *MemorySensorStore) Create(sensor *Sensor) (*Sensor, error) {
+func (s *MemorySensorStore) ForTenant(tenantID string) SensorStore {
+	return &MemorySensorStore{
+		tenantID: tenantID,
+		mu:       s.mu,
+		byTenant: s.byTenant,
+		lastID:   s.lastID,
+	}
+}
+
+// byName returns the sensors belonging to the store's tenant.
+// s.mu must be held for writing.
+func (s *MemorySensorStore) byName() map[string]*Sensor {
+	sensors, ok := s.byTenant[s.tenantID]
+	if !ok {
+		sensors = make(map[string]*Sensor)
+		s.byTenant[s.tenantID] = sensors
+	}
+
+	return sensors
+}
+
+func (s *MemorySensorStore) Create(ctx context.Context, sensor *Sensor) (*Sensor, error) {
 	// TODO: validate sensor input
-	// TODO: validate unique name
-	s.byName[sensor.Name] = sensor
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.byName()[sensor.Name]; exists {
+		return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+	}
+
+	// Sensors are assigned the next ID, unless they already have one (eg. when restored from a snapshot)
+	if sensor.ID == 0 {
+		sensor.ID = *s.lastID + 1
+	}
+	if sensor.ID > *s.lastID {
+		*s.lastID = sensor.ID
+	}
+	s.byName()[sensor.Name] = sensor
 
 	return sensor, nil
 }
 
-func (s *MemorySensorStore) GetByName(name string) (*Sensor, error) {
-	sensor, ok := s.byName[name]
+func (s *MemorySensorStore) GetByName(ctx context.Context, name string) (*Sensor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sensor, ok := s.byTenant[s.tenantID][name]
 	if !ok {
 		return nil, nil
 	}
@@ -32,22 +81,121 @@ func (s *MemorySensorStore) GetByName(name string) (*Sensor, error) {
 	return sensor, nil
 }
 
-func (s *MemorySensorStore) UpdateByName(name string, sensor *Sensor) (*Sensor, error) {
-	_, ok := s.byName[name]
+func (s *MemorySensorStore) List(ctx context.Context, after string, limit int) ([]*Sensor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sensors []*Sensor
+	for name, sensor := range s.byTenant[s.tenantID] {
+		if name > after {
+			sensors = append(sensors, sensor)
+		}
+	}
+	sort.Slice(sensors, func(i, j int) bool {
+		return sensors[i].Name < sensors[j].Name
+	})
+	if len(sensors) > limit {
+		sensors = sensors[:limit]
+	}
+
+	return sensors, nil
+}
+
+func (s *MemorySensorStore) UpdateByName(ctx context.Context, name string, sensor *Sensor) (*Sensor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sensors := s.byName()
+	existing, ok := sensors[name]
 	if !ok {
 		return nil, &MissingResourceError{
 			ID:           name,
 			ResourceType: "sensor",
 		}
 	}
+	if _, exists := sensors[sensor.Name]; exists && sensor.Name != name {
+		return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+	}
 
 	// TODO validate sensor data
-	// TODO what if name has changed? Is this allowed?
-	s.byName[name] = sensor
+	// Sensors keep their ID, and may be renamed
+	sensor.ID = existing.ID
+	delete(sensors, name)
+	sensors[sensor.Name] = sensor
 
 	return sensor, nil
 }
 
-func (s *MemorySensorStore) FindClosest(lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
-	return []*Sensor{}, errors.New("not implemented")
+func (s *MemorySensorStore) DeleteByName(ctx context.Context, name string) (*Sensor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sensors := s.byName()
+	sensor, ok := sensors[name]
+	if !ok {
+		return nil, &MissingResourceError{
+			ID:           name,
+			ResourceType: "sensor",
+		}
+	}
+	delete(sensors, name)
+
+	return sensor, nil
+}
+
+// FindClosest returns the sensors within radiusMeters, nearest first.
+// Every sensor in the tenant is checked, so this suits small stores.
+func (s *MemorySensorStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type result struct {
+		sensor   *Sensor
+		distance float64
+	}
+	var results []result
+	for _, sensor := range s.byTenant[s.tenantID] {
+		distance := geodesicDistance(lat, lon, sensor.Lat, sensor.Lon)
+		if distance <= float64(radiusMeters) {
+			results = append(results, result{sensor, distance})
+		}
+	}
+
+	// Sort by distance, then ID, so that results are consistent
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].distance != results[j].distance {
+			return results[i].distance < results[j].distance
+		}
+		return results[i].sensor.ID < results[j].sensor.ID
+	})
+	sensors := make([]*Sensor, len(results))
+	for i, r := range results {
+		sensors[i] = r.sensor
+	}
+
+	return sensors, nil
+}
+
+func (s *MemorySensorStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	for _, sensor := range s.byTenant[s.tenantID] {
+		if sensor.Lat >= minLat && sensor.Lat <= maxLat && sensor.Lon >= minLon && sensor.Lon <= maxLon {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *MemorySensorStore) CountByTenant(ctx context.Context) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := make(map[string]int)
+	for tenantID, sensors := range s.byTenant {
+		if len(sensors) > 0 {
+			counts[tenantID] = len(sensors)
+		}
+	}
+
+	return counts, nil
 }
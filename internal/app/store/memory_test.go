@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -17,9 +18,17 @@ func TestCreate(t *testing.T) {
 	}
 
 	// Create the sensor resource
-	createdSensor, err := store.Create(sensor)
+	createdSensor, err := store.Create(context.Background(), sensor)
 	require.NoError(t, err)
 	assert.Same(t, sensor, createdSensor)
+
+	// Names are unique, so the existing sensor isn't replaced
+	_, err = store.Create(context.Background(), &Sensor{Name: "abc123", Lat: 30, Lon: 40})
+	require.IsType(t, &DuplicateResourceError{}, err)
+	require.EqualError(t, err, "a sensor resource already exists: abc123")
+	found, err := store.GetByName(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Same(t, sensor, found)
 }
 
 func TestGetByName(t *testing.T) {
@@ -33,11 +42,11 @@ func TestGetByName(t *testing.T) {
 	}
 
 	// Create the sensor resource
-	_, err := store.Create(sensor)
+	_, err := store.Create(context.Background(), sensor)
 	require.NoError(t, err)
 
 	// Retrieve the sensor by name
-	retrievedSensor, err := store.GetByName("abc123")
+	retrievedSensor, err := store.GetByName(context.Background(), "abc123")
 	require.NoError(t, err)
 	assert.Same(t, sensor, retrievedSensor)
 }
@@ -53,7 +62,7 @@ func TestUpdate(t *testing.T) {
 	}
 
 	// Create the sensor resource
-	_, err := store.Create(sensor)
+	_, err := store.Create(context.Background(), sensor)
 	require.NoError(t, err)
 
 	// Update the sensor
@@ -63,7 +72,7 @@ func TestUpdate(t *testing.T) {
 		Lon:  7,
 		Tags: []string{"x", "y"},
 	}
-	updatedSensor, err := store.UpdateByName("abc123", newSensor)
+	updatedSensor, err := store.UpdateByName(context.Background(), "abc123", newSensor)
 	require.NoError(t, err)
 	require.Same(t, newSensor, updatedSensor)
 }
@@ -78,9 +87,157 @@ func TestUpdateMissing(t *testing.T) {
 		Lon:  7,
 		Tags: []string{"x", "y"},
 	}
-	updatedSensor, err := store.UpdateByName("abc123", newSensor)
+	updatedSensor, err := store.UpdateByName(context.Background(), "abc123", newSensor)
 	require.Nil(t, updatedSensor)
 	require.NotNil(t, err)
 	require.IsType(t, err, &MissingResourceError{})
 	require.Equal(t, "no sensor resource exists: abc123", err.Error())
 }
+
+func TestCountWithinBounds(t *testing.T) {
+	store := NewMemorySensorStore()
+	for _, sensor := range []*Sensor{
+		{Name: "STP", Lat: 44.95, Lon: -93.09},
+		{Name: "MPLS", Lat: 44.97, Lon: -93.27},
+		{Name: "CHI", Lat: 41.86, Lon: -87.68},
+	} {
+		_, err := store.Create(context.Background(), sensor)
+		require.NoError(t, err)
+	}
+
+	count, err := store.CountWithinBounds(context.Background(), 44.8, -93.4, 45.1, -92.9)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestList(t *testing.T) {
+	store := NewMemorySensorStore()
+	for _, name := range []string{"STP", "MPLS", "CHI", "abc"} {
+		_, err := store.Create(context.Background(), &Sensor{Name: name})
+		require.NoError(t, err)
+	}
+	names := func(sensors []*Sensor) []string {
+		names := make([]string, len(sensors))
+		for i, sensor := range sensors {
+			names[i] = sensor.Name
+		}
+		return names
+	}
+
+	// Sensors are listed in order of their names, one page at a time
+	sensors, err := store.List(context.Background(), "", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CHI", "MPLS", "STP"}, names(sensors))
+
+	sensors, err = store.List(context.Background(), "STP", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, names(sensors))
+
+	sensors, err = store.List(context.Background(), "abc", 3)
+	require.NoError(t, err)
+	assert.Empty(t, sensors)
+
+	// Tenants only list their own sensors
+	sensors, err = store.ForTenant("acme").List(context.Background(), "", 3)
+	require.NoError(t, err)
+	assert.Empty(t, sensors)
+}
+
+func TestDelete(t *testing.T) {
+	store := NewMemorySensorStore()
+	sensor, err := store.Create(context.Background(), &Sensor{Name: "abc123", Lat: 10, Lon: 20})
+	require.NoError(t, err)
+
+	deleted, err := store.DeleteByName(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Same(t, sensor, deleted)
+
+	retrievedSensor, err := store.GetByName(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Nil(t, retrievedSensor)
+
+	// Missing sensors can't be deleted
+	_, err = store.DeleteByName(context.Background(), "abc123")
+	require.IsType(t, &MissingResourceError{}, err)
+}
+
+func TestForTenant(t *testing.T) {
+	store := NewMemorySensorStore()
+	acme := store.ForTenant("acme")
+	globex := store.ForTenant("globex")
+
+	// Sensor names are unique per tenant
+	acmeSensor, err := acme.Create(context.Background(), &Sensor{Name: "abc123", Lat: 10, Lon: 20})
+	require.NoError(t, err)
+	globexSensor, err := globex.Create(context.Background(), &Sensor{Name: "abc123", Lat: 30, Lon: 40})
+	require.NoError(t, err)
+
+	retrievedSensor, err := acme.GetByName(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Same(t, acmeSensor, retrievedSensor)
+
+	retrievedSensor, err = globex.GetByName(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Same(t, globexSensor, retrievedSensor)
+
+	// Tenants cannot see each other's sensors
+	retrievedSensor, err = store.GetByName(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Nil(t, retrievedSensor)
+
+	_, err = store.UpdateByName(context.Background(), "abc123", &Sensor{Name: "abc123"})
+	require.IsType(t, &MissingResourceError{}, err)
+
+	count, err := acme.CountWithinBounds(context.Background(), 0, 0, 50, 50)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestUpdateRename(t *testing.T) {
+	store := NewMemorySensorStore()
+	created, err := store.Create(context.Background(), &Sensor{Name: "abc123", Lat: 10, Lon: 20})
+	require.NoError(t, err)
+	require.Equal(t, 1, created.ID)
+
+	// Renamed sensors keep their ID
+	updated, err := store.UpdateByName(context.Background(), "abc123", &Sensor{Name: "xyz789", Lat: 10, Lon: 20})
+	require.NoError(t, err)
+	require.Equal(t, 1, updated.ID)
+
+	sensor, err := store.GetByName(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Nil(t, sensor)
+	sensor, err = store.GetByName(context.Background(), "xyz789")
+	require.NoError(t, err)
+	assert.Same(t, updated, sensor)
+
+	// Can't rename a sensor to the name of another sensor
+	_, err = store.Create(context.Background(), &Sensor{Name: "abc123", Lat: 10, Lon: 20})
+	require.NoError(t, err)
+	_, err = store.UpdateByName(context.Background(), "abc123", &Sensor{Name: "xyz789", Lat: 10, Lon: 20})
+	require.IsType(t, &DuplicateResourceError{}, err)
+	require.EqualError(t, err, "a sensor resource already exists: xyz789")
+}
+
+func TestFindClosest(t *testing.T) {
+	store := NewMemorySensorStore()
+	for _, sensor := range []*Sensor{
+		{Name: "STP", Lat: 44.9558833427991, Lon: -93.09844267331863},
+		{Name: "MPLS", Lat: 44.97620767775624, Lon: -93.27360528040553},
+		{Name: "CHI", Lat: 41.86950364771445, Lon: -87.68055283399988},
+	} {
+		_, err := store.Create(context.Background(), sensor)
+		require.NoError(t, err)
+	}
+
+	// Find locations within 100km of S. Minneapolis, nearest first
+	sensors, err := store.FindClosest(context.Background(), 44.91016213524799, -93.22412239250284, 100e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 2)
+	require.Equal(t, "MPLS", sensors[0].Name)
+	require.Equal(t, "STP", sensors[1].Name)
+
+	sensors, err = store.FindClosest(context.Background(), 0, 0, 100e3)
+	require.NoError(t, err)
+	require.Empty(t, sensors)
+}
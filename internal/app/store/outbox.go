@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Event types written to the outbox
+const (
+	SensorCreatedEvent = "sensor.created"
+	SensorUpdatedEvent = "sensor.updated"
+	// The event's sensor is the sensor as it was before it was deleted
+	SensorDeletedEvent = "sensor.deleted"
+)
+
+// OutboxEvent records a change to a sensor.
+// Events are written to the outbox in the same transaction as the change itself,
+// so that an event exists if (and only if) the change was committed.
+type OutboxEvent struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	// Tenant which owns the sensor
+	TenantID string `json:"tenant_id"`
+	// SensorID is used to order events for a single sensor.
+	// Unlike the sensor name, it does not change when a sensor is renamed.
+	SensorID  int       `json:"sensor_id"`
+	Sensor    *Sensor   `json:"sensor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxStore provides access to events which have not yet been published
+type OutboxStore interface {
+	// LockOutbox claims the outbox for one relay at a time, so that events are published once, and in order,
+	// when every instance of the API runs a relay. Returns false if another relay holds the lock.
+	// Call unlock once the claimed events have been published.
+	LockOutbox(ctx context.Context) (unlock func(), ok bool, err error)
+	// PendingEvents returns unpublished events, oldest first, except for events of the skipped sensors
+	PendingEvents(ctx context.Context, limit int, skipSensorIDs []int) ([]*OutboxEvent, error)
+	// MarkPublished flags events as published, so they are not returned by PendingEvents
+	MarkPublished(ctx context.Context, ids []int64) error
+}
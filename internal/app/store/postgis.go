@@ -3,118 +3,233 @@ package store
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/cridenour/go-postgis"
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
+	"log/slog"
+	"strconv"
 	"strings"
 )
 
 type PostgisStore struct {
 	db *sql.DB
+	// Tenant which owns the sensors accessed through this store
+	tenantID string
+	// Whether to set the app.tenant_id setting used by row-level security policies
+	rowLevelSecurity bool
 }
 
-func NewPostgisStore(dbUrl string) (*PostgisStore, error) {
+// PostgisOption configures a PostgisStore
+type PostgisOption func(store *PostgisStore)
+
+// WithRowLevelSecurity runs every sensor query in a transaction
+// with the app.tenant_id setting set to the store's tenant,
+// for use with the row-level security policies in scripts/db-rls.sql.
+// Queries are filtered by tenant either way: the policies are a second line of defence.
+func WithRowLevelSecurity() PostgisOption {
+	return func(store *PostgisStore) {
+		store.rowLevelSecurity = true
+	}
+}
+
+func NewPostgisStore(dbUrl string, opts ...PostgisOption) (*PostgisStore, error) {
 	db, err := sql.Open("postgres", dbUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PostgisStore{
-		db: db,
-	}, nil
+	store := &PostgisStore{
+		db:       db,
+		tenantID: DefaultTenant,
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	return store, nil
+}
+
+// ForTenant returns a view of the store, scoped to the given tenant.
+// The view shares the database connection pool with the original store.
+func (store *PostgisStore) ForTenant(tenantID string) SensorStore {
+	scoped := *store
+	scoped.tenantID = tenantID
+	return &scoped
 }
 
-func (store *PostgisStore) Create(sensor *Sensor) (*Sensor, error) {
+func (store *PostgisStore) Create(ctx context.Context, sensor *Sensor) (*Sensor, error) {
 	// Begin the DB transaction
-	tx, err := store.db.BeginTx(context.Background(), nil)
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, store.queryError(ctx, "Create", err)
+	}
 	defer tx.Rollback()
+	q := tracedQuerier{tx, "postgresql"}
 
 	// Insert the sensor record
 	createSql := `
-		INSERT INTO sensors (name, location) 
+		INSERT INTO sensors (tenant_id, name, location, place_name) 
 		-- see https://postgis.net/docs/ST_MakePoint.html
-		--VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326))
-		VALUES ($1, GeomFromEWKB($2))
+		--VALUES ($1, ST_SetSRID(ST_MakePoint($3, $4), 4326))
+		VALUES ($1, $2, GeomFromEWKB($3), NULLIF($4, ''))
 		RETURNING id;
 	`
 	var id int
-	err = tx.QueryRow(createSql, sensor.Name, newGisPoint(sensor.Lat, sensor.Lon)).
+	err = q.QueryRowContext(ctx, createSql, store.tenantID, sensor.Name, newGisPoint(sensor.Lat, sensor.Lon), sensor.PlaceName).
 		Scan(&id)
+	if isPostgresUniqueViolation(err) {
+		return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+	}
 	if err != nil {
-		return nil, err
+		return nil, store.queryError(ctx, "Create", err)
 	}
 
 	// Update the sensor ID
 	sensor.ID = id
 
 	// Insert tags
-	err = store.createSensorTags(sensor.ID, sensor.Tags, tx)
+	err = store.createSensorTags(ctx, sensor.ID, sensor.Tags, q)
 	if err != nil {
-		return nil, err
+		return nil, store.queryError(ctx, "Create", err)
+	}
+
+	// Record the change in the outbox, as part of the same transaction
+	err = store.createOutboxEvent(ctx, SensorCreatedEvent, sensor, q)
+	if err != nil {
+		return nil, store.queryError(ctx, "Create", err)
 	}
 
 	// Commit the transaction.
 	if err = tx.Commit(); err != nil {
-		return nil, err
+		return nil, store.queryError(ctx, "Create", err)
 	}
 
 	return sensor, nil
 }
 
-func (store *PostgisStore) GetByName(name string) (*Sensor, error) {
+func (store *PostgisStore) GetByName(ctx context.Context, name string) (*Sensor, error) {
 	sql := `
 		SELECT 
 			sensors.id, 
 			sensors.location,
+			COALESCE(sensors.place_name, ''),
 			-- Join in tags, as a nested array
 			array_remove(array_agg(tags.value), NULL) as tags
 		FROM sensors
 		LEFT JOIN tags on sensors.id = tags.sensor_id
-		WHERE sensors.name = $1
+		WHERE sensors.tenant_id = $1 AND sensors.name = $2
 		GROUP BY sensors.id
 	`
 	var id int
 	location := newGisPoint(0, 0)
+	var placeName string
 	var tags pq.StringArray
-	err := store.db.QueryRow(sql, name).
-		Scan(&id, &location, &tags)
+	err := store.query(ctx, func(q querier) error {
+		return q.QueryRowContext(ctx, sql, store.tenantID, name).
+			Scan(&id, &location, &placeName, &tags)
+	})
 	if err != nil {
 		// We want to return nil if there are no matches
 		// sql lib does not have typed errors, so we need to match on a string here
 		if err.Error() == "sql: no rows in result set" {
 			return nil, nil
 		}
-		return nil, err
+		return nil, store.queryError(ctx, "GetByName", err)
 	}
 
 	return &Sensor{
-		ID:   id,
-		Name: name,
-		Lon:  location.X,
-		Lat:  location.Y,
-		Tags: tags,
+		ID:        id,
+		Name:      name,
+		Lon:       location.X,
+		Lat:       location.Y,
+		Tags:      tags,
+		PlaceName: placeName,
 	}, nil
 }
 
-func (store *PostgisStore) UpdateByName(name string, sensor *Sensor) (*Sensor, error) {
+func (store *PostgisStore) List(ctx context.Context, after string, limit int) ([]*Sensor, error) {
+	var sensors []*Sensor
+	err := store.query(ctx, func(q querier) error {
+		rows, err := q.QueryContext(ctx, `
+			SELECT
+				sensors.id,
+				sensors.name,
+				sensors.location,
+				COALESCE(sensors.place_name, ''),
+				-- Join in tags, as a nested array
+				array_remove(array_agg(tags.value), NULL) as tags
+			FROM sensors
+			LEFT JOIN tags on sensors.id = tags.sensor_id
+			-- Order by byte values, like the other stores, regardless of the database's collation
+			WHERE sensors.tenant_id = $1 AND sensors.name COLLATE "C" > $2
+			GROUP BY sensors.id
+			ORDER BY sensors.name COLLATE "C"
+			LIMIT $3
+		`, store.tenantID, after, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int
+			var name string
+			var placeName string
+			var tags pq.StringArray
+			location := newGisPoint(0, 0)
+			if err := rows.Scan(&id, &name, &location, &placeName, &tags); err != nil {
+				return err
+			}
+
+			sensors = append(sensors, &Sensor{
+				ID:        id,
+				Name:      name,
+				Lon:       location.X,
+				Lat:       location.Y,
+				Tags:      tags,
+				PlaceName: placeName,
+			})
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, store.queryError(ctx, "List", err)
+	}
+
+	return sensors, nil
+}
+
+func (store *PostgisStore) UpdateByName(ctx context.Context, name string, sensor *Sensor) (*Sensor, error) {
 	// Begin the DB transaction
-	tx, err := store.db.BeginTx(context.Background(), nil)
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
+	}
 	defer tx.Rollback()
+	q := tracedQuerier{tx, "postgresql"}
 
 	var id int
-	err = tx.QueryRow(`
+	err = q.QueryRowContext(ctx, `
 		UPDATE sensors
-		SET name = $2, location = GeomFromEWKB($3)
-		WHERE name = $1
+		SET name = $3, location = GeomFromEWKB($4), place_name = NULLIF($5, '')
+		WHERE tenant_id = $1 AND name = $2
 		RETURNING id
-	`, name, sensor.Name, newGisPoint(sensor.Lat, sensor.Lon)).Scan(&id)
+	`, store.tenantID, name, sensor.Name, newGisPoint(sensor.Lat, sensor.Lon), sensor.PlaceName).Scan(&id)
 	if err != nil {
 		// Handle no match errors
 		if err.Error() == "sql: no rows in result set" {
-			return nil, fmt.Errorf("failed to update sensor: no sensor exists with name \"%s\"", name)
+			return nil, &MissingResourceError{ID: name, ResourceType: "sensor"}
 		}
-		return nil, err
+		// The sensor was renamed to the name of another sensor
+		if isPostgresUniqueViolation(err) {
+			return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+		}
+		return nil, store.queryError(ctx, "UpdateByName", err)
 	}
 
 	sensor.ID = id
@@ -122,80 +237,455 @@ func (store *PostgisStore) UpdateByName(name string, sensor *Sensor) (*Sensor, e
 	// Replace all the tags
 	// TODO: There's probably a way to do this that avoids unnecessary deletion
 	// Delete all the tags....
-	_, err = store.db.Exec(`
+	_, err = q.ExecContext(ctx, `
 		DELETE FROM tags
 		WHERE sensor_id = $1
 	`, sensor.ID)
 	if err != nil {
-		return nil, err
+		return nil, store.queryError(ctx, "UpdateByName", err)
 	}
 	// ...then recreate them all
-	if err := store.createSensorTags(sensor.ID, sensor.Tags, tx); err != nil {
-		return nil, err
+	if err := store.createSensorTags(ctx, sensor.ID, sensor.Tags, q); err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
+	}
+
+	// Record the change in the outbox, as part of the same transaction
+	if err := store.createOutboxEvent(ctx, SensorUpdatedEvent, sensor, q); err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
 	}
 
 	// Commit the transaction.
 	if err = tx.Commit(); err != nil {
-		return nil, err
+		return nil, store.queryError(ctx, "UpdateByName", err)
 	}
 
 	return sensor, nil
 }
 
-func (store *PostgisStore) FindClosest(lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
-	// Query DB for closest sensors
-	rows, err := store.db.Query(`
-		SELECT 
-			sensors.id, 
-			sensors.name,
+func (store *PostgisStore) DeleteByName(ctx context.Context, name string) (*Sensor, error) {
+	// Begin the DB transaction
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+	defer tx.Rollback()
+	q := tracedQuerier{tx, "postgresql"}
+
+	// Lock the sensor, and read it for the outbox event
+	var id int
+	location := newGisPoint(0, 0)
+	var placeName string
+	var tags pq.StringArray
+	err = q.QueryRowContext(ctx, `
+		SELECT
+			sensors.id,
 			sensors.location,
-			-- Join in tags, as a nested array
-			array_remove(array_agg(tags.value), NULL) as tags,
-			ST_Distance(sensors.location::geography, GeomFromEWKB($1)::geography) as distance
+			COALESCE(sensors.place_name, ''),
+			ARRAY(SELECT tags.value FROM tags WHERE tags.sensor_id = sensors.id)
 		FROM sensors
-		LEFT JOIN tags on sensors.id = tags.sensor_id
-		-- find within radius
-		WHERE ST_DWithin(sensors.location::geography, GeomFromEWKB($1)::geography, $2)
-		GROUP BY sensors.id
-		-- sort by distance
-		ORDER BY ST_Distance(sensors.location::geography, GeomFromEWKB($1)::geography);
-	`, newGisPoint(lat, lon), radiusMeters)
+		WHERE sensors.tenant_id = $1 AND sensors.name = $2
+		FOR UPDATE
+	`, store.tenantID, name).Scan(&id, &location, &placeName, &tags)
 	if err != nil {
-		return []*Sensor{}, err
+		if err.Error() == "sql: no rows in result set" {
+			return nil, &MissingResourceError{ID: name, ResourceType: "sensor"}
+		}
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+	sensor := &Sensor{
+		ID:        id,
+		Name:      name,
+		Lon:       location.X,
+		Lat:       location.Y,
+		Tags:      tags,
+		PlaceName: placeName,
 	}
-	defer rows.Close()
 
-	// Iterate through results, to create slice of Sensors
+	// Delete the tags, then the sensor
+	if _, err := q.ExecContext(ctx, `DELETE FROM tags WHERE sensor_id = $1`, id); err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+	if _, err := q.ExecContext(ctx, `DELETE FROM sensors WHERE id = $1`, id); err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+
+	// Record the change in the outbox, as part of the same transaction
+	if err := store.createOutboxEvent(ctx, SensorDeletedEvent, sensor, q); err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+
+	return sensor, nil
+}
+
+func (store *PostgisStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
 	var sensors []*Sensor
+	err := store.query(ctx, func(q querier) error {
+		// Query DB for closest sensors
+		rows, err := q.QueryContext(ctx, `
+			SELECT 
+				sensors.id, 
+				sensors.name,
+				sensors.location,
+				COALESCE(sensors.place_name, ''),
+				-- Join in tags, as a nested array
+				array_remove(array_agg(tags.value), NULL) as tags,
+				ST_Distance(sensors.location::geography, GeomFromEWKB($2)::geography) as distance
+			FROM sensors
+			LEFT JOIN tags on sensors.id = tags.sensor_id
+			-- find within radius
+			WHERE sensors.tenant_id = $1
+				AND ST_DWithin(sensors.location::geography, GeomFromEWKB($2)::geography, $3)
+			GROUP BY sensors.id
+			-- sort by distance
+			ORDER BY ST_Distance(sensors.location::geography, GeomFromEWKB($2)::geography);
+		`, store.tenantID, newGisPoint(lat, lon), radiusMeters)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// Iterate through results, to create slice of Sensors
+		for rows.Next() {
+			// hydrate values from DB row
+			var id int
+			var name string
+			var placeName string
+			var tags pq.StringArray
+			var distance float64
+			location := newGisPoint(0, 0)
+			if err := rows.Scan(&id, &name, &location, &placeName, &tags, &distance); err != nil {
+				return nil
+			}
+
+			// Create a sensor for db row data
+			sensors = append(sensors, &Sensor{
+				ID:        id,
+				Name:      name,
+				Lon:       location.X,
+				Lat:       location.Y,
+				Tags:      tags,
+				PlaceName: placeName,
+			})
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return []*Sensor{}, store.queryError(ctx, "FindClosest", err)
+	}
+
+	return sensors, nil
+}
+
+func (store *PostgisStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	var count int
+	err := store.query(ctx, func(q querier) error {
+		return q.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM sensors
+			-- see https://postgis.net/docs/ST_MakeEnvelope.html
+			WHERE sensors.tenant_id = $1
+				AND sensors.location && ST_MakeEnvelope($2, $3, $4, $5, 4326)
+		`, store.tenantID, minLon, minLat, maxLon, maxLat).Scan(&count)
+	})
+	if err != nil {
+		return 0, store.queryError(ctx, "CountWithinBounds", err)
+	}
+
+	return count, nil
+}
+
+// CountByTenant counts sensors in every tenant.
+// With row-level security, this requires a database role which bypasses the policies.
+func (store *PostgisStore) CountByTenant(ctx context.Context) (map[string]int, error) {
+	rows, err := store.db.QueryContext(ctx, `
+		SELECT tenant_id, COUNT(*)
+		FROM sensors
+		GROUP BY tenant_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tenantID string
+		var count int
+		if err := rows.Scan(&tenantID, &count); err != nil {
+			return nil, err
+		}
+		counts[tenantID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// Stats returns database connection pool statistics
+func (store *PostgisStore) Stats() sql.DBStats {
+	return store.db.Stats()
+}
+
+// outboxLockID is the key of the advisory lock held by the relay which is publishing the outbox
+const outboxLockID = 8_453_201
+
+func (store *PostgisStore) LockOutbox(ctx context.Context) (func(), bool, error) {
+	// Advisory locks belong to a session, so the lock is held on a dedicated connection
+	conn, err := store.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, outboxLockID).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, outboxLockID)
+		if err != nil {
+			// Discard the connection (which releases the lock), rather than returning it to the pool still locked
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}
+	return unlock, true, nil
+}
+
+func (store *PostgisStore) PendingEvents(ctx context.Context, limit int, skipSensorIDs []int) ([]*OutboxEvent, error) {
+	skipped := make([]int64, len(skipSensorIDs))
+	for i, sensorID := range skipSensorIDs {
+		skipped[i] = int64(sensorID)
+	}
+	rows, err := store.db.QueryContext(ctx, `
+		SELECT id, event_type, tenant_id, sensor_id, payload, created_at
+		FROM outbox
+		WHERE published_at IS NULL AND NOT (sensor_id = ANY($2))
+		ORDER BY id
+		LIMIT $1
+	`, limit, pq.Array(skipped))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Type, &event.TenantID, &event.SensorID, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &event.Sensor); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox event %d: %w", event.ID, err)
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+func (store *PostgisStore) MarkPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := store.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET published_at = now()
+		WHERE id = ANY($1)
+	`, pq.Array(ids))
+	return err
+}
+
+func (store *PostgisStore) CreateAPIKey(ctx context.Context, key *APIKey) (*APIKey, error) {
+	err := store.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (tenant_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, key.TenantID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes)).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (store *PostgisStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	key, err := scanAPIKey(store.db.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE prefix = $1
+	`, prefix))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return key, err
+}
+
+func (store *PostgisStore) ListAPIKeys(ctx context.Context, tenantID string) ([]*APIKey, error) {
+	rows, err := store.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE tenant_id = $1
+		ORDER BY id
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
 	for rows.Next() {
-		// hydrate values from DB row
-		var id int
-		var name string
-		var tags pq.StringArray
-		var distance float64
-		location := newGisPoint(0, 0)
-		if err := rows.Scan(&id, &name, &location, &tags, &distance); err != nil {
-			return sensors, nil
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
 		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (store *PostgisStore) RotateAPIKey(ctx context.Context, tenantID string, id int64, prefix string, hash []byte) (*APIKey, error) {
+	key, err := scanAPIKey(store.db.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET prefix = $3, key_hash = $4
+		WHERE tenant_id = $1 AND id = $2 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns,
+		tenantID, id, prefix, hash))
+	if err == sql.ErrNoRows {
+		return nil, &MissingResourceError{ID: strconv.FormatInt(id, 10), ResourceType: "api key"}
+	}
+
+	return key, err
+}
+
+func (store *PostgisStore) RevokeAPIKey(ctx context.Context, tenantID string, id int64) (*APIKey, error) {
+	key, err := scanAPIKey(store.db.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, now())
+		WHERE tenant_id = $1 AND id = $2
+		RETURNING `+apiKeyColumns,
+		tenantID, id))
+	if err == sql.ErrNoRows {
+		return nil, &MissingResourceError{ID: strconv.FormatInt(id, 10), ResourceType: "api key"}
+	}
 
-		// Create a sensor for db row data
-		sensors = append(sensors, &Sensor{
-			ID:   id,
-			Name: name,
-			Lon:  location.X,
-			Lat:  location.Y,
-			Tags: tags,
-		})
+	return key, err
+}
+
+// SchemaVersion is the version of scripts/db-init.sql which the store requires
+const SchemaVersion = 1
+
+// Ping checks that the database is reachable
+func (store *PostgisStore) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+// CheckPostGIS checks that the PostGIS extension is installed
+func (store *PostgisStore) CheckPostGIS(ctx context.Context) error {
+	var version string
+	err := store.db.QueryRowContext(ctx, `SELECT extversion FROM pg_extension WHERE extname = 'postgis'`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("the postgis extension is not installed")
 	}
+	return err
+}
 
-	return sensors, rows.Err()
+// CheckSchemaVersion checks that the database schema is the version the store requires
+func (store *PostgisStore) CheckSchemaVersion(ctx context.Context) error {
+	var version int
+	err := store.db.QueryRowContext(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("the schema_version table is empty")
+	}
+	if err != nil {
+		return err
+	}
+	if version != SchemaVersion {
+		return fmt.Errorf("schema version is %d, expected %d", version, SchemaVersion)
+	}
+	return nil
 }
 
 func (store *PostgisStore) Close() error {
 	return store.db.Close()
 }
 
-func (store *PostgisStore) createSensorTags(sensorId int, tags []string, tx *sql.Tx) error {
+// queryError logs a failed query, including the request ID from the context, and returns the error
+func (store *PostgisStore) queryError(ctx context.Context, method string, err error) error {
+	slog.ErrorContext(ctx, "sensor store query failed",
+		"store", "postgis",
+		"method", method,
+		"tenant_id", store.tenantID,
+		"error", err,
+	)
+	return err
+}
+
+// isPostgresUniqueViolation checks if a query failed because of a unique constraint,
+// eg. sensor names, which are unique per tenant
+func isPostgresUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// querier runs SQL queries, against either the DB or a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// query runs read-only queries for the store's tenant, recording a span for each statement.
+// With row-level security, the queries need a transaction to carry the tenant setting.
+func (store *PostgisStore) query(ctx context.Context, fn func(q querier) error) error {
+	if !store.rowLevelSecurity {
+		return fn(tracedQuerier{store.db, "postgresql"})
+	}
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tracedQuerier{tx, "postgresql"}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// beginTx starts a transaction for the store's tenant
+func (store *PostgisStore) beginTx(ctx context.Context) (*sql.Tx, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if store.rowLevelSecurity {
+		// SET LOCAL does not accept bind parameters, so use the equivalent set_config()
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('app.tenant_id', $1, true)`, store.tenantID); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return tx, nil
+}
+
+func (store *PostgisStore) createSensorTags(ctx context.Context, sensorId int, tags []string, q querier) error {
 	if len(tags) == 0 {
 		return nil
 	}
@@ -213,10 +703,39 @@ func (store *PostgisStore) createSensorTags(sensorId int, tags []string, tx *sql
 	}
 	tagSql += strings.Join(tagValuesSqls, ", ")
 
-	_, err := tx.Exec(tagSql, tagSqlArgs...)
+	_, err := q.ExecContext(ctx, tagSql, tagSqlArgs...)
 	return err
 }
 
+func (store *PostgisStore) createOutboxEvent(ctx context.Context, eventType string, sensor *Sensor, q querier) error {
+	payload, err := json.Marshal(sensor)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox event: %w", err)
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO outbox (event_type, tenant_id, sensor_id, payload)
+		VALUES ($1, $2, $3, $4)
+	`, eventType, store.tenantID, sensor.ID, payload)
+	return err
+}
+
+// Columns read by scanAPIKey
+const apiKeyColumns = "id, tenant_id, name, prefix, key_hash, scopes, created_at, revoked_at"
+
+// scanAPIKey reads an api_keys row, selected with apiKeyColumns
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*APIKey, error) {
+	var key APIKey
+	var scopes pq.StringArray
+	err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = scopes
+
+	return &key, nil
+}
+
 func newGisPoint(lat, lon float64) *postgis.PointS {
 	return &postgis.PointS{SRID: 4326, X: lon, Y: lat}
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"github.com/stretchr/testify/require"
 	"os"
@@ -14,6 +15,8 @@ func resetDb(t *testing.T, dbUrl string) {
 	_, err = db.Exec(`
 		TRUNCATE sensors CASCADE;
 		TRUNCATE tags;
+		TRUNCATE outbox;
+		TRUNCATE api_keys;
 	`)
 	require.NoError(t, err)
 }
@@ -43,7 +46,7 @@ func TestPostgisStore_CreateAndGetByName(t *testing.T) {
 	defer cleanup()
 
 	// Create a sensor
-	sensor, err := store.Create(&Sensor{
+	sensor, err := store.Create(context.Background(), &Sensor{
 		Name: "sensor-abc",
 		Lat:  45.123456,
 		Lon:  -90.98765,
@@ -61,7 +64,7 @@ func TestPostgisStore_CreateAndGetByName(t *testing.T) {
 	require.NotEqual(t, 0, sensor.ID)
 
 	// Retrieve the created sensor
-	sensor, err = store.GetByName("sensor-abc")
+	sensor, err = store.GetByName(context.Background(), "sensor-abc")
 	require.NoError(t, err)
 	require.Equal(t, &Sensor{
 		ID:   sensor.ID,
@@ -78,7 +81,7 @@ func TestPostgisStore_CreateAndGetByNameNoTags(t *testing.T) {
 	defer cleanup()
 
 	// Create a sensor with no tags
-	sensor, err := store.Create(&Sensor{
+	sensor, err := store.Create(context.Background(), &Sensor{
 		Name: "sensor-abc",
 		Lat:  45.123456,
 		Lon:  -90.98765,
@@ -94,7 +97,7 @@ func TestPostgisStore_CreateAndGetByNameNoTags(t *testing.T) {
 	require.NotEqual(t, 0, sensor.ID)
 
 	// Retrieve the created sensor
-	sensor, err = store.GetByName("sensor-abc")
+	sensor, err = store.GetByName(context.Background(), "sensor-abc")
 	require.NoError(t, err)
 	require.Equal(t, &Sensor{
 		ID:   sensor.ID,
@@ -111,7 +114,7 @@ func TestPostgisStore_GetByNameMissing(t *testing.T) {
 	defer cleanup()
 
 	// Retrieve a sensor that doesn't exist
-	sensor, err := store.GetByName("not-a-sensor")
+	sensor, err := store.GetByName(context.Background(), "not-a-sensor")
 	require.NoError(t, err)
 	require.Nil(t, sensor)
 }
@@ -121,7 +124,7 @@ func TestPostgisStore_UpdateByName(t *testing.T) {
 	defer cleanup()
 
 	// Create a sensor
-	_, err := store.Create(&Sensor{
+	_, err := store.Create(context.Background(), &Sensor{
 		Name: "sensor-abc",
 		Lat:  45.123456,
 		Lon:  -90.98765,
@@ -130,7 +133,7 @@ func TestPostgisStore_UpdateByName(t *testing.T) {
 	require.NoError(t, err)
 
 	// Update the sensor
-	sensor, err := store.UpdateByName("sensor-abc", &Sensor{
+	sensor, err := store.UpdateByName(context.Background(), "sensor-abc", &Sensor{
 		Name: "sensor-xyz",
 		Lat:  -36.8779565276809,
 		Lon:  174.7881226266269744,
@@ -139,7 +142,7 @@ func TestPostgisStore_UpdateByName(t *testing.T) {
 	require.NoError(t, err)
 
 	// Retrieve the updated sensor
-	sensor, err = store.GetByName("sensor-xyz")
+	sensor, err = store.GetByName(context.Background(), "sensor-xyz")
 	require.NoError(t, err)
 	require.Equal(t, &Sensor{
 		ID:   sensor.ID,
@@ -149,6 +152,15 @@ func TestPostgisStore_UpdateByName(t *testing.T) {
 		Tags: []string{"x", "y", "z"},
 	}, sensor)
 	require.NotEqual(t, 0, sensor.ID)
+
+	// Names are unique, when creating or renaming sensors
+	_, err = store.Create(context.Background(), &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.IsType(t, &DuplicateResourceError{}, err)
+	require.EqualError(t, err, "a sensor resource already exists: sensor-xyz")
+	_, err = store.Create(context.Background(), &Sensor{Name: "sensor-def", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	_, err = store.UpdateByName(context.Background(), "sensor-def", &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.IsType(t, &DuplicateResourceError{}, err)
 }
 
 func TestPostgisStore_UpdateMissing(t *testing.T) {
@@ -156,14 +168,14 @@ func TestPostgisStore_UpdateMissing(t *testing.T) {
 	defer cleanup()
 
 	// Update a sensor that does not exist
-	_, err := store.UpdateByName("sensor-xyz", &Sensor{
+	_, err := store.UpdateByName(context.Background(), "sensor-xyz", &Sensor{
 		Name: "sensor-xyz",
 		Lat:  -36.8779565276809,
 		Lon:  174.7881226266269744,
 		Tags: []string{"x", "y", "z"},
 	})
-	require.Error(t, err)
-	require.Equal(t, "failed to update sensor: no sensor exists with name \"sensor-xyz\"", err.Error())
+	require.IsType(t, &MissingResourceError{}, err)
+	require.EqualError(t, err, "no sensor resource exists: sensor-xyz")
 }
 
 func TestNewPostgisStore_FindClosest(t *testing.T) {
@@ -180,12 +192,12 @@ func TestNewPostgisStore_FindClosest(t *testing.T) {
 		{Name: "CHI", Lat: 41.86950364771445, Lon: -87.68055283399988},
 	}
 	for _, sensor := range testSensors {
-		_, err := store.Create(sensor)
+		_, err := store.Create(context.Background(), sensor)
 		require.NoError(t, err)
 	}
 
 	// Find locations within 100km of S. Minneapolis
-	sensors, err := store.FindClosest(44.91016213524799, -93.22412239250284, 100e3)
+	sensors, err := store.FindClosest(context.Background(), 44.91016213524799, -93.22412239250284, 100e3)
 	require.NoError(t, err)
 	require.Len(t, sensors, 2)
 	require.Equal(t, Sensor{
@@ -209,9 +221,295 @@ func TestNewPostgisStore_FindClosestNoResults(t *testing.T) {
 	defer cleanup()
 
 	// Find closest locations, when none exist
-	sensors, err := store.FindClosest(44.91016213524799, -93.22412239250284, 100e3)
+	sensors, err := store.FindClosest(context.Background(), 44.91016213524799, -93.22412239250284, 100e3)
 	require.NoError(t, err)
 
 	// Should return an empty slice
 	require.Len(t, sensors, 0)
 }
+
+func TestPostgisStore_CountWithinBounds(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+
+	for _, sensor := range []*Sensor{
+		// St. Paul, MN
+		{Name: "STP", Lat: 44.9558833427991, Lon: -93.09844267331863},
+		// Minneapolis, MN (downtown)
+		{Name: "MPLS", Lat: 44.97620767775624, Lon: -93.27360528040553},
+		// Chicago, IL
+		{Name: "CHI", Lat: 41.86950364771445, Lon: -87.68055283399988},
+	} {
+		_, err := store.Create(context.Background(), sensor)
+		require.NoError(t, err)
+	}
+
+	// Count sensors in the Twin Cities
+	count, err := store.CountWithinBounds(context.Background(), 44.8, -93.4, 45.1, -92.9)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// Count sensors in the middle of the ocean
+	count, err = store.CountWithinBounds(context.Background(), -1, -1, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestPostgisStore_PlaceName(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+
+	// Create a sensor with a place name
+	_, err := store.Create(context.Background(), &Sensor{
+		Name:      "sensor-abc",
+		Lat:       44.97,
+		Lon:       -93.26,
+		PlaceName: "Minneapolis, Minnesota, United States",
+	})
+	require.NoError(t, err)
+
+	sensor, err := store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, "Minneapolis, Minnesota, United States", sensor.PlaceName)
+
+	// Clear the place name
+	_, err = store.UpdateByName(context.Background(), "sensor-abc", &Sensor{
+		Name: "sensor-abc",
+		Lat:  44.97,
+		Lon:  -93.26,
+	})
+	require.NoError(t, err)
+
+	sensor, err = store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, "", sensor.PlaceName)
+}
+
+func TestPostgisStore_Outbox(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+
+	// Create, then update a sensor
+	created, err := store.Create(context.Background(), &Sensor{
+		Name: "sensor-abc",
+		Lat:  45.123456,
+		Lon:  -90.98765,
+		Tags: []string{"a"},
+	})
+	require.NoError(t, err)
+	_, err = store.UpdateByName(context.Background(), "sensor-abc", &Sensor{
+		Name: "sensor-xyz",
+		Lat:  45.123456,
+		Lon:  -90.98765,
+		Tags: []string{"b"},
+	})
+	require.NoError(t, err)
+
+	// Both changes should be pending in the outbox, in order
+	events, err := store.PendingEvents(context.Background(), 10, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, SensorCreatedEvent, events[0].Type)
+	require.Equal(t, DefaultTenant, events[0].TenantID)
+	require.Equal(t, created.ID, events[0].SensorID)
+	require.Equal(t, "sensor-abc", events[0].Sensor.Name)
+	require.Equal(t, SensorUpdatedEvent, events[1].Type)
+	require.Equal(t, created.ID, events[1].SensorID)
+	require.Equal(t, "sensor-xyz", events[1].Sensor.Name)
+
+	// Published events should no longer be pending
+	err = store.MarkPublished(context.Background(), []int64{events[0].ID})
+	require.NoError(t, err)
+	events, err = store.PendingEvents(context.Background(), 10, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, SensorUpdatedEvent, events[0].Type)
+
+	// Events of skipped sensors are not returned
+	events, err = store.PendingEvents(context.Background(), 10, []int{created.ID})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestPostgisStore_LockOutbox(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+	other, err := NewPostgisStore(os.Getenv("TEST_DATABASE_URL"))
+	require.NoError(t, err)
+	defer other.Close()
+
+	// Only one relay may hold the lock at a time
+	unlock, ok, err := store.LockOutbox(context.Background())
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = other.LockOutbox(context.Background())
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// The lock is available again once it is released
+	unlock()
+	unlock, ok, err = other.LockOutbox(context.Background())
+	require.NoError(t, err)
+	require.True(t, ok)
+	unlock()
+}
+
+func TestPostgisStore_APIKeys(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	key, err := store.CreateAPIKey(ctx, &APIKey{
+		TenantID: "acme",
+		Name:     "ingest",
+		Prefix:   "sk_0123456789abcdef",
+		Hash:     []byte("hash"),
+		Scopes:   []string{"sensors:read", "sensors:write"},
+	})
+	require.NoError(t, err)
+	require.NotZero(t, key.ID)
+	require.False(t, key.CreatedAt.IsZero())
+
+	// Lookup the key by prefix
+	found, err := store.GetAPIKeyByPrefix(ctx, "sk_0123456789abcdef")
+	require.NoError(t, err)
+	require.Equal(t, key.ID, found.ID)
+	require.Equal(t, "acme", found.TenantID)
+	require.Equal(t, []byte("hash"), found.Hash)
+	require.Equal(t, []string{"sensors:read", "sensors:write"}, found.Scopes)
+	require.Nil(t, found.RevokedAt)
+
+	// Keys can only be managed within their tenant
+	_, err = store.RotateAPIKey(ctx, "globex", key.ID, "sk_fedcba9876543210", []byte("new-hash"))
+	var missingErr *MissingResourceError
+	require.ErrorAs(t, err, &missingErr)
+	keys, err := store.ListAPIKeys(ctx, "globex")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// Rotate the key
+	_, err = store.RotateAPIKey(ctx, "acme", key.ID, "sk_fedcba9876543210", []byte("new-hash"))
+	require.NoError(t, err)
+	found, err = store.GetAPIKeyByPrefix(ctx, "sk_0123456789abcdef")
+	require.NoError(t, err)
+	require.Nil(t, found)
+	found, err = store.GetAPIKeyByPrefix(ctx, "sk_fedcba9876543210")
+	require.NoError(t, err)
+	require.Equal(t, []byte("new-hash"), found.Hash)
+
+	// Revoke the key
+	revoked, err := store.RevokeAPIKey(ctx, "acme", key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+
+	// Revoked keys can't be rotated
+	_, err = store.RotateAPIKey(ctx, "acme", key.ID, "sk_0000000000000000", []byte("hash"))
+	require.ErrorAs(t, err, &missingErr)
+
+	keys, err = store.ListAPIKeys(ctx, "acme")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].RevokedAt)
+
+	// Missing keys
+	_, err = store.RevokeAPIKey(ctx, "acme", key.ID+1)
+	require.ErrorAs(t, err, &missingErr)
+}
+
+func TestPostgisStore_DeleteByName(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+	created, err := store.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"a"}})
+	require.NoError(t, err)
+
+	deleted, err := store.DeleteByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, created.ID, deleted.ID)
+	require.Equal(t, []string{"a"}, deleted.Tags)
+
+	found, err := store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	// The deletion is recorded in the outbox, with the deleted sensor
+	events, err := store.PendingEvents(context.Background(), 10, nil)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, SensorDeletedEvent, events[1].Type)
+	require.Equal(t, created.ID, events[1].SensorID)
+	require.Equal(t, "sensor-abc", events[1].Sensor.Name)
+
+	_, err = store.DeleteByName(context.Background(), "sensor-abc")
+	require.IsType(t, &MissingResourceError{}, err)
+}
+
+func TestPostgisStore_List(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+	for _, name := range []string{"STP", "MPLS", "CHI", "abc"} {
+		_, err := store.Create(context.Background(), &Sensor{Name: name, Lat: 1, Lon: 2, Tags: []string{name}})
+		require.NoError(t, err)
+	}
+	_, err := store.ForTenant("acme").Create(context.Background(), &Sensor{Name: "BOS", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+
+	// Sensors are listed in order of their names, one page at a time
+	sensors, err := store.List(context.Background(), "", 3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 3)
+	require.Equal(t, "CHI", sensors[0].Name)
+	require.Equal(t, []string{"CHI"}, sensors[0].Tags)
+	require.Equal(t, "MPLS", sensors[1].Name)
+	require.Equal(t, "STP", sensors[2].Name)
+
+	sensors, err = store.List(context.Background(), "STP", 3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	require.Equal(t, "abc", sensors[0].Name)
+}
+
+func TestPostgisStore_ForTenant(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+	acme := store.ForTenant("acme")
+	globex := store.ForTenant("globex")
+
+	// Sensor names are unique per tenant
+	_, err := acme.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"acme"}})
+	require.NoError(t, err)
+	_, err = globex.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.97, Lon: -93.27, Tags: []string{"globex"}})
+	require.NoError(t, err)
+	_, err = acme.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09})
+	require.Error(t, err)
+
+	sensor, err := acme.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, []string{"acme"}, sensor.Tags)
+
+	// Tenants cannot see each other's sensors
+	sensor, err = store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Nil(t, sensor)
+
+	sensors, err := globex.FindClosest(context.Background(), 44.95, -93.09, 50000)
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	require.Equal(t, []string{"globex"}, sensors[0].Tags)
+
+	count, err := acme.CountWithinBounds(context.Background(), 44.8, -93.4, 45.1, -92.9)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	_, err = store.UpdateByName(context.Background(), "sensor-abc", &Sensor{Name: "sensor-abc"})
+	require.Error(t, err)
+}
+
+func TestPostgisStore_HealthChecks(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, store.Ping(ctx))
+	require.NoError(t, store.CheckPostGIS(ctx))
+	require.NoError(t, store.CheckSchemaVersion(ctx))
+}
@@ -0,0 +1,579 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteStore is a SensorStore in a SQLite database file, for when running PostGIS isn't practical
+// (eg. on edge devices, or for local development). It also stores API keys.
+//
+// Sensor locations are indexed with an R*Tree, which narrows FindClosest and CountWithinBounds
+// to a bounding box. FindClosest then calculates exact geodesic distances, matching PostGIS's geography type.
+type SQLiteStore struct {
+	db *sql.DB
+	// Tenant which owns the sensors accessed through this store
+	tenantID string
+}
+
+// sqliteSchema creates the tables, if they don't exist. See scripts/db-init.sql for the PostGIS equivalent.
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS sensors (
+		id INTEGER PRIMARY KEY,
+		tenant_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		lat REAL NOT NULL,
+		lon REAL NOT NULL,
+		place_name TEXT,
+		UNIQUE (tenant_id, name)  -- sensor names are unique per tenant
+	);
+
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY,
+		sensor_id INTEGER NOT NULL REFERENCES sensors ON DELETE CASCADE,
+		value TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS tags_sensor_idx ON tags (sensor_id);
+
+	-- Spatial index of sensor locations, with the same id as the sensor.
+	-- R*Tree coordinates are 32-bit floats, rounded outwards, so queries must also check the exact location.
+	CREATE VIRTUAL TABLE IF NOT EXISTS sensors_rtree USING rtree(id, min_lat, max_lat, min_lon, max_lon);
+	CREATE TRIGGER IF NOT EXISTS sensors_rtree_insert AFTER INSERT ON sensors BEGIN
+		INSERT INTO sensors_rtree VALUES (new.id, new.lat, new.lat, new.lon, new.lon);
+	END;
+	CREATE TRIGGER IF NOT EXISTS sensors_rtree_update AFTER UPDATE OF lat, lon ON sensors BEGIN
+		UPDATE sensors_rtree SET min_lat = new.lat, max_lat = new.lat, min_lon = new.lon, max_lon = new.lon
+		WHERE id = new.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS sensors_rtree_delete AFTER DELETE ON sensors BEGIN
+		DELETE FROM sensors_rtree WHERE id = old.id;
+	END;
+
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY,
+		tenant_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL UNIQUE,  -- non-secret start of the key, used for lookups
+		key_hash BLOB NOT NULL,
+		scopes TEXT NOT NULL,  -- JSON array
+		created_at TEXT NOT NULL,
+		revoked_at TEXT
+	);
+	CREATE INDEX IF NOT EXISTS api_keys_tenant_idx ON api_keys (tenant_id);
+
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL
+	);
+	INSERT INTO schema_version (version)
+	SELECT 1 WHERE NOT EXISTS (SELECT 1 FROM schema_version);
+`
+
+// NewSQLiteStore opens (or creates) the SQLite database file at path, and creates the tables if they don't exist.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, errors.New("must set the SQLite database file")
+	}
+
+	// Enforce foreign keys, and wait for locks rather than failing immediately.
+	// Write-ahead logging lets readers continue while a sensor is written.
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, so a single connection avoids SQLITE_BUSY errors
+	// when transactions upgrade from reading to writing
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %w", err)
+	}
+
+	return &SQLiteStore{
+		db:       db,
+		tenantID: DefaultTenant,
+	}, nil
+}
+
+// ForTenant returns a view of the store, scoped to the given tenant.
+// The view shares the database connection with the original store.
+func (store *SQLiteStore) ForTenant(tenantID string) SensorStore {
+	scoped := *store
+	scoped.tenantID = tenantID
+	return &scoped
+}
+
+func (store *SQLiteStore) Create(ctx context.Context, sensor *Sensor) (*Sensor, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, store.queryError(ctx, "Create", err)
+	}
+	defer tx.Rollback()
+	q := tracedQuerier{tx, "sqlite"}
+
+	var id int
+	err = q.QueryRowContext(ctx, `
+		INSERT INTO sensors (tenant_id, name, lat, lon, place_name)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''))
+		RETURNING id
+	`, store.tenantID, sensor.Name, sensor.Lat, sensor.Lon, sensor.PlaceName).Scan(&id)
+	if isSQLiteUniqueViolation(err) {
+		return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+	}
+	if err != nil {
+		return nil, store.queryError(ctx, "Create", err)
+	}
+	sensor.ID = id
+
+	if err := store.createSensorTags(ctx, sensor.ID, sensor.Tags, q); err != nil {
+		return nil, store.queryError(ctx, "Create", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, store.queryError(ctx, "Create", err)
+	}
+
+	return sensor, nil
+}
+
+func (store *SQLiteStore) GetByName(ctx context.Context, name string) (*Sensor, error) {
+	q := tracedQuerier{store.db, "sqlite"}
+	sensor, err := scanSQLiteSensor(q.QueryRowContext(ctx, `
+		SELECT `+sqliteSensorColumns+`
+		FROM sensors
+		WHERE sensors.tenant_id = ? AND sensors.name = ?
+	`, store.tenantID, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, store.queryError(ctx, "GetByName", err)
+	}
+
+	return sensor, nil
+}
+
+func (store *SQLiteStore) List(ctx context.Context, after string, limit int) ([]*Sensor, error) {
+	q := tracedQuerier{store.db, "sqlite"}
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+sqliteSensorColumns+`
+		FROM sensors
+		WHERE sensors.tenant_id = ? AND sensors.name > ?
+		ORDER BY sensors.name
+		LIMIT ?
+	`, store.tenantID, after, limit)
+	if err != nil {
+		return nil, store.queryError(ctx, "List", err)
+	}
+	defer rows.Close()
+
+	var sensors []*Sensor
+	for rows.Next() {
+		sensor, err := scanSQLiteSensor(rows)
+		if err != nil {
+			return nil, store.queryError(ctx, "List", err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, store.queryError(ctx, "List", err)
+	}
+
+	return sensors, nil
+}
+
+func (store *SQLiteStore) UpdateByName(ctx context.Context, name string, sensor *Sensor) (*Sensor, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
+	}
+	defer tx.Rollback()
+	q := tracedQuerier{tx, "sqlite"}
+
+	var id int
+	err = q.QueryRowContext(ctx, `
+		UPDATE sensors
+		SET name = ?, lat = ?, lon = ?, place_name = NULLIF(?, '')
+		WHERE tenant_id = ? AND name = ?
+		RETURNING id
+	`, sensor.Name, sensor.Lat, sensor.Lon, sensor.PlaceName, store.tenantID, name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &MissingResourceError{ID: name, ResourceType: "sensor"}
+	}
+	// The sensor was renamed to the name of another sensor
+	if isSQLiteUniqueViolation(err) {
+		return nil, &DuplicateResourceError{ID: sensor.Name, ResourceType: "sensor"}
+	}
+	if err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
+	}
+	sensor.ID = id
+
+	// Replace all the tags
+	if _, err := q.ExecContext(ctx, `DELETE FROM tags WHERE sensor_id = ?`, sensor.ID); err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
+	}
+	if err := store.createSensorTags(ctx, sensor.ID, sensor.Tags, q); err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, store.queryError(ctx, "UpdateByName", err)
+	}
+
+	return sensor, nil
+}
+
+func (store *SQLiteStore) DeleteByName(ctx context.Context, name string) (*Sensor, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+	defer tx.Rollback()
+	q := tracedQuerier{tx, "sqlite"}
+
+	sensor, err := scanSQLiteSensor(q.QueryRowContext(ctx, `
+		SELECT `+sqliteSensorColumns+`
+		FROM sensors
+		WHERE sensors.tenant_id = ? AND sensors.name = ?
+	`, store.tenantID, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &MissingResourceError{ID: name, ResourceType: "sensor"}
+	}
+	if err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+
+	// Tags and the spatial index entry are deleted with the sensor (by a foreign key and a trigger)
+	if _, err := q.ExecContext(ctx, `DELETE FROM sensors WHERE id = ?`, sensor.ID); err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, store.queryError(ctx, "DeleteByName", err)
+	}
+
+	return sensor, nil
+}
+
+// FindClosest returns the sensors within radiusMeters, nearest first.
+// Candidates are found with the R*Tree index, then filtered and sorted by their geodesic distance.
+func (store *SQLiteStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
+	type result struct {
+		sensor   *Sensor
+		distance float64
+	}
+	var results []result
+	q := tracedQuerier{store.db, "sqlite"}
+	for _, box := range boundingBoxes(lat, lon, float64(radiusMeters)) {
+		sensors, err := store.withinBox(ctx, q, box)
+		if err != nil {
+			return []*Sensor{}, store.queryError(ctx, "FindClosest", err)
+		}
+		for _, sensor := range sensors {
+			distance := geodesicDistance(lat, lon, sensor.Lat, sensor.Lon)
+			if distance <= float64(radiusMeters) {
+				results = append(results, result{sensor, distance})
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].distance < results[j].distance
+	})
+	sensors := make([]*Sensor, len(results))
+	for i, r := range results {
+		sensors[i] = r.sensor
+	}
+
+	return sensors, nil
+}
+
+func (store *SQLiteStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	var count int
+	q := tracedQuerier{store.db, "sqlite"}
+	err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM sensors_rtree
+		JOIN sensors ON sensors.id = sensors_rtree.id
+		WHERE sensors_rtree.max_lat >= ? AND sensors_rtree.min_lat <= ?
+			AND sensors_rtree.max_lon >= ? AND sensors_rtree.min_lon <= ?
+			-- R*Tree bounds are approximate
+			AND sensors.lat BETWEEN ? AND ?
+			AND sensors.lon BETWEEN ? AND ?
+			AND sensors.tenant_id = ?
+	`, minLat, maxLat, minLon, maxLon, minLat, maxLat, minLon, maxLon, store.tenantID).Scan(&count)
+	if err != nil {
+		return 0, store.queryError(ctx, "CountWithinBounds", err)
+	}
+
+	return count, nil
+}
+
+// CountByTenant counts sensors in every tenant
+func (store *SQLiteStore) CountByTenant(ctx context.Context) (map[string]int, error) {
+	rows, err := store.db.QueryContext(ctx, `
+		SELECT tenant_id, COUNT(*)
+		FROM sensors
+		GROUP BY tenant_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tenantID string
+		var count int
+		if err := rows.Scan(&tenantID, &count); err != nil {
+			return nil, err
+		}
+		counts[tenantID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// Stats returns database connection pool statistics
+func (store *SQLiteStore) Stats() sql.DBStats {
+	return store.db.Stats()
+}
+
+func (store *SQLiteStore) CreateAPIKey(ctx context.Context, key *APIKey) (*APIKey, error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	key.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	err = store.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (tenant_id, name, prefix, key_hash, scopes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, key.TenantID, key.Name, key.Prefix, key.Hash, string(scopes), formatSQLiteTime(key.CreatedAt)).Scan(&key.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (store *SQLiteStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	key, err := scanSQLiteAPIKey(store.db.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE prefix = ?
+	`, prefix))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return key, err
+}
+
+func (store *SQLiteStore) ListAPIKeys(ctx context.Context, tenantID string) ([]*APIKey, error) {
+	rows, err := store.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE tenant_id = ?
+		ORDER BY id
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		key, err := scanSQLiteAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (store *SQLiteStore) RotateAPIKey(ctx context.Context, tenantID string, id int64, prefix string, hash []byte) (*APIKey, error) {
+	key, err := scanSQLiteAPIKey(store.db.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET prefix = ?, key_hash = ?
+		WHERE tenant_id = ? AND id = ? AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns,
+		prefix, hash, tenantID, id))
+	if err == sql.ErrNoRows {
+		return nil, &MissingResourceError{ID: strconv.FormatInt(id, 10), ResourceType: "api key"}
+	}
+
+	return key, err
+}
+
+func (store *SQLiteStore) RevokeAPIKey(ctx context.Context, tenantID string, id int64) (*APIKey, error) {
+	key, err := scanSQLiteAPIKey(store.db.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, ?)
+		WHERE tenant_id = ? AND id = ?
+		RETURNING `+apiKeyColumns,
+		formatSQLiteTime(time.Now()), tenantID, id))
+	if err == sql.ErrNoRows {
+		return nil, &MissingResourceError{ID: strconv.FormatInt(id, 10), ResourceType: "api key"}
+	}
+
+	return key, err
+}
+
+// Ping checks that the database is reachable
+func (store *SQLiteStore) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+// CheckSchemaVersion checks that the database schema is the version the store requires
+func (store *SQLiteStore) CheckSchemaVersion(ctx context.Context) error {
+	var version int
+	err := store.db.QueryRowContext(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("the schema_version table is empty")
+	}
+	if err != nil {
+		return err
+	}
+	if version != SchemaVersion {
+		return fmt.Errorf("schema version is %d, expected %d", version, SchemaVersion)
+	}
+	return nil
+}
+
+func (store *SQLiteStore) Close() error {
+	return store.db.Close()
+}
+
+// queryError logs a failed query, including the request ID from the context, and returns the error
+func (store *SQLiteStore) queryError(ctx context.Context, method string, err error) error {
+	slog.ErrorContext(ctx, "sensor store query failed",
+		"store", "sqlite",
+		"method", method,
+		"tenant_id", store.tenantID,
+		"error", err,
+	)
+	return err
+}
+
+// isSQLiteUniqueViolation checks if a query failed because of a unique constraint,
+// eg. sensor names, which are unique per tenant
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// withinBox returns the tenant's sensors within a bounding box
+func (store *SQLiteStore) withinBox(ctx context.Context, q querier, box boundingBox) ([]*Sensor, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+sqliteSensorColumns+`
+		FROM sensors_rtree
+		JOIN sensors ON sensors.id = sensors_rtree.id
+		WHERE sensors_rtree.max_lat >= ? AND sensors_rtree.min_lat <= ?
+			AND sensors_rtree.max_lon >= ? AND sensors_rtree.min_lon <= ?
+			AND sensors.tenant_id = ?
+	`, box.minLat, box.maxLat, box.minLon, box.maxLon, store.tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sensors []*Sensor
+	for rows.Next() {
+		sensor, err := scanSQLiteSensor(rows)
+		if err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, sensor)
+	}
+
+	return sensors, rows.Err()
+}
+
+func (store *SQLiteStore) createSensorTags(ctx context.Context, sensorId int, tags []string, q querier) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	// Insert a row for every tag
+	placeholders := strings.TrimSuffix(strings.Repeat("(?, ?), ", len(tags)), ", ")
+	args := make([]any, 0, len(tags)*2)
+	for _, tag := range tags {
+		args = append(args, sensorId, tag)
+	}
+
+	_, err := q.ExecContext(ctx, `INSERT INTO tags (sensor_id, value) VALUES `+placeholders, args...)
+	return err
+}
+
+// Columns read by scanSQLiteSensor. Tags are aggregated as a JSON array, in the order they were added.
+const sqliteSensorColumns = `
+	sensors.id,
+	sensors.name,
+	sensors.lat,
+	sensors.lon,
+	COALESCE(sensors.place_name, ''),
+	(SELECT json_group_array(value) FROM (SELECT value FROM tags WHERE tags.sensor_id = sensors.id ORDER BY tags.id))`
+
+// scanSQLiteSensor reads a sensors row, selected with sqliteSensorColumns
+func scanSQLiteSensor(row interface{ Scan(dest ...any) error }) (*Sensor, error) {
+	var sensor Sensor
+	var tags string
+	if err := row.Scan(&sensor.ID, &sensor.Name, &sensor.Lat, &sensor.Lon, &sensor.PlaceName, &tags); err != nil {
+		return nil, err
+	}
+	sensor.Tags = []string{}
+	if err := json.Unmarshal([]byte(tags), &sensor.Tags); err != nil {
+		return nil, fmt.Errorf("failed to decode tags of sensor %d: %w", sensor.ID, err)
+	}
+
+	return &sensor, nil
+}
+
+// scanSQLiteAPIKey reads an api_keys row, selected with apiKeyColumns
+func scanSQLiteAPIKey(row interface{ Scan(dest ...any) error }) (*APIKey, error) {
+	var key APIKey
+	var scopes string
+	var createdAt string
+	var revokedAt sql.NullString
+	err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Prefix, &key.Hash, &scopes, &createdAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to decode scopes of api key %d: %w", key.ID, err)
+	}
+	if key.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, revokedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		key.RevokedAt = &t
+	}
+
+	return &key, nil
+}
+
+// formatSQLiteTime formats a timestamp, for a TEXT column. SQLite has no timestamp type.
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
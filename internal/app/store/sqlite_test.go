@@ -0,0 +1,390 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func sqliteTestSetup(t *testing.T) *SQLiteStore {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "sensors.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func TestSQLiteStore_CreateAndGetByName(t *testing.T) {
+	store := sqliteTestSetup(t)
+
+	// Create a sensor
+	sensor, err := store.Create(context.Background(), &Sensor{
+		Name: "sensor-abc",
+		Lat:  45.123456,
+		Lon:  -90.98765,
+		Tags: []string{"c", "a", "b"},
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, 0, sensor.ID)
+
+	// Retrieve the created sensor. Tags keep their order.
+	found, err := store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, &Sensor{
+		ID:   sensor.ID,
+		Name: "sensor-abc",
+		Lat:  45.123456,
+		Lon:  -90.98765,
+		Tags: []string{"c", "a", "b"},
+	}, found)
+
+	// Sensors without tags have an empty list of tags
+	_, err = store.Create(context.Background(), &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	found, err = store.GetByName(context.Background(), "sensor-xyz")
+	require.NoError(t, err)
+	require.Equal(t, []string{}, found.Tags)
+
+	// Names are unique
+	_, err = store.Create(context.Background(), &Sensor{Name: "sensor-xyz", Lat: 3, Lon: 4})
+	require.IsType(t, &DuplicateResourceError{}, err)
+	require.EqualError(t, err, "a sensor resource already exists: sensor-xyz")
+
+	// Missing sensors
+	found, err = store.GetByName(context.Background(), "not-a-sensor")
+	require.NoError(t, err)
+	require.Nil(t, found)
+}
+
+func TestSQLiteStore_UpdateByName(t *testing.T) {
+	store := sqliteTestSetup(t)
+
+	_, err := store.Create(context.Background(), &Sensor{
+		Name:      "sensor-abc",
+		Lat:       45.123456,
+		Lon:       -90.98765,
+		Tags:      []string{"a", "b", "c"},
+		PlaceName: "Wisconsin, United States",
+	})
+	require.NoError(t, err)
+
+	// Update the sensor, moving it to Auckland, and clearing its place name
+	_, err = store.UpdateByName(context.Background(), "sensor-abc", &Sensor{
+		Name: "sensor-xyz",
+		Lat:  -36.8779565276809,
+		Lon:  174.7881226266269744,
+		Tags: []string{"x", "y", "z"},
+	})
+	require.NoError(t, err)
+
+	sensor, err := store.GetByName(context.Background(), "sensor-xyz")
+	require.NoError(t, err)
+	require.Equal(t, &Sensor{
+		ID:   sensor.ID,
+		Name: "sensor-xyz",
+		Lat:  -36.8779565276809,
+		Lon:  174.7881226266269744,
+		Tags: []string{"x", "y", "z"},
+	}, sensor)
+
+	// The spatial index follows the sensor
+	sensors, err := store.FindClosest(context.Background(), -36.88, 174.79, 1000)
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	sensors, err = store.FindClosest(context.Background(), 45.123456, -90.98765, 1000)
+	require.NoError(t, err)
+	require.Empty(t, sensors)
+
+	// Update a sensor that does not exist
+	_, err = store.UpdateByName(context.Background(), "sensor-abc", &Sensor{Name: "sensor-abc"})
+	require.IsType(t, &MissingResourceError{}, err)
+	require.EqualError(t, err, "no sensor resource exists: sensor-abc")
+
+	// Rename a sensor to the name of another sensor
+	_, err = store.Create(context.Background(), &Sensor{Name: "sensor-def", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	_, err = store.UpdateByName(context.Background(), "sensor-def", &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.IsType(t, &DuplicateResourceError{}, err)
+	require.EqualError(t, err, "a sensor resource already exists: sensor-xyz")
+}
+
+func TestSQLiteStore_FindClosest(t *testing.T) {
+	store := sqliteTestSetup(t)
+
+	for _, sensor := range []*Sensor{
+		// St. Paul, MN
+		{Name: "STP", Lat: 44.9558833427991, Lon: -93.09844267331863, Tags: []string{"east"}},
+		// Minneapolis, MN (downtown)
+		{Name: "MPLS", Lat: 44.97620767775624, Lon: -93.27360528040553},
+		// Chicago, IL
+		{Name: "CHI", Lat: 41.86950364771445, Lon: -87.68055283399988},
+	} {
+		_, err := store.Create(context.Background(), sensor)
+		require.NoError(t, err)
+	}
+
+	// Find locations within 100km of S. Minneapolis, nearest first
+	sensors, err := store.FindClosest(context.Background(), 44.91016213524799, -93.22412239250284, 100e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 2)
+	require.Equal(t, Sensor{
+		ID:   sensors[0].ID,
+		Name: "MPLS",
+		Lat:  44.97620767775624,
+		Lon:  -93.27360528040553,
+		Tags: []string{},
+	}, *sensors[0])
+	require.Equal(t, Sensor{
+		ID:   sensors[1].ID,
+		Name: "STP",
+		Lat:  44.9558833427991,
+		Lon:  -93.09844267331863,
+		Tags: []string{"east"},
+	}, *sensors[1])
+
+	// Chicago is ~570km away
+	sensors, err = store.FindClosest(context.Background(), 44.91016213524799, -93.22412239250284, 560e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 2)
+	sensors, err = store.FindClosest(context.Background(), 44.91016213524799, -93.22412239250284, 580e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 3)
+
+	// No results
+	sensors, err = store.FindClosest(context.Background(), 0, 0, 100e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 0)
+}
+
+func TestSQLiteStore_FindClosestAntimeridianAndPoles(t *testing.T) {
+	store := sqliteTestSetup(t)
+
+	for _, sensor := range []*Sensor{
+		// Either side of the antimeridian, in Fiji
+		{Name: "west", Lat: -17.5, Lon: 179.9},
+		{Name: "east", Lat: -17.5, Lon: -179.9},
+		// Near the north pole, on opposite sides
+		{Name: "arctic-1", Lat: 89.9, Lon: 0},
+		{Name: "arctic-2", Lat: 89.9, Lon: 180},
+	} {
+		_, err := store.Create(context.Background(), sensor)
+		require.NoError(t, err)
+	}
+
+	sensors, err := store.FindClosest(context.Background(), -17.5, 179.95, 20e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 2)
+
+	// The arctic sensors are ~22km apart, across the pole
+	sensors, err = store.FindClosest(context.Background(), 89.9, 0, 25e3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 2)
+	require.Equal(t, "arctic-1", sensors[0].Name)
+	require.Equal(t, "arctic-2", sensors[1].Name)
+}
+
+func TestSQLiteStore_CountWithinBounds(t *testing.T) {
+	store := sqliteTestSetup(t)
+
+	for _, sensor := range []*Sensor{
+		{Name: "STP", Lat: 44.9558833427991, Lon: -93.09844267331863},
+		{Name: "MPLS", Lat: 44.97620767775624, Lon: -93.27360528040553},
+		{Name: "CHI", Lat: 41.86950364771445, Lon: -87.68055283399988},
+	} {
+		_, err := store.Create(context.Background(), sensor)
+		require.NoError(t, err)
+	}
+
+	// Count sensors in the Twin Cities
+	count, err := store.CountWithinBounds(context.Background(), 44.8, -93.4, 45.1, -92.9)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// Bounds are exact, though the R*Tree's coordinates are rounded
+	count, err = store.CountWithinBounds(context.Background(), 44.8, -93.4, 45.1, -93.273606)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	count, err = store.CountWithinBounds(context.Background(), -1, -1, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestSQLiteStore_DeleteByName(t *testing.T) {
+	store := sqliteTestSetup(t)
+	created, err := store.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"a", "b"}})
+	require.NoError(t, err)
+
+	deleted, err := store.DeleteByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, &Sensor{ID: created.ID, Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"a", "b"}}, deleted)
+
+	// The sensor, its tags and its spatial index entry are deleted
+	found, err := store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Nil(t, found)
+	sensors, err := store.FindClosest(context.Background(), 44.95, -93.09, 1000)
+	require.NoError(t, err)
+	require.Empty(t, sensors)
+	var count int
+	require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM tags`).Scan(&count))
+	require.Equal(t, 0, count)
+
+	// Missing sensors, and other tenants' sensors, can't be deleted
+	_, err = store.DeleteByName(context.Background(), "sensor-abc")
+	require.IsType(t, &MissingResourceError{}, err)
+	_, err = store.ForTenant("acme").Create(context.Background(), &Sensor{Name: "sensor-xyz", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+	_, err = store.DeleteByName(context.Background(), "sensor-xyz")
+	require.IsType(t, &MissingResourceError{}, err)
+}
+
+func TestSQLiteStore_List(t *testing.T) {
+	store := sqliteTestSetup(t)
+	for _, name := range []string{"STP", "MPLS", "CHI", "abc"} {
+		_, err := store.Create(context.Background(), &Sensor{Name: name, Lat: 1, Lon: 2, Tags: []string{name}})
+		require.NoError(t, err)
+	}
+	_, err := store.ForTenant("acme").Create(context.Background(), &Sensor{Name: "BOS", Lat: 1, Lon: 2})
+	require.NoError(t, err)
+
+	// Sensors are listed in order of their names, one page at a time
+	sensors, err := store.List(context.Background(), "", 3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 3)
+	require.Equal(t, "CHI", sensors[0].Name)
+	require.Equal(t, []string{"CHI"}, sensors[0].Tags)
+	require.Equal(t, "MPLS", sensors[1].Name)
+	require.Equal(t, "STP", sensors[2].Name)
+
+	sensors, err = store.List(context.Background(), "STP", 3)
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	require.Equal(t, "abc", sensors[0].Name)
+
+	sensors, err = store.List(context.Background(), "abc", 3)
+	require.NoError(t, err)
+	require.Empty(t, sensors)
+}
+
+func TestSQLiteStore_ForTenant(t *testing.T) {
+	store := sqliteTestSetup(t)
+	acme := store.ForTenant("acme")
+	globex := store.ForTenant("globex")
+
+	// Sensor names are unique per tenant
+	_, err := acme.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"acme"}})
+	require.NoError(t, err)
+	_, err = globex.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.97, Lon: -93.27, Tags: []string{"globex"}})
+	require.NoError(t, err)
+	_, err = acme.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09})
+	require.Error(t, err)
+
+	sensor, err := acme.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, []string{"acme"}, sensor.Tags)
+
+	// Tenants cannot see each other's sensors
+	sensor, err = store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Nil(t, sensor)
+
+	sensors, err := globex.FindClosest(context.Background(), 44.95, -93.09, 50000)
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+	require.Equal(t, []string{"globex"}, sensors[0].Tags)
+
+	count, err := acme.CountWithinBounds(context.Background(), 44.8, -93.4, 45.1, -92.9)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	counts, err := store.CountByTenant(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"acme": 1, "globex": 1}, counts)
+}
+
+func TestSQLiteStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sensors.db")
+	store, err := NewSQLiteStore(path)
+	require.NoError(t, err)
+	_, err = store.Create(context.Background(), &Sensor{Name: "sensor-abc", Lat: 44.95, Lon: -93.09, Tags: []string{"a"}})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	// Sensors remain after reopening the database
+	store, err = NewSQLiteStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	sensor, err := store.GetByName(context.Background(), "sensor-abc")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, sensor.Tags)
+	sensors, err := store.FindClosest(context.Background(), 44.95, -93.09, 1000)
+	require.NoError(t, err)
+	require.Len(t, sensors, 1)
+
+	require.NoError(t, store.Ping(context.Background()))
+	require.NoError(t, store.CheckSchemaVersion(context.Background()))
+}
+
+func TestSQLiteStore_APIKeys(t *testing.T) {
+	store := sqliteTestSetup(t)
+	ctx := context.Background()
+
+	key, err := store.CreateAPIKey(ctx, &APIKey{
+		TenantID: "acme",
+		Name:     "ingest",
+		Prefix:   "sk_0123456789abcdef",
+		Hash:     []byte("hash"),
+		Scopes:   []string{"sensors:read", "sensors:write"},
+	})
+	require.NoError(t, err)
+	require.NotZero(t, key.ID)
+	require.False(t, key.CreatedAt.IsZero())
+
+	// Lookup the key by prefix
+	found, err := store.GetAPIKeyByPrefix(ctx, "sk_0123456789abcdef")
+	require.NoError(t, err)
+	require.Equal(t, key.ID, found.ID)
+	require.Equal(t, "acme", found.TenantID)
+	require.Equal(t, []byte("hash"), found.Hash)
+	require.Equal(t, []string{"sensors:read", "sensors:write"}, found.Scopes)
+	require.True(t, key.CreatedAt.Equal(found.CreatedAt))
+	require.Nil(t, found.RevokedAt)
+
+	// Keys can only be managed within their tenant
+	_, err = store.RotateAPIKey(ctx, "globex", key.ID, "sk_fedcba9876543210", []byte("new-hash"))
+	var missingErr *MissingResourceError
+	require.ErrorAs(t, err, &missingErr)
+	keys, err := store.ListAPIKeys(ctx, "globex")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// Rotate the key
+	_, err = store.RotateAPIKey(ctx, "acme", key.ID, "sk_fedcba9876543210", []byte("new-hash"))
+	require.NoError(t, err)
+	found, err = store.GetAPIKeyByPrefix(ctx, "sk_0123456789abcdef")
+	require.NoError(t, err)
+	require.Nil(t, found)
+	found, err = store.GetAPIKeyByPrefix(ctx, "sk_fedcba9876543210")
+	require.NoError(t, err)
+	require.Equal(t, []byte("new-hash"), found.Hash)
+
+	// Revoke the key
+	revoked, err := store.RevokeAPIKey(ctx, "acme", key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+
+	// Revoked keys can't be rotated
+	_, err = store.RotateAPIKey(ctx, "acme", key.ID, "sk_0000000000000000", []byte("hash"))
+	require.ErrorAs(t, err, &missingErr)
+
+	keys, err = store.ListAPIKeys(ctx, "acme")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].RevokedAt)
+
+	// Missing keys
+	_, err = store.RevokeAPIKey(ctx, "acme", key.ID+1)
+	require.ErrorAs(t, err, &missingErr)
+}
@@ -1,6 +1,9 @@
 package store
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type MissingResourceError struct {
 	ID           string
@@ -11,17 +14,56 @@ func (e *MissingResourceError) Error() string {
 	return fmt.Sprintf("no %s resource exists: %s", e.ResourceType, e.ID)
 }
 
+// DuplicateResourceError is returned when a resource can't be created (or renamed),
+// because another resource already has its name
+type DuplicateResourceError struct {
+	ID           string
+	ResourceType string
+}
+
+func (e *DuplicateResourceError) Error() string {
+	return fmt.Sprintf("a %s resource already exists: %s", e.ResourceType, e.ID)
+}
+
 type Sensor struct {
 	ID   int      `json:"id"`
 	Name string   `json:"name"`
 	Lat  float64  `json:"lat"`
 	Lon  float64  `json:"lon"`
 	Tags []string `json:"tags"`
+	// Human-readable address / locality of the sensor, if known
+	PlaceName string `json:"place_name,omitempty"`
 }
 
+// DefaultTenant owns sensors when authentication is disabled
+const DefaultTenant = "default"
+
+// SensorStore provides access to sensors.
+// Sensors belong to a tenant, and sensor names are only unique within a tenant.
+// Every query is scoped to the store's tenant (DefaultTenant, unless set with ForTenant).
 type SensorStore interface {
-	Create(sensor *Sensor) (*Sensor, error)
-	GetByName(name string) (*Sensor, error)
-	UpdateByName(name string, sensor *Sensor) (*Sensor, error)
-	FindClosest(lat float64, lon float64, radiusMeters int) ([]*Sensor, error)
+	// ForTenant returns a view of the store, scoped to the given tenant
+	ForTenant(tenantID string) SensorStore
+	// Create adds a sensor. Returns a *DuplicateResourceError if the tenant already has a sensor with the name.
+	Create(ctx context.Context, sensor *Sensor) (*Sensor, error)
+	GetByName(ctx context.Context, name string) (*Sensor, error)
+	// List returns up to limit sensors, ordered by name, whose names sort after the given name.
+	// Pass after = "" for the first page, and the last sensor's name for the following pages.
+	List(ctx context.Context, after string, limit int) ([]*Sensor, error)
+	// UpdateByName replaces the sensor with the given name. The sensor may be renamed.
+	// Returns a *MissingResourceError if there is no sensor with the name,
+	// or a *DuplicateResourceError if it is renamed to the name of another sensor.
+	UpdateByName(ctx context.Context, name string, sensor *Sensor) (*Sensor, error)
+	// DeleteByName removes a sensor, and returns it as it was before it was deleted.
+	// Returns a *MissingResourceError if there is no sensor with the name.
+	DeleteByName(ctx context.Context, name string) (*Sensor, error)
+	FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*Sensor, error)
+	// Counts sensors within a lat/lon bounding box
+	CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error)
+}
+
+// SensorCounter is implemented by stores which can count sensors across every tenant (eg. for metrics)
+type SensorCounter interface {
+	// CountByTenant returns the number of sensors in each tenant
+	CountByTenant(ctx context.Context) (map[string]int, error)
 }
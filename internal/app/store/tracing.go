@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"strings"
+)
+
+// NewTracedStore wraps a SensorStore, recording a span for each method.
+// dbSystem identifies the database, eg. "postgresql" (see the OpenTelemetry db.system attribute).
+func NewTracedStore(next SensorStore, dbSystem string) SensorStore {
+	return &tracedStore{next: next, dbSystem: dbSystem}
+}
+
+// tracedStore is a SensorStore decorator, which records a span for each method
+type tracedStore struct {
+	next     SensorStore
+	dbSystem string
+}
+
+func (s *tracedStore) ForTenant(tenantID string) SensorStore {
+	return &tracedStore{next: s.next.ForTenant(tenantID), dbSystem: s.dbSystem}
+}
+
+func (s *tracedStore) Create(ctx context.Context, sensor *Sensor) (*Sensor, error) {
+	ctx, span := s.start(ctx, "Create")
+	defer span.End()
+	created, err := s.next.Create(ctx, sensor)
+	span.RecordError(err)
+	return created, err
+}
+
+func (s *tracedStore) GetByName(ctx context.Context, name string) (*Sensor, error) {
+	ctx, span := s.start(ctx, "GetByName")
+	defer span.End()
+	sensor, err := s.next.GetByName(ctx, name)
+	span.RecordError(err)
+	return sensor, err
+}
+
+func (s *tracedStore) List(ctx context.Context, after string, limit int) ([]*Sensor, error) {
+	ctx, span := s.start(ctx, "List", tracing.Int("sensor.limit", limit))
+	defer span.End()
+	sensors, err := s.next.List(ctx, after, limit)
+	span.SetAttributes(tracing.Int("sensor.count", len(sensors)))
+	span.RecordError(err)
+	return sensors, err
+}
+
+func (s *tracedStore) UpdateByName(ctx context.Context, name string, sensor *Sensor) (*Sensor, error) {
+	ctx, span := s.start(ctx, "UpdateByName")
+	defer span.End()
+	updated, err := s.next.UpdateByName(ctx, name, sensor)
+	span.RecordError(err)
+	return updated, err
+}
+
+func (s *tracedStore) DeleteByName(ctx context.Context, name string) (*Sensor, error) {
+	ctx, span := s.start(ctx, "DeleteByName")
+	defer span.End()
+	deleted, err := s.next.DeleteByName(ctx, name)
+	span.RecordError(err)
+	return deleted, err
+}
+
+func (s *tracedStore) FindClosest(ctx context.Context, lat float64, lon float64, radiusMeters int) ([]*Sensor, error) {
+	ctx, span := s.start(ctx, "FindClosest",
+		tracing.Float64("sensor.lat", lat),
+		tracing.Float64("sensor.lon", lon),
+		tracing.Int("sensor.radius_meters", radiusMeters),
+	)
+	defer span.End()
+	sensors, err := s.next.FindClosest(ctx, lat, lon, radiusMeters)
+	span.SetAttributes(tracing.Int("sensor.count", len(sensors)))
+	span.RecordError(err)
+	return sensors, err
+}
+
+func (s *tracedStore) CountWithinBounds(ctx context.Context, minLat float64, minLon float64, maxLat float64, maxLon float64) (int, error) {
+	ctx, span := s.start(ctx, "CountWithinBounds")
+	defer span.End()
+	count, err := s.next.CountWithinBounds(ctx, minLat, minLon, maxLat, maxLon)
+	span.RecordError(err)
+	return count, err
+}
+
+func (s *tracedStore) start(ctx context.Context, method string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	attrs = append(attrs,
+		tracing.String("db.system", s.dbSystem),
+		tracing.String("code.function", method),
+	)
+	return tracing.Start(ctx, "SensorStore."+method, tracing.SpanKindInternal, attrs...)
+}
+
+// tracedQuerier records a client span for each SQL statement, with the statement as an attribute.
+// Statements are recorded with their bind parameters ($1, $2...), not the parameters' values.
+type tracedQuerier struct {
+	querier
+	// Database, for the db.system attribute, eg. "postgresql"
+	dbSystem string
+}
+
+func (q tracedQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatementSpan(ctx, q.dbSystem, query)
+	defer span.End()
+	result, err := q.querier.ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return result, err
+}
+
+func (q tracedQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatementSpan(ctx, q.dbSystem, query)
+	defer span.End()
+	rows, err := q.querier.QueryContext(ctx, query, args...)
+	span.RecordError(err)
+	return rows, err
+}
+
+// QueryRowContext's span ends before the row is scanned, so does not include errors (eg. sql.ErrNoRows)
+func (q tracedQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatementSpan(ctx, q.dbSystem, query)
+	defer span.End()
+	return q.querier.QueryRowContext(ctx, query, args...)
+}
+
+func startStatementSpan(ctx context.Context, dbSystem string, query string) (context.Context, *tracing.Span) {
+	statement := normalizeStatement(query)
+	operation, _, _ := strings.Cut(statement, " ")
+	operation = strings.ToUpper(operation)
+	return tracing.Start(ctx, operation, tracing.SpanKindClient,
+		tracing.String("db.system", dbSystem),
+		tracing.String("db.operation", operation),
+		tracing.String("db.statement", statement),
+	)
+}
+
+// normalizeStatement removes comments and collapses whitespace, so statements are readable in a single line
+func normalizeStatement(query string) string {
+	var lines []string
+	for _, line := range strings.Split(query, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+}
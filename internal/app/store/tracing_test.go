@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing/tracingtest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTracedStore(t *testing.T) {
+	recorder := tracingtest.Install(t)
+	store := NewTracedStore(NewMemorySensorStore(), "memory")
+	ctx, parent := tracing.Start(context.Background(), "request", tracing.SpanKindServer)
+
+	_, err := store.ForTenant("acme").Create(ctx, &Sensor{Name: "abc123"})
+	require.NoError(t, err)
+	_, err = store.FindClosest(ctx, 44.5, -93.2, 1000)
+	require.NoError(t, err)
+	// The sensor belongs to acme, so can't be updated in the default tenant
+	_, err = store.UpdateByName(ctx, "abc123", &Sensor{Name: "abc123"})
+	require.Error(t, err)
+	parent.End()
+
+	create := recorder.Span(t, "SensorStore.Create")
+	require.Equal(t, parent.SpanContext().SpanID, create.ParentSpanID)
+	require.Equal(t, "memory", tracingtest.Attribute(create, "db.system"))
+	require.Equal(t, tracing.StatusUnset, create.Status)
+
+	findClosest := recorder.Span(t, "SensorStore.FindClosest")
+	require.Equal(t, int64(1000), tracingtest.Attribute(findClosest, "sensor.radius_meters"))
+	require.Equal(t, int64(0), tracingtest.Attribute(findClosest, "sensor.count"))
+
+	update := recorder.Span(t, "SensorStore.UpdateByName")
+	require.Equal(t, tracing.StatusError, update.Status)
+}
+
+func TestPostgisStore_Tracing(t *testing.T) {
+	store, cleanup := testSetup(t)
+	defer cleanup()
+	recorder := tracingtest.Install(t)
+
+	_, err := NewTracedStore(store, "postgresql").GetByName(context.Background(), "abc123")
+	require.NoError(t, err)
+
+	// Each statement is recorded as a child of the store method's span
+	method := recorder.Span(t, "SensorStore.GetByName")
+	statement := recorder.Span(t, "SELECT")
+	require.Equal(t, method.SpanContext.SpanID, statement.ParentSpanID)
+	require.Equal(t, tracing.SpanKindClient, statement.Kind)
+	require.Equal(t, "postgresql", tracingtest.Attribute(statement, "db.system"))
+	require.Contains(t, tracingtest.Attribute(statement, "db.statement"), "FROM sensors LEFT JOIN tags on sensors.id = tags.sensor_id WHERE sensors.tenant_id = $1 AND sensors.name = $2")
+}
+
+func TestNormalizeStatement(t *testing.T) {
+	require.Equal(t, "SELECT id FROM sensors WHERE name = $1", normalizeStatement(`
+		SELECT id
+		-- find by name
+		FROM sensors
+		WHERE name = $1 -- unique within a tenant
+	`))
+}
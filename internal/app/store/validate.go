@@ -0,0 +1,126 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxSensorNameLength is the longest sensor name, in characters
+	MaxSensorNameLength = 64
+	// MaxSensorTags is the most tags a sensor may have
+	MaxSensorTags = 32
+	// MaxTagLength is the longest tag, in characters
+	MaxTagLength = 64
+)
+
+// Sensor names are used in URLs (eg. GET /sensors/{name}), so they're limited to characters which don't need escaping
+var sensorNameRegexp = regexp.MustCompile("^[A-Za-z0-9][A-Za-z0-9._~-]*$")
+
+// FieldError is an invalid field of a resource
+type FieldError struct {
+	// JSON pointer to the field (RFC 6901), eg. "/tags/0"
+	Pointer string `json:"pointer"`
+	// Why the field is invalid, eg. "must be between -90 and 90"
+	Detail string `json:"detail"`
+}
+
+// ValidationError lists every invalid field of a resource, so that they can all be fixed at once
+type ValidationError struct {
+	// Kind of resource which is invalid, eg. "sensor"
+	ResourceType string
+	Fields       []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = strings.TrimPrefix(field.Pointer, "/") + " " + field.Detail
+	}
+	return fmt.Sprintf("invalid %s: %s", e.ResourceType, strings.Join(fields, "; "))
+}
+
+// Validate checks the fields of a sensor submitted by a client (eg. to create or update it).
+// Names and tags are checked as Normalize will store them, ie. without surrounding whitespace.
+// If any fields are invalid, a *ValidationError is returned.
+func (sensor *Sensor) Validate() error {
+	var fields []FieldError
+	invalid := func(pointer string, detail string) {
+		fields = append(fields, FieldError{Pointer: pointer, Detail: detail})
+	}
+
+	name := strings.TrimSpace(sensor.Name)
+	switch {
+	case name == "":
+		invalid("/name", "must not be empty")
+	case utf8.RuneCountInString(name) > MaxSensorNameLength:
+		invalid("/name", fmt.Sprintf("must be at most %d characters", MaxSensorNameLength))
+	case !sensorNameRegexp.MatchString(name):
+		invalid("/name", "must start with a letter or digit, and contain only letters, digits, \".\", \"_\", \"~\" and \"-\"")
+	}
+
+	if err := validateCoordinate(sensor.Lat, 90); err != "" {
+		invalid("/lat", err)
+	}
+	if err := validateCoordinate(sensor.Lon, 180); err != "" {
+		invalid("/lon", err)
+	}
+
+	if len(sensor.Tags) > MaxSensorTags {
+		invalid("/tags", fmt.Sprintf("must have at most %d tags", MaxSensorTags))
+	}
+	for i, tag := range sensor.Tags {
+		tag = strings.TrimSpace(tag)
+		pointer := fmt.Sprintf("/tags/%d", i)
+		switch {
+		case tag == "":
+			invalid(pointer, "must not be blank")
+		case utf8.RuneCountInString(tag) > MaxTagLength:
+			invalid(pointer, fmt.Sprintf("must be at most %d characters", MaxTagLength))
+		case strings.IndexFunc(tag, unicode.IsControl) >= 0:
+			invalid(pointer, "must not contain control characters")
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{ResourceType: "sensor", Fields: fields}
+	}
+	return nil
+}
+
+// validateCoordinate checks that a latitude or longitude is within ±limit degrees.
+// Returns why it is invalid, or "" if it is valid.
+func validateCoordinate(degrees float64, limit float64) string {
+	if math.IsNaN(degrees) || math.IsInf(degrees, 0) {
+		return "must be a finite number"
+	}
+	if degrees < -limit || degrees > limit {
+		return fmt.Sprintf("must be between %g and %g", -limit, limit)
+	}
+	return ""
+}
+
+// Normalize trims whitespace from the sensor's name and tags, and removes duplicate tags (keeping the first).
+// Call Validate first: blank tags are not removed.
+func (sensor *Sensor) Normalize() {
+	sensor.Name = strings.TrimSpace(sensor.Name)
+	if sensor.Tags == nil {
+		return
+	}
+
+	seen := make(map[string]bool, len(sensor.Tags))
+	tags := make([]string, 0, len(sensor.Tags))
+	for _, tag := range sensor.Tags {
+		tag = strings.TrimSpace(tag)
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	sensor.Tags = tags
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSensorValidate(t *testing.T) {
+	valid := &Sensor{Name: "sensor-abc_1.2~3", Lat: -90, Lon: 180, Tags: []string{"a", " b "}}
+	require.NoError(t, valid.Validate())
+
+	// Every invalid field is reported
+	sensor := &Sensor{
+		Name: "sensor abc",
+		Lat:  9999,
+		Lon:  math.NaN(),
+		Tags: []string{"a", "  ", strings.Repeat("x", MaxTagLength+1), "tab\there"},
+	}
+	err := sensor.Validate()
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "sensor", validationErr.ResourceType)
+	require.Equal(t, []FieldError{
+		{Pointer: "/name", Detail: "must start with a letter or digit, and contain only letters, digits, \".\", \"_\", \"~\" and \"-\""},
+		{Pointer: "/lat", Detail: "must be between -90 and 90"},
+		{Pointer: "/lon", Detail: "must be a finite number"},
+		{Pointer: "/tags/1", Detail: "must not be blank"},
+		{Pointer: "/tags/2", Detail: "must be at most 64 characters"},
+		{Pointer: "/tags/3", Detail: "must not contain control characters"},
+	}, validationErr.Fields)
+	require.Contains(t, err.Error(), "invalid sensor: name must start with a letter or digit")
+	require.Contains(t, err.Error(), "; lat must be between -90 and 90; ")
+
+	tests := []struct {
+		sensor *Sensor
+		field  FieldError
+	}{
+		{&Sensor{Name: " "}, FieldError{"/name", "must not be empty"}},
+		{&Sensor{Name: strings.Repeat("a", MaxSensorNameLength+1)}, FieldError{"/name", "must be at most 64 characters"}},
+		{&Sensor{Name: ".hidden"}, FieldError{"/name", "must start with a letter or digit, and contain only letters, digits, \".\", \"_\", \"~\" and \"-\""}},
+		{&Sensor{Name: "a/b"}, FieldError{"/name", "must start with a letter or digit, and contain only letters, digits, \".\", \"_\", \"~\" and \"-\""}},
+		{&Sensor{Name: "abc", Lon: -180.5}, FieldError{"/lon", "must be between -180 and 180"}},
+		{&Sensor{Name: "abc", Lat: math.Inf(-1)}, FieldError{"/lat", "must be a finite number"}},
+		{&Sensor{Name: "abc", Tags: make([]string, MaxSensorTags+1)}, FieldError{"/tags", "must have at most 32 tags"}},
+	}
+	for _, test := range tests {
+		err := test.sensor.Validate()
+		require.ErrorAs(t, err, &validationErr, test.field.Pointer)
+		require.Contains(t, validationErr.Fields, test.field)
+	}
+}
+
+func TestSensorNormalize(t *testing.T) {
+	sensor := &Sensor{Name: " abc123\n", Tags: []string{"b", " a", "b ", "c", "a"}}
+	sensor.Normalize()
+	require.Equal(t, &Sensor{Name: "abc123", Tags: []string{"b", "a", "c"}}, sensor)
+
+	// Sensors without tags are left without tags
+	sensor = &Sensor{Name: "abc123"}
+	sensor.Normalize()
+	require.Nil(t, sensor.Tags)
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrCorrupt is returned when a FileSensorStore's files fail their checksums, or records are missing.
+// The store can't be opened until the files are restored (eg. from a backup).
+var ErrCorrupt = errors.New("sensor store files are corrupt")
+
+// ErrLocked is returned when a FileSensorStore's directory is already in use by another process
+var ErrLocked = errors.New("sensor store directory is locked by another process")
+
+// Files are made of frames, each holding one JSON record:
+//
+//	length (uint32, little-endian) | CRC-32C of the payload (uint32, little-endian) | payload
+const frameHeaderSize = 8
+
+// Largest record which will be read, so that a corrupt length can't exhaust memory
+const maxFrameSize = 1 << 30
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTruncatedFrame means the file ends part way through a frame, eg. after a crash during a write
+var errTruncatedFrame = errors.New("truncated record")
+
+// writeFrame writes a payload as a single frame, with a single write
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame reads the frame at the start of data, returning its payload and the frame's size.
+// The size is returned with checksum errors, so callers can tell whether the frame was the last in the file.
+func readFrame(data []byte) (payload []byte, size int, err error) {
+	if len(data) < frameHeaderSize {
+		return nil, len(data), errTruncatedFrame
+	}
+	length := binary.LittleEndian.Uint32(data[0:4])
+	if length == 0 || length > maxFrameSize {
+		return nil, len(data), fmt.Errorf("invalid record length %d", length)
+	}
+	size = frameHeaderSize + int(length)
+	if len(data) < size {
+		return nil, len(data), errTruncatedFrame
+	}
+	payload = data[frameHeaderSize:size]
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(data[4:8]) {
+		return nil, size, errors.New("checksum mismatch")
+	}
+	return payload, size, nil
+}
+
+// writeFileAtomic replaces a file with a single frame. The file is either replaced entirely, or not at all:
+// the frame is written to a temporary file, flushed to disk, and then renamed over the original.
+func writeFileAtomic(path string, payload []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(f, payload); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes a directory to disk, so that files created or renamed within it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// walSegment is a write-ahead log file. Its name includes the sequence number of its first record.
+type walSegment struct {
+	path     string
+	firstSeq uint64
+}
+
+func walSegmentPath(dir string, firstSeq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("wal-%020d.log", firstSeq))
+}
+
+// walSegments lists the write-ahead log files in dir, oldest first
+func walSegments(dir string) ([]walSegment, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []walSegment
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "wal-"), ".log")
+		firstSeq, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			// Not a segment, eg. wal-backup.log
+			continue
+		}
+		segments = append(segments, walSegment{path: path, firstSeq: firstSeq})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].firstSeq < segments[j].firstSeq
+	})
+
+	return segments, nil
+}
+
+// allZero reports whether data contains only zero bytes.
+// After a crash, some filesystems leave zeroes where an append was in progress.
+func allZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
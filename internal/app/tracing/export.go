@@ -0,0 +1,312 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Name of the instrumentation scope, in exported spans
+	scopeName = "github.com/eschwartz/go-sensor-api"
+	// Number of spans buffered by the OTLP exporter. Spans are dropped when the buffer is full.
+	otlpQueueSize = 2048
+	// Largest batch of spans sent to the collector
+	otlpMaxBatchSize = 512
+	// How often buffered spans are sent to the collector
+	otlpBatchInterval = 5 * time.Second
+	// Timeout for each request to the collector
+	otlpRequestTimeout = 10 * time.Second
+)
+
+// WriterExporter writes each span to a writer (eg. stdout or a file),
+// as a line of OTLP/JSON (an ExportTraceServiceRequest, containing the single span).
+// This is the format read by the OpenTelemetry collector's file receiver.
+type WriterExporter struct {
+	serviceName string
+	mu          sync.Mutex
+	w           io.Writer
+	// Closed on shutdown, if the exporter opened the file
+	closer io.Closer
+}
+
+func NewWriterExporter(serviceName string, w io.Writer) *WriterExporter {
+	return &WriterExporter{serviceName: serviceName, w: w}
+}
+
+// NewFileExporter creates a WriterExporter which appends spans to a file
+func NewFileExporter(serviceName string, path string) (*WriterExporter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open traces file: %w", err)
+	}
+	return &WriterExporter{serviceName: serviceName, w: file, closer: file}, nil
+}
+
+func (e *WriterExporter) ExportSpan(span SpanData) {
+	line, err := json.Marshal(newExportRequest(e.serviceName, []SpanData{span}))
+	if err != nil {
+		slog.Warn("failed to encode span", "error", err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.w.Write(append(line, '\n')); err != nil {
+		slog.Warn("failed to write span", "error", err)
+	}
+}
+
+// Shutdown closes the file, if the exporter was created with NewFileExporter
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector, using OTLP/HTTP with JSON encoding.
+// Spans are buffered, and sent in batches from a background goroutine.
+type OTLPExporter struct {
+	serviceName string
+	// URL of the collector's traces endpoint, eg. http://localhost:4318/v1/traces
+	url     string
+	headers map[string]string
+	http    *http.Client
+	queue   chan SpanData
+	// Closed by Shutdown, to stop the background goroutine
+	stop chan struct{}
+	// Closed by the background goroutine, once it has sent the remaining spans
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewOTLPExporter creates an exporter which sends spans to url, with the given headers (eg. for authentication).
+// Spans are sent with their own HTTP client, so that requests to the collector are not traced.
+func NewOTLPExporter(serviceName string, url string, headers map[string]string) *OTLPExporter {
+	e := &OTLPExporter{
+		serviceName: serviceName,
+		url:         url,
+		headers:     headers,
+		http:        &http.Client{Timeout: otlpRequestTimeout},
+		queue:       make(chan SpanData, otlpQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go e.run()
+
+	return e
+}
+
+func (e *OTLPExporter) ExportSpan(span SpanData) {
+	select {
+	case e.queue <- span:
+	default:
+		// Don't slow down requests if the collector can't keep up
+		slog.Warn("dropped span: export queue is full", "span", span.Name)
+	}
+}
+
+// Shutdown sends any buffered spans, and stops the exporter
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(otlpBatchInterval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			slog.Warn("failed to export spans", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= otlpMaxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			// Send whatever is left in the queue
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= otlpMaxBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *OTLPExporter) send(spans []SpanData) error {
+	body, err := json.Marshal(newExportRequest(e.serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := e.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with %s", res.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON encoding of an ExportTraceServiceRequest.
+// See https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId,omitempty"`
+	TraceState   string `json:"traceState,omitempty"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	// 64-bit integers are encoded as strings
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func newExportRequest(serviceName string, spans []SpanData) otlpExportRequest {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		encoded := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.Attributes),
+			Status:            otlpStatus{Code: int(span.Status), Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			encoded.ParentSpanID = span.ParentSpanID.String()
+		}
+		otlpSpans = append(otlpSpans, encoded)
+	}
+
+	return otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: encodeAttributes([]Attribute{String("service.name", serviceName)}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+func encodeAttributes(attrs []Attribute) []otlpKeyValue {
+	var encoded []otlpKeyValue
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		encoded = append(encoded, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
@@ -0,0 +1,127 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testSpan(name string) tracing.SpanData {
+	sc, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent := sc.SpanID
+	sc.SpanID = tracing.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	return tracing.SpanData{
+		Name:         name,
+		Kind:         tracing.SpanKindClient,
+		SpanContext:  sc,
+		ParentSpanID: parent,
+		Start:        time.Unix(1700000000, 0),
+		End:          time.Unix(1700000000, 5000000),
+		Attributes: []tracing.Attribute{
+			tracing.String("db.statement", "SELECT 1"),
+			tracing.Int("rows", 2),
+			tracing.Float64("lat", 44.5),
+			tracing.Bool("found", false),
+		},
+		Status:        tracing.StatusError,
+		StatusMessage: "failed",
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := tracing.NewWriterExporter("sensor-api", &buf)
+	exporter.ExportSpan(testSpan("SELECT"))
+	exporter.ExportSpan(testSpan("INSERT"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.JSONEq(t, `{
+		"resourceSpans": [{
+			"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "sensor-api"}}]},
+			"scopeSpans": [{
+				"scope": {"name": "github.com/eschwartz/go-sensor-api"},
+				"spans": [{
+					"traceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+					"spanId": "0102030405060708",
+					"parentSpanId": "00f067aa0ba902b7",
+					"name": "SELECT",
+					"kind": 3,
+					"startTimeUnixNano": "1700000000000000000",
+					"endTimeUnixNano": "1700000000005000000",
+					"attributes": [
+						{"key": "db.statement", "value": {"stringValue": "SELECT 1"}},
+						{"key": "rows", "value": {"intValue": "2"}},
+						{"key": "lat", "value": {"doubleValue": 44.5}},
+						{"key": "found", "value": {"boolValue": false}}
+					],
+					"status": {"code": 2, "message": "failed"}
+				}]
+			}]
+		}]
+	}`, lines[0])
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := tracing.NewFileExporter("sensor-api", path)
+	require.NoError(t, err)
+	exporter.ExportSpan(testSpan("SELECT"))
+	require.NoError(t, exporter.Shutdown(context.Background()))
+
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(contents), `"name":"SELECT"`)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var names []string
+	var apiKey, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						Name string `json:"name"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+
+		mu.Lock()
+		defer mu.Unlock()
+		apiKey = r.Header.Get("api-key")
+		contentType = r.Header.Get("Content-Type")
+		for _, span := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+			names = append(names, span.Name)
+		}
+	}))
+	defer server.Close()
+
+	exporter := tracing.NewOTLPExporter("sensor-api", server.URL+"/v1/traces", map[string]string{"api-key": "abc123"})
+	exporter.ExportSpan(testSpan("SELECT"))
+	exporter.ExportSpan(testSpan("INSERT"))
+
+	// Buffered spans are sent on shutdown
+	require.NoError(t, exporter.Shutdown(context.Background()))
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"SELECT", "INSERT"}, names)
+	require.Equal(t, "abc123", apiKey)
+	require.Equal(t, "application/json", contentType)
+}
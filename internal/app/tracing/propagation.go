@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// W3C trace context headers (see https://www.w3.org/TR/trace-context/)
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// Longest tracestate header we propagate. Longer headers are dropped, as the spec allows.
+const maxTraceStateLength = 512
+
+// Extract returns a context carrying the span context from the traceparent and tracestate headers,
+// to be used as the parent of the server span. Invalid headers are ignored.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(traceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	if traceState := header.Get(tracestateHeader); len(traceState) <= maxTraceStateLength {
+		sc.TraceState = traceState
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject sets the traceparent and tracestate headers, from the current span in ctx
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(traceparentHeader, FormatTraceparent(sc))
+	if sc.TraceState != "" {
+		header.Set(tracestateHeader, sc.TraceState)
+	}
+}
+
+// FormatTraceparent encodes a span context as a traceparent header, eg.
+//
+//	00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent decodes a traceparent header
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent \"%s\": expected 4 fields", traceparent)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// Future versions may add fields, but must keep the first four
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent \"%s\": unsupported version", traceparent)
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) {
+		return sc, fmt.Errorf("invalid traceparent \"%s\": invalid trace ID", traceparent)
+	}
+	if len(spanID) != 16 || !isLowerHex(spanID) {
+		return sc, fmt.Errorf("invalid traceparent \"%s\": invalid parent ID", traceparent)
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return sc, fmt.Errorf("invalid traceparent \"%s\": invalid flags", traceparent)
+	}
+
+	_, _ = hex.Decode(sc.TraceID[:], []byte(traceID))
+	_, _ = hex.Decode(sc.SpanID[:], []byte(spanID))
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent \"%s\": trace and parent IDs must not be zero", traceparent)
+	}
+	var flagBits [1]byte
+	_, _ = hex.Decode(flagBits[:], []byte(flags))
+	sc.Sampled = flagBits[0]&0x01 == 0x01
+	sc.Remote = true
+
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Transport is an http.RoundTripper which records a client span for each request,
+// and propagates the trace context to the server
+type Transport struct {
+	// Sends the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := Start(req.Context(), "HTTP "+req.Method, SpanKindClient,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Hostname()),
+		// Omit the query, which may include credentials (eg. a Mapbox access token)
+		String("url.path", req.URL.Path),
+	)
+	defer span.End()
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= 400 {
+		span.SetStatus(StatusError, res.Status)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,101 @@
+package tracing_test
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing/tracingtest"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	require.True(t, sc.Sampled)
+	require.True(t, sc.Remote)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tracing.FormatTraceparent(sc))
+
+	sc, err = tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	require.False(t, sc.Sampled)
+
+	// Later versions may have more fields
+	_, err = tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	require.NoError(t, err)
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-x1",
+	} {
+		_, err := tracing.ParseTraceparent(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("tracestate", "vendor=abc")
+	ctx := tracing.Extract(context.Background(), header)
+
+	outbound := http.Header{}
+	tracing.Inject(ctx, outbound)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", outbound.Get("traceparent"))
+	require.Equal(t, "vendor=abc", outbound.Get("tracestate"))
+
+	// Invalid or missing headers are ignored
+	header.Set("traceparent", "garbage")
+	ctx = tracing.Extract(context.Background(), header)
+	outbound = http.Header{}
+	tracing.Inject(ctx, outbound)
+	require.Empty(t, outbound.Get("traceparent"))
+
+	// Oversized trace state is dropped
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("tracestate", "vendor="+strings.Repeat("a", 1000))
+	require.Empty(t, tracing.SpanContextFromContext(tracing.Extract(context.Background(), header)).TraceState)
+}
+
+func TestTransport(t *testing.T) {
+	recorder := tracingtest.Install(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, parent := tracing.Start(context.Background(), "parent", tracing.SpanKindInternal)
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/geocode?access_token=secret", nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: &tracing.Transport{}}
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	parent.End()
+
+	span := recorder.Span(t, "HTTP GET")
+	require.Equal(t, tracing.SpanKindClient, span.Kind)
+	require.Equal(t, parent.SpanContext().SpanID, span.ParentSpanID)
+	require.Equal(t, "/geocode", tracingtest.Attribute(span, "url.path"))
+	require.Equal(t, int64(http.StatusTooManyRequests), tracingtest.Attribute(span, "http.response.status_code"))
+	require.Equal(t, tracing.StatusError, span.Status)
+
+	// The server receives the client span as its parent
+	require.Equal(t, tracing.FormatTraceparent(span.SpanContext), traceparent)
+	// The caller's request is not modified
+	require.Empty(t, req.Header.Get("traceparent"))
+}
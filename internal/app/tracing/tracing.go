@@ -0,0 +1,438 @@
+// Package tracing records spans for HTTP requests, sensor store queries and geocoder calls,
+// and propagates W3C trace context (the traceparent header) between services.
+//
+// Spans follow the OpenTelemetry data model, and are exported as OTLP/JSON,
+// either to a collector (see NewOTLPExporter) or to a file (see NewWriterExporter).
+// Until a tracer is installed with SetTracer, spans are not recorded.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace: a request, and all the work done for it, across services
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a single span within a trace
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// SpanContext is the part of a span which is propagated to child spans and other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Whether the trace is being recorded. Child spans follow their parent's decision.
+	Sampled bool
+	// Vendor-specific trace state, from the tracestate header. Propagated unchanged.
+	TraceState string
+	// Whether the span context was received from another service
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind describes the relationship of a span to its parent. Values match OTLP.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	// Handling a request from a client
+	SpanKindServer SpanKind = 2
+	// Making a request to another service (eg. a database or geocoder)
+	SpanKindClient SpanKind = 3
+)
+
+// StatusCode is the outcome of a span. Values match OTLP.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute is a key/value pair describing a span, eg. "db.statement"
+type Attribute struct {
+	Key string
+	// A string, int64, float64 or bool
+	Value interface{}
+}
+
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Float64(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is a completed span, as passed to an Exporter
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span is an operation within a trace, eg. handling a request or running a query.
+// Spans are safe for concurrent use. All methods may be called on a nil Span, and do nothing.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanContext returns the span's trace and span IDs
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// IsRecording reports whether the span will be exported when it ends
+func (s *Span) IsRecording() bool {
+	return s != nil && s.data.SpanContext.Sampled
+}
+
+// SetName replaces the span's name, eg. once the route of a request is known
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+// SetAttributes adds attributes to the span, replacing any existing attributes with the same keys
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		s.data.Attributes = setAttribute(s.data.Attributes, attr)
+	}
+}
+
+// SetStatus sets the outcome of the span
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = code
+	if code == StatusError {
+		s.data.StatusMessage = message
+	} else {
+		s.data.StatusMessage = ""
+	}
+}
+
+// RecordError marks the span as failed, if err is not nil
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+	s.SetAttributes(String("error.type", errorType(err)))
+}
+
+// End completes the span, and exports it. Later calls to End do nothing.
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.exporter.ExportSpan(data)
+}
+
+func setAttribute(attrs []Attribute, attr Attribute) []Attribute {
+	for i := range attrs {
+		if attrs[i].Key == attr.Key {
+			attrs[i] = attr
+			return attrs
+		}
+	}
+	return append(attrs, attr)
+}
+
+// errorType describes an error for the error.type attribute, eg. "*store.MissingResourceError"
+func errorType(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	// Describe wrapped errors by their cause, rather than eg. "*fmt.wrapError"
+	for {
+		unwrapped := errors.Unwrap(err)
+		if unwrapped == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = unwrapped
+	}
+}
+
+// Exporter sends completed spans to a tracing backend.
+// ExportSpan is called as each span ends, so must not block for long.
+type Exporter interface {
+	ExportSpan(span SpanData)
+	// Shutdown exports any buffered spans, and releases the exporter's resources
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer creates spans, and exports the sampled ones
+type Tracer struct {
+	exporter Exporter
+	// Fraction of new traces to sample, from 0 to 1
+	sampleRatio float64
+}
+
+// TracerOption configures a Tracer
+type TracerOption func(t *Tracer)
+
+// WithSampleRatio samples a fraction of new traces (from 0 to 1). Defaults to 1 (every trace).
+// Traces started by another service are sampled if the other service sampled them.
+func WithSampleRatio(ratio float64) TracerOption {
+	return func(t *Tracer) {
+		t.sampleRatio = ratio
+	}
+}
+
+func NewTracer(exporter Exporter, opts ...TracerOption) *Tracer {
+	tracer := &Tracer{
+		exporter:    exporter,
+		sampleRatio: 1,
+	}
+	for _, opt := range opts {
+		opt(tracer)
+	}
+
+	return tracer
+}
+
+// Shutdown exports any buffered spans
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.exporter.Shutdown(ctx)
+}
+
+// Start creates a span, as a child of the span (or remote span context) in ctx.
+// The returned context carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	spanContext := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		spanContext.TraceID = parent.TraceID
+		spanContext.Sampled = parent.Sampled
+		spanContext.TraceState = parent.TraceState
+	} else {
+		spanContext.TraceID = newTraceID()
+		spanContext.Sampled = t.sample(spanContext.TraceID)
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			SpanContext:  spanContext,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+		},
+	}
+	for _, attr := range attrs {
+		span.data.Attributes = setAttribute(span.data.Attributes, attr)
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// sample decides whether to record a new trace, from its ID,
+// so that every service sampling at the same ratio makes the same decision
+func (t *Tracer) sample(traceID TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	threshold := uint64(t.sampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < threshold
+}
+
+// The tracer used by Start. Nil until set with SetTracer.
+var globalTracer atomic.Pointer[Tracer]
+
+// SetTracer sets the tracer used to start spans
+func SetTracer(t *Tracer) {
+	globalTracer.Store(t)
+}
+
+// Start creates a span using the tracer set with SetTracer.
+// If no tracer is set, it returns ctx and a nil span, which does nothing.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	tracer := globalTracer.Load()
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.Start(ctx, name, kind, attrs...)
+}
+
+// New creates a tracer, exporting spans as configured:
+//   - "otlp": to the collector at cfg.TracesEndpoint, or cfg.Endpoint with "/v1/traces" appended
+//   - "console": to stdout
+//   - "file": appended to cfg.File
+//   - "none": tracing is disabled
+//
+// Returns nil if tracing is disabled.
+func New(cfg config.Tracing) (*Tracer, error) {
+	opts := []TracerOption{WithSampleRatio(cfg.SampleRatio)}
+
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "console":
+		return NewTracer(NewWriterExporter(cfg.ServiceName, os.Stdout), opts...), nil
+	case "file":
+		exporter, err := NewFileExporter(cfg.ServiceName, cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		return NewTracer(exporter, opts...), nil
+	case "otlp":
+		url := cfg.TracesEndpoint
+		if url == "" {
+			url = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces"
+		}
+		headers, err := parseHeaders(cfg.Headers)
+		if err != nil {
+			return nil, err
+		}
+		return NewTracer(NewOTLPExporter(cfg.ServiceName, url, headers), opts...), nil
+	default:
+		return nil, fmt.Errorf("invalid trace exporter \"%s\": must be \"otlp\", \"console\", \"file\" or \"none\"", cfg.Exporter)
+	}
+}
+
+// parseHeaders parses the collector headers (OTEL_EXPORTER_OTLP_HEADERS), eg. "api-key=abc123,tenant=acme".
+// Values may be URL-encoded.
+func parseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	if value == "" {
+		return headers, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS: expected key=value pairs")
+		}
+		decoded, err := url.QueryUnescape(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
+
+type spanContextKey struct{}
+
+type remoteSpanContextKey struct{}
+
+// SpanFromContext returns the current span, or nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext returns a context carrying a span context received from another service,
+// to be used as the parent of the next span
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the current span.
+// If there is no current span, it returns the remote span context, if any.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,137 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eschwartz/go-sensor-api/internal/app/config"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing/tracingtest"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	recorder := tracingtest.Install(t)
+
+	ctx, parent := tracing.Start(context.Background(), "parent", tracing.SpanKindServer, tracing.String("a", "b"))
+	_, child := tracing.Start(ctx, "child", tracing.SpanKindClient)
+	child.SetAttributes(tracing.Int("rows", 3), tracing.Int("rows", 4))
+	child.RecordError(fmt.Errorf("query failed: %w", context.DeadlineExceeded))
+	child.End()
+	parent.SetName("renamed")
+	parent.End()
+	// Ending twice does nothing
+	parent.End()
+
+	spans := recorder.Spans()
+	require.Len(t, spans, 2)
+	childData, parentData := spans[0], spans[1]
+
+	require.Equal(t, "renamed", parentData.Name)
+	require.Equal(t, tracing.SpanKindServer, parentData.Kind)
+	require.False(t, parentData.ParentSpanID.IsValid())
+	require.Equal(t, []tracing.Attribute{tracing.String("a", "b")}, parentData.Attributes)
+	require.True(t, parentData.End.After(parentData.Start))
+
+	// Children share the trace, and point to their parent
+	require.Equal(t, "child", childData.Name)
+	require.Equal(t, parentData.SpanContext.TraceID, childData.SpanContext.TraceID)
+	require.Equal(t, parentData.SpanContext.SpanID, childData.ParentSpanID)
+	require.NotEqual(t, parentData.SpanContext.SpanID, childData.SpanContext.SpanID)
+	require.Equal(t, int64(4), tracingtest.Attribute(childData, "rows"))
+	require.Equal(t, tracing.StatusError, childData.Status)
+	require.Equal(t, "query failed: context deadline exceeded", childData.StatusMessage)
+	require.Equal(t, "timeout", tracingtest.Attribute(childData, "error.type"))
+}
+
+func TestStart_RemoteParent(t *testing.T) {
+	recorder := tracingtest.Install(t)
+
+	remote, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	remote.TraceState = "vendor=abc"
+	ctx := tracing.ContextWithRemoteSpanContext(context.Background(), remote)
+
+	_, span := tracing.Start(ctx, "server", tracing.SpanKindServer)
+	span.End()
+
+	data := recorder.Span(t, "server")
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", data.SpanContext.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", data.ParentSpanID.String())
+	require.Equal(t, "vendor=abc", data.SpanContext.TraceState)
+}
+
+func TestStart_Sampling(t *testing.T) {
+	recorder := &tracingtest.Recorder{}
+	tracer := tracing.NewTracer(recorder, tracing.WithSampleRatio(0))
+
+	// Unsampled spans are not exported, but still propagate the trace
+	ctx, parent := tracer.Start(context.Background(), "parent", tracing.SpanKindServer)
+	_, child := tracer.Start(ctx, "child", tracing.SpanKindInternal)
+	require.False(t, parent.IsRecording())
+	require.True(t, parent.SpanContext().IsValid())
+	require.Equal(t, parent.SpanContext().TraceID, child.SpanContext().TraceID)
+	child.End()
+	parent.End()
+	require.Empty(t, recorder.Spans())
+
+	// Traces sampled by the caller are recorded, regardless of the ratio
+	remote, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	_, span := tracer.Start(tracing.ContextWithRemoteSpanContext(context.Background(), remote), "sampled", tracing.SpanKindServer)
+	span.End()
+	require.Len(t, recorder.Spans(), 1)
+
+	// Ratios sample roughly that fraction of traces
+	tracer = tracing.NewTracer(recorder, tracing.WithSampleRatio(0.25))
+	sampled := 0
+	for i := 0; i < 2000; i++ {
+		if _, span := tracer.Start(context.Background(), "span", tracing.SpanKindServer); span.IsRecording() {
+			sampled++
+		}
+	}
+	require.InDelta(t, 500, sampled, 100)
+}
+
+func TestStart_NoTracer(t *testing.T) {
+	ctx := context.Background()
+	spanCtx, span := tracing.Start(ctx, "span", tracing.SpanKindInternal)
+	require.Nil(t, span)
+	require.Equal(t, ctx, spanCtx)
+
+	// Nil spans can be used as normal
+	span.SetName("renamed")
+	span.SetAttributes(tracing.String("a", "b"))
+	span.RecordError(errors.New("failed"))
+	span.End()
+	require.False(t, span.SpanContext().IsValid())
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.Default().Tracing
+	tracer, err := tracing.New(cfg)
+	require.NoError(t, err)
+	require.Nil(t, tracer)
+
+	cfg.Exporter = "console"
+	tracer, err = tracing.New(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, tracer)
+
+	cfg.Exporter = "file"
+	cfg.File = filepath.Join(t.TempDir(), "traces.jsonl")
+	tracer, err = tracing.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	cfg.Exporter = "otlp"
+	cfg.Headers = "no-equals-sign"
+	_, err = tracing.New(cfg)
+	require.EqualError(t, err, "invalid OTEL_EXPORTER_OTLP_HEADERS: expected key=value pairs")
+
+	cfg.Exporter = "zipkin"
+	_, err = tracing.New(cfg)
+	require.EqualError(t, err, "invalid trace exporter \"zipkin\": must be \"otlp\", \"console\", \"file\" or \"none\"")
+}
@@ -0,0 +1,62 @@
+// Package tracingtest records spans in memory, for tests
+package tracingtest
+
+import (
+	"context"
+	"github.com/eschwartz/go-sensor-api/internal/app/tracing"
+	"sync"
+	"testing"
+)
+
+// Recorder is an Exporter which keeps every span in memory
+type Recorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (r *Recorder) ExportSpan(span tracing.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func (r *Recorder) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns the ended spans, in the order they ended
+func (r *Recorder) Spans() []tracing.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]tracing.SpanData(nil), r.spans...)
+}
+
+// Span returns the first ended span with the given name. Fails the test if there is none.
+func (r *Recorder) Span(t *testing.T, name string) tracing.SpanData {
+	t.Helper()
+	for _, span := range r.Spans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named \"%s\"", name)
+	return tracing.SpanData{}
+}
+
+// Install records every span for the rest of the test
+func Install(t *testing.T) *Recorder {
+	recorder := &Recorder{}
+	tracing.SetTracer(tracing.NewTracer(recorder))
+	t.Cleanup(func() { tracing.SetTracer(nil) })
+	return recorder
+}
+
+// Attribute returns the value of a span attribute, or nil if it is not set
+func Attribute(span tracing.SpanData, key string) interface{} {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1054 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: sensors/v1/sensors.proto
+
+// Sensor API, for gRPC clients. Mirrors the REST API (see openapi.json), over the same sensors.
+
+package sensorsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SensorChange_Type int32
+
+const (
+	SensorChange_TYPE_UNSPECIFIED SensorChange_Type = 0
+	SensorChange_CREATED          SensorChange_Type = 1
+	SensorChange_UPDATED          SensorChange_Type = 2
+	SensorChange_DELETED          SensorChange_Type = 3
+)
+
+// Enum value maps for SensorChange_Type.
+var (
+	SensorChange_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "CREATED",
+		2: "UPDATED",
+		3: "DELETED",
+	}
+	SensorChange_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"CREATED":          1,
+		"UPDATED":          2,
+		"DELETED":          3,
+	}
+)
+
+func (x SensorChange_Type) Enum() *SensorChange_Type {
+	p := new(SensorChange_Type)
+	*p = x
+	return p
+}
+
+func (x SensorChange_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SensorChange_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_sensors_v1_sensors_proto_enumTypes[0].Descriptor()
+}
+
+func (SensorChange_Type) Type() protoreflect.EnumType {
+	return &file_sensors_v1_sensors_proto_enumTypes[0]
+}
+
+func (x SensorChange_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SensorChange_Type.Descriptor instead.
+func (SensorChange_Type) EnumDescriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{10, 0}
+}
+
+// Sensor is a physical sensor, at a geographical location
+type Sensor struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Set by the server
+	Id   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Lat  float64  `protobuf:"fixed64,3,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon  float64  `protobuf:"fixed64,4,opt,name=lon,proto3" json:"lon,omitempty"`
+	Tags []string `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Human-readable address / locality of the sensor, if known. Set by the server.
+	PlaceName string `protobuf:"bytes,6,opt,name=place_name,json=placeName,proto3" json:"place_name,omitempty"`
+}
+
+func (x *Sensor) Reset() {
+	*x = Sensor{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Sensor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sensor) ProtoMessage() {}
+
+func (x *Sensor) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sensor.ProtoReflect.Descriptor instead.
+func (*Sensor) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sensor) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Sensor) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Sensor) GetLat() float64 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *Sensor) GetLon() float64 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+func (x *Sensor) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Sensor) GetPlaceName() string {
+	if x != nil {
+		return x.PlaceName
+	}
+	return ""
+}
+
+type GetSensorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *GetSensorRequest) Reset() {
+	*x = GetSensorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSensorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSensorRequest) ProtoMessage() {}
+
+func (x *GetSensorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSensorRequest.ProtoReflect.Descriptor instead.
+func (*GetSensorRequest) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetSensorRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type CreateSensorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The sensor's id and place_name are ignored
+	Sensor *Sensor `protobuf:"bytes,1,opt,name=sensor,proto3" json:"sensor,omitempty"`
+}
+
+func (x *CreateSensorRequest) Reset() {
+	*x = CreateSensorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateSensorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSensorRequest) ProtoMessage() {}
+
+func (x *CreateSensorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSensorRequest.ProtoReflect.Descriptor instead.
+func (*CreateSensorRequest) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateSensorRequest) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+type UpdateSensorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Current name of the sensor
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The sensor's id and place_name are ignored
+	Sensor *Sensor `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+}
+
+func (x *UpdateSensorRequest) Reset() {
+	*x = UpdateSensorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateSensorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateSensorRequest) ProtoMessage() {}
+
+func (x *UpdateSensorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateSensorRequest.ProtoReflect.Descriptor instead.
+func (*UpdateSensorRequest) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdateSensorRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UpdateSensorRequest) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+type DeleteSensorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *DeleteSensorRequest) Reset() {
+	*x = DeleteSensorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteSensorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSensorRequest) ProtoMessage() {}
+
+func (x *DeleteSensorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSensorRequest.ProtoReflect.Descriptor instead.
+func (*DeleteSensorRequest) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteSensorRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// Coordinate is a WGS 84 latitude and longitude, in degrees
+type Coordinate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lat float64 `protobuf:"fixed64,1,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon float64 `protobuf:"fixed64,2,opt,name=lon,proto3" json:"lon,omitempty"`
+}
+
+func (x *Coordinate) Reset() {
+	*x = Coordinate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Coordinate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Coordinate) ProtoMessage() {}
+
+func (x *Coordinate) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Coordinate.ProtoReflect.Descriptor instead.
+func (*Coordinate) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Coordinate) GetLat() float64 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *Coordinate) GetLon() float64 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+type FindClosestRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Location:
+	//	*FindClosestRequest_Coordinate
+	//	*FindClosestRequest_Place
+	Location isFindClosestRequest_Location `protobuf_oneof:"location"`
+	// Distance to search within. Defaults to 20km if 0.
+	RadiusMeters int32 `protobuf:"varint,3,opt,name=radius_meters,json=radiusMeters,proto3" json:"radius_meters,omitempty"`
+}
+
+func (x *FindClosestRequest) Reset() {
+	*x = FindClosestRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindClosestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindClosestRequest) ProtoMessage() {}
+
+func (x *FindClosestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindClosestRequest.ProtoReflect.Descriptor instead.
+func (*FindClosestRequest) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{6}
+}
+
+func (m *FindClosestRequest) GetLocation() isFindClosestRequest_Location {
+	if m != nil {
+		return m.Location
+	}
+	return nil
+}
+
+func (x *FindClosestRequest) GetCoordinate() *Coordinate {
+	if x, ok := x.GetLocation().(*FindClosestRequest_Coordinate); ok {
+		return x.Coordinate
+	}
+	return nil
+}
+
+func (x *FindClosestRequest) GetPlace() string {
+	if x, ok := x.GetLocation().(*FindClosestRequest_Place); ok {
+		return x.Place
+	}
+	return ""
+}
+
+func (x *FindClosestRequest) GetRadiusMeters() int32 {
+	if x != nil {
+		return x.RadiusMeters
+	}
+	return 0
+}
+
+type isFindClosestRequest_Location interface {
+	isFindClosestRequest_Location()
+}
+
+type FindClosestRequest_Coordinate struct {
+	Coordinate *Coordinate `protobuf:"bytes,1,opt,name=coordinate,proto3,oneof"`
+}
+
+type FindClosestRequest_Place struct {
+	// Place name or address to geocode, eg. "Minneapolis". Fails with UNIMPLEMENTED if geocoding is not configured.
+	Place string `protobuf:"bytes,2,opt,name=place,proto3,oneof"`
+}
+
+func (*FindClosestRequest_Coordinate) isFindClosestRequest_Location() {}
+
+func (*FindClosestRequest_Place) isFindClosestRequest_Location() {}
+
+type FindClosestResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Sensors []*Sensor `protobuf:"bytes,1,rep,name=sensors,proto3" json:"sensors,omitempty"`
+}
+
+func (x *FindClosestResponse) Reset() {
+	*x = FindClosestResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FindClosestResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindClosestResponse) ProtoMessage() {}
+
+func (x *FindClosestResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindClosestResponse.ProtoReflect.Descriptor instead.
+func (*FindClosestResponse) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FindClosestResponse) GetSensors() []*Sensor {
+	if x != nil {
+		return x.Sensors
+	}
+	return nil
+}
+
+type ListSensorsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Only list sensors whose names sort after this name, eg. to resume an interrupted stream
+	StartAfter string `protobuf:"bytes,1,opt,name=start_after,json=startAfter,proto3" json:"start_after,omitempty"`
+	// Most sensors to send. Sends every sensor if 0.
+	Limit int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *ListSensorsRequest) Reset() {
+	*x = ListSensorsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListSensorsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSensorsRequest) ProtoMessage() {}
+
+func (x *ListSensorsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSensorsRequest.ProtoReflect.Descriptor instead.
+func (*ListSensorsRequest) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListSensorsRequest) GetStartAfter() string {
+	if x != nil {
+		return x.StartAfter
+	}
+	return ""
+}
+
+func (x *ListSensorsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type WatchChangesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *WatchChangesRequest) Reset() {
+	*x = WatchChangesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchChangesRequest) ProtoMessage() {}
+
+func (x *WatchChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchChangesRequest.ProtoReflect.Descriptor instead.
+func (*WatchChangesRequest) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{9}
+}
+
+type SensorChange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Increases with each change
+	Id   int64             `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type SensorChange_Type `protobuf:"varint,2,opt,name=type,proto3,enum=sensors.v1.SensorChange_Type" json:"type,omitempty"`
+	// The sensor after the change. For deletions, the sensor as it was before it was deleted.
+	Sensor *Sensor                `protobuf:"bytes,3,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	Time   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *SensorChange) Reset() {
+	*x = SensorChange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sensors_v1_sensors_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SensorChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorChange) ProtoMessage() {}
+
+func (x *SensorChange) ProtoReflect() protoreflect.Message {
+	mi := &file_sensors_v1_sensors_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorChange.ProtoReflect.Descriptor instead.
+func (*SensorChange) Descriptor() ([]byte, []int) {
+	return file_sensors_v1_sensors_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SensorChange) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SensorChange) GetType() SensorChange_Type {
+	if x != nil {
+		return x.Type
+	}
+	return SensorChange_TYPE_UNSPECIFIED
+}
+
+func (x *SensorChange) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+func (x *SensorChange) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+var File_sensors_v1_sensors_proto protoreflect.FileDescriptor
+
+var file_sensors_v1_sensors_proto_rawDesc = []byte{
+	0x0a, 0x18, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2f, 0x76, 0x31, 0x2f, 0x73, 0x65, 0x6e,
+	0x73, 0x6f, 0x72, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x73, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x83, 0x01, 0x0a, 0x06, 0x53, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02,
+	0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x03, 0x6c, 0x61, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6c, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x61,
+	0x67, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x12, 0x1d,
+	0x0a, 0x0a, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x22, 0x26, 0x0a,
+	0x10, 0x47, 0x65, 0x74, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x41, 0x0a, 0x13, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53,
+	0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2a, 0x0a, 0x06,
+	0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x73,
+	0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72,
+	0x52, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x22, 0x55, 0x0a, 0x13, 0x55, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x12, 0x2a, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x22,
+	0x29, 0x0a, 0x13, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x30, 0x0a, 0x0a, 0x43, 0x6f,
+	0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6c, 0x61, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6c, 0x6f, 0x6e, 0x22, 0x97, 0x01, 0x0a,
+	0x12, 0x46, 0x69, 0x6e, 0x64, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x38, 0x0a, 0x0a, 0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x48,
+	0x00, 0x52, 0x0a, 0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x12, 0x16, 0x0a,
+	0x05, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x05,
+	0x70, 0x6c, 0x61, 0x63, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x61, 0x64, 0x69, 0x75, 0x73, 0x5f,
+	0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x72, 0x61,
+	0x64, 0x69, 0x75, 0x73, 0x4d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x42, 0x0a, 0x0a, 0x08, 0x6c, 0x6f,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x43, 0x0a, 0x13, 0x46, 0x69, 0x6e, 0x64, 0x43, 0x6c,
+	0x6f, 0x73, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a,
+	0x07, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12,
+	0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x52, 0x07, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x22, 0x4b, 0x0a, 0x12, 0x4c,
+	0x69, 0x73, 0x74, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x41, 0x66, 0x74,
+	0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x15, 0x0a, 0x13, 0x57, 0x61, 0x74, 0x63,
+	0x68, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22,
+	0xf2, 0x01, 0x0a, 0x0c, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02, 0x69, 0x64,
+	0x12, 0x31, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1d,
+	0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x12, 0x2a, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x12,
+	0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x22,
+	0x43, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x54, 0x59, 0x50, 0x45, 0x5f,
+	0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a,
+	0x07, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x50,
+	0x44, 0x41, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x44, 0x45, 0x4c, 0x45, 0x54,
+	0x45, 0x44, 0x10, 0x03, 0x32, 0xe0, 0x03, 0x0a, 0x0d, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x37, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x1c, 0x2e,
+	0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x73, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x12,
+	0x3d, 0x0a, 0x06, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x12, 0x1f, 0x2e, 0x73, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x6e,
+	0x73, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x73, 0x65, 0x6e,
+	0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x12, 0x3d,
+	0x0a, 0x06, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x1f, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f,
+	0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x73, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x12, 0x3d, 0x0a,
+	0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x1f, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53, 0x65, 0x6e, 0x73, 0x6f,
+	0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f,
+	0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x12, 0x4e, 0x0a, 0x0b,
+	0x46, 0x69, 0x6e, 0x64, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x2e, 0x73, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x43, 0x6c, 0x6f,
+	0x73, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x73, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x43, 0x6c, 0x6f,
+	0x73, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3c, 0x0a, 0x04,
+	0x4c, 0x69, 0x73, 0x74, 0x12, 0x1e, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x30, 0x01, 0x12, 0x4b, 0x0a, 0x0c, 0x57, 0x61,
+	0x74, 0x63, 0x68, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x1f, 0x2e, 0x73, 0x65, 0x6e,
+	0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x73, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x43,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x30, 0x01, 0x42, 0x3f, 0x5a, 0x3d, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x65, 0x73, 0x63, 0x68, 0x77, 0x61, 0x72, 0x74, 0x7a, 0x2f,
+	0x67, 0x6f, 0x2d, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x2d, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x73,
+	0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_sensors_v1_sensors_proto_rawDescOnce sync.Once
+	file_sensors_v1_sensors_proto_rawDescData = file_sensors_v1_sensors_proto_rawDesc
+)
+
+func file_sensors_v1_sensors_proto_rawDescGZIP() []byte {
+	file_sensors_v1_sensors_proto_rawDescOnce.Do(func() {
+		file_sensors_v1_sensors_proto_rawDescData = protoimpl.X.CompressGZIP(file_sensors_v1_sensors_proto_rawDescData)
+	})
+	return file_sensors_v1_sensors_proto_rawDescData
+}
+
+var file_sensors_v1_sensors_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_sensors_v1_sensors_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_sensors_v1_sensors_proto_goTypes = []any{
+	(SensorChange_Type)(0),        // 0: sensors.v1.SensorChange.Type
+	(*Sensor)(nil),                // 1: sensors.v1.Sensor
+	(*GetSensorRequest)(nil),      // 2: sensors.v1.GetSensorRequest
+	(*CreateSensorRequest)(nil),   // 3: sensors.v1.CreateSensorRequest
+	(*UpdateSensorRequest)(nil),   // 4: sensors.v1.UpdateSensorRequest
+	(*DeleteSensorRequest)(nil),   // 5: sensors.v1.DeleteSensorRequest
+	(*Coordinate)(nil),            // 6: sensors.v1.Coordinate
+	(*FindClosestRequest)(nil),    // 7: sensors.v1.FindClosestRequest
+	(*FindClosestResponse)(nil),   // 8: sensors.v1.FindClosestResponse
+	(*ListSensorsRequest)(nil),    // 9: sensors.v1.ListSensorsRequest
+	(*WatchChangesRequest)(nil),   // 10: sensors.v1.WatchChangesRequest
+	(*SensorChange)(nil),          // 11: sensors.v1.SensorChange
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
+}
+var file_sensors_v1_sensors_proto_depIdxs = []int32{
+	1,  // 0: sensors.v1.CreateSensorRequest.sensor:type_name -> sensors.v1.Sensor
+	1,  // 1: sensors.v1.UpdateSensorRequest.sensor:type_name -> sensors.v1.Sensor
+	6,  // 2: sensors.v1.FindClosestRequest.coordinate:type_name -> sensors.v1.Coordinate
+	1,  // 3: sensors.v1.FindClosestResponse.sensors:type_name -> sensors.v1.Sensor
+	0,  // 4: sensors.v1.SensorChange.type:type_name -> sensors.v1.SensorChange.Type
+	1,  // 5: sensors.v1.SensorChange.sensor:type_name -> sensors.v1.Sensor
+	12, // 6: sensors.v1.SensorChange.time:type_name -> google.protobuf.Timestamp
+	2,  // 7: sensors.v1.SensorService.Get:input_type -> sensors.v1.GetSensorRequest
+	3,  // 8: sensors.v1.SensorService.Create:input_type -> sensors.v1.CreateSensorRequest
+	4,  // 9: sensors.v1.SensorService.Update:input_type -> sensors.v1.UpdateSensorRequest
+	5,  // 10: sensors.v1.SensorService.Delete:input_type -> sensors.v1.DeleteSensorRequest
+	7,  // 11: sensors.v1.SensorService.FindClosest:input_type -> sensors.v1.FindClosestRequest
+	9,  // 12: sensors.v1.SensorService.List:input_type -> sensors.v1.ListSensorsRequest
+	10, // 13: sensors.v1.SensorService.WatchChanges:input_type -> sensors.v1.WatchChangesRequest
+	1,  // 14: sensors.v1.SensorService.Get:output_type -> sensors.v1.Sensor
+	1,  // 15: sensors.v1.SensorService.Create:output_type -> sensors.v1.Sensor
+	1,  // 16: sensors.v1.SensorService.Update:output_type -> sensors.v1.Sensor
+	1,  // 17: sensors.v1.SensorService.Delete:output_type -> sensors.v1.Sensor
+	8,  // 18: sensors.v1.SensorService.FindClosest:output_type -> sensors.v1.FindClosestResponse
+	1,  // 19: sensors.v1.SensorService.List:output_type -> sensors.v1.Sensor
+	11, // 20: sensors.v1.SensorService.WatchChanges:output_type -> sensors.v1.SensorChange
+	14, // [14:21] is the sub-list for method output_type
+	7,  // [7:14] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_sensors_v1_sensors_proto_init() }
+func file_sensors_v1_sensors_proto_init() {
+	if File_sensors_v1_sensors_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_sensors_v1_sensors_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Sensor); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*GetSensorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*CreateSensorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*UpdateSensorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*DeleteSensorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*Coordinate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*FindClosestRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*FindClosestResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*ListSensorsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchChangesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sensors_v1_sensors_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*SensorChange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_sensors_v1_sensors_proto_msgTypes[6].OneofWrappers = []any{
+		(*FindClosestRequest_Coordinate)(nil),
+		(*FindClosestRequest_Place)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_sensors_v1_sensors_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sensors_v1_sensors_proto_goTypes,
+		DependencyIndexes: file_sensors_v1_sensors_proto_depIdxs,
+		EnumInfos:         file_sensors_v1_sensors_proto_enumTypes,
+		MessageInfos:      file_sensors_v1_sensors_proto_msgTypes,
+	}.Build()
+	File_sensors_v1_sensors_proto = out.File
+	file_sensors_v1_sensors_proto_rawDesc = nil
+	file_sensors_v1_sensors_proto_goTypes = nil
+	file_sensors_v1_sensors_proto_depIdxs = nil
+}
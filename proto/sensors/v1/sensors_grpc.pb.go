@@ -0,0 +1,427 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: sensors/v1/sensors.proto
+
+// Sensor API, for gRPC clients. Mirrors the REST API (see openapi.json), over the same sensors.
+
+package sensorsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	SensorService_Get_FullMethodName          = "/sensors.v1.SensorService/Get"
+	SensorService_Create_FullMethodName       = "/sensors.v1.SensorService/Create"
+	SensorService_Update_FullMethodName       = "/sensors.v1.SensorService/Update"
+	SensorService_Delete_FullMethodName       = "/sensors.v1.SensorService/Delete"
+	SensorService_FindClosest_FullMethodName  = "/sensors.v1.SensorService/FindClosest"
+	SensorService_List_FullMethodName         = "/sensors.v1.SensorService/List"
+	SensorService_WatchChanges_FullMethodName = "/sensors.v1.SensorService/WatchChanges"
+)
+
+// SensorServiceClient is the client API for SensorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SensorService stores and queries sensor metadata.
+//
+// Requests are authenticated like REST requests: send an API key or token as
+// "authorization: Bearer <key>" (or "x-api-key: <key>") metadata.
+// Reads require the "sensors:read" scope, and changes require "sensors:write".
+type SensorServiceClient interface {
+	// Get returns a sensor by name. Fails with NOT_FOUND if there is no such sensor.
+	Get(ctx context.Context, in *GetSensorRequest, opts ...grpc.CallOption) (*Sensor, error)
+	// Create adds a sensor. Fails with INVALID_ARGUMENT, with a BadRequest detail listing every invalid field,
+	// if the sensor is invalid, or with ALREADY_EXISTS if another sensor has its name.
+	Create(ctx context.Context, in *CreateSensorRequest, opts ...grpc.CallOption) (*Sensor, error)
+	// Update replaces the sensor with the given name. The sensor may be renamed.
+	// Fails with NOT_FOUND if there is no such sensor, or with ALREADY_EXISTS if it is renamed to the name of another sensor.
+	Update(ctx context.Context, in *UpdateSensorRequest, opts ...grpc.CallOption) (*Sensor, error)
+	// Delete removes a sensor, and returns it as it was before it was deleted.
+	Delete(ctx context.Context, in *DeleteSensorRequest, opts ...grpc.CallOption) (*Sensor, error)
+	// FindClosest returns the sensors closest to a location, ordered by distance.
+	FindClosest(ctx context.Context, in *FindClosestRequest, opts ...grpc.CallOption) (*FindClosestResponse, error)
+	// List streams every sensor, ordered by name.
+	List(ctx context.Context, in *ListSensorsRequest, opts ...grpc.CallOption) (SensorService_ListClient, error)
+	// WatchChanges streams changes to sensors, as they are made, until the client cancels the call.
+	// Only changes made after the call starts are sent.
+	WatchChanges(ctx context.Context, in *WatchChangesRequest, opts ...grpc.CallOption) (SensorService_WatchChangesClient, error)
+}
+
+type sensorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSensorServiceClient(cc grpc.ClientConnInterface) SensorServiceClient {
+	return &sensorServiceClient{cc}
+}
+
+func (c *sensorServiceClient) Get(ctx context.Context, in *GetSensorRequest, opts ...grpc.CallOption) (*Sensor, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Sensor)
+	err := c.cc.Invoke(ctx, SensorService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) Create(ctx context.Context, in *CreateSensorRequest, opts ...grpc.CallOption) (*Sensor, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Sensor)
+	err := c.cc.Invoke(ctx, SensorService_Create_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) Update(ctx context.Context, in *UpdateSensorRequest, opts ...grpc.CallOption) (*Sensor, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Sensor)
+	err := c.cc.Invoke(ctx, SensorService_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) Delete(ctx context.Context, in *DeleteSensorRequest, opts ...grpc.CallOption) (*Sensor, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Sensor)
+	err := c.cc.Invoke(ctx, SensorService_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) FindClosest(ctx context.Context, in *FindClosestRequest, opts ...grpc.CallOption) (*FindClosestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FindClosestResponse)
+	err := c.cc.Invoke(ctx, SensorService_FindClosest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) List(ctx context.Context, in *ListSensorsRequest, opts ...grpc.CallOption) (SensorService_ListClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SensorService_ServiceDesc.Streams[0], SensorService_List_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sensorServiceListClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SensorService_ListClient interface {
+	Recv() (*Sensor, error)
+	grpc.ClientStream
+}
+
+type sensorServiceListClient struct {
+	grpc.ClientStream
+}
+
+func (x *sensorServiceListClient) Recv() (*Sensor, error) {
+	m := new(Sensor)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sensorServiceClient) WatchChanges(ctx context.Context, in *WatchChangesRequest, opts ...grpc.CallOption) (SensorService_WatchChangesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SensorService_ServiceDesc.Streams[1], SensorService_WatchChanges_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sensorServiceWatchChangesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SensorService_WatchChangesClient interface {
+	Recv() (*SensorChange, error)
+	grpc.ClientStream
+}
+
+type sensorServiceWatchChangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *sensorServiceWatchChangesClient) Recv() (*SensorChange, error) {
+	m := new(SensorChange)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SensorServiceServer is the server API for SensorService service.
+// All implementations must embed UnimplementedSensorServiceServer
+// for forward compatibility
+//
+// SensorService stores and queries sensor metadata.
+//
+// Requests are authenticated like REST requests: send an API key or token as
+// "authorization: Bearer <key>" (or "x-api-key: <key>") metadata.
+// Reads require the "sensors:read" scope, and changes require "sensors:write".
+type SensorServiceServer interface {
+	// Get returns a sensor by name. Fails with NOT_FOUND if there is no such sensor.
+	Get(context.Context, *GetSensorRequest) (*Sensor, error)
+	// Create adds a sensor. Fails with INVALID_ARGUMENT, with a BadRequest detail listing every invalid field,
+	// if the sensor is invalid, or with ALREADY_EXISTS if another sensor has its name.
+	Create(context.Context, *CreateSensorRequest) (*Sensor, error)
+	// Update replaces the sensor with the given name. The sensor may be renamed.
+	// Fails with NOT_FOUND if there is no such sensor, or with ALREADY_EXISTS if it is renamed to the name of another sensor.
+	Update(context.Context, *UpdateSensorRequest) (*Sensor, error)
+	// Delete removes a sensor, and returns it as it was before it was deleted.
+	Delete(context.Context, *DeleteSensorRequest) (*Sensor, error)
+	// FindClosest returns the sensors closest to a location, ordered by distance.
+	FindClosest(context.Context, *FindClosestRequest) (*FindClosestResponse, error)
+	// List streams every sensor, ordered by name.
+	List(*ListSensorsRequest, SensorService_ListServer) error
+	// WatchChanges streams changes to sensors, as they are made, until the client cancels the call.
+	// Only changes made after the call starts are sent.
+	WatchChanges(*WatchChangesRequest, SensorService_WatchChangesServer) error
+	mustEmbedUnimplementedSensorServiceServer()
+}
+
+// UnimplementedSensorServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSensorServiceServer struct {
+}
+
+func (UnimplementedSensorServiceServer) Get(context.Context, *GetSensorRequest) (*Sensor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedSensorServiceServer) Create(context.Context, *CreateSensorRequest) (*Sensor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedSensorServiceServer) Update(context.Context, *UpdateSensorRequest) (*Sensor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedSensorServiceServer) Delete(context.Context, *DeleteSensorRequest) (*Sensor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedSensorServiceServer) FindClosest(context.Context, *FindClosestRequest) (*FindClosestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindClosest not implemented")
+}
+func (UnimplementedSensorServiceServer) List(*ListSensorsRequest, SensorService_ListServer) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedSensorServiceServer) WatchChanges(*WatchChangesRequest, SensorService_WatchChangesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchChanges not implemented")
+}
+func (UnimplementedSensorServiceServer) mustEmbedUnimplementedSensorServiceServer() {}
+
+// UnsafeSensorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SensorServiceServer will
+// result in compilation errors.
+type UnsafeSensorServiceServer interface {
+	mustEmbedUnimplementedSensorServiceServer()
+}
+
+func RegisterSensorServiceServer(s grpc.ServiceRegistrar, srv SensorServiceServer) {
+	s.RegisterService(&SensorService_ServiceDesc, srv)
+}
+
+func _SensorService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).Get(ctx, req.(*GetSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).Create(ctx, req.(*CreateSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).Update(ctx, req.(*UpdateSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSensorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).Delete(ctx, req.(*DeleteSensorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_FindClosest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindClosestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).FindClosest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_FindClosest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).FindClosest(ctx, req.(*FindClosestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListSensorsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SensorServiceServer).List(m, &sensorServiceListServer{ServerStream: stream})
+}
+
+type SensorService_ListServer interface {
+	Send(*Sensor) error
+	grpc.ServerStream
+}
+
+type sensorServiceListServer struct {
+	grpc.ServerStream
+}
+
+func (x *sensorServiceListServer) Send(m *Sensor) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SensorService_WatchChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SensorServiceServer).WatchChanges(m, &sensorServiceWatchChangesServer{ServerStream: stream})
+}
+
+type SensorService_WatchChangesServer interface {
+	Send(*SensorChange) error
+	grpc.ServerStream
+}
+
+type sensorServiceWatchChangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *sensorServiceWatchChangesServer) Send(m *SensorChange) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SensorService_ServiceDesc is the grpc.ServiceDesc for SensorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SensorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sensors.v1.SensorService",
+	HandlerType: (*SensorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _SensorService_Get_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _SensorService_Create_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _SensorService_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _SensorService_Delete_Handler,
+		},
+		{
+			MethodName: "FindClosest",
+			Handler:    _SensorService_FindClosest_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _SensorService_List_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchChanges",
+			Handler:       _SensorService_WatchChanges_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sensors/v1/sensors.proto",
+}
@@ -1,7 +1,10 @@
 CREATE TABLE sensors (
     id SERIAL PRIMARY KEY,
-    name VARCHAR UNIQUE,
-    location GEOMETRY(Point,4326)  -- 4326 is the SRID for WGS84 (std GPS coordinate system)
+    tenant_id VARCHAR NOT NULL DEFAULT 'default',  -- organization which owns the sensor
+    name VARCHAR,
+    location GEOMETRY(Point,4326),  -- 4326 is the SRID for WGS84 (std GPS coordinate system)
+    place_name VARCHAR,  -- reverse geocoded address / locality
+    UNIQUE (tenant_id, name)  -- sensor names are unique per tenant
 );
 
 CREATE TABLE tags (
@@ -9,3 +12,56 @@ CREATE TABLE tags (
     sensor_id INT REFERENCES sensors,
     value VARCHAR
 );
+
+-- Transactional outbox of sensor changes
+-- Rows are written in the same transaction as the change, and published by the outbox relay
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR NOT NULL,
+    tenant_id VARCHAR NOT NULL,
+    sensor_id INT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+
+-- Persistent tier for the geocoding cache
+CREATE TABLE geocode_cache (
+    key VARCHAR PRIMARY KEY,
+    lat DOUBLE PRECISION NOT NULL DEFAULT 0,
+    lon DOUBLE PRECISION NOT NULL DEFAULT 0,
+    place_name VARCHAR NOT NULL DEFAULT '',
+    suggestions JSONB,
+    not_found BOOLEAN NOT NULL DEFAULT false,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- API keys. Only a hash of each key is stored.
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR NOT NULL,  -- organization which the key grants access to
+    name VARCHAR NOT NULL,
+    prefix VARCHAR NOT NULL UNIQUE,  -- non-secret start of the key, used for lookups
+    key_hash BYTEA NOT NULL,  -- SHA-256 of the full key
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_tenant_idx ON api_keys (tenant_id);
+
+-- Token buckets for rate limiting, shared by all instances of the API (see RATE_LIMIT_BACKEND)
+CREATE UNLOGGED TABLE rate_limits (
+    key VARCHAR PRIMARY KEY,  -- eg. "spatial:api-key:sk_0123456789abcdef"
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Version of this schema, checked by GET /health/ready. Increment store.SchemaVersion when changing the schema.
+CREATE TABLE schema_version (
+    version INT NOT NULL
+);
+
+INSERT INTO schema_version (version) VALUES (1);
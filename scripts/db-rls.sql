@@ -0,0 +1,20 @@
+-- Optional row-level security policies, enforcing tenant isolation in the database itself.
+-- Run after db-init.sql, and start the API with TENANT_RLS=true,
+-- so that each transaction sets app.tenant_id to the tenant of the request.
+--
+-- FORCE applies the policies to the table owner too.
+-- Connections which do not set app.tenant_id see no sensors at all.
+
+ALTER TABLE sensors ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sensors FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY sensors_tenant_isolation ON sensors
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- Tags are visible if their sensor is
+ALTER TABLE tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tags FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY tags_tenant_isolation ON tags
+    USING (sensor_id IN (SELECT id FROM sensors));
@@ -0,0 +1,14 @@
+#!/usr/bin/env bash
+set -eu
+
+# Generate the gRPC Go code from the .proto files in ./proto
+# Requires protoc, and the Go plugins:
+#
+#   go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.34.2
+#   go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.4.0
+cd "$(dirname "$0")/.."
+
+protoc -I proto \
+    --go_out=proto --go_opt=paths=source_relative \
+    --go-grpc_out=proto --go-grpc_opt=paths=source_relative \
+    sensors/v1/sensors.proto